@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+)
+
+// Bench defaults, used when the flags are not given.
+const (
+	defaultBenchRPC         = "hello"
+	defaultBenchDuration    = 10 * time.Second
+	defaultBenchConcurrency = 8
+)
+
+// latencyStats accumulates call latencies and failures for the bench
+// command.
+type latencyStats struct {
+	latencies []time.Duration
+	errors    int
+}
+
+// add records one call: its latency, or a failure if err is non-nil.
+func (s *latencyStats) add(d time.Duration, err error) {
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.latencies = append(s.latencies, d)
+}
+
+// merge adds the calls recorded in other.
+func (s *latencyStats) merge(other *latencyStats) {
+	s.latencies = append(s.latencies, other.latencies...)
+	s.errors += other.errors
+}
+
+// benchSummary is the result of a bench run.
+type benchSummary struct {
+	requests  int
+	errors    int
+	p50       time.Duration
+	p95       time.Duration
+	p99       time.Duration
+	max       time.Duration
+	errorRate float64
+}
+
+// summary computes the latency percentiles of the successful calls and the
+// error rate over all calls.
+func (s *latencyStats) summary() benchSummary {
+	sorted := append([]time.Duration(nil), s.latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	sum := benchSummary{
+		requests: len(sorted) + s.errors,
+		errors:   s.errors,
+		p50:      percentile(sorted, 50),
+		p95:      percentile(sorted, 95),
+		p99:      percentile(sorted, 99),
+	}
+	if len(sorted) > 0 {
+		sum.max = sorted[len(sorted)-1]
+	}
+	if sum.requests > 0 {
+		sum.errorRate = float64(s.errors) / float64(sum.requests)
+	}
+	return sum
+}
+
+// percentile returns the p-th percentile of sorted using the nearest-rank
+// method, or zero for an empty slice.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// benchCall returns a function making one call of the named unary RPC with
+// name.
+func (r *runner) benchCall(rpc, name string) (func(ctx context.Context) error, error) {
+	switch rpc {
+	case "hello":
+		return func(ctx context.Context) error {
+			_, err := r.hello.SayHello(ctx, &hello.HelloRequest{Name: name})
+			return err
+		}, nil
+	case "goodbye":
+		return func(ctx context.Context) error {
+			_, err := r.goodbye.SayGoodbye(ctx, &goodbye.GoodbyeRequest{Name: name})
+			return err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown RPC %q", rpc)
+	}
+}
+
+// bench calls rpc with name from concurrency goroutines back to back for
+// duration, or until interrupted with Ctrl-C or the --budget runs out, then
+// prints the latency percentiles and error rate. Each call is bounded by the
+// request timeout; calls cut short by the end of the run are not counted.
+func (r *runner) bench(rpc, name string, duration time.Duration, concurrency int) error {
+	call, err := r.benchCall(rpc, name)
+	if err != nil {
+		return err
+	}
+
+	// The run context ends on Ctrl-C or when the --budget runs out
+	ctx, cancel := context.WithTimeout(r.baseContext(), duration)
+	defer cancel()
+
+	log.Printf("Benchmarking %s for %s with %d concurrent callers", rpc, duration, concurrency)
+	start := time.Now()
+
+	results := make([]latencyStats, concurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(stats *latencyStats) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				callCtx, callCancel := context.WithTimeout(ctx, r.timeout)
+				callStart := time.Now()
+				err := call(callCtx)
+				elapsed := time.Since(callStart)
+				callCancel()
+				if ctx.Err() != nil {
+					return
+				}
+				stats.add(elapsed, err)
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var total latencyStats
+	for i := range results {
+		total.merge(&results[i])
+	}
+	sum := total.summary()
+
+	log.Printf("=== Bench Summary (%s) ===", rpc)
+	log.Printf("Duration: %s", elapsed.Round(time.Millisecond))
+	log.Printf("Requests: %d (%.1f/s)", sum.requests, float64(sum.requests)/elapsed.Seconds())
+	log.Printf("Errors: %d (%.2f%%)", sum.errors, sum.errorRate*100)
+	log.Printf("Latency p50: %s", sum.p50)
+	log.Printf("Latency p95: %s", sum.p95)
+	log.Printf("Latency p99: %s", sum.p99)
+	log.Printf("Latency max: %s", sum.max)
+	log.Printf("==========================")
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+)
+
+// blockingGreeter answers SayHello only once the call's context ends, and
+// counts the calls it saw cancelled.
+type blockingGreeter struct {
+	hello.GreeterClient
+	cancelled atomic.Int32
+}
+
+func (g *blockingGreeter) SayHello(ctx context.Context, _ *hello.HelloRequest, _ ...grpc.CallOption) (*hello.HelloReply, error) {
+	<-ctx.Done()
+	if errors.Is(ctx.Err(), context.Canceled) {
+		g.cancelled.Add(1)
+	}
+	return nil, ctx.Err()
+}
+
+func TestBenchStopsInFlightCallsWithRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	greeter := &blockingGreeter{}
+	r := &runner{hello: greeter, timeout: time.Hour, ctx: ctx}
+
+	done := make(chan error, 1)
+	go func() { done <- r.bench("hello", "World", time.Hour, 4) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("bench: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bench still running 5s after the run context was cancelled")
+	}
+	if n := greeter.cancelled.Load(); n != 4 {
+		t.Errorf("%d in-flight calls cancelled, want all 4", n)
+	}
+}
+
+func TestBenchStopsWhenBudgetRunsOut(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := &runner{hello: &blockingGreeter{}, timeout: time.Hour, ctx: ctx, budget: 50 * time.Millisecond}
+
+	start := time.Now()
+	if err := r.bench("hello", "World", time.Hour, 2); err != nil {
+		t.Fatalf("bench: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("bench ran %s with a 50ms budget", elapsed)
+	}
+}
+
+func TestPercentileNearestRank(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	for _, tc := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 50 * time.Millisecond},
+		{95, 95 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	} {
+		if got := percentile(sorted, tc.p); got != tc.want {
+			t.Errorf("p%v of 1..100ms = %s, want %s", tc.p, got, tc.want)
+		}
+	}
+	if got := percentile(sorted[:3], 50); got != 2*time.Millisecond {
+		t.Errorf("p50 of 1..3ms = %s, want 2ms", got)
+	}
+	if got := percentile(nil, 99); got != 0 {
+		t.Errorf("p99 of no calls = %s, want 0", got)
+	}
+}
+
+func TestLatencyStatsSummary(t *testing.T) {
+	var a, b latencyStats
+	// Recorded out of order and across workers, as bench does
+	for _, ms := range []int{30, 10, 20, 50, 40} {
+		a.add(time.Duration(ms)*time.Millisecond, nil)
+	}
+	for _, ms := range []int{90, 70, 60, 100, 80} {
+		b.add(time.Duration(ms)*time.Millisecond, nil)
+	}
+	b.add(0, errors.New("unavailable"))
+	b.add(0, errors.New("unavailable"))
+	a.merge(&b)
+
+	sum := a.summary()
+	if sum.requests != 12 || sum.errors != 2 {
+		t.Errorf("requests, errors = %d, %d, want 12, 2", sum.requests, sum.errors)
+	}
+	if want := 2.0 / 12; sum.errorRate != want {
+		t.Errorf("error rate = %v, want %v", sum.errorRate, want)
+	}
+	if sum.p50 != 50*time.Millisecond || sum.p95 != 100*time.Millisecond || sum.p99 != 100*time.Millisecond || sum.max != 100*time.Millisecond {
+		t.Errorf("p50/p95/p99/max = %s/%s/%s/%s, want 50ms/100ms/100ms/100ms", sum.p50, sum.p95, sum.p99, sum.max)
+	}
+
+	if empty := (&latencyStats{}).summary(); empty != (benchSummary{}) {
+		t.Errorf("summary of no calls = %+v, want zero", empty)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// RPC variants selectable from the command line.
+const (
+	modeUnary        = "unary"
+	modeStream       = "stream"
+	modeClientStream = "client-stream"
+	modeBidi         = "bidi"
+)
+
+// command is a parsed client invocation.
+type command struct {
+	// service is "hello", "goodbye", "all", "bench" or "invoke".
+	service string
+	// mode is one of the mode* constants; ignored for "all".
+	mode string
+	name string
+	// names overrides the names sent by the client streaming and
+	// bidirectional variants; nil keeps each method's built-in list.
+	names []string
+	// namesFromStdin streams the client streaming variant's names from
+	// stdin, one per line, instead of names or the built-in list.
+	namesFromStdin bool
+	// namesFile is a JSON file holding an array of names that main loads
+	// into names; empty leaves names as --names set them.
+	namesFile string
+	// interval overrides the pause between streamed sends; zero keeps each
+	// method's built-in pacing.
+	interval time.Duration
+	// transform asks the server to upper-case or reverse names in hello
+	// --bidi replies; empty leaves them unchanged.
+	transform string
+	// injectError asks the server to fail hello with this status code,
+	// e.g. "unavailable", through the inject-error metadata key.
+	injectError string
+	// reason makes goodbye call SayGoodbyeWithReason instead of SayGoodbye;
+	// empty keeps SayGoodbye.
+	reason string
+	// rpc, duration and concurrency configure the bench command.
+	rpc         string
+	duration    time.Duration
+	concurrency int
+	// method is the method invoke calls, as package.Service/Method; empty
+	// lists the server's services and methods instead.
+	method string
+	// data holds invoke's JSON request messages; "-" reads them from stdin.
+	data string
+	// budget bounds the time all calls of the run may take together; zero
+	// leaves only the per-call limits.
+	budget  time.Duration
+	verbose bool
+	// watchConn logs every connection state transition while the client
+	// runs.
+	watchConn  bool
+	configPath string
+}
+
+// usage describes the client's subcommands and flags.
+const usage = `Usage: client [command] [flags]
+
+Commands:
+  hello     Call one Greeter method
+  goodbye   Call one Farewell method
+  all       Call every method of both services in sequence (default)
+  bench     Call a unary method repeatedly and report latency percentiles
+  invoke    List the server's methods, or call one by name with a JSON
+            request, through server reflection
+
+Flags:
+  --name NAME        name to send (default "World")
+  --stream           use the server streaming variant
+  --client-stream    use the client streaming variant
+  --bidi             use the bidirectional streaming variant
+  --names A,B,C      comma-separated names sent by --client-stream and --bidi;
+                     "-" streams --client-stream names from stdin, one per line
+  --names-file PATH  JSON file holding an array of names, used like --names
+  --interval DUR     pause between streamed sends, e.g. 200ms
+  --transform T      hello --bidi only: upper or reverse each name in replies
+  --reason TEXT      goodbye only: call SayGoodbyeWithReason with this reason
+  --inject-error C   unary hello only: ask the server to fail with status code C
+                     (needs GRPC_ENABLE_FAULT_INJECTION on the server)
+  --rpc RPC          bench only: hello or goodbye (default "hello")
+  --duration DUR     bench only: how long to run (default 10s)
+  --concurrency N    bench only: number of concurrent callers (default 8)
+  --data JSON        invoke only: request message as JSON; client streaming
+                     methods take several objects in a row; "-" reads stdin
+  --budget DUR       total time for every call of the run, e.g. 10s; each call
+                     gets at most what is left, and the rest are skipped once
+                     it runs out
+  --verbose          print response headers, trailers and status details
+  --watch-conn       log connection state transitions, e.g. CONNECTING -> READY
+  --config PATH      path to a JSON config file
+
+Examples:
+  client hello --name Alice
+  client goodbye --name Bob --stream --verbose
+  client hello --client-stream --names Alice,Bob,Charlie --interval 100ms
+  cat names.txt | client hello --client-stream --names -
+  client goodbye --bidi --names-file names.json
+  client hello --bidi --transform upper
+  client goodbye --name Mallory --reason "moving on" --verbose
+  client hello --inject-error unavailable
+  client bench --rpc hello --duration 10s --concurrency 8
+  client all --budget 10s
+  client invoke
+  client invoke grpc.hello.Greeter/SayHello --data '{"name": "Alice"}'
+  echo '{"name": "A"} {"name": "B"}' | client invoke grpc.hello.Greeter/SayHelloClientStream --data -
+`
+
+// parseArgs parses the command-line arguments (without the program name).
+func parseArgs(args []string) (command, error) {
+	cmd := command{service: "all", mode: modeUnary}
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		cmd.service = args[0]
+		args = args[1:]
+	}
+	switch cmd.service {
+	case "hello", "goodbye", "all", "bench", "invoke":
+	default:
+		return command{}, fmt.Errorf("unknown command %q", cmd.service)
+	}
+	if cmd.service == "invoke" && len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		cmd.method = args[0]
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet(cmd.service, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&cmd.name, "name", defaultName, "name to send")
+	stream := fs.Bool("stream", false, "use the server streaming variant")
+	clientStream := fs.Bool("client-stream", false, "use the client streaming variant")
+	bidi := fs.Bool("bidi", false, "use the bidirectional streaming variant")
+	names := fs.String("names", "", "comma-separated names sent by --client-stream and --bidi")
+	fs.StringVar(&cmd.namesFile, "names-file", "", "JSON file holding an array of names")
+	fs.DurationVar(&cmd.interval, "interval", 0, "pause between streamed sends")
+	fs.StringVar(&cmd.transform, "transform", "", "upper or reverse each name in hello --bidi replies")
+	fs.StringVar(&cmd.reason, "reason", "", "call SayGoodbyeWithReason with this reason")
+	fs.StringVar(&cmd.injectError, "inject-error", "", "ask the server to fail hello with this status code")
+	fs.StringVar(&cmd.rpc, "rpc", "", "unary RPC to benchmark: hello or goodbye")
+	fs.DurationVar(&cmd.duration, "duration", 0, "how long to benchmark")
+	fs.IntVar(&cmd.concurrency, "concurrency", 0, "number of concurrent bench callers")
+	fs.StringVar(&cmd.data, "data", "", "JSON request messages for invoke")
+	fs.DurationVar(&cmd.budget, "budget", 0, "total time for every call of the run")
+	fs.BoolVar(&cmd.verbose, "verbose", false, "print response headers, trailers and status details")
+	fs.BoolVar(&cmd.watchConn, "watch-conn", false, "log connection state transitions")
+	fs.StringVar(&cmd.configPath, "config", "", "path to a JSON config file")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return command{}, err
+		}
+		return command{}, fmt.Errorf("%s: %w", cmd.service, err)
+	}
+	if cmd.service == "invoke" && cmd.method == "" && fs.NArg() == 1 {
+		cmd.method = fs.Arg(0)
+	} else if fs.NArg() > 0 {
+		return command{}, fmt.Errorf("%s: unexpected arguments %v", cmd.service, fs.Args())
+	}
+
+	if cmd.interval < 0 {
+		return command{}, fmt.Errorf("%s: --interval must not be negative", cmd.service)
+	}
+	if cmd.budget < 0 {
+		return command{}, fmt.Errorf("%s: --budget must not be negative", cmd.service)
+	}
+	if cmd.budget > 0 && cmd.service == "bench" {
+		return command{}, fmt.Errorf("bench: --budget does not apply; bench runs for --duration")
+	}
+	if *names != "" && cmd.namesFile != "" {
+		return command{}, fmt.Errorf("%s: --names and --names-file are mutually exclusive", cmd.service)
+	}
+	if *names == "-" {
+		cmd.namesFromStdin = true
+	} else if *names != "" {
+		cmd.names = splitNames(*names)
+		if len(cmd.names) == 0 {
+			return command{}, fmt.Errorf("%s: --names must contain at least one non-empty name", cmd.service)
+		}
+	}
+
+	selected := 0
+	for mode, set := range map[string]bool{modeStream: *stream, modeClientStream: *clientStream, modeBidi: *bidi} {
+		if set {
+			cmd.mode = mode
+			selected++
+		}
+	}
+	if selected > 1 {
+		return command{}, fmt.Errorf("%s: --stream, --client-stream and --bidi are mutually exclusive", cmd.service)
+	}
+	if selected > 0 && cmd.service == "all" {
+		return command{}, fmt.Errorf("all: streaming flags only apply to the hello and goodbye commands")
+	}
+	if cmd.namesFromStdin && cmd.mode != modeClientStream {
+		return command{}, fmt.Errorf("%s: --names - only applies to --client-stream", cmd.service)
+	}
+	if cmd.transform != "" && (cmd.service != "hello" || cmd.mode != modeBidi) {
+		return command{}, fmt.Errorf("%s: --transform only applies to hello --bidi", cmd.service)
+	}
+	if cmd.injectError != "" && (cmd.service != "hello" || cmd.mode != modeUnary) {
+		return command{}, fmt.Errorf("%s: --inject-error only applies to unary hello", cmd.service)
+	}
+	if cmd.reason != "" && (cmd.service != "goodbye" || cmd.mode != modeUnary) {
+		return command{}, fmt.Errorf("%s: --reason only applies to unary goodbye", cmd.service)
+	}
+
+	if cmd.data != "" && (cmd.service != "invoke" || cmd.method == "") {
+		return command{}, fmt.Errorf("%s: --data only applies to invoke with a method", cmd.service)
+	}
+	if cmd.service == "invoke" {
+		nameSet := false
+		fs.Visit(func(f *flag.Flag) { nameSet = nameSet || f.Name == "name" })
+		if selected > 0 || nameSet || *names != "" || cmd.namesFile != "" || cmd.interval != 0 {
+			return command{}, fmt.Errorf("invoke: --name, --names, --names-file, --interval and streaming flags do not apply; send the request with --data")
+		}
+	}
+
+	if cmd.service != "bench" {
+		if cmd.rpc != "" || cmd.duration != 0 || cmd.concurrency != 0 {
+			return command{}, fmt.Errorf("%s: --rpc, --duration and --concurrency only apply to bench", cmd.service)
+		}
+		return cmd, nil
+	}
+	if selected > 0 {
+		return command{}, fmt.Errorf("bench: streaming flags do not apply; bench calls unary methods")
+	}
+	if cmd.rpc == "" {
+		cmd.rpc = defaultBenchRPC
+	}
+	if cmd.rpc != "hello" && cmd.rpc != "goodbye" {
+		return command{}, fmt.Errorf("bench: --rpc must be hello or goodbye, got %q", cmd.rpc)
+	}
+	if cmd.duration == 0 {
+		cmd.duration = defaultBenchDuration
+	}
+	if cmd.duration < 0 {
+		return command{}, fmt.Errorf("bench: --duration must be positive")
+	}
+	if cmd.concurrency == 0 {
+		cmd.concurrency = defaultBenchConcurrency
+	}
+	if cmd.concurrency < 0 {
+		return command{}, fmt.Errorf("bench: --concurrency must be positive")
+	}
+
+	return cmd, nil
+}
+
+// splitNames splits a comma-separated list of names, trimming whitespace and
+// dropping empty entries.
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// loadNamesFile reads a JSON array of names from path, such as
+// ["Alice", "Bob"], trimming whitespace and dropping empty entries as
+// splitNames does. It fails unless at least one name remains.
+func loadNamesFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read names file: %w", err)
+	}
+	var entries []string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("names file %s must hold a JSON array of strings: %w", path, err)
+	}
+	var names []string
+	for _, name := range entries {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("names file %s must contain at least one non-empty name", path)
+	}
+	return names, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgsSelectsMethod(t *testing.T) {
+	tests := []struct {
+		args    []string
+		service string
+		mode    string
+		name    string
+	}{
+		{nil, "all", modeUnary, defaultName},
+		{[]string{"hello"}, "hello", modeUnary, defaultName},
+		{[]string{"hello", "--name", "Alice"}, "hello", modeUnary, "Alice"},
+		{[]string{"hello", "--stream"}, "hello", modeStream, defaultName},
+		{[]string{"goodbye", "--client-stream"}, "goodbye", modeClientStream, defaultName},
+		{[]string{"goodbye", "--bidi", "--name=Bob"}, "goodbye", modeBidi, "Bob"},
+	}
+	for _, tt := range tests {
+		cmd, err := parseArgs(tt.args)
+		if err != nil {
+			t.Errorf("parseArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if cmd.service != tt.service || cmd.mode != tt.mode || cmd.name != tt.name {
+			t.Errorf("parseArgs(%q) = %s %s %q, want %s %s %q", tt.args, cmd.service, cmd.mode, cmd.name, tt.service, tt.mode, tt.name)
+		}
+	}
+}
+
+func TestParseArgsRejectsBadInvocations(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"wave"}, `unknown command "wave"`},
+		{[]string{"hello", "--stream", "--bidi"}, "mutually exclusive"},
+		{[]string{"all", "--stream"}, "streaming flags only apply"},
+		{[]string{"hello", "--nope"}, "flag provided but not defined"},
+		{[]string{"hello", "extra"}, "unexpected arguments"},
+	}
+	for _, tt := range tests {
+		_, err := parseArgs(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseArgs(%q) = %v, want an error containing %q", tt.args, err, tt.want)
+		}
+	}
+}
+
+func TestSplitNames(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"Alice,Bob", []string{"Alice", "Bob"}},
+		{" Alice , Bob ,Carol ", []string{"Alice", "Bob", "Carol"}},
+		{"Alice,,Bob,", []string{"Alice", "Bob"}},
+		{"Mary Ann", []string{"Mary Ann"}},
+		{"", nil},
+		{" , ,", nil},
+	}
+	for _, tt := range tests {
+		if got := splitNames(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitNames(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseArgsNames(t *testing.T) {
+	cmd, err := parseArgs([]string{"hello", "--client-stream", "--names", " Alice, ,Bob "})
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if want := []string{"Alice", "Bob"}; !reflect.DeepEqual(cmd.names, want) {
+		t.Errorf("names = %q, want %q", cmd.names, want)
+	}
+	if _, err := parseArgs([]string{"hello", "--client-stream", "--names", " , "}); err == nil {
+		t.Error("parseArgs accepted --names with only empty entries")
+	}
+}
+
+func TestLoadNamesFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	names, err := loadNamesFile(write("names.json", `["Alice", " Bob ", "", "Carol"]`))
+	if err != nil {
+		t.Fatalf("loadNamesFile: %v", err)
+	}
+	if want := []string{"Alice", "Bob", "Carol"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+
+	for _, tc := range []struct {
+		path, wantErr string
+	}{
+		{filepath.Join(dir, "missing.json"), "could not read names file"},
+		{write("object.json", `{"names": ["Alice"]}`), "must hold a JSON array of strings"},
+		{write("numbers.json", `[1, 2]`), "must hold a JSON array of strings"},
+		{write("empty.json", `[]`), "at least one non-empty name"},
+		{write("blank.json", `["", "  "]`), "at least one non-empty name"},
+	} {
+		if _, err := loadNamesFile(tc.path); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("loadNamesFile(%s) = %v, want an error saying %q", filepath.Base(tc.path), err, tc.wantErr)
+		}
+	}
+}
+
+func TestParseArgsNamesFile(t *testing.T) {
+	cmd, err := parseArgs([]string{"goodbye", "--bidi", "--names-file", "names.json"})
+	if err != nil {
+		t.Fatalf("parseArgs: %v", err)
+	}
+	if cmd.namesFile != "names.json" {
+		t.Errorf("namesFile = %q, want names.json", cmd.namesFile)
+	}
+	if _, err := parseArgs([]string{"goodbye", "--bidi", "--names", "Alice", "--names-file", "names.json"}); err == nil {
+		t.Error("parseArgs accepted both --names and --names-file")
+	}
+}
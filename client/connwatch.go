@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// stateSource is the part of *grpc.ClientConn the connection watcher needs.
+type stateSource interface {
+	GetState() connectivity.State
+	WaitForStateChange(ctx context.Context, sourceState connectivity.State) bool
+}
+
+// watchConnState logs conn's current state and then every transition, e.g.
+// CONNECTING -> READY -> TRANSIENT_FAILURE, until the connection shuts down
+// or the returned stop function is called. stop waits for the watcher to
+// exit so no transition is logged after it returns.
+func watchConnState(conn stateSource, logf func(format string, args ...interface{})) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		state := conn.GetState()
+		logf("Connection state: %s", state)
+		for state != connectivity.Shutdown && conn.WaitForStateChange(ctx, state) {
+			next := conn.GetState()
+			logf("Connection state: %s -> %s", state, next)
+			state = next
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// manualConn is a stateSource whose state changes only when set is called.
+type manualConn struct {
+	mu      sync.Mutex
+	state   connectivity.State
+	changed chan struct{}
+}
+
+func newManualConn(state connectivity.State) *manualConn {
+	return &manualConn{state: state, changed: make(chan struct{})}
+}
+
+func (c *manualConn) GetState() connectivity.State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+func (c *manualConn) WaitForStateChange(ctx context.Context, source connectivity.State) bool {
+	for {
+		c.mu.Lock()
+		state, changed := c.state, c.changed
+		c.mu.Unlock()
+		if state != source {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (c *manualConn) set(state connectivity.State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state = state
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// waitForLine waits until spy has logged want.
+func waitForLine(t *testing.T, spy *spyLog, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(spy.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%q not logged; got:\n%s", want, spy)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchConnStateLogsTransitions(t *testing.T) {
+	conn := newManualConn(connectivity.Idle)
+	spy := &spyLog{}
+	stop := watchConnState(conn, spy.logf)
+	defer stop()
+
+	waitForLine(t, spy, "Connection state: IDLE")
+	for _, step := range []struct {
+		state connectivity.State
+		line  string
+	}{
+		{connectivity.Connecting, "IDLE -> CONNECTING"},
+		{connectivity.Ready, "CONNECTING -> READY"},
+		{connectivity.TransientFailure, "READY -> TRANSIENT_FAILURE"},
+	} {
+		conn.set(step.state)
+		waitForLine(t, spy, "Connection state: "+step.line)
+	}
+}
+
+func TestWatchConnStateStops(t *testing.T) {
+	conn := newManualConn(connectivity.Ready)
+	spy := &spyLog{}
+	stop := watchConnState(conn, spy.logf)
+	waitForLine(t, spy, "Connection state: READY")
+
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop did not return while the connection stayed READY")
+	}
+	conn.set(connectivity.Idle)
+	if strings.Contains(spy.String(), "READY -> IDLE") {
+		t.Error("transition logged after stop returned")
+	}
+
+	// The watcher ends by itself once the connection shuts down
+	conn, spy = newManualConn(connectivity.Ready), &spyLog{}
+	stop = watchConnState(conn, spy.logf)
+	waitForLine(t, spy, "Connection state: READY")
+	conn.set(connectivity.Shutdown)
+	waitForLine(t, spy, "READY -> SHUTDOWN")
+	stop()
+}
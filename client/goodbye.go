@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// sayGoodbye calls the unary SayGoodbye RPC.
+func (r *runner) sayGoodbye(name string) error {
+	log.Printf("Calling SayGoodbye with name: %s", name)
+
+	goodbyeCtx := metadata.NewOutgoingContext(r.baseContext(), metadata.Pairs("goodbye-client-id", "grpc-sample-goodbye"))
+	goodbyeCtx, goodbyeCancel := context.WithTimeout(goodbyeCtx, r.timeout)
+	defer goodbyeCancel()
+
+	goodbyeReply, err := r.goodbye.SayGoodbye(goodbyeCtx, &goodbye.GoodbyeRequest{Name: name})
+	if err != nil {
+		return fmt.Errorf("could not say goodbye: %w", err)
+	}
+
+	log.Printf("Goodbye message: %s", goodbyeReply.GetMessage())
+	return nil
+}
+
+// sayGoodbyeWithReason calls the unary SayGoodbyeWithReason RPC. With
+// --verbose, a refusal's ErrorInfo detail is printed by the client
+// interceptor.
+func (r *runner) sayGoodbyeWithReason(name, reason string) error {
+	log.Printf("Calling SayGoodbyeWithReason with name: %s, reason: %s", name, reason)
+
+	ctx, cancel := context.WithTimeout(r.baseContext(), r.timeout)
+	defer cancel()
+
+	reply, err := r.goodbye.SayGoodbyeWithReason(ctx, &goodbye.GoodbyeWithReasonRequest{Name: name, Reason: reason})
+	if err != nil {
+		return fmt.Errorf("could not say goodbye: %w", err)
+	}
+
+	log.Printf("Goodbye message: %s", reply.GetMessage())
+	return nil
+}
+
+// sayGoodbyeStream calls the server streaming SayGoodbyeStream RPC.
+func (r *runner) sayGoodbyeStream(name string) error {
+	log.Printf("Calling SayGoodbyeStream with name: %s", name)
+
+	goodbyeStreamCtx := metadata.NewOutgoingContext(r.baseContext(), metadata.Pairs("goodbye-stream-client-id", "grpc-sample-goodbye-stream"))
+	goodbyeStream, err := r.goodbye.SayGoodbyeStream(goodbyeStreamCtx, &goodbye.GoodbyeRequest{Name: name})
+	if err != nil {
+		return fmt.Errorf("could not call SayGoodbyeStream: %w", err)
+	}
+
+	goodbyeMessageCount := 0
+	for {
+		reply, err := goodbyeStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if st, ok := status.FromError(err); ok {
+				log.Printf("Goodbye stream error - Code: %v, Message: %s", st.Code(), st.Message())
+			}
+			return fmt.Errorf("could not receive goodbye: %w", err)
+		}
+		goodbyeMessageCount++
+		log.Printf("Goodbye stream message %d: %s", goodbyeMessageCount, reply.GetMessage())
+	}
+
+	log.Printf("Total goodbye messages received: %d", goodbyeMessageCount)
+	return nil
+}
+
+// sayGoodbyeClientStream calls the client streaming SayGoodbyeClientStream RPC.
+func (r *runner) sayGoodbyeClientStream() error {
+	log.Printf("Calling SayGoodbyeClientStream")
+
+	goodbyeClientStreamCtx := metadata.NewOutgoingContext(r.baseContext(), metadata.Pairs("goodbye-client-stream-id", "grpc-sample-goodbye-client-stream"))
+	goodbyeClientStream, err := r.goodbye.SayGoodbyeClientStream(goodbyeClientStreamCtx)
+	if err != nil {
+		return fmt.Errorf("could not call SayGoodbyeClientStream: %w", err)
+	}
+
+	// Send multiple names for goodbye
+	err = r.eachStreamName([]string{"Helen", "Ivan", "Julia", "Kevin", "Luna"}, func(i int, name string) error {
+		if err := goodbyeClientStream.Send(&goodbye.GoodbyeRequest{Name: name}); err == io.EOF {
+			// The server ended the stream; CloseAndRecv reports why
+			return err
+		} else if err != nil {
+			return fmt.Errorf("could not send goodbye: %w", err)
+		}
+		log.Printf("Sent goodbye client stream message %d: %s", i+1, name)
+		time.Sleep(r.sendInterval(400 * time.Millisecond))
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	// Close and receive response
+	goodbyeReply, err := goodbyeClientStream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("could not receive goodbye: %w", err)
+	}
+
+	log.Printf("Goodbye client stream response: %s", goodbyeReply.GetMessage())
+
+	return nil
+}
+
+// sayGoodbyeBidirectional calls the bidirectional SayGoodbyeBidirectional RPC.
+func (r *runner) sayGoodbyeBidirectional() error {
+	log.Printf("Calling SayGoodbyeBidirectional")
+
+	goodbyeBidiCtx, cancel := context.WithCancel(metadata.NewOutgoingContext(r.baseContext(), metadata.Pairs("goodbye-bidi-client-id", "grpc-sample-goodbye-bidi")))
+	goodbyeBidiStream, err := r.goodbye.SayGoodbyeBidirectional(goodbyeBidiCtx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("could not call SayGoodbyeBidirectional: %w", err)
+	}
+
+	// Send goodbye messages from a goroutine, stopped and joined once
+	// receiving ends
+	sender := startSender(goodbyeBidiCtx, cancel, func(ctx context.Context) {
+		goodbyeBidiNames := r.streamNames([]string{"Maya", "Noah", "Olivia", "Paul"})
+		for i, name := range goodbyeBidiNames {
+			if err := goodbyeBidiStream.Send(&goodbye.GoodbyeRequest{Name: name}); err != nil {
+				log.Printf("could not send goodbye bidirectional: %v", err)
+				return
+			}
+			log.Printf("Sent goodbye bidirectional message %d: %s", i+1, name)
+			if sleepContext(ctx, r.sendInterval(1200*time.Millisecond)) != nil {
+				return
+			}
+		}
+		goodbyeBidiStream.CloseSend()
+	})
+	defer sender.stop()
+
+	// Receive goodbye responses
+	goodbyeBidiMessageCount := 0
+	for {
+		reply, err := goodbyeBidiStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not receive goodbye bidirectional: %w", err)
+		}
+		goodbyeBidiMessageCount++
+		log.Printf("Goodbye bidirectional response %d: %s", goodbyeBidiMessageCount, reply.GetMessage())
+	}
+
+	log.Printf("Total goodbye bidirectional messages received: %d", goodbyeBidiMessageCount)
+	return nil
+}
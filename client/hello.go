@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// sayHello calls the unary SayHello RPC.
+func (r *runner) sayHello(name string) error {
+	log.Printf("Calling SayHello with name: %s", name)
+
+	// Create context with metadata to capture response headers
+	md := metadata.Pairs("client-id", "grpc-sample-client")
+	if r.injectError != "" {
+		md.Set("inject-error", r.injectError)
+	}
+	ctx := metadata.NewOutgoingContext(r.baseContext(), md)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Trailers arrive on failed calls too, even trailers-only responses
+	var trailer metadata.MD
+	reply, err := r.hello.SayHello(ctx, &hello.HelloRequest{Name: name}, grpc.Trailer(&trailer))
+	if err != nil {
+		if category := trailer.Get("error-category"); len(category) > 0 {
+			log.Printf("SayHello failed with error-category: %s", category[0])
+		}
+		return fmt.Errorf("could not greet: %w", err)
+	}
+
+	log.Printf("Greeting: %s", reply.GetMessage())
+	return nil
+}
+
+// sayHelloStream calls the server streaming SayHelloStream RPC.
+func (r *runner) sayHelloStream(name string) error {
+	log.Printf("Calling SayHelloStream with name: %s", name)
+
+	streamCtx := metadata.NewOutgoingContext(r.baseContext(), metadata.Pairs("stream-client-id", "grpc-sample-stream"))
+	stream, err := r.hello.SayHelloStream(streamCtx, &hello.HelloRequest{Name: name})
+	if err != nil {
+		return fmt.Errorf("could not call SayHelloStream: %w", err)
+	}
+
+	messageCount := 0
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if st, ok := status.FromError(err); ok {
+				log.Printf("Stream error - Code: %v, Message: %s", st.Code(), st.Message())
+			}
+			return fmt.Errorf("could not receive: %w", err)
+		}
+		messageCount++
+		log.Printf("Stream message %d: %s", messageCount, reply.GetMessage())
+	}
+
+	log.Printf("Total messages received: %d", messageCount)
+	return nil
+}
+
+// sayHelloClientStream calls the client streaming SayHelloClientStream RPC.
+func (r *runner) sayHelloClientStream() error {
+	log.Printf("Calling SayHelloClientStream")
+
+	clientStreamCtx := metadata.NewOutgoingContext(r.baseContext(), metadata.Pairs("client-stream-id", "grpc-sample-client-stream"))
+	clientStream, err := r.hello.SayHelloClientStream(clientStreamCtx)
+	if err != nil {
+		return fmt.Errorf("could not call SayHelloClientStream: %w", err)
+	}
+
+	// Send multiple names to server
+	err = r.eachStreamName([]string{"Alice", "Bob", "Charlie", "Diana"}, func(i int, name string) error {
+		if err := clientStream.Send(&hello.HelloRequest{Name: name}); err == io.EOF {
+			// The server ended the stream; CloseAndRecv reports why
+			return err
+		} else if err != nil {
+			return fmt.Errorf("could not send: %w", err)
+		}
+		log.Printf("Sent client stream message %d: %s", i+1, name)
+		time.Sleep(r.sendInterval(500 * time.Millisecond))
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	// Close and receive response
+	reply, err := clientStream.CloseAndRecv()
+	if err != nil {
+		return fmt.Errorf("could not receive: %w", err)
+	}
+
+	log.Printf("Client stream response: %s", reply.GetMessage())
+
+	return nil
+}
+
+// sayHelloBidirectional calls the bidirectional SayHelloBidirectional RPC.
+func (r *runner) sayHelloBidirectional() error {
+	log.Printf("Calling SayHelloBidirectional")
+
+	md := metadata.Pairs("bidi-client-id", "grpc-sample-bidi")
+	if r.transform != "" {
+		md.Set("transform", r.transform)
+	}
+	bidiCtx, cancel := context.WithCancel(metadata.NewOutgoingContext(r.baseContext(), md))
+	bidiStream, err := r.hello.SayHelloBidirectional(bidiCtx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("could not call SayHelloBidirectional: %w", err)
+	}
+
+	// Send messages from a goroutine, stopped and joined once receiving ends
+	sender := startSender(bidiCtx, cancel, func(ctx context.Context) {
+		bidiNames := r.streamNames([]string{"Emma", "Frank", "Grace"})
+		for i, name := range bidiNames {
+			if err := bidiStream.Send(&hello.HelloRequest{Name: name}); err != nil {
+				log.Printf("could not send bidirectional: %v", err)
+				return
+			}
+			log.Printf("Sent bidirectional message %d: %s", i+1, name)
+			if sleepContext(ctx, r.sendInterval(1*time.Second)) != nil {
+				return
+			}
+		}
+		bidiStream.CloseSend()
+	})
+	defer sender.stop()
+
+	// Receive responses
+	bidiMessageCount := 0
+	for {
+		reply, err := bidiStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not receive bidirectional: %w", err)
+		}
+		bidiMessageCount++
+		log.Printf("Bidirectional response %d: %s", bidiMessageCount, reply.GetMessage())
+	}
+
+	log.Printf("Total bidirectional messages received: %d", bidiMessageCount)
+	return nil
+}
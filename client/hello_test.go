@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// captureLog sends the standard logger's output to a buffer for the rest of
+// the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(previous)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestClientStreamSendsNamesFromReader(t *testing.T) {
+	logs := captureLog(t)
+	var calls atomic.Int32
+	cfg := config.Default()
+	cfg.ServerAddress = startBackend(t, &calls)
+	conn, err := dial(cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	r := &runner{hello: hello.NewGreeterClient(conn), namesFrom: strings.NewReader("Ann\n\n  Ben  \nCat\nDan\nEve")}
+	if err := r.sayHelloClientStream(); err != nil {
+		t.Fatalf("sayHelloClientStream: %v", err)
+	}
+	if want := "Client stream response: Hello to all 5 friends: Ann, Ben, Cat, Dan, Eve!"; !strings.Contains(logs.String(), want) {
+		t.Errorf("output lacks %q:\n%s", want, logs)
+	}
+}
+
+func TestEachStreamNameSendsAsItReads(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := &runner{namesFrom: pr}
+	sent := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.eachStreamName(nil, func(_ int, name string) error {
+			sent <- name
+			return nil
+		})
+	}()
+
+	// Each name goes out before the next line, let alone EOF, arrives
+	for _, name := range []string{"Ann", "Ben"} {
+		io.WriteString(pw, name+"\n")
+		select {
+		case got := <-sent:
+			if got != name {
+				t.Errorf("sent %q, want %q", got, name)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s not sent while the input is still open", name)
+		}
+	}
+	pw.Close()
+	if err := <-done; err != nil {
+		t.Errorf("eachStreamName at EOF = %v, want nil", err)
+	}
+}
+
+// failingBidi is a bidirectional stream whose Recv fails at once and whose
+// Send blocks until the stream's context ends, recording that it returned.
+type failingBidi struct {
+	grpc.BidiStreamingClient[hello.HelloRequest, hello.HelloReply]
+	ctx         context.Context
+	sendStarted chan struct{}
+	sendExited  atomic.Bool
+}
+
+func (s *failingBidi) Send(*hello.HelloRequest) error {
+	close(s.sendStarted)
+	<-s.ctx.Done()
+	s.sendExited.Store(true)
+	return s.ctx.Err()
+}
+
+func (s *failingBidi) Recv() (*hello.HelloReply, error) {
+	<-s.sendStarted
+	return nil, status.Error(codes.Unavailable, "connection lost")
+}
+
+// bidiGreeter hands out stream for SayHelloBidirectional.
+type bidiGreeter struct {
+	hello.GreeterClient
+	stream *failingBidi
+}
+
+func (g *bidiGreeter) SayHelloBidirectional(ctx context.Context, _ ...grpc.CallOption) (grpc.BidiStreamingClient[hello.HelloRequest, hello.HelloReply], error) {
+	g.stream.ctx = ctx
+	return g.stream, nil
+}
+
+func TestBidirectionalSenderExitsWhenRecvFails(t *testing.T) {
+	captureLog(t)
+	stream := &failingBidi{sendStarted: make(chan struct{})}
+	r := &runner{hello: &bidiGreeter{stream: stream}}
+
+	done := make(chan error, 1)
+	go func() { done <- r.sayHelloBidirectional() }()
+	select {
+	case err := <-done:
+		if status.Code(errors.Unwrap(err)) != codes.Unavailable {
+			t.Errorf("sayHelloBidirectional = %v, want the receive error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sayHelloBidirectional still running after Recv failed")
+	}
+	if !stream.sendExited.Load() {
+		t.Error("send goroutine still running after sayHelloBidirectional returned")
+	}
+}
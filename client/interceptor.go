@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"io"
+	"path"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyMetadataKey is the metadata key the server reads GRPC_API_KEY from.
+const apiKeyMetadataKey = "x-api-key"
+
+// apiKeyDialOptions returns interceptors that send key as x-api-key metadata
+// on every call, unary and streaming alike.
+func apiKeyDialOptions(key string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(metadata.AppendToOutgoingContext(ctx, apiKeyMetadataKey, key), method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(metadata.AppendToOutgoingContext(ctx, apiKeyMetadataKey, key), desc, cc, method, opts...)
+		}),
+	}
+}
+
+// bearerToken is a grpc.PerRPCCredentials that sends a token as
+// "authorization: Bearer <token>" metadata on every call, unary and streaming
+// alike.
+type bearerToken string
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials. The
+// token is sent over plaintext connections too, like the API key, since the
+// server only uses TLS when configured to.
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// metadataLogger prints the response headers, trailers and status of every
+// call made on a connection. It is only installed with --verbose, so calls
+// stay quiet by default.
+type metadataLogger struct {
+	logf func(format string, args ...interface{})
+}
+
+// dialOptions returns the interceptors that report each call to m.logf.
+func (m *metadataLogger) dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(m.unary),
+		grpc.WithChainStreamInterceptor(m.stream),
+	}
+}
+
+func (m *metadataLogger) unary(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var header, trailer metadata.MD
+	opts = append(opts, grpc.Header(&header), grpc.Trailer(&trailer))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	m.report(method, header, trailer, err)
+	return err
+}
+
+func (m *metadataLogger) stream(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		m.report(method, nil, nil, err)
+		return nil, err
+	}
+	return &loggedStream{ClientStream: cs, logger: m, method: method, serverStreams: desc.ServerStreams}, nil
+}
+
+// report prints one call's headers, trailers and final status.
+func (m *metadataLogger) report(method string, header, trailer metadata.MD, err error) {
+	name := path.Base(method)
+	m.printMetadata(name+" Headers", header)
+	m.printMetadata(name+" Trailers", trailer)
+
+	m.logf("=== %s Status ===", name)
+	st := status.Convert(err)
+	m.logf("  Code: %v", st.Code())
+	if err != nil {
+		m.logf("  Message: %s", st.Message())
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.ErrorInfo:
+				m.logf("  ErrorInfo: reason=%s domain=%s metadata=%v", d.GetReason(), d.GetDomain(), d.GetMetadata())
+			default:
+				m.logf("  Detail: %v", d)
+			}
+		}
+	}
+	m.logf("========================\n")
+}
+
+func (m *metadataLogger) printMetadata(title string, md metadata.MD) {
+	m.logf("=== %s ===", title)
+	for key, values := range md {
+		m.logf("  %s: %v", key, values)
+	}
+	m.logf("========================\n")
+}
+
+// loggedStream reports a stream once it has finished: when a receive fails,
+// including with io.EOF, or after the single response of a client streaming
+// call. By then the headers and trailers are both available.
+type loggedStream struct {
+	grpc.ClientStream
+	logger        *metadataLogger
+	method        string
+	serverStreams bool
+	once          sync.Once
+}
+
+func (s *loggedStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	if err != nil || !s.serverStreams {
+		s.once.Do(func() {
+			header, _ := s.ClientStream.Header()
+			final := err
+			if final == io.EOF {
+				final = nil
+			}
+			s.logger.report(s.method, header, s.ClientStream.Trailer(), final)
+		})
+	}
+	return err
+}
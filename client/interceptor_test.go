@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+	"grpc-sample/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// spyLog collects the lines a metadataLogger prints.
+type spyLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *spyLog) logf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *spyLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func TestMetadataLoggerPrintsServerName(t *testing.T) {
+	var calls atomic.Int32
+	cfg := config.Default()
+	cfg.ServerAddress = startBackend(t, &calls)
+	spy := &spyLog{}
+	conn, err := dial(cfg, (&metadataLogger{logf: spy.logf}).dialOptions()...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	out := spy.String()
+	for _, want := range []string{"=== SayHello Headers ===", "server-name: [grpc-sample-server]", "Code: OK"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logged output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestDialSendsAuthTokenOnEveryCall(t *testing.T) {
+	// The server records the authorization metadata of each call, and
+	// requires it as its API key
+	var mu sync.Mutex
+	seen := map[string][]string{}
+	record := func(ctx context.Context, method string) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		seen[method] = md.Get("authorization")
+	}
+	registry := service.NewRegistry()
+	registry.Register(service.APIKeyInterceptor("secret-token"))
+	registry.Register(service.Interceptor{
+		Name:  "record-authorization",
+		Stage: service.StageRecovery,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			record(ctx, info.FullMethod)
+			return handler(ctx, req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			record(ss.Context(), info.FullMethod)
+			return handler(srv, ss)
+		},
+	})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(registry.ServerOptions()...)
+	service.Register(srv, service.NewHelloServer(service.WithStreamDelays(0, 0)), nil)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	cfg := config.Default()
+	cfg.ServerAddress = lis.Addr().String()
+	cfg.AuthToken = "secret-token"
+	conn, err := dial(cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	greeter := hello.NewGreeterClient(conn)
+
+	if _, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello with the token: %v", err)
+	}
+	stream, err := greeter.SayHelloStream(ctx, &hello.HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayHelloStream: %v", err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("SayHelloStream with the token: %v", err)
+		}
+	}
+
+	for _, method := range []string{"/grpc.hello.Greeter/SayHello", "/grpc.hello.Greeter/SayHelloStream"} {
+		if got := seen[method]; len(got) != 1 || got[0] != "Bearer secret-token" {
+			t.Errorf("%s authorization metadata = %v, want Bearer secret-token", method, got)
+		}
+	}
+
+	// Without the token the server turns calls away
+	cfg.AuthToken = ""
+	bare, err := dial(cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer bare.Close()
+	if _, err := hello.NewGreeterClient(bare).SayHello(ctx, &hello.HelloRequest{Name: "World"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("SayHello without a token = %v, want Unauthenticated", err)
+	}
+}
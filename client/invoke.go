@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// reflectionClient looks up services through the server's reflection
+// service, so methods can be called without compiled stubs.
+type reflectionClient struct {
+	stream reflectionpb.ServerReflection_ServerReflectionInfoClient
+	// files holds every file descriptor received so far, by file name.
+	files map[string]*descriptorpb.FileDescriptorProto
+}
+
+// newReflectionClient opens a reflection stream on conn. Close it when done.
+func newReflectionClient(ctx context.Context, conn grpc.ClientConnInterface) (*reflectionClient, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not start reflection (is it enabled on the server?): %w", err)
+	}
+	return &reflectionClient{stream: stream, files: map[string]*descriptorpb.FileDescriptorProto{}}, nil
+}
+
+// Close ends the reflection stream.
+func (c *reflectionClient) Close() error {
+	return c.stream.CloseSend()
+}
+
+// ask sends one reflection request and returns its response, turning an
+// error response into a status error.
+func (c *reflectionClient) ask(req *reflectionpb.ServerReflectionRequest) (*reflectionpb.ServerReflectionResponse, error) {
+	if err := c.stream.Send(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// listServices returns the names of the services the server reflects, sorted.
+func (c *reflectionClient) listServices() ([]string, error) {
+	resp, err := c.ask(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list services: %w", err)
+	}
+	var names []string
+	for _, svc := range resp.GetListServicesResponse().GetService() {
+		names = append(names, svc.GetName())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// addFiles records the file descriptors carried by resp.
+func (c *reflectionClient) addFiles(resp *reflectionpb.ServerReflectionResponse) error {
+	for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(raw, fd); err != nil {
+			return fmt.Errorf("invalid file descriptor from server: %w", err)
+		}
+		c.files[fd.GetName()] = fd
+	}
+	return nil
+}
+
+// missingImport returns an import of a received file that has not been
+// received itself, or "" once every import is known.
+func (c *reflectionClient) missingImport() string {
+	for _, fd := range c.files {
+		for _, dep := range fd.GetDependency() {
+			if _, ok := c.files[dep]; !ok {
+				return dep
+			}
+		}
+	}
+	return ""
+}
+
+// service returns the descriptor of the named service, fetching the file
+// that defines it and any imports the server did not send along.
+func (c *reflectionClient) service(name string) (protoreflect.ServiceDescriptor, error) {
+	resp, err := c.ask(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: name},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve %s: %w", name, err)
+	}
+	if err := c.addFiles(resp); err != nil {
+		return nil, err
+	}
+	for dep := c.missingImport(); dep != ""; dep = c.missingImport() {
+		resp, err := c.ask(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch %s: %w", dep, err)
+		}
+		if err := c.addFiles(resp); err != nil {
+			return nil, err
+		}
+		if _, ok := c.files[dep]; !ok {
+			return nil, fmt.Errorf("server did not send %s", dep)
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range c.files {
+		set.File = append(set.File, fd)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors for %s: %w", name, err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("could not find %s: %w", name, err)
+	}
+	svc, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", name)
+	}
+	return svc, nil
+}
+
+// splitMethod splits "package.Service/Method" or "package.Service.Method"
+// into its service and method names.
+func splitMethod(fullMethod string) (service, method string, err error) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	i := strings.LastIndex(fullMethod, "/")
+	if i < 0 {
+		i = strings.LastIndex(fullMethod, ".")
+	}
+	if i <= 0 || i == len(fullMethod)-1 {
+		return "", "", fmt.Errorf("invalid method %q: want package.Service/Method", fullMethod)
+	}
+	return fullMethod[:i], fullMethod[i+1:], nil
+}
+
+// methodSignature describes m like its rpc line in a .proto file.
+func methodSignature(m protoreflect.MethodDescriptor) string {
+	in, out := string(m.Input().FullName()), string(m.Output().FullName())
+	if m.IsStreamingClient() {
+		in = "stream " + in
+	}
+	if m.IsStreamingServer() {
+		out = "stream " + out
+	}
+	return fmt.Sprintf("%s(%s) returns (%s)", m.Name(), in, out)
+}
+
+// listMethods prints every service the server reflects with its methods.
+func (r *runner) listMethods() error {
+	ctx, cancel := context.WithTimeout(r.baseContext(), r.timeout)
+	defer cancel()
+	refl, err := newReflectionClient(ctx, r.conn)
+	if err != nil {
+		return err
+	}
+	defer refl.Close()
+
+	services, err := refl.listServices()
+	if err != nil {
+		return err
+	}
+	for _, name := range services {
+		svc, err := refl.service(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(r.out, name)
+		methods := svc.Methods()
+		for i := 0; i < methods.Len(); i++ {
+			fmt.Fprintln(r.out, "  "+methodSignature(methods.Get(i)))
+		}
+	}
+	return nil
+}
+
+// invoke calls fullMethod, resolved through reflection, with the requests
+// in r.data: a sequence of JSON objects, one per message for client
+// streaming methods and at most one otherwise, where no input sends an
+// empty request. Each reply is printed to r.out as indented JSON. Unary
+// calls are bounded by r.timeout.
+func (r *runner) invoke(fullMethod string) error {
+	serviceName, methodName, err := splitMethod(fullMethod)
+	if err != nil {
+		return err
+	}
+
+	lookupCtx, cancel := context.WithTimeout(r.baseContext(), r.timeout)
+	defer cancel()
+	refl, err := newReflectionClient(lookupCtx, r.conn)
+	if err != nil {
+		return err
+	}
+	svc, err := refl.service(serviceName)
+	refl.Close()
+	if err != nil {
+		return err
+	}
+	method := svc.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return fmt.Errorf("service %s has no method %s", serviceName, methodName)
+	}
+
+	requests, err := decodeRequests(r.data, method)
+	if err != nil {
+		return err
+	}
+	log.Printf("Calling %s with %d request message(s)", methodSignature(method), len(requests))
+
+	ctx := r.baseContext()
+	if !method.IsStreamingClient() && !method.IsStreamingServer() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	desc := &grpc.StreamDesc{
+		StreamName:    methodName,
+		ServerStreams: method.IsStreamingServer(),
+		ClientStreams: method.IsStreamingClient(),
+	}
+	stream, err := r.conn.NewStream(ctx, desc, "/"+serviceName+"/"+methodName)
+	if err != nil {
+		return fmt.Errorf("could not call %s: %w", fullMethod, err)
+	}
+	for _, req := range requests {
+		if err := stream.SendMsg(req); err != nil {
+			break // the error surfaces from RecvMsg
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("could not close %s: %w", fullMethod, err)
+	}
+
+	for {
+		reply := dynamicpb.NewMessage(method.Output())
+		err := stream.RecvMsg(reply)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s failed: %w", fullMethod, err)
+		}
+		if err := printJSON(r.out, reply); err != nil {
+			return err
+		}
+		if !desc.ServerStreams {
+			return nil
+		}
+	}
+}
+
+// decodeRequests reads the JSON request messages of method from data.
+func decodeRequests(data io.Reader, method protoreflect.MethodDescriptor) ([]proto.Message, error) {
+	var requests []proto.Message
+	if data != nil {
+		dec := json.NewDecoder(data)
+		for {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("invalid JSON request: %w", err)
+			}
+			req := dynamicpb.NewMessage(method.Input())
+			if err := protojson.Unmarshal(raw, req); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", method.Input().FullName(), err)
+			}
+			requests = append(requests, req)
+		}
+	}
+	if method.IsStreamingClient() {
+		return requests, nil
+	}
+	switch len(requests) {
+	case 0:
+		return []proto.Message{dynamicpb.NewMessage(method.Input())}, nil
+	case 1:
+		return requests, nil
+	default:
+		return nil, fmt.Errorf("%s takes a single request, got %d", method.Name(), len(requests))
+	}
+}
+
+// printJSON writes msg to w as indented JSON. protojson's own output varies
+// its spacing on purpose, so it is re-indented for stable output.
+func printJSON(w io.Writer, msg proto.Message) error {
+	data, err := protojson.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("could not encode reply: %w", err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return fmt.Errorf("could not encode reply: %w", err)
+	}
+	indented.WriteByte('\n')
+	_, err = indented.WriteTo(w)
+	return err
+}
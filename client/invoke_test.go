@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+)
+
+// reflectedRunner returns a runner connected to a Greeter and Farewell
+// server with reflection enabled, writing its output to out.
+func reflectedRunner(t *testing.T, out *bytes.Buffer) *runner {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	service.Register(srv, service.NewHelloServer(), service.NewGoodbyeServer())
+	reflection.Register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &runner{conn: conn, timeout: 5 * time.Second, ctx: context.Background(), out: out}
+}
+
+func TestInvokeSayHelloWithJSON(t *testing.T) {
+	captureLog(t)
+	var out bytes.Buffer
+	r := reflectedRunner(t, &out)
+	r.data = strings.NewReader(`{"name": "Reflection"}`)
+
+	if err := r.invoke("grpc.hello.Greeter/SayHello"); err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	var reply map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &reply); err != nil {
+		t.Fatalf("reply %q is not JSON: %v", out.String(), err)
+	}
+	if got, _ := reply["message"].(string); !strings.Contains(got, "Reflection") {
+		t.Errorf("message = %q, want a greeting for Reflection", got)
+	}
+
+	out.Reset()
+	r.data = strings.NewReader(`{"nmae": "typo"}`)
+	if err := r.invoke("grpc.hello.Greeter/SayHello"); err == nil {
+		t.Error("invoke with an unknown field succeeded, want an error")
+	}
+	if err := r.invoke("grpc.hello.Greeter/NoSuchMethod"); err == nil {
+		t.Error("invoke of a missing method succeeded, want an error")
+	}
+}
+
+func TestListMethodsShowsReflectedServices(t *testing.T) {
+	var out bytes.Buffer
+	r := reflectedRunner(t, &out)
+
+	if err := r.listMethods(); err != nil {
+		t.Fatalf("listMethods: %v", err)
+	}
+	for _, want := range []string{
+		"grpc.hello.Greeter\n",
+		"  SayHello(grpc.hello.HelloRequest) returns (grpc.hello.HelloReply)\n",
+		"  SayHelloBidirectional(stream grpc.hello.HelloRequest) returns (stream grpc.hello.HelloReply)\n",
+		"grpc.goodbye.Farewell\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("listing is missing %q:\n%s", want, out.String())
+		}
+	}
+}
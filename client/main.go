@@ -1,462 +1,411 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
 	"time"
 
+	"grpc-sample/config"
 	"grpc-sample/proto/goodbye"
 	"grpc-sample/proto/hello"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 )
 
 const (
-	defaultAddress = "localhost:50051"
-	defaultName    = "World"
+	defaultName = "World"
+
+	// serviceConfig spreads calls across every address the resolver returns,
+	// e.g. all A records of a dns:///host:port target, instead of pinning
+	// them to the first one.
+	serviceConfig = `{"loadBalancingConfig": [{"round_robin": {}}]}`
 )
 
-// getServerAddress returns the server address from environment variable or default
-func getServerAddress() string {
-	if addr := os.Getenv("GRPC_SERVER_ADDRESS"); addr != "" {
-		return addr
+// transportCredentials returns TLS credentials when a CA file is configured,
+// otherwise plaintext credentials.
+func transportCredentials(cfg config.Config) (credentials.TransportCredentials, error) {
+	if cfg.TLSCAFile == "" {
+		return insecure.NewCredentials(), nil
 	}
-	return defaultAddress
+	return credentials.NewClientTLSFromFile(cfg.TLSCAFile, "")
 }
 
-// printResponseInfo prints gRPC response metadata and status information
-func printResponseInfo(ctx context.Context, err error, methodName string) {
-	log.Printf("=== %s Response Info ===", methodName)
-
-	// Print status information
+// dial creates the client connection with any extra options appended,
+// sending cfg.APIKey and cfg.AuthToken on every call when set.
+// grpc.NewClient connects lazily on the first call; with FailFast set, dial
+// instead connects up front and reports an unreachable server within
+// DialTimeout rather than on the first RPC.
+func dial(cfg config.Config, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	creds, err := transportCredentials(cfg)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok {
-			log.Printf("Status Code: %v", st.Code())
-			log.Printf("Status Message: %s", st.Message())
-			log.Printf("Status Details: %v", st.Details())
-		} else {
-			log.Printf("Error (not gRPC status): %v", err)
-		}
-	} else {
-		log.Printf("Status Code: OK")
-		log.Printf("Status Message: Success")
+		return nil, fmt.Errorf("could not load TLS credentials: %w", err)
 	}
 
-	// Print response headers/trailers if available
-	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		log.Printf("Response Headers/Trailers:")
-		for key, values := range md {
-			log.Printf("  %s: %v", key, values)
-		}
-	} else {
-		log.Printf("No response metadata available")
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+	}, opts...)
+	if cfg.APIKey != "" {
+		opts = append(opts, apiKeyDialOptions(cfg.APIKey)...)
 	}
-
-	log.Printf("========================\n")
-}
-
-func main() {
-	// Get server address from environment or use default
-	serverAddress := getServerAddress()
-	log.Printf("Connecting to gRPC server at: %s", serverAddress)
-
-	// Set up a connection to the server.
-	conn, err := grpc.Dial(serverAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if cfg.AuthToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(cfg.AuthToken)))
+	}
+	conn, err := grpc.NewClient(cfg.ServerAddress, opts...)
 	if err != nil {
-		log.Fatalf("did not connect: %v", err)
+		return nil, fmt.Errorf("invalid server address %q: %w", cfg.ServerAddress, err)
+	}
+	if !cfg.FailFast {
+		return conn, nil
 	}
-	defer conn.Close()
-
-	// Create clients for both services
-	helloClient := hello.NewGreeterClient(conn)
-	goodbyeClient := goodbye.NewFarewellClient(conn)
-
-	// Test SayHello with response info
-	log.Printf("Calling SayHello with name: %s", defaultName)
 
-	// Create context with metadata to capture response headers
-	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("client-id", "grpc-sample-client"))
-	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout.Duration)
 	defer cancel()
+	if err := waitForReady(ctx, conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to %s: %w", cfg.ServerAddress, err)
+	}
+	return conn, nil
+}
 
-	var header, trailer metadata.MD
-	r, err := helloClient.SayHello(ctx, &hello.HelloRequest{Name: defaultName}, grpc.Header(&header), grpc.Trailer(&trailer))
-
-	if err != nil {
-		printResponseInfo(ctx, err, "SayHello")
-		log.Fatalf("could not greet: %v", err)
-	} else {
-		log.Printf("Greeting: %s", r.GetMessage())
-
-		// Print detailed response information
-		log.Printf("=== SayHello Response Info ===")
-		log.Printf("Status Code: OK")
-		log.Printf("Status Message: Success")
-		log.Printf("Response Headers:")
-		for key, values := range header {
-			log.Printf("  %s: %v", key, values)
+// waitForReady forces conn to connect and blocks until it is ready, its first
+// connection attempt fails, or ctx is done.
+func waitForReady(ctx context.Context, conn *grpc.ClientConn) error {
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return fmt.Errorf("connection is %s", state)
 		}
-		log.Printf("Response Trailers:")
-		for key, values := range trailer {
-			log.Printf("  %s: %v", key, values)
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("timed out while %s: %w", state, ctx.Err())
 		}
-		log.Printf("Response Size: %d bytes", len(r.GetMessage()))
-		log.Printf("========================\n")
 	}
+}
 
-	// Test streaming RPC with response info
-	log.Printf("Calling SayHelloStream with name: %s", defaultName)
-
-	streamCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("stream-client-id", "grpc-sample-stream"))
-	stream, err := helloClient.SayHelloStream(streamCtx, &hello.HelloRequest{Name: defaultName})
+// runner executes RPCs against the server and prints their results.
+type runner struct {
+	hello   hello.GreeterClient
+	goodbye goodbye.FarewellClient
+	// conn carries the invoke command's calls, which have no generated stubs.
+	conn *grpc.ClientConn
+	// timeout bounds each unary call.
+	timeout time.Duration
+	// ctx is the parent of every call. It ends on Ctrl-C and, with a
+	// budget, once budget has passed, so each call gets at most the time
+	// left and later calls of the run are skipped.
+	ctx    context.Context
+	budget time.Duration
+	// names and interval override the built-in names and pacing of the
+	// client streaming and bidirectional calls when set.
+	names    []string
+	interval time.Duration
+	// namesFrom, when set, supplies the client streaming names instead, one
+	// per line, sent as they are read.
+	namesFrom io.Reader
+	// transform is sent as the transform metadata key on the hello
+	// bidirectional call when set.
+	transform string
+	// injectError is sent as the inject-error metadata key on SayHello when
+	// set.
+	injectError string
+	// data supplies the invoke command's JSON request messages; nil sends an
+	// empty request. out receives its replies and method listings.
+	data io.Reader
+	out  io.Writer
+	// verbose enables connection state output; per-call headers, trailers
+	// and status are printed by metadataLogger.
+	verbose bool
+}
 
-	if err != nil {
-		printResponseInfo(streamCtx, err, "SayHelloStream")
-		log.Fatalf("could not call SayHelloStream: %v", err)
+// baseContext returns the context every call derives from.
+func (r *runner) baseContext() context.Context {
+	if r.ctx == nil {
+		return context.Background()
 	}
+	return r.ctx
+}
 
-	// Get stream headers
-	streamHeader, err := stream.Header()
-	if err != nil {
-		log.Printf("Failed to get stream headers: %v", err)
-	} else {
-		log.Printf("=== SayHelloStream Headers ===")
-		for key, values := range streamHeader {
-			log.Printf("  %s: %v", key, values)
-		}
-		log.Printf("==============================")
-	}
+// budgetSpent reports whether the --budget has run out.
+func (r *runner) budgetSpent() bool {
+	return r.budget > 0 && errors.Is(r.baseContext().Err(), context.DeadlineExceeded)
+}
 
-	messageCount := 0
-	for {
-		reply, err := stream.Recv()
-		if err == io.EOF {
-			// Get stream trailers
-			streamTrailer := stream.Trailer()
-			log.Printf("=== SayHelloStream Trailers ===")
-			for key, values := range streamTrailer {
-				log.Printf("  %s: %v", key, values)
-			}
-			log.Printf("Total messages received: %d", messageCount)
-			log.Printf("Stream Status: Completed successfully")
-			log.Printf("===============================\n")
-			break
-		}
-		if err != nil {
-			st, ok := status.FromError(err)
-			if ok {
-				log.Printf("Stream error - Code: %v, Message: %s", st.Code(), st.Message())
-			}
-			log.Fatalf("could not receive: %v", err)
-		}
-		messageCount++
-		log.Printf("Stream message %d: %s", messageCount, reply.GetMessage())
+// budgetError says that err, from a call cut short or refused because the
+// --budget ran out, was caused by the budget. Other errors are returned
+// unchanged.
+func (r *runner) budgetError(err error) error {
+	if err == nil || !r.budgetSpent() {
+		return err
 	}
+	return fmt.Errorf("budget of %s exhausted: %w", r.budget, err)
+}
 
-	// Test client streaming RPC
-	log.Printf("Calling SayHelloClientStream")
-
-	clientStreamCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("client-stream-id", "grpc-sample-client-stream"))
-	clientStream, err := helloClient.SayHelloClientStream(clientStreamCtx)
-
-	if err != nil {
-		log.Fatalf("could not call SayHelloClientStream: %v", err)
+// streamNames returns the names to send on a streaming call, preferring the
+// user-supplied list over defaults.
+func (r *runner) streamNames(defaults []string) []string {
+	if len(r.names) > 0 {
+		return r.names
 	}
+	return defaults
+}
 
-	// Get client stream headers
-	clientStreamHeader, err := clientStream.Header()
-	if err != nil {
-		log.Printf("Failed to get client stream headers: %v", err)
-	} else {
-		log.Printf("=== SayHelloClientStream Headers ===")
-		for key, values := range clientStreamHeader {
-			log.Printf("  %s: %v", key, values)
+// eachStreamName calls send with each name to stream and its index. Names
+// from namesFrom are sent line by line as they are read, skipping blank
+// lines, so large inputs are never held in memory; otherwise they come from
+// streamNames(defaults).
+func (r *runner) eachStreamName(defaults []string, send func(i int, name string) error) error {
+	if r.namesFrom == nil {
+		for i, name := range r.streamNames(defaults) {
+			if err := send(i, name); err != nil {
+				return err
+			}
 		}
-		log.Printf("====================================")
+		return nil
 	}
 
-	// Send multiple names to server
-	names := []string{"Alice", "Bob", "Charlie", "Diana"}
-	for i, name := range names {
-		if err := clientStream.Send(&hello.HelloRequest{Name: name}); err != nil {
-			log.Fatalf("could not send: %v", err)
+	scanner := bufio.NewScanner(r.namesFrom)
+	i := 0
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" {
+			continue
 		}
-		log.Printf("Sent client stream message %d: %s", i+1, name)
-		time.Sleep(500 * time.Millisecond)
+		if err := send(i, name); err != nil {
+			return err
+		}
+		i++
 	}
-
-	// Close and receive response
-	reply, err := clientStream.CloseAndRecv()
-	if err != nil {
-		log.Fatalf("could not receive: %v", err)
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read names: %w", err)
 	}
+	return nil
+}
 
-	log.Printf("Client stream response: %s", reply.GetMessage())
-
-	// Get client stream trailers
-	clientStreamTrailer := clientStream.Trailer()
-	log.Printf("=== SayHelloClientStream Trailers ===")
-	for key, values := range clientStreamTrailer {
-		log.Printf("  %s: %v", key, values)
+// sendInterval returns the pause between streamed sends, preferring the
+// user-supplied interval over def. Names read from namesFrom are sent back
+// to back unless an interval is set.
+func (r *runner) sendInterval(def time.Duration) time.Duration {
+	if r.interval > 0 {
+		return r.interval
 	}
-	log.Printf("=====================================\n")
-
-	// Test bidirectional streaming RPC
-	log.Printf("Calling SayHelloBidirectional")
-
-	bidiCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("bidi-client-id", "grpc-sample-bidi"))
-	bidiStream, err := helloClient.SayHelloBidirectional(bidiCtx)
-
-	if err != nil {
-		log.Fatalf("could not call SayHelloBidirectional: %v", err)
+	if r.namesFrom != nil {
+		return 0
 	}
+	return def
+}
 
-	// Get bidirectional stream headers
-	bidiHeader, err := bidiStream.Header()
-	if err != nil {
-		log.Printf("Failed to get bidirectional stream headers: %v", err)
-	} else {
-		log.Printf("=== SayHelloBidirectional Headers ===")
-		for key, values := range bidiHeader {
-			log.Printf("  %s: %v", key, values)
-		}
-		log.Printf("=====================================")
+// sleepContext pauses for d or until ctx is done, whichever comes first. It
+// returns ctx.Err() if the context ended the wait early.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
-	// Start a goroutine to send messages
+// backgroundSender runs the send side of a bidirectional stream on its own
+// goroutine while the caller receives.
+type backgroundSender struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startSender runs send on a new goroutine. ctx must be the context the
+// stream was opened with and cancel its cancel function, so that stop can
+// abort a Send blocked on flow control as well as send's pauses.
+func startSender(ctx context.Context, cancel context.CancelFunc, send func(ctx context.Context)) *backgroundSender {
+	s := &backgroundSender{cancel: cancel, done: make(chan struct{})}
 	go func() {
-		bidiNames := []string{"Emma", "Frank", "Grace"}
-		for i, name := range bidiNames {
-			if err := bidiStream.Send(&hello.HelloRequest{Name: name}); err != nil {
-				log.Printf("could not send bidirectional: %v", err)
-				return
-			}
-			log.Printf("Sent bidirectional message %d: %s", i+1, name)
-			time.Sleep(1 * time.Second)
-		}
-		bidiStream.CloseSend()
+		defer close(s.done)
+		send(ctx)
 	}()
+	return s
+}
 
-	// Receive responses
-	bidiMessageCount := 0
-	for {
-		reply, err := bidiStream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("could not receive bidirectional: %v", err)
-		}
-		bidiMessageCount++
-		log.Printf("Bidirectional response %d: %s", bidiMessageCount, reply.GetMessage())
-	}
+// stop cancels the stream and waits for the send goroutine to return, so it
+// never outlives the call. Call it once receiving is over, whether the
+// stream ended cleanly or Recv failed.
+func (s *backgroundSender) stop() {
+	s.cancel()
+	<-s.done
+}
 
-	// Get bidirectional stream trailers
-	bidiTrailer := bidiStream.Trailer()
-	log.Printf("=== SayHelloBidirectional Trailers ===")
-	for key, values := range bidiTrailer {
-		log.Printf("  %s: %v", key, values)
+// run executes the RPC (or sequence of RPCs) selected by cmd.
+func (r *runner) run(cmd command) error {
+	if cmd.service == "all" {
+		return r.runAll(cmd.name)
 	}
-	log.Printf("Total bidirectional messages received: %d", bidiMessageCount)
-	log.Printf("======================================\n")
-
-	// Test goodbye RPC with response info
-	log.Printf("Calling SayGoodbye with name: %s", defaultName)
-
-	goodbyeCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("goodbye-client-id", "grpc-sample-goodbye"))
-	goodbyeCtx, goodbyeCancel := context.WithTimeout(goodbyeCtx, time.Second)
-	defer goodbyeCancel()
-
-	var goodbyeHeader, goodbyeTrailer metadata.MD
-	goodbyeReply, err := goodbyeClient.SayGoodbye(goodbyeCtx, &goodbye.GoodbyeRequest{Name: defaultName}, grpc.Header(&goodbyeHeader), grpc.Trailer(&goodbyeTrailer))
+	return r.budgetError(r.runOne(cmd))
+}
 
-	if err != nil {
-		printResponseInfo(goodbyeCtx, err, "SayGoodbye")
-		log.Fatalf("could not say goodbye: %v", err)
-	} else {
-		log.Printf("Goodbye message: %s", goodbyeReply.GetMessage())
-
-		// Print detailed response information
-		log.Printf("=== SayGoodbye Response Info ===")
-		log.Printf("Status Code: OK")
-		log.Printf("Status Message: Success")
-		log.Printf("Response Headers:")
-		for key, values := range goodbyeHeader {
-			log.Printf("  %s: %v", key, values)
+// runOne makes the single call cmd selects.
+func (r *runner) runOne(cmd command) error {
+	switch cmd.service {
+	case "hello":
+		switch cmd.mode {
+		case modeStream:
+			return r.sayHelloStream(cmd.name)
+		case modeClientStream:
+			return r.sayHelloClientStream()
+		case modeBidi:
+			return r.sayHelloBidirectional()
+		default:
+			return r.sayHello(cmd.name)
 		}
-		log.Printf("Response Trailers:")
-		for key, values := range goodbyeTrailer {
-			log.Printf("  %s: %v", key, values)
+	case "bench":
+		return r.bench(cmd.rpc, cmd.name, cmd.duration, cmd.concurrency)
+	case "invoke":
+		if cmd.method == "" {
+			return r.listMethods()
 		}
-		log.Printf("Response Size: %d bytes", len(goodbyeReply.GetMessage()))
-		log.Printf("===============================\n")
-	}
-
-	// Test goodbye streaming RPC with response info
-	log.Printf("Calling SayGoodbyeStream with name: %s", defaultName)
-
-	goodbyeStreamCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("goodbye-stream-client-id", "grpc-sample-goodbye-stream"))
-	goodbyeStream, err := goodbyeClient.SayGoodbyeStream(goodbyeStreamCtx, &goodbye.GoodbyeRequest{Name: defaultName})
-
-	if err != nil {
-		printResponseInfo(goodbyeStreamCtx, err, "SayGoodbyeStream")
-		log.Fatalf("could not call SayGoodbyeStream: %v", err)
-	}
-
-	// Get goodbye stream headers
-	goodbyeStreamHeader, err := goodbyeStream.Header()
-	if err != nil {
-		log.Printf("Failed to get goodbye stream headers: %v", err)
-	} else {
-		log.Printf("=== SayGoodbyeStream Headers ===")
-		for key, values := range goodbyeStreamHeader {
-			log.Printf("  %s: %v", key, values)
+		return r.invoke(cmd.method)
+	case "goodbye":
+		switch cmd.mode {
+		case modeStream:
+			return r.sayGoodbyeStream(cmd.name)
+		case modeClientStream:
+			return r.sayGoodbyeClientStream()
+		case modeBidi:
+			return r.sayGoodbyeBidirectional()
+		default:
+			if cmd.reason != "" {
+				return r.sayGoodbyeWithReason(cmd.name, cmd.reason)
+			}
+			return r.sayGoodbye(cmd.name)
 		}
-		log.Printf("================================")
 	}
+	return fmt.Errorf("unknown command %q", cmd.service)
+}
 
-	goodbyeMessageCount := 0
-	for {
-		reply, err := goodbyeStream.Recv()
-		if err == io.EOF {
-			// Get goodbye stream trailers
-			goodbyeStreamTrailer := goodbyeStream.Trailer()
-			log.Printf("=== SayGoodbyeStream Trailers ===")
-			for key, values := range goodbyeStreamTrailer {
-				log.Printf("  %s: %v", key, values)
-			}
-			log.Printf("Total goodbye messages received: %d", goodbyeMessageCount)
-			log.Printf("Goodbye Stream Status: Completed successfully")
-			log.Printf("=================================\n")
-			break
+// runAll calls every method of both services in sequence, skipping the
+// calls left once the --budget runs out.
+func (r *runner) runAll(name string) error {
+	steps := []func() error{
+		func() error { return r.sayHello(name) },
+		func() error { return r.sayHelloStream(name) },
+		r.sayHelloClientStream,
+		r.sayHelloBidirectional,
+		func() error { return r.sayGoodbye(name) },
+		func() error { return r.sayGoodbyeStream(name) },
+		r.sayGoodbyeClientStream,
+		r.sayGoodbyeBidirectional,
+	}
+	for i, step := range steps {
+		if r.budgetSpent() {
+			return fmt.Errorf("budget of %s exhausted after %d of %d calls; skipping the rest", r.budget, i, len(steps))
 		}
-		if err != nil {
-			st, ok := status.FromError(err)
-			if ok {
-				log.Printf("Goodbye stream error - Code: %v, Message: %s", st.Code(), st.Message())
+		if err := step(); err != nil {
+			if r.budgetSpent() {
+				return fmt.Errorf("budget of %s exhausted during call %d of %d; skipping the rest: %w", r.budget, i+1, len(steps), err)
 			}
-			log.Fatalf("could not receive goodbye: %v", err)
+			return err
 		}
-		goodbyeMessageCount++
-		log.Printf("Goodbye stream message %d: %s", goodbyeMessageCount, reply.GetMessage())
-	}
-
-	// Test goodbye client streaming RPC
-	log.Printf("Calling SayGoodbyeClientStream")
-
-	goodbyeClientStreamCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("goodbye-client-stream-id", "grpc-sample-goodbye-client-stream"))
-	goodbyeClientStream, err := goodbyeClient.SayGoodbyeClientStream(goodbyeClientStreamCtx)
-
-	if err != nil {
-		log.Fatalf("could not call SayGoodbyeClientStream: %v", err)
 	}
+	return nil
+}
 
-	// Get goodbye client stream headers
-	goodbyeClientStreamHeader, err := goodbyeClientStream.Header()
+func main() {
+	cmd, err := parseArgs(os.Args[1:])
 	if err != nil {
-		log.Printf("Failed to get goodbye client stream headers: %v", err)
-	} else {
-		log.Printf("=== SayGoodbyeClientStream Headers ===")
-		for key, values := range goodbyeClientStreamHeader {
-			log.Printf("  %s: %v", key, values)
+		fmt.Fprint(os.Stderr, usage)
+		if errors.Is(err, flag.ErrHelp) {
+			return
 		}
-		log.Printf("======================================")
+		log.Fatalf("%v", err)
 	}
 
-	// Send multiple names for goodbye
-	goodbyeNames := []string{"Helen", "Ivan", "Julia", "Kevin", "Luna"}
-	for i, name := range goodbyeNames {
-		if err := goodbyeClientStream.Send(&goodbye.GoodbyeRequest{Name: name}); err != nil {
-			log.Fatalf("could not send goodbye: %v", err)
+	if cmd.namesFile != "" {
+		if cmd.names, err = loadNamesFile(cmd.namesFile); err != nil {
+			log.Fatalf("%v", err)
 		}
-		log.Printf("Sent goodbye client stream message %d: %s", i+1, name)
-		time.Sleep(400 * time.Millisecond)
 	}
 
-	// Close and receive response
-	goodbyeReply2, err := goodbyeClientStream.CloseAndRecv()
+	cfg, err := config.Load(cmd.configPath)
 	if err != nil {
-		log.Fatalf("could not receive goodbye: %v", err)
+		log.Fatalf("invalid configuration: %v", err)
 	}
 
-	log.Printf("Goodbye client stream response: %s", goodbyeReply2.GetMessage())
+	// Get server address from configuration
+	serverAddress := cfg.ServerAddress
+	log.Printf("Connecting to gRPC server at: %s", serverAddress)
 
-	// Get goodbye client stream trailers
-	goodbyeClientStreamTrailer := goodbyeClientStream.Trailer()
-	log.Printf("=== SayGoodbyeClientStream Trailers ===")
-	for key, values := range goodbyeClientStreamTrailer {
-		log.Printf("  %s: %v", key, values)
+	// Set up a connection to the server, printing each call's metadata
+	// and status in verbose mode.
+	var opts []grpc.DialOption
+	if cmd.verbose {
+		opts = (&metadataLogger{logf: log.Printf}).dialOptions()
 	}
-	log.Printf("=======================================\n")
-
-	// Test goodbye bidirectional streaming RPC
-	log.Printf("Calling SayGoodbyeBidirectional")
-
-	goodbyeBidiCtx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("goodbye-bidi-client-id", "grpc-sample-goodbye-bidi"))
-	goodbyeBidiStream, err := goodbyeClient.SayGoodbyeBidirectional(goodbyeBidiCtx)
-
+	conn, err := dial(cfg, opts...)
 	if err != nil {
-		log.Fatalf("could not call SayGoodbyeBidirectional: %v", err)
+		log.Fatalf("did not connect: %v", err)
 	}
+	defer conn.Close()
 
-	// Get goodbye bidirectional stream headers
-	goodbyeBidiHeader, err := goodbyeBidiStream.Header()
-	if err != nil {
-		log.Printf("Failed to get goodbye bidirectional stream headers: %v", err)
-	} else {
-		log.Printf("=== SayGoodbyeBidirectional Headers ===")
-		for key, values := range goodbyeBidiHeader {
-			log.Printf("  %s: %v", key, values)
-		}
-		log.Printf("=======================================")
+	if cmd.watchConn {
+		stop := watchConnState(conn, log.Printf)
+		defer stop()
 	}
 
-	// Start a goroutine to send goodbye messages
-	go func() {
-		goodbyeBidiNames := []string{"Maya", "Noah", "Olivia", "Paul"}
-		for i, name := range goodbyeBidiNames {
-			if err := goodbyeBidiStream.Send(&goodbye.GoodbyeRequest{Name: name}); err != nil {
-				log.Printf("could not send goodbye bidirectional: %v", err)
-				return
-			}
-			log.Printf("Sent goodbye bidirectional message %d: %s", i+1, name)
-			time.Sleep(1200 * time.Millisecond)
-		}
-		goodbyeBidiStream.CloseSend()
-	}()
-
-	// Receive goodbye responses
-	goodbyeBidiMessageCount := 0
-	for {
-		reply, err := goodbyeBidiStream.Recv()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("could not receive goodbye bidirectional: %v", err)
-		}
-		goodbyeBidiMessageCount++
-		log.Printf("Goodbye bidirectional response %d: %s", goodbyeBidiMessageCount, reply.GetMessage())
+	r := &runner{
+		hello:       hello.NewGreeterClient(conn),
+		goodbye:     goodbye.NewFarewellClient(conn),
+		conn:        conn,
+		timeout:     cfg.RequestTimeout.Duration,
+		names:       cmd.names,
+		interval:    cmd.interval,
+		transform:   cmd.transform,
+		injectError: cmd.injectError,
+		verbose:     cmd.verbose,
+		out:         os.Stdout,
+		budget:      cmd.budget,
 	}
-
-	// Get goodbye bidirectional stream trailers
-	goodbyeBidiTrailer := goodbyeBidiStream.Trailer()
-	log.Printf("=== SayGoodbyeBidirectional Trailers ===")
-	for key, values := range goodbyeBidiTrailer {
-		log.Printf("  %s: %v", key, values)
+	// Ctrl-C cancels the calls in flight. The budget covers the calls
+	// only, not connecting
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if cmd.budget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmd.budget)
+		defer cancel()
+	}
+	r.ctx = ctx
+	if cmd.namesFromStdin {
+		r.namesFrom = os.Stdin
+	}
+	if cmd.data == "-" {
+		r.data = os.Stdin
+	} else if cmd.data != "" {
+		r.data = strings.NewReader(cmd.data)
+	}
+	if err := r.run(cmd); err != nil {
+		log.Fatalf("%v", err)
 	}
-	log.Printf("Total goodbye bidirectional messages received: %d", goodbyeBidiMessageCount)
-	log.Printf("========================================\n")
 
 	// Print connection state information
-	log.Printf("=== Connection Info ===")
-	log.Printf("Target: %s", conn.Target())
-	log.Printf("Connection State: %v", conn.GetState())
-	log.Printf("======================")
+	if r.verbose {
+		log.Printf("=== Connection Info ===")
+		log.Printf("Target: %s", conn.Target())
+		log.Printf("Connection State: %v", conn.GetState())
+		log.Printf("======================")
+	}
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+	"grpc-sample/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+// startBackend serves a Greeter on a loopback port that counts the calls it
+// answers, and returns its address.
+func startBackend(t *testing.T, calls *atomic.Int32) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	greeter := service.GreeterFunc(func(ctx context.Context, name string) string {
+		calls.Add(1)
+		return "Hello " + name
+	})
+	srv := grpc.NewServer()
+	service.Register(srv, service.NewHelloServer(service.WithGreeter(greeter)), nil)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestDialSpreadsCallsAcrossBackends(t *testing.T) {
+	var first, second atomic.Int32
+	backends := manual.NewBuilderWithScheme("test")
+	backends.InitialState(resolver.State{Addresses: []resolver.Address{
+		{Addr: startBackend(t, &first)},
+		{Addr: startBackend(t, &second)},
+	}})
+
+	cfg := config.Default()
+	cfg.ServerAddress = "test:///greeters"
+	conn, err := dial(cfg, grpc.WithResolvers(backends))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := hello.NewGreeterClient(conn)
+	for i := 0; i < 20; i++ {
+		if _, err := client.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.WaitForReady(true)); err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+	}
+	if first.Load() == 0 || second.Load() == 0 {
+		t.Errorf("backends answered %d and %d calls, want both used", first.Load(), second.Load())
+	}
+}
+
+func TestDialFailFastReportsUnreachableServer(t *testing.T) {
+	// Reserve a port, then free it so nothing answers there
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	cfg := config.Default()
+	cfg.ServerAddress = addr
+	cfg.FailFast = true
+	cfg.DialTimeout = config.Duration{Duration: 5 * time.Second}
+
+	start := time.Now()
+	conn, err := dial(cfg)
+	if err == nil {
+		conn.Close()
+		t.Fatal("dial succeeded against a closed port")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("dial took %s to fail, want a prompt error", elapsed)
+	}
+	if want := "could not connect to " + addr; !strings.Contains(err.Error(), want) {
+		t.Errorf("dial error = %q, want it to contain %q", err, want)
+	}
+}
+
+func TestDialRejectsInvalidAddress(t *testing.T) {
+	cfg := config.Default()
+	cfg.ServerAddress = "unknown-scheme://%%"
+	conn, err := dial(cfg)
+	if err == nil {
+		conn.Close()
+		t.Fatal("dial accepted an unparseable address")
+	}
+	if !strings.Contains(err.Error(), "invalid server address") {
+		t.Errorf("dial error = %q, want it to name the invalid server address", err)
+	}
+}
+
+func TestBudgetSkipsLaterCalls(t *testing.T) {
+	captureLog(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	greeter := &blockingGreeter{}
+	// Only SayHello is implemented, so any later call would panic
+	r := &runner{hello: greeter, timeout: time.Hour, ctx: ctx, budget: 50 * time.Millisecond}
+
+	start := time.Now()
+	err := r.run(command{service: "all", name: "World"})
+	if err == nil || !strings.Contains(err.Error(), "budget of 50ms exhausted during call 1 of 8") {
+		t.Errorf("run all = %v, want the budget exhausted during the first call", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("first call ran %s with a 50ms budget and an hour's timeout", elapsed)
+	}
+
+	// With the budget gone, nothing else is attempted
+	err = r.run(command{service: "all", name: "World"})
+	if err == nil || !strings.Contains(err.Error(), "exhausted after 0 of 8 calls; skipping the rest") {
+		t.Errorf("run all after the budget ran out = %v, want every call skipped", err)
+	}
+	if err := r.run(command{service: "hello", name: "World"}); err == nil || !strings.Contains(err.Error(), "budget of 50ms exhausted") {
+		t.Errorf("run hello after the budget ran out = %v, want a budget error", err)
+	}
+}
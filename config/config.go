@@ -0,0 +1,929 @@
+// Package config loads the settings shared by the server and client.
+//
+// Values are resolved in order of precedence: environment variables override
+// an optional JSON config file, which overrides the built-in defaults.
+//
+//	Field                 Env var                     Default
+//	Port                  GRPC_PORT                   50051
+//	ListenAddr            GRPC_LISTEN_ADDR            (none, all interfaces on Port)
+//	HTTPPort              HTTP_PORT                   (none, REST shares the gRPC port)
+//	ServerAddress         GRPC_SERVER_ADDRESS         localhost:50051
+//	TLSCertFile           GRPC_TLS_CERT_FILE          (none, plaintext)
+//	TLSKeyFile            GRPC_TLS_KEY_FILE           (none, plaintext)
+//	TLSCAFile             GRPC_TLS_CA_FILE            (none, plaintext)
+//	TLSReloadInterval     GRPC_TLS_RELOAD_INTERVAL    10s
+//	TLSMinVersion         GRPC_TLS_MIN_VERSION        1.2
+//	TLSRequired           GRPC_TLS_REQUIRED           false
+//	RequestTimeout        GRPC_REQUEST_TIMEOUT        1s
+//	DialTimeout           GRPC_DIAL_TIMEOUT           5s
+//	FailFast              GRPC_FAIL_FAST              false
+//	LogLevel              LOG_LEVEL                   info
+//	LogBannerStyle        LOG_BANNER_STYLE            decorated
+//	LogMetadata           LOG_METADATA                false
+//	LogAccessFormat       LOG_ACCESS_FORMAT           off
+//	ServiceName           SERVICE_NAME                gRPC Sample Server
+//	ServiceVersion        SERVICE_VERSION             Version (1.0.0 unless set at build time)
+//	AdminAPIKey           ADMIN_API_KEY               (none, admin routes disabled)
+//	APIKey                GRPC_API_KEY                (none, gRPC calls unauthenticated)
+//	AuthToken             GRPC_AUTH_TOKEN             (none)
+//	IPAllowList           IP_ALLOW_LIST               (none, every peer allowed)
+//	IPDenyList            IP_DENY_LIST                (none)
+//	EnableReflection      GRPC_ENABLE_REFLECTION      true
+//	EnableFaultInjection  GRPC_ENABLE_FAULT_INJECTION false
+//	EnableHello           ENABLE_HELLO                true
+//	EnableGoodbye         ENABLE_GOODBYE              true
+//	GreetingStyle         GREETING_STYLE              plain
+//	CoalesceGreetings     GREETING_COALESCE           false
+//	HelloStreamDelay      HELLO_STREAM_DELAY          1s
+//	HelloBidiDelay        HELLO_BIDI_DELAY            500ms
+//	BlockedNames          GOODBYE_BLOCKED_NAMES       (none)
+//	GoodbyeTemplate       GOODBYE_TEMPLATE            (none, built-in farewell)
+//	GoodbyeInterval       GOODBYE_INTERVAL            1s
+//	GoodbyeStreamDelay    GOODBYE_STREAM_DELAY        1.5s
+//	HistoryStore          HISTORY_STORE               (none, history disabled)
+//	HistorySQLitePath     HISTORY_SQLITE_PATH         history.db
+//	HistorySize           HISTORY_SIZE                1000
+//	DisabledInterceptors  GRPC_DISABLED_INTERCEPTORS  (none)
+//	RequiredMetadataKeys  GRPC_REQUIRED_METADATA_KEYS (none)
+//	MethodTimeouts        GRPC_METHOD_TIMEOUTS        (none)
+//	SlowCallThreshold     GRPC_SLOW_CALL_THRESHOLD    1s (0 disables)
+//	MinDeadline           GRPC_MIN_DEADLINE           0 (disabled)
+//	RejectShortDeadlines  GRPC_REJECT_SHORT_DEADLINES false
+//	IdempotencyTTL        GRPC_IDEMPOTENCY_TTL        5m
+//	IdempotencyCacheSize  GRPC_IDEMPOTENCY_CACHE_SIZE 1000
+//	RedactedMetadataKeys  LOG_REDACTED_METADATA_KEYS  authorization,x-api-key,cookie,proxy-authorization
+//	MaxRecvMsgSize        GRPC_MAX_RECV_MSG_SIZE      4194304 (4 MiB)
+//	MaxConcurrentStreams  GRPC_MAX_CONCURRENT_STREAMS 100
+//	MaxStreamedNames      GRPC_MAX_STREAMED_NAMES     10000
+//	MaxStreamedBytes      GRPC_MAX_STREAMED_BYTES     1048576 (1 MiB)
+//	MaxHTTPBodyBytes      HTTP_MAX_BODY_BYTES         1048576 (1 MiB)
+//	MaxHTTPHeaderBytes    HTTP_MAX_HEADER_BYTES       1048576 (1 MiB)
+//	HTTPReadHeaderTimeout HTTP_READ_HEADER_TIMEOUT    10s
+//	HTTPReadTimeout       HTTP_READ_TIMEOUT           30s
+//	HTTPWriteTimeout      HTTP_WRITE_TIMEOUT          30s
+//	HTTPIdleTimeout       HTTP_IDLE_TIMEOUT           2m
+//	HTTPBackendTimeout    HTTP_BACKEND_TIMEOUT        (none)
+//	HTTPRouteTimeouts     HTTP_ROUTE_TIMEOUTS         (none)
+//	HTTPCacheTTL          HTTP_CACHE_TTL              0 (caching disabled)
+//	HTTPCacheSize         HTTP_CACHE_SIZE             1000
+//	CORSAllowedOrigins    CORS_ALLOWED_ORIGINS        *
+//	CORSAllowedMethods    CORS_ALLOWED_METHODS        GET,POST,OPTIONS
+//	CORSAllowedHeaders    CORS_ALLOWED_HEADERS        Content-Type,X-Request-ID
+//	CORSAllowCredentials  CORS_ALLOW_CREDENTIALS      false
+package config
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the effective server and client configuration.
+type Config struct {
+	// Port is the port the server listens on for both gRPC and HTTP; "0"
+	// picks a free port.
+	Port string `json:"port"`
+	// ListenAddr is a host:port to listen on instead of Port on every
+	// interface, e.g. "127.0.0.1:50051" to accept only local connections.
+	ListenAddr string `json:"listen_addr"`
+	// HTTPPort, when set, serves REST on its own port and leaves the gRPC
+	// port to gRPC alone, e.g. for separate firewall rules. It uses the
+	// host of ListenAddr, if any. Empty keeps both protocols on one port.
+	HTTPPort string `json:"http_port"`
+	// ServerAddress is the address the client dials.
+	ServerAddress string `json:"server_address"`
+
+	// TLSCertFile and TLSKeyFile enable TLS on the server when both are set.
+	TLSCertFile string `json:"tls_cert_file"`
+	TLSKeyFile  string `json:"tls_key_file"`
+	// TLSCAFile enables TLS on the client, trusting the given CA bundle.
+	TLSCAFile string `json:"tls_ca_file"`
+	// TLSReloadInterval is how often the server checks TLSCertFile and
+	// TLSKeyFile for changes, picking up rotated certificates without a
+	// restart.
+	TLSReloadInterval Duration `json:"tls_reload_interval"`
+	// TLSMinVersion is the lowest TLS version the server accepts: 1.0, 1.1,
+	// 1.2 or 1.3. Older handshakes fail, and gRPC calls on an older
+	// connection are rejected with PermissionDenied.
+	TLSMinVersion string `json:"tls_min_version"`
+	// TLSRequired rejects gRPC calls over plaintext connections with
+	// PermissionDenied. When false, plaintext calls are served as usual. It
+	// needs TLSCertFile and TLSKeyFile.
+	TLSRequired bool `json:"tls_required"`
+
+	// RequestTimeout bounds each unary call made by the client.
+	RequestTimeout Duration `json:"request_timeout"`
+	// FailFast makes the client connect at startup and exit if the server
+	// is unreachable within DialTimeout, instead of failing on the first call.
+	FailFast    bool     `json:"fail_fast"`
+	DialTimeout Duration `json:"dial_timeout"`
+
+	// LogLevel is one of debug, info, warn or error.
+	LogLevel string `json:"log_level"`
+	// LogBannerStyle is BannerStyleDecorated to prefix the startup banner
+	// with emojis, or BannerStylePlain for ASCII-only messages.
+	LogBannerStyle string `json:"log_banner_style"`
+	// LogMetadata makes every handler log each incoming metadata key at debug
+	// level. It is noisy and costly under load, so keep it off in production.
+	LogMetadata bool `json:"log_metadata"`
+	// LogAccessFormat is AccessLogOff, AccessLogJSON to log every REST
+	// request as a JSON record alongside the other logs, or
+	// AccessLogCombined to write Apache Combined Log Format lines instead.
+	LogAccessFormat string `json:"log_access_format"`
+	// RedactedMetadataKeys lists incoming metadata keys whose values are
+	// logged as [REDACTED]. The environment variable is comma-separated.
+	RedactedMetadataKeys []string `json:"redacted_metadata_keys"`
+
+	// AdminAPIKey must be sent in the X-API-Key header of every /admin
+	// request. Empty disables the admin routes.
+	AdminAPIKey string `json:"admin_api_key"`
+	// APIKey, when set, must be sent as x-api-key metadata on every gRPC
+	// call except health checks and reflection, and the client sends it.
+	// Empty leaves gRPC calls unauthenticated.
+	APIKey string `json:"api_key"`
+	// AuthToken, when set, is sent by the client as an
+	// "authorization: Bearer <token>" header on every gRPC call. The server
+	// accepts its APIKey in that form too.
+	AuthToken string `json:"auth_token"`
+	// IPAllowList, when set, limits gRPC calls and REST requests to peers
+	// whose IP address is in one of its CIDR ranges, e.g. "10.0.0.0/8"; a
+	// bare IP address stands for itself. Peers in an IPDenyList range are
+	// rejected even when allowed. Rejected gRPC calls fail with
+	// PermissionDenied and REST requests with 403. The environment variables
+	// are comma-separated.
+	IPAllowList []string `json:"ip_allow_list"`
+	IPDenyList  []string `json:"ip_deny_list"`
+
+	// EnableReflection registers the gRPC reflection service.
+	EnableReflection bool `json:"enable_reflection"`
+	// EnableFaultInjection lets callers make SayHello fail or respond late
+	// with the inject-error and inject-delay-ms metadata keys. Keep it off
+	// in production.
+	EnableFaultInjection bool `json:"enable_fault_injection"`
+
+	// ServiceName and ServiceVersion identify the server in the startup
+	// banner and the welcome message served at /. ServiceVersion is also
+	// reported by /health, /api/doc, /openapi.json and the server-version
+	// trailer of SayHello.
+	ServiceName    string `json:"service_name"`
+	ServiceVersion string `json:"service_version"`
+
+	// EnableHello and EnableGoodbye register the Greeter and Farewell
+	// services with their REST routes. A disabled service is not served at
+	// all: its gRPC calls fail with Unimplemented and its routes 404. At
+	// least one must be enabled.
+	EnableHello   bool `json:"enable_hello"`
+	EnableGoodbye bool `json:"enable_goodbye"`
+
+	// GreetingStyle picks the SayHello message format: plain, enthusiastic
+	// or time-of-day. Callers can override it per call with the
+	// greeting-style metadata key.
+	GreetingStyle string `json:"greeting_style"`
+	// CoalesceGreetings makes concurrent SayHello calls for the same name
+	// share one greeting, for greeters expensive enough to be worth it.
+	CoalesceGreetings bool `json:"coalesce_greetings"`
+	// HelloStreamDelay is the pause between SayHelloStream messages unless
+	// the caller sets stream-delay-ms, and HelloBidiDelay the pause after
+	// each SayHelloBidirectional reply. Zero, e.g. in CI, sends without
+	// pausing.
+	HelloStreamDelay Duration `json:"hello_stream_delay"`
+	HelloBidiDelay   Duration `json:"hello_bidi_delay"`
+
+	// BlockedNames lists names SayGoodbyeWithReason refuses, compared
+	// case-insensitively. The environment variable is comma-separated.
+	BlockedNames []string `json:"blocked_names"`
+	// GoodbyeTemplate is a text/template for the SayGoodbye and
+	// SayGoodbyeWithReason replies, with {{.Name}} and {{.Reason}}
+	// placeholders, e.g. "Farewell, {{.Name}}!". It must include {{.Name}}
+	// and is checked at startup. Empty keeps the built-in farewell.
+	GoodbyeTemplate string `json:"goodbye_template"`
+	// GoodbyeInterval is the pause between the messages of
+	// SayGoodbyeUntilCancelled, which streams until the client cancels.
+	GoodbyeInterval Duration `json:"goodbye_interval"`
+	// GoodbyeStreamDelay is the pause between SayGoodbyeStream messages;
+	// zero sends them without pausing.
+	GoodbyeStreamDelay Duration `json:"goodbye_stream_delay"`
+
+	// HistoryStore records every unary greeting and farewell, served at
+	// GET /api/history: "memory" keeps the latest HistorySize records,
+	// "sqlite" persists them all in the database at HistorySQLitePath and
+	// needs a binary built with -tags sqlite. Empty disables history.
+	HistoryStore      string `json:"history_store"`
+	HistorySQLitePath string `json:"history_sqlite_path"`
+	HistorySize       int    `json:"history_size"`
+
+	// DisabledInterceptors names server interceptors to leave out of the
+	// chain, e.g. "logging". The environment variable is comma-separated.
+	DisabledInterceptors []string `json:"disabled_interceptors"`
+	// RequiredMetadataKeys lists metadata keys, e.g. "tenant-id", that every
+	// gRPC call must carry; calls missing one fail with InvalidArgument. The
+	// environment variable is comma-separated.
+	RequiredMetadataKeys []string `json:"required_metadata_keys"`
+
+	// MethodTimeouts caps how long a method may run on the server, whatever
+	// deadline the client sent, keyed by full ("/grpc.hello.Greeter/SayHello")
+	// or bare ("SayHello") method name. The environment variable is a
+	// comma-separated list of method=duration pairs.
+	MethodTimeouts map[string]Duration `json:"method_timeouts"`
+	// SlowCallThreshold is the latency above which a unary call is logged
+	// as slow and counted, apart from the access log; zero disables it.
+	SlowCallThreshold Duration `json:"slow_call_threshold"`
+	// MinDeadline is the least time a client deadline should leave a call;
+	// tighter deadlines are logged as warnings, and rejected with
+	// InvalidArgument when RejectShortDeadlines is set. Zero disables the
+	// check.
+	MinDeadline          Duration `json:"min_deadline"`
+	RejectShortDeadlines bool     `json:"reject_short_deadlines"`
+
+	// IdempotencyTTL is how long the reply to a unary call carrying an
+	// idempotency-key is replayed to retries with the same key.
+	// IdempotencyCacheSize bounds the number of replies kept; the least
+	// recently used is evicted first.
+	IdempotencyTTL       Duration `json:"idempotency_ttl"`
+	IdempotencyCacheSize int      `json:"idempotency_cache_size"`
+
+	// MaxRecvMsgSize caps the size in bytes of a gRPC message the server
+	// accepts; larger messages fail with ResourceExhausted.
+	MaxRecvMsgSize int `json:"max_recv_msg_size"`
+	// MaxConcurrentStreams caps the streams, and so the calls, one HTTP/2
+	// connection may have open at once. Clients learn the limit when they
+	// connect; gRPC clients queue calls beyond it until a stream finishes,
+	// and streams a client opens regardless are refused.
+	MaxConcurrentStreams uint32 `json:"max_concurrent_streams"`
+	// MaxStreamedNames and MaxStreamedBytes cap the names, and their total
+	// encoded size, that one SayHelloClientStream call may send before its
+	// summary; a stream that sends more fails with ResourceExhausted.
+	MaxStreamedNames int `json:"max_streamed_names"`
+	MaxStreamedBytes int `json:"max_streamed_bytes"`
+	// MaxHTTPBodyBytes caps REST request bodies; larger bodies get a 413.
+	MaxHTTPBodyBytes int64 `json:"max_http_body_bytes"`
+	// MaxHTTPHeaderBytes caps the request headers of both protocols; REST
+	// requests with larger headers get a 431.
+	MaxHTTPHeaderBytes int `json:"max_http_header_bytes"`
+
+	// HTTPReadHeaderTimeout bounds how long a new connection may take to
+	// send its request headers, cutting off slow-loris clients on both
+	// protocols. HTTPReadTimeout and HTTPWriteTimeout bound reading the body
+	// of and writing the response to each REST request; gRPC calls and the
+	// SSE stream are exempt so long-lived streams survive. HTTPIdleTimeout
+	// closes keep-alive connections with no requests in flight.
+	HTTPReadHeaderTimeout Duration `json:"http_read_header_timeout"`
+	HTTPReadTimeout       Duration `json:"http_read_timeout"`
+	HTTPWriteTimeout      Duration `json:"http_write_timeout"`
+	HTTPIdleTimeout       Duration `json:"http_idle_timeout"`
+
+	// HTTPRouteTimeouts caps how long the gRPC method behind a REST route
+	// may run, keyed by the route's path ("/api/hello", "/v1/goodbye").
+	// Routes not listed get HTTPBackendTimeout; zero leaves them unbounded.
+	// A call past its limit is answered with 504 Gateway Timeout. The
+	// environment variable is a comma-separated list of route=duration pairs.
+	HTTPRouteTimeouts  map[string]Duration `json:"http_route_timeouts"`
+	HTTPBackendTimeout Duration            `json:"http_backend_timeout"`
+
+	// HTTPCacheTTL is how long GET /api/hello and GET /api/goodbye
+	// responses are served from an in-memory cache; zero, the default,
+	// disables caching.
+	// HTTPCacheSize bounds the number of responses kept.
+	HTTPCacheTTL  Duration `json:"http_cache_ttl"`
+	HTTPCacheSize int      `json:"http_cache_size"`
+
+	// CORSAllowedOrigins lists the browser origins allowed to call the REST
+	// API; "*" allows any. CORSAllowedMethods and CORSAllowedHeaders are
+	// returned to preflight requests. The environment variables are
+	// comma-separated.
+	CORSAllowedOrigins []string `json:"cors_allowed_origins"`
+	CORSAllowedMethods []string `json:"cors_allowed_methods"`
+	CORSAllowedHeaders []string `json:"cors_allowed_headers"`
+	// CORSAllowCredentials lets browsers send cookies and auth headers
+	// cross-origin. It cannot be combined with the "*" origin.
+	CORSAllowCredentials bool `json:"cors_allow_credentials"`
+}
+
+// Version is the default ServiceVersion. Release builds set it with
+//
+//	go build -ldflags "-X grpc-sample/config.Version=1.2.3" ./server
+var Version = "1.0.0"
+
+// Startup banner styles for LogBannerStyle.
+const (
+	BannerStyleDecorated = "decorated"
+	BannerStylePlain     = "plain"
+)
+
+// REST access log formats for LogAccessFormat.
+const (
+	AccessLogOff      = "off"
+	AccessLogJSON     = "json"
+	AccessLogCombined = "combined"
+)
+
+// Duration is a time.Duration that reads and writes JSON as a Go duration
+// string such as "1s" or "500ms".
+type Duration struct {
+	time.Duration
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() Config {
+	return Config{
+		Port:                  "50051",
+		ServerAddress:         "localhost:50051",
+		TLSReloadInterval:     Duration{10 * time.Second},
+		TLSMinVersion:         "1.2",
+		RequestTimeout:        Duration{time.Second},
+		DialTimeout:           Duration{5 * time.Second},
+		LogLevel:              "info",
+		LogBannerStyle:        BannerStyleDecorated,
+		LogAccessFormat:       AccessLogOff,
+		ServiceName:           "gRPC Sample Server",
+		ServiceVersion:        Version,
+		RedactedMetadataKeys:  []string{"authorization", "x-api-key", "cookie", "proxy-authorization"},
+		EnableReflection:      true,
+		EnableHello:           true,
+		EnableGoodbye:         true,
+		GreetingStyle:         "plain",
+		HelloStreamDelay:      Duration{time.Second},
+		HelloBidiDelay:        Duration{500 * time.Millisecond},
+		GoodbyeInterval:       Duration{time.Second},
+		GoodbyeStreamDelay:    Duration{1500 * time.Millisecond},
+		HistorySQLitePath:     "history.db",
+		HistorySize:           1000,
+		SlowCallThreshold:     Duration{time.Second},
+		IdempotencyTTL:        Duration{5 * time.Minute},
+		IdempotencyCacheSize:  1000,
+		MaxRecvMsgSize:        4 << 20,
+		MaxConcurrentStreams:  100,
+		MaxStreamedNames:      10000,
+		MaxStreamedBytes:      1 << 20,
+		MaxHTTPBodyBytes:      1 << 20,
+		MaxHTTPHeaderBytes:    1 << 20,
+		HTTPReadHeaderTimeout: Duration{10 * time.Second},
+		HTTPReadTimeout:       Duration{30 * time.Second},
+		HTTPWriteTimeout:      Duration{30 * time.Second},
+		HTTPIdleTimeout:       Duration{2 * time.Minute},
+		HTTPCacheSize:         1000,
+		CORSAllowedOrigins:    []string{"*"},
+		CORSAllowedMethods:    []string{"GET", "POST", "OPTIONS"},
+		CORSAllowedHeaders:    []string{"Content-Type", "X-Request-ID"},
+	}
+}
+
+// Load builds the configuration from the defaults, the JSON file at path (if
+// path is non-empty) and the environment, then validates the result.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides fields with any environment variables that are set.
+func (c *Config) applyEnv() error {
+	lookupString("GRPC_PORT", &c.Port)
+	lookupString("GRPC_LISTEN_ADDR", &c.ListenAddr)
+	lookupString("HTTP_PORT", &c.HTTPPort)
+	lookupString("GRPC_SERVER_ADDRESS", &c.ServerAddress)
+	lookupString("GRPC_TLS_CERT_FILE", &c.TLSCertFile)
+	lookupString("GRPC_TLS_KEY_FILE", &c.TLSKeyFile)
+	lookupString("GRPC_TLS_CA_FILE", &c.TLSCAFile)
+	lookupString("GRPC_TLS_MIN_VERSION", &c.TLSMinVersion)
+	lookupString("LOG_LEVEL", &c.LogLevel)
+	lookupString("LOG_BANNER_STYLE", &c.LogBannerStyle)
+	lookupString("LOG_ACCESS_FORMAT", &c.LogAccessFormat)
+	if err := lookupBool("LOG_METADATA", &c.LogMetadata); err != nil {
+		return err
+	}
+	lookupString("SERVICE_NAME", &c.ServiceName)
+	lookupString("SERVICE_VERSION", &c.ServiceVersion)
+	lookupString("ADMIN_API_KEY", &c.AdminAPIKey)
+	lookupString("GRPC_API_KEY", &c.APIKey)
+	lookupString("GRPC_AUTH_TOKEN", &c.AuthToken)
+	lookupList("IP_ALLOW_LIST", &c.IPAllowList)
+	lookupList("IP_DENY_LIST", &c.IPDenyList)
+	if err := lookupBool("ENABLE_HELLO", &c.EnableHello); err != nil {
+		return err
+	}
+	if err := lookupBool("ENABLE_GOODBYE", &c.EnableGoodbye); err != nil {
+		return err
+	}
+	lookupString("GREETING_STYLE", &c.GreetingStyle)
+	if err := lookupBool("GREETING_COALESCE", &c.CoalesceGreetings); err != nil {
+		return err
+	}
+	if err := lookupDuration("HELLO_STREAM_DELAY", &c.HelloStreamDelay.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("HELLO_BIDI_DELAY", &c.HelloBidiDelay.Duration); err != nil {
+		return err
+	}
+	lookupList("GOODBYE_BLOCKED_NAMES", &c.BlockedNames)
+	lookupString("GOODBYE_TEMPLATE", &c.GoodbyeTemplate)
+	if err := lookupDuration("GOODBYE_INTERVAL", &c.GoodbyeInterval.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("GOODBYE_STREAM_DELAY", &c.GoodbyeStreamDelay.Duration); err != nil {
+		return err
+	}
+	lookupString("HISTORY_STORE", &c.HistoryStore)
+	lookupString("HISTORY_SQLITE_PATH", &c.HistorySQLitePath)
+	if err := lookupInt("HISTORY_SIZE", &c.HistorySize); err != nil {
+		return err
+	}
+
+	if err := lookupDuration("GRPC_TLS_RELOAD_INTERVAL", &c.TLSReloadInterval.Duration); err != nil {
+		return err
+	}
+	if err := lookupBool("GRPC_TLS_REQUIRED", &c.TLSRequired); err != nil {
+		return err
+	}
+	if err := lookupDuration("GRPC_REQUEST_TIMEOUT", &c.RequestTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("GRPC_DIAL_TIMEOUT", &c.DialTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupBool("GRPC_FAIL_FAST", &c.FailFast); err != nil {
+		return err
+	}
+	if err := lookupBool("GRPC_ENABLE_REFLECTION", &c.EnableReflection); err != nil {
+		return err
+	}
+	if err := lookupBool("GRPC_ENABLE_FAULT_INJECTION", &c.EnableFaultInjection); err != nil {
+		return err
+	}
+	lookupList("GRPC_DISABLED_INTERCEPTORS", &c.DisabledInterceptors)
+	lookupList("GRPC_REQUIRED_METADATA_KEYS", &c.RequiredMetadataKeys)
+	if err := lookupDurationMap("GRPC_METHOD_TIMEOUTS", &c.MethodTimeouts); err != nil {
+		return err
+	}
+	if err := lookupDuration("GRPC_SLOW_CALL_THRESHOLD", &c.SlowCallThreshold.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("GRPC_MIN_DEADLINE", &c.MinDeadline.Duration); err != nil {
+		return err
+	}
+	if err := lookupBool("GRPC_REJECT_SHORT_DEADLINES", &c.RejectShortDeadlines); err != nil {
+		return err
+	}
+	lookupList("LOG_REDACTED_METADATA_KEYS", &c.RedactedMetadataKeys)
+	if err := lookupDuration("GRPC_IDEMPOTENCY_TTL", &c.IdempotencyTTL.Duration); err != nil {
+		return err
+	}
+	if err := lookupInt("GRPC_IDEMPOTENCY_CACHE_SIZE", &c.IdempotencyCacheSize); err != nil {
+		return err
+	}
+	if err := lookupInt("GRPC_MAX_RECV_MSG_SIZE", &c.MaxRecvMsgSize); err != nil {
+		return err
+	}
+	if err := lookupUint32("GRPC_MAX_CONCURRENT_STREAMS", &c.MaxConcurrentStreams); err != nil {
+		return err
+	}
+	if err := lookupInt("GRPC_MAX_STREAMED_NAMES", &c.MaxStreamedNames); err != nil {
+		return err
+	}
+	if err := lookupInt("GRPC_MAX_STREAMED_BYTES", &c.MaxStreamedBytes); err != nil {
+		return err
+	}
+	if err := lookupInt64("HTTP_MAX_BODY_BYTES", &c.MaxHTTPBodyBytes); err != nil {
+		return err
+	}
+	if err := lookupInt("HTTP_MAX_HEADER_BYTES", &c.MaxHTTPHeaderBytes); err != nil {
+		return err
+	}
+	if err := lookupDuration("HTTP_READ_HEADER_TIMEOUT", &c.HTTPReadHeaderTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("HTTP_READ_TIMEOUT", &c.HTTPReadTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("HTTP_WRITE_TIMEOUT", &c.HTTPWriteTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("HTTP_IDLE_TIMEOUT", &c.HTTPIdleTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupDuration("HTTP_BACKEND_TIMEOUT", &c.HTTPBackendTimeout.Duration); err != nil {
+		return err
+	}
+	if err := lookupDurationMap("HTTP_ROUTE_TIMEOUTS", &c.HTTPRouteTimeouts); err != nil {
+		return err
+	}
+	if err := lookupDuration("HTTP_CACHE_TTL", &c.HTTPCacheTTL.Duration); err != nil {
+		return err
+	}
+	if err := lookupInt("HTTP_CACHE_SIZE", &c.HTTPCacheSize); err != nil {
+		return err
+	}
+	lookupList("CORS_ALLOWED_ORIGINS", &c.CORSAllowedOrigins)
+	lookupList("CORS_ALLOWED_METHODS", &c.CORSAllowedMethods)
+	lookupList("CORS_ALLOWED_HEADERS", &c.CORSAllowedHeaders)
+	if err := lookupBool("CORS_ALLOW_CREDENTIALS", &c.CORSAllowCredentials); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting, if any.
+func (c Config) Validate() error {
+	port, err := strconv.Atoi(c.Port)
+	if err != nil || port < 0 || port > 65535 {
+		return fmt.Errorf("invalid port %q: must be between 0 and 65535", c.Port)
+	}
+	if c.ListenAddr != "" {
+		_, listenPort, err := net.SplitHostPort(c.ListenAddr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", c.ListenAddr, err)
+		}
+		if port, err := strconv.Atoi(listenPort); err != nil || port < 0 || port > 65535 {
+			return fmt.Errorf("invalid listen address %q: port must be between 0 and 65535", c.ListenAddr)
+		}
+	}
+	if c.HTTPPort != "" {
+		httpPort, err := strconv.Atoi(c.HTTPPort)
+		if err != nil || httpPort < 0 || httpPort > 65535 {
+			return fmt.Errorf("invalid HTTP port %q: must be between 0 and 65535", c.HTTPPort)
+		}
+		_, listenPort, _ := net.SplitHostPort(c.ListenAddress())
+		if grpcPort, _ := strconv.Atoi(listenPort); httpPort != 0 && httpPort == grpcPort {
+			return fmt.Errorf("invalid HTTP port %q: must differ from the gRPC port", c.HTTPPort)
+		}
+	}
+	if !c.EnableHello && !c.EnableGoodbye {
+		return fmt.Errorf("at least one of the hello and goodbye services must be enabled")
+	}
+	if c.ServerAddress == "" {
+		return fmt.Errorf("server address must not be empty")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS cert file and key file must be set together")
+	}
+	if c.TLSRequired && !c.TLSEnabled() {
+		return fmt.Errorf("TLS required but no TLS cert file and key file are set: every gRPC call would be rejected")
+	}
+	if c.TLSReloadInterval.Duration <= 0 {
+		return fmt.Errorf("invalid TLS reload interval %s: must be positive", c.TLSReloadInterval)
+	}
+	if _, ok := tlsVersions[c.TLSMinVersion]; !ok {
+		return fmt.Errorf("invalid TLS min version %q: must be 1.0, 1.1, 1.2 or 1.3", c.TLSMinVersion)
+	}
+	for _, r := range append(append([]string(nil), c.IPAllowList...), c.IPDenyList...) {
+		if !validIPRange(r) {
+			return fmt.Errorf("invalid IP range %q: want a CIDR such as 10.0.0.0/8 or an IP address", r)
+		}
+	}
+	if c.RequestTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid request timeout %s: must be positive", c.RequestTimeout)
+	}
+	if c.DialTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid dial timeout %s: must be positive", c.DialTimeout)
+	}
+	if c.HistoryStore != "" && c.HistoryStore != "memory" && c.HistoryStore != "sqlite" {
+		return fmt.Errorf("invalid history store %q: must be memory or sqlite", c.HistoryStore)
+	}
+	if c.HistoryStore == "sqlite" && c.HistorySQLitePath == "" {
+		return fmt.Errorf("history store sqlite needs a database path")
+	}
+	if c.HistorySize <= 0 {
+		return fmt.Errorf("invalid history size %d: must be positive", c.HistorySize)
+	}
+	methods := make([]string, 0, len(c.MethodTimeouts))
+	for method := range c.MethodTimeouts {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		if timeout := c.MethodTimeouts[method]; method == "" || timeout.Duration <= 0 {
+			return fmt.Errorf("invalid method timeout %q=%s: needs a method name and a positive duration", method, timeout)
+		}
+	}
+	if c.GoodbyeInterval.Duration <= 0 {
+		return fmt.Errorf("invalid goodbye interval %s: must be positive", c.GoodbyeInterval)
+	}
+	if c.HelloStreamDelay.Duration < 0 {
+		return fmt.Errorf("invalid hello stream delay %s: must not be negative", c.HelloStreamDelay)
+	}
+	if c.HelloBidiDelay.Duration < 0 {
+		return fmt.Errorf("invalid hello bidi delay %s: must not be negative", c.HelloBidiDelay)
+	}
+	if c.GoodbyeStreamDelay.Duration < 0 {
+		return fmt.Errorf("invalid goodbye stream delay %s: must not be negative", c.GoodbyeStreamDelay)
+	}
+	if c.SlowCallThreshold.Duration < 0 {
+		return fmt.Errorf("invalid slow call threshold %s: must not be negative", c.SlowCallThreshold)
+	}
+	if c.MinDeadline.Duration < 0 {
+		return fmt.Errorf("invalid minimum deadline %s: must not be negative", c.MinDeadline)
+	}
+	if c.RejectShortDeadlines && c.MinDeadline.Duration == 0 {
+		return fmt.Errorf("reject short deadlines needs a minimum deadline")
+	}
+	if c.IdempotencyTTL.Duration <= 0 {
+		return fmt.Errorf("invalid idempotency TTL %s: must be positive", c.IdempotencyTTL)
+	}
+	if c.IdempotencyCacheSize <= 0 {
+		return fmt.Errorf("invalid idempotency cache size %d: must be positive", c.IdempotencyCacheSize)
+	}
+	if c.MaxRecvMsgSize <= 0 {
+		return fmt.Errorf("invalid max receive message size %d: must be positive", c.MaxRecvMsgSize)
+	}
+	if c.MaxConcurrentStreams == 0 {
+		return fmt.Errorf("invalid max concurrent streams %d: must be positive", c.MaxConcurrentStreams)
+	}
+	if c.MaxStreamedNames <= 0 {
+		return fmt.Errorf("invalid max streamed names %d: must be positive", c.MaxStreamedNames)
+	}
+	if c.MaxStreamedBytes <= 0 {
+		return fmt.Errorf("invalid max streamed bytes %d: must be positive", c.MaxStreamedBytes)
+	}
+	if c.MaxHTTPBodyBytes <= 0 {
+		return fmt.Errorf("invalid max HTTP body size %d: must be positive", c.MaxHTTPBodyBytes)
+	}
+	if c.MaxHTTPHeaderBytes <= 0 {
+		return fmt.Errorf("invalid max HTTP header size %d: must be positive", c.MaxHTTPHeaderBytes)
+	}
+	if c.HTTPReadHeaderTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid HTTP read header timeout %s: must be positive", c.HTTPReadHeaderTimeout)
+	}
+	if c.HTTPReadTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid HTTP read timeout %s: must be positive", c.HTTPReadTimeout)
+	}
+	if c.HTTPWriteTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid HTTP write timeout %s: must be positive", c.HTTPWriteTimeout)
+	}
+	if c.HTTPIdleTimeout.Duration <= 0 {
+		return fmt.Errorf("invalid HTTP idle timeout %s: must be positive", c.HTTPIdleTimeout)
+	}
+	if c.HTTPBackendTimeout.Duration < 0 {
+		return fmt.Errorf("invalid HTTP backend timeout %s: must not be negative", c.HTTPBackendTimeout)
+	}
+	routes := make([]string, 0, len(c.HTTPRouteTimeouts))
+	for route := range c.HTTPRouteTimeouts {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		if timeout := c.HTTPRouteTimeouts[route]; !strings.HasPrefix(route, "/") || timeout.Duration <= 0 {
+			return fmt.Errorf("invalid HTTP route timeout %q=%s: needs a route path and a positive duration", route, timeout)
+		}
+	}
+	if c.HTTPCacheTTL.Duration < 0 {
+		return fmt.Errorf("invalid HTTP cache TTL %s: must not be negative", c.HTTPCacheTTL)
+	}
+	if c.HTTPCacheSize <= 0 {
+		return fmt.Errorf("invalid HTTP cache size %d: must be positive", c.HTTPCacheSize)
+	}
+	if c.CORSAllowCredentials {
+		for _, origin := range c.CORSAllowedOrigins {
+			if origin == "*" {
+				return fmt.Errorf("CORS credentials require explicit allowed origins, not %q", origin)
+			}
+		}
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		return fmt.Errorf("invalid log level %q: %w", c.LogLevel, err)
+	}
+	if c.LogBannerStyle != BannerStyleDecorated && c.LogBannerStyle != BannerStylePlain {
+		return fmt.Errorf("invalid log banner style %q: must be %s or %s", c.LogBannerStyle, BannerStyleDecorated, BannerStylePlain)
+	}
+	switch c.LogAccessFormat {
+	case AccessLogOff, AccessLogJSON, AccessLogCombined:
+	default:
+		return fmt.Errorf("invalid access log format %q: must be %s, %s or %s", c.LogAccessFormat, AccessLogOff, AccessLogJSON, AccessLogCombined)
+	}
+	return nil
+}
+
+// RedactedValue replaces secrets in Redacted.
+const RedactedValue = "[REDACTED]"
+
+// Redacted returns a copy of c that is safe to show operators, with the
+// secrets AdminAPIKey, APIKey and AuthToken replaced by RedactedValue. File
+// paths, including TLSKeyFile, are kept since they reveal where a secret is,
+// not what it is.
+func (c Config) Redacted() Config {
+	if c.AdminAPIKey != "" {
+		c.AdminAPIKey = RedactedValue
+	}
+	if c.APIKey != "" {
+		c.APIKey = RedactedValue
+	}
+	if c.AuthToken != "" {
+		c.AuthToken = RedactedValue
+	}
+	return c
+}
+
+// SlogLevel returns LogLevel as a slog.Level. It assumes Validate passed.
+func (c Config) SlogLevel() slog.Level {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
+
+// ListenAddress returns the address the server listens on: ListenAddr when
+// set, otherwise Port on every interface.
+func (c Config) ListenAddress() string {
+	if c.ListenAddr != "" {
+		return c.ListenAddr
+	}
+	return ":" + c.Port
+}
+
+// SplitPorts reports whether gRPC and REST are served on separate ports.
+func (c Config) SplitPorts() bool {
+	return c.HTTPPort != ""
+}
+
+// HTTPListenAddress returns the address REST is served on: HTTPPort on the
+// host of ListenAddr in split-port mode, otherwise ListenAddress.
+func (c Config) HTTPListenAddress() string {
+	if !c.SplitPorts() {
+		return c.ListenAddress()
+	}
+	host, _, _ := net.SplitHostPort(c.ListenAddress())
+	return net.JoinHostPort(host, c.HTTPPort)
+}
+
+// TLSEnabled reports whether the server should serve TLS.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// tlsVersions maps the TLSMinVersion values to crypto/tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// MinTLSVersion returns TLSMinVersion as a crypto/tls version such as
+// tls.VersionTLS12. It assumes Validate passed.
+func (c Config) MinTLSVersion() uint16 {
+	return tlsVersions[c.TLSMinVersion]
+}
+
+// validIPRange reports whether r is a CIDR range or a bare IP address.
+func validIPRange(r string) bool {
+	if _, err := netip.ParsePrefix(r); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(r)
+	return err == nil
+}
+
+// The lookup helpers treat empty environment variables as unset.
+
+func lookupString(key string, dst *string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+func lookupList(key string, dst *[]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dst = list
+}
+
+// lookupDurationMap parses a comma-separated list of name=duration pairs.
+func lookupDurationMap(key string, dst *map[string]Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed := map[string]Duration{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("invalid %s entry %q: want name=duration", key, item)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("invalid %s entry %q: %w", key, item, err)
+		}
+		parsed[strings.TrimSpace(name)] = Duration{d}
+	}
+	*dst = parsed
+	return nil
+}
+
+func lookupInt(key string, dst *int) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", key, value, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func lookupInt64(key string, dst *int64) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", key, value, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func lookupUint32(key string, dst *uint32) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", key, value, err)
+	}
+	*dst = uint32(parsed)
+	return nil
+}
+
+func lookupBool(key string, dst *bool) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", key, value, err)
+	}
+	*dst = parsed
+	return nil
+}
+
+func lookupDuration(key string, dst *time.Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %w", key, value, err)
+	}
+	*dst = parsed
+	return nil
+}
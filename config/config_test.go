@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes a JSON config file with contents and returns its
+// path.
+func writeConfigFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{"port": "6000", "service_name": "From File", "request_timeout": "2s"}`)
+	t.Setenv("GRPC_PORT", "7000")
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.Port != "7000" {
+		t.Errorf("Port = %q, want the environment's 7000", c.Port)
+	}
+	if c.ServiceName != "From File" || c.RequestTimeout.Duration != 2*time.Second {
+		t.Errorf("ServiceName, RequestTimeout = %q, %s, want the file's From File, 2s", c.ServiceName, c.RequestTimeout.Duration)
+	}
+	if want := Default().LogLevel; c.LogLevel != want {
+		t.Errorf("LogLevel = %q, want the default %q", c.LogLevel, want)
+	}
+}
+
+func TestLoadRejectsBadInput(t *testing.T) {
+	if _, err := Load(writeConfigFile(t, `{"port": 6000}`)); err == nil {
+		t.Error("Load accepted a config file with a numeric port")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Load accepted a missing config file")
+	}
+	t.Setenv("GRPC_REQUEST_TIMEOUT", "soon")
+	if _, err := Load(""); err == nil {
+		t.Error("Load accepted GRPC_REQUEST_TIMEOUT=soon")
+	}
+}
+
+func TestRedactedHidesEverySecret(t *testing.T) {
+	c := Default()
+	c.AdminAPIKey = "admin-secret"
+	c.APIKey = "grpc-secret"
+	c.AuthToken = "token-secret"
+	c.TLSKeyFile = "/etc/tls/key.pem"
+
+	data, err := json.Marshal(c.Redacted())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	for _, secret := range []string{"admin-secret", "grpc-secret", "token-secret"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("redacted config %s contains %q", data, secret)
+		}
+	}
+	if !strings.Contains(string(data), "/etc/tls/key.pem") {
+		t.Errorf("redacted config %s lost the TLS key path", data)
+	}
+	if c.AdminAPIKey != "admin-secret" {
+		t.Error("Redacted modified the original config")
+	}
+}
+
+func TestRedactedLeavesUnsetSecretsEmpty(t *testing.T) {
+	r := Default().Redacted()
+	if r.AdminAPIKey != "" || r.APIKey != "" || r.AuthToken != "" {
+		t.Errorf("unset secrets redacted to %q, %q, %q, want them left empty", r.AdminAPIKey, r.APIKey, r.AuthToken)
+	}
+}
+
+func TestLoadRouteTimeouts(t *testing.T) {
+	t.Setenv("HTTP_ROUTE_TIMEOUTS", "/api/hello=2s, /api/goodbye=5s")
+	c, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := map[string]Duration{"/api/hello": {2 * time.Second}, "/api/goodbye": {5 * time.Second}}
+	if len(c.HTTPRouteTimeouts) != len(want) {
+		t.Fatalf("HTTPRouteTimeouts = %v, want %v", c.HTTPRouteTimeouts, want)
+	}
+	for route, d := range want {
+		if got := c.HTTPRouteTimeouts[route]; got != d {
+			t.Errorf("HTTPRouteTimeouts[%s] = %s, want %s", route, got, d)
+		}
+	}
+
+	for _, bad := range []string{"/api/hello", "/api/hello=soon", "api/hello=2s", "/api/hello=0s"} {
+		t.Setenv("HTTP_ROUTE_TIMEOUTS", bad)
+		if _, err := Load(""); err == nil {
+			t.Errorf("Load accepted HTTP_ROUTE_TIMEOUTS=%s", bad)
+		}
+	}
+}
+
+func TestServiceVersionDefaultsToBuildVersion(t *testing.T) {
+	previous := Version
+	Version = "2.0.0-test"
+	t.Cleanup(func() { Version = previous })
+
+	if got := Default().ServiceVersion; got != "2.0.0-test" {
+		t.Errorf("default ServiceVersion = %q, want the build's 2.0.0-test", got)
+	}
+	t.Setenv("SERVICE_VERSION", "3.1.4")
+	c, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if c.ServiceVersion != "3.1.4" {
+		t.Errorf("ServiceVersion = %q, want SERVICE_VERSION's 3.1.4", c.ServiceVersion)
+	}
+}
+
+func TestTLSRequiredNeedsACertificate(t *testing.T) {
+	t.Setenv("GRPC_TLS_REQUIRED", "true")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "TLS required") {
+		t.Errorf("Load with GRPC_TLS_REQUIRED and no certificate = %v, want a TLS required error", err)
+	}
+
+	t.Setenv("GRPC_TLS_CERT_FILE", "/etc/tls/cert.pem")
+	t.Setenv("GRPC_TLS_KEY_FILE", "/etc/tls/key.pem")
+	c, err := Load("")
+	if err != nil {
+		t.Fatalf("Load with GRPC_TLS_REQUIRED and a certificate: %v", err)
+	}
+	if !c.TLSRequired {
+		t.Error("TLSRequired = false, want GRPC_TLS_REQUIRED's true")
+	}
+}
@@ -22,9 +22,11 @@ const (
 )
 
 // The request message containing the user's name.
+// Field rules are enforced by the server (service/validation.go).
 type GoodbyeRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Required, 1-100 characters
+	Name          string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -66,6 +68,62 @@ func (x *GoodbyeRequest) GetName() string {
 	return ""
 }
 
+// The request message for SayGoodbyeWithReason.
+// Field rules are enforced by the server (service/validation.go).
+type GoodbyeWithReasonRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Required, 1-100 characters
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Optional, at most 200 characters
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GoodbyeWithReasonRequest) Reset() {
+	*x = GoodbyeWithReasonRequest{}
+	mi := &file_proto_goodbye_goodbye_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GoodbyeWithReasonRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GoodbyeWithReasonRequest) ProtoMessage() {}
+
+func (x *GoodbyeWithReasonRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_goodbye_goodbye_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GoodbyeWithReasonRequest.ProtoReflect.Descriptor instead.
+func (*GoodbyeWithReasonRequest) Descriptor() ([]byte, []int) {
+	return file_proto_goodbye_goodbye_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GoodbyeWithReasonRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GoodbyeWithReasonRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 // The response message containing the goodbye message.
 type GoodbyeReply struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -76,7 +134,7 @@ type GoodbyeReply struct {
 
 func (x *GoodbyeReply) Reset() {
 	*x = GoodbyeReply{}
-	mi := &file_proto_goodbye_goodbye_proto_msgTypes[1]
+	mi := &file_proto_goodbye_goodbye_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -88,7 +146,7 @@ func (x *GoodbyeReply) String() string {
 func (*GoodbyeReply) ProtoMessage() {}
 
 func (x *GoodbyeReply) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_goodbye_goodbye_proto_msgTypes[1]
+	mi := &file_proto_goodbye_goodbye_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -101,7 +159,7 @@ func (x *GoodbyeReply) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GoodbyeReply.ProtoReflect.Descriptor instead.
 func (*GoodbyeReply) Descriptor() ([]byte, []int) {
-	return file_proto_goodbye_goodbye_proto_rawDescGZIP(), []int{1}
+	return file_proto_goodbye_goodbye_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *GoodbyeReply) GetMessage() string {
@@ -117,15 +175,20 @@ const file_proto_goodbye_goodbye_proto_rawDesc = "" +
 	"\n" +
 	"\x1bproto/goodbye/goodbye.proto\x12\fgrpc.goodbye\"$\n" +
 	"\x0eGoodbyeRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"(\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"F\n" +
+	"\x18GoodbyeWithReasonRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"(\n" +
 	"\fGoodbyeReply\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage2\xd9\x02\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage2\x91\x04\n" +
 	"\bFarewell\x12H\n" +
 	"\n" +
 	"SayGoodbye\x12\x1c.grpc.goodbye.GoodbyeRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x00\x12P\n" +
 	"\x10SayGoodbyeStream\x12\x1c.grpc.goodbye.GoodbyeRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x000\x01\x12V\n" +
 	"\x16SayGoodbyeClientStream\x12\x1c.grpc.goodbye.GoodbyeRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x00(\x01\x12Y\n" +
-	"\x17SayGoodbyeBidirectional\x12\x1c.grpc.goodbye.GoodbyeRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x00(\x010\x01B\x1bZ\x19grpc-sample/proto/goodbyeb\x06proto3"
+	"\x17SayGoodbyeBidirectional\x12\x1c.grpc.goodbye.GoodbyeRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x00(\x010\x01\x12\\\n" +
+	"\x14SayGoodbyeWithReason\x12&.grpc.goodbye.GoodbyeWithReasonRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x00\x12X\n" +
+	"\x18SayGoodbyeUntilCancelled\x12\x1c.grpc.goodbye.GoodbyeRequest\x1a\x1a.grpc.goodbye.GoodbyeReply\"\x000\x01B\x1bZ\x19grpc-sample/proto/goodbyeb\x06proto3"
 
 var (
 	file_proto_goodbye_goodbye_proto_rawDescOnce sync.Once
@@ -139,22 +202,27 @@ func file_proto_goodbye_goodbye_proto_rawDescGZIP() []byte {
 	return file_proto_goodbye_goodbye_proto_rawDescData
 }
 
-var file_proto_goodbye_goodbye_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_goodbye_goodbye_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
 var file_proto_goodbye_goodbye_proto_goTypes = []any{
-	(*GoodbyeRequest)(nil), // 0: grpc.goodbye.GoodbyeRequest
-	(*GoodbyeReply)(nil),   // 1: grpc.goodbye.GoodbyeReply
+	(*GoodbyeRequest)(nil),           // 0: grpc.goodbye.GoodbyeRequest
+	(*GoodbyeWithReasonRequest)(nil), // 1: grpc.goodbye.GoodbyeWithReasonRequest
+	(*GoodbyeReply)(nil),             // 2: grpc.goodbye.GoodbyeReply
 }
 var file_proto_goodbye_goodbye_proto_depIdxs = []int32{
 	0, // 0: grpc.goodbye.Farewell.SayGoodbye:input_type -> grpc.goodbye.GoodbyeRequest
 	0, // 1: grpc.goodbye.Farewell.SayGoodbyeStream:input_type -> grpc.goodbye.GoodbyeRequest
 	0, // 2: grpc.goodbye.Farewell.SayGoodbyeClientStream:input_type -> grpc.goodbye.GoodbyeRequest
 	0, // 3: grpc.goodbye.Farewell.SayGoodbyeBidirectional:input_type -> grpc.goodbye.GoodbyeRequest
-	1, // 4: grpc.goodbye.Farewell.SayGoodbye:output_type -> grpc.goodbye.GoodbyeReply
-	1, // 5: grpc.goodbye.Farewell.SayGoodbyeStream:output_type -> grpc.goodbye.GoodbyeReply
-	1, // 6: grpc.goodbye.Farewell.SayGoodbyeClientStream:output_type -> grpc.goodbye.GoodbyeReply
-	1, // 7: grpc.goodbye.Farewell.SayGoodbyeBidirectional:output_type -> grpc.goodbye.GoodbyeReply
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
+	1, // 4: grpc.goodbye.Farewell.SayGoodbyeWithReason:input_type -> grpc.goodbye.GoodbyeWithReasonRequest
+	0, // 5: grpc.goodbye.Farewell.SayGoodbyeUntilCancelled:input_type -> grpc.goodbye.GoodbyeRequest
+	2, // 6: grpc.goodbye.Farewell.SayGoodbye:output_type -> grpc.goodbye.GoodbyeReply
+	2, // 7: grpc.goodbye.Farewell.SayGoodbyeStream:output_type -> grpc.goodbye.GoodbyeReply
+	2, // 8: grpc.goodbye.Farewell.SayGoodbyeClientStream:output_type -> grpc.goodbye.GoodbyeReply
+	2, // 9: grpc.goodbye.Farewell.SayGoodbyeBidirectional:output_type -> grpc.goodbye.GoodbyeReply
+	2, // 10: grpc.goodbye.Farewell.SayGoodbyeWithReason:output_type -> grpc.goodbye.GoodbyeReply
+	2, // 11: grpc.goodbye.Farewell.SayGoodbyeUntilCancelled:output_type -> grpc.goodbye.GoodbyeReply
+	6, // [6:12] is the sub-list for method output_type
+	0, // [0:6] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -171,7 +239,7 @@ func file_proto_goodbye_goodbye_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_goodbye_goodbye_proto_rawDesc), len(file_proto_goodbye_goodbye_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   2,
+			NumMessages:   3,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -19,26 +19,41 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Farewell_SayGoodbye_FullMethodName              = "/grpc.goodbye.Farewell/SayGoodbye"
-	Farewell_SayGoodbyeStream_FullMethodName        = "/grpc.goodbye.Farewell/SayGoodbyeStream"
-	Farewell_SayGoodbyeClientStream_FullMethodName  = "/grpc.goodbye.Farewell/SayGoodbyeClientStream"
-	Farewell_SayGoodbyeBidirectional_FullMethodName = "/grpc.goodbye.Farewell/SayGoodbyeBidirectional"
+	Farewell_SayGoodbye_FullMethodName               = "/grpc.goodbye.Farewell/SayGoodbye"
+	Farewell_SayGoodbyeStream_FullMethodName         = "/grpc.goodbye.Farewell/SayGoodbyeStream"
+	Farewell_SayGoodbyeClientStream_FullMethodName   = "/grpc.goodbye.Farewell/SayGoodbyeClientStream"
+	Farewell_SayGoodbyeBidirectional_FullMethodName  = "/grpc.goodbye.Farewell/SayGoodbyeBidirectional"
+	Farewell_SayGoodbyeWithReason_FullMethodName     = "/grpc.goodbye.Farewell/SayGoodbyeWithReason"
+	Farewell_SayGoodbyeUntilCancelled_FullMethodName = "/grpc.goodbye.Farewell/SayGoodbyeUntilCancelled"
 )
 
 // FarewellClient is the client API for Farewell service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// The goodbye service definition.
+// The goodbye service definition. The REST route of each method is served
+// from the table in service/transcode.go, which must list the same paths.
 type FarewellClient interface {
 	// Sends a goodbye message
+	// REST: POST /v1/goodbye
 	SayGoodbye(ctx context.Context, in *GoodbyeRequest, opts ...grpc.CallOption) (*GoodbyeReply, error)
 	// Sends multiple goodbye messages in a stream
+	// REST: POST /v1/goodbye-stream
 	SayGoodbyeStream(ctx context.Context, in *GoodbyeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GoodbyeReply], error)
 	// Client sends multiple names, server responds with farewell summary
+	// REST: POST /v1/goodbye-client-stream
 	SayGoodbyeClientStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[GoodbyeRequest, GoodbyeReply], error)
 	// Bidirectional streaming - client sends names, server responds to each
+	// REST: POST /v1/goodbye-bidirectional
 	SayGoodbyeBidirectional(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[GoodbyeRequest, GoodbyeReply], error)
+	// Sends a goodbye message with a reason. Blocked names are refused with a
+	// PERMISSION_DENIED status carrying a google.rpc.ErrorInfo detail.
+	// REST: POST /v1/goodbye-with-reason
+	SayGoodbyeWithReason(ctx context.Context, in *GoodbyeWithReasonRequest, opts ...grpc.CallOption) (*GoodbyeReply, error)
+	// Sends goodbye messages at a fixed interval until the client cancels the
+	// call, unlike SayGoodbyeStream, which stops after three
+	// REST: POST /v1/goodbye-until-cancelled
+	SayGoodbyeUntilCancelled(ctx context.Context, in *GoodbyeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GoodbyeReply], error)
 }
 
 type farewellClient struct {
@@ -104,20 +119,62 @@ func (c *farewellClient) SayGoodbyeBidirectional(ctx context.Context, opts ...gr
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Farewell_SayGoodbyeBidirectionalClient = grpc.BidiStreamingClient[GoodbyeRequest, GoodbyeReply]
 
+func (c *farewellClient) SayGoodbyeWithReason(ctx context.Context, in *GoodbyeWithReasonRequest, opts ...grpc.CallOption) (*GoodbyeReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GoodbyeReply)
+	err := c.cc.Invoke(ctx, Farewell_SayGoodbyeWithReason_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *farewellClient) SayGoodbyeUntilCancelled(ctx context.Context, in *GoodbyeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GoodbyeReply], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Farewell_ServiceDesc.Streams[3], Farewell_SayGoodbyeUntilCancelled_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GoodbyeRequest, GoodbyeReply]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Farewell_SayGoodbyeUntilCancelledClient = grpc.ServerStreamingClient[GoodbyeReply]
+
 // FarewellServer is the server API for Farewell service.
 // All implementations must embed UnimplementedFarewellServer
 // for forward compatibility.
 //
-// The goodbye service definition.
+// The goodbye service definition. The REST route of each method is served
+// from the table in service/transcode.go, which must list the same paths.
 type FarewellServer interface {
 	// Sends a goodbye message
+	// REST: POST /v1/goodbye
 	SayGoodbye(context.Context, *GoodbyeRequest) (*GoodbyeReply, error)
 	// Sends multiple goodbye messages in a stream
+	// REST: POST /v1/goodbye-stream
 	SayGoodbyeStream(*GoodbyeRequest, grpc.ServerStreamingServer[GoodbyeReply]) error
 	// Client sends multiple names, server responds with farewell summary
+	// REST: POST /v1/goodbye-client-stream
 	SayGoodbyeClientStream(grpc.ClientStreamingServer[GoodbyeRequest, GoodbyeReply]) error
 	// Bidirectional streaming - client sends names, server responds to each
+	// REST: POST /v1/goodbye-bidirectional
 	SayGoodbyeBidirectional(grpc.BidiStreamingServer[GoodbyeRequest, GoodbyeReply]) error
+	// Sends a goodbye message with a reason. Blocked names are refused with a
+	// PERMISSION_DENIED status carrying a google.rpc.ErrorInfo detail.
+	// REST: POST /v1/goodbye-with-reason
+	SayGoodbyeWithReason(context.Context, *GoodbyeWithReasonRequest) (*GoodbyeReply, error)
+	// Sends goodbye messages at a fixed interval until the client cancels the
+	// call, unlike SayGoodbyeStream, which stops after three
+	// REST: POST /v1/goodbye-until-cancelled
+	SayGoodbyeUntilCancelled(*GoodbyeRequest, grpc.ServerStreamingServer[GoodbyeReply]) error
 	mustEmbedUnimplementedFarewellServer()
 }
 
@@ -140,6 +197,12 @@ func (UnimplementedFarewellServer) SayGoodbyeClientStream(grpc.ClientStreamingSe
 func (UnimplementedFarewellServer) SayGoodbyeBidirectional(grpc.BidiStreamingServer[GoodbyeRequest, GoodbyeReply]) error {
 	return status.Errorf(codes.Unimplemented, "method SayGoodbyeBidirectional not implemented")
 }
+func (UnimplementedFarewellServer) SayGoodbyeWithReason(context.Context, *GoodbyeWithReasonRequest) (*GoodbyeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SayGoodbyeWithReason not implemented")
+}
+func (UnimplementedFarewellServer) SayGoodbyeUntilCancelled(*GoodbyeRequest, grpc.ServerStreamingServer[GoodbyeReply]) error {
+	return status.Errorf(codes.Unimplemented, "method SayGoodbyeUntilCancelled not implemented")
+}
 func (UnimplementedFarewellServer) mustEmbedUnimplementedFarewellServer() {}
 func (UnimplementedFarewellServer) testEmbeddedByValue()                  {}
 
@@ -204,6 +267,35 @@ func _Farewell_SayGoodbyeBidirectional_Handler(srv interface{}, stream grpc.Serv
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Farewell_SayGoodbyeBidirectionalServer = grpc.BidiStreamingServer[GoodbyeRequest, GoodbyeReply]
 
+func _Farewell_SayGoodbyeWithReason_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GoodbyeWithReasonRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FarewellServer).SayGoodbyeWithReason(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Farewell_SayGoodbyeWithReason_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FarewellServer).SayGoodbyeWithReason(ctx, req.(*GoodbyeWithReasonRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Farewell_SayGoodbyeUntilCancelled_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GoodbyeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FarewellServer).SayGoodbyeUntilCancelled(m, &grpc.GenericServerStream[GoodbyeRequest, GoodbyeReply]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Farewell_SayGoodbyeUntilCancelledServer = grpc.ServerStreamingServer[GoodbyeReply]
+
 // Farewell_ServiceDesc is the grpc.ServiceDesc for Farewell service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -215,6 +307,10 @@ var Farewell_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SayGoodbye",
 			Handler:    _Farewell_SayGoodbye_Handler,
 		},
+		{
+			MethodName: "SayGoodbyeWithReason",
+			Handler:    _Farewell_SayGoodbyeWithReason_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -233,6 +329,11 @@ var Farewell_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "SayGoodbyeUntilCancelled",
+			Handler:       _Farewell_SayGoodbyeUntilCancelled_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "proto/goodbye/goodbye.proto",
 }
@@ -22,9 +22,14 @@ const (
 )
 
 // The request message containing the user's name
+// Field rules are enforced by the server (service/validation.go).
 type HelloRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Required, 1-100 characters
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Language tag such as "fr" or "es-MX", used by SayHelloInLanguage;
+	// at most 35 characters
+	Language      string `protobuf:"bytes,2,opt,name=language,proto3" json:"language,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -66,10 +71,21 @@ func (x *HelloRequest) GetName() string {
 	return ""
 }
 
+func (x *HelloRequest) GetLanguage() string {
+	if x != nil {
+		return x.Language
+	}
+	return ""
+}
+
 // The response message containing the greetings
 type HelloReply struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Message string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	// Names received so far on the stream, set by SayHelloAggregate
+	TotalCount int32 `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	// Names received since the previous reply, set by SayHelloAggregate
+	RecentNames   []string `protobuf:"bytes,3,rep,name=recent_names,json=recentNames,proto3" json:"recent_names,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -111,22 +127,42 @@ func (x *HelloReply) GetMessage() string {
 	return ""
 }
 
+func (x *HelloReply) GetTotalCount() int32 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+func (x *HelloReply) GetRecentNames() []string {
+	if x != nil {
+		return x.RecentNames
+	}
+	return nil
+}
+
 var File_proto_hello_hello_proto protoreflect.FileDescriptor
 
 const file_proto_hello_hello_proto_rawDesc = "" +
 	"\n" +
 	"\x17proto/hello/hello.proto\x12\n" +
-	"grpc.hello\"\"\n" +
+	"grpc.hello\">\n" +
 	"\fHelloRequest\x12\x12\n" +
-	"\x04name\x18\x01 \x01(\tR\x04name\"&\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1a\n" +
+	"\blanguage\x18\x02 \x01(\tR\blanguage\"j\n" +
 	"\n" +
 	"HelloReply\x12\x18\n" +
-	"\amessage\x18\x01 \x01(\tR\amessage2\xb0\x02\n" +
+	"\amessage\x18\x01 \x01(\tR\amessage\x12\x1f\n" +
+	"\vtotal_count\x18\x02 \x01(\x05R\n" +
+	"totalCount\x12!\n" +
+	"\frecent_names\x18\x03 \x03(\tR\vrecentNames2\xc7\x03\n" +
 	"\aGreeter\x12>\n" +
 	"\bSayHello\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x00\x12F\n" +
 	"\x0eSayHelloStream\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x000\x01\x12L\n" +
 	"\x14SayHelloClientStream\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x00(\x01\x12O\n" +
-	"\x15SayHelloBidirectional\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x00(\x010\x01B\x19Z\x17grpc-sample/proto/hellob\x06proto3"
+	"\x15SayHelloBidirectional\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x00(\x010\x01\x12H\n" +
+	"\x12SayHelloInLanguage\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x00\x12K\n" +
+	"\x11SayHelloAggregate\x12\x18.grpc.hello.HelloRequest\x1a\x16.grpc.hello.HelloReply\"\x00(\x010\x01B\x19Z\x17grpc-sample/proto/hellob\x06proto3"
 
 var (
 	file_proto_hello_hello_proto_rawDescOnce sync.Once
@@ -150,12 +186,16 @@ var file_proto_hello_hello_proto_depIdxs = []int32{
 	0, // 1: grpc.hello.Greeter.SayHelloStream:input_type -> grpc.hello.HelloRequest
 	0, // 2: grpc.hello.Greeter.SayHelloClientStream:input_type -> grpc.hello.HelloRequest
 	0, // 3: grpc.hello.Greeter.SayHelloBidirectional:input_type -> grpc.hello.HelloRequest
-	1, // 4: grpc.hello.Greeter.SayHello:output_type -> grpc.hello.HelloReply
-	1, // 5: grpc.hello.Greeter.SayHelloStream:output_type -> grpc.hello.HelloReply
-	1, // 6: grpc.hello.Greeter.SayHelloClientStream:output_type -> grpc.hello.HelloReply
-	1, // 7: grpc.hello.Greeter.SayHelloBidirectional:output_type -> grpc.hello.HelloReply
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
+	0, // 4: grpc.hello.Greeter.SayHelloInLanguage:input_type -> grpc.hello.HelloRequest
+	0, // 5: grpc.hello.Greeter.SayHelloAggregate:input_type -> grpc.hello.HelloRequest
+	1, // 6: grpc.hello.Greeter.SayHello:output_type -> grpc.hello.HelloReply
+	1, // 7: grpc.hello.Greeter.SayHelloStream:output_type -> grpc.hello.HelloReply
+	1, // 8: grpc.hello.Greeter.SayHelloClientStream:output_type -> grpc.hello.HelloReply
+	1, // 9: grpc.hello.Greeter.SayHelloBidirectional:output_type -> grpc.hello.HelloReply
+	1, // 10: grpc.hello.Greeter.SayHelloInLanguage:output_type -> grpc.hello.HelloReply
+	1, // 11: grpc.hello.Greeter.SayHelloAggregate:output_type -> grpc.hello.HelloReply
+	6, // [6:12] is the sub-list for method output_type
+	0, // [0:6] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -23,22 +23,36 @@ const (
 	Greeter_SayHelloStream_FullMethodName        = "/grpc.hello.Greeter/SayHelloStream"
 	Greeter_SayHelloClientStream_FullMethodName  = "/grpc.hello.Greeter/SayHelloClientStream"
 	Greeter_SayHelloBidirectional_FullMethodName = "/grpc.hello.Greeter/SayHelloBidirectional"
+	Greeter_SayHelloInLanguage_FullMethodName    = "/grpc.hello.Greeter/SayHelloInLanguage"
+	Greeter_SayHelloAggregate_FullMethodName     = "/grpc.hello.Greeter/SayHelloAggregate"
 )
 
 // GreeterClient is the client API for Greeter service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 //
-// The greeting service definition
+// The greeting service definition. The REST route of each method is served
+// from the table in service/transcode.go, which must list the same paths.
 type GreeterClient interface {
 	// Sends a greeting
+	// REST: POST /v1/hello
 	SayHello(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error)
 	// Sends multiple greetings
+	// REST: POST /v1/hello-stream
 	SayHelloStream(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[HelloReply], error)
 	// Client sends multiple names, server responds with summary
+	// REST: POST /v1/hello-client-stream
 	SayHelloClientStream(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[HelloRequest, HelloReply], error)
 	// Bidirectional streaming - client sends names, server responds to each
+	// REST: POST /v1/hello-bidirectional
 	SayHelloBidirectional(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HelloRequest, HelloReply], error)
+	// Sends a greeting in the requested language, falling back to English
+	// REST: POST /v1/hello-in-language
+	SayHelloInLanguage(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error)
+	// Bidirectional streaming - client sends names, server replies with a
+	// running summary every few names and a final one when the client is done
+	// REST: POST /v1/hello-aggregate
+	SayHelloAggregate(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HelloRequest, HelloReply], error)
 }
 
 type greeterClient struct {
@@ -104,20 +118,55 @@ func (c *greeterClient) SayHelloBidirectional(ctx context.Context, opts ...grpc.
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Greeter_SayHelloBidirectionalClient = grpc.BidiStreamingClient[HelloRequest, HelloReply]
 
+func (c *greeterClient) SayHelloInLanguage(ctx context.Context, in *HelloRequest, opts ...grpc.CallOption) (*HelloReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HelloReply)
+	err := c.cc.Invoke(ctx, Greeter_SayHelloInLanguage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *greeterClient) SayHelloAggregate(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[HelloRequest, HelloReply], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Greeter_ServiceDesc.Streams[3], Greeter_SayHelloAggregate_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[HelloRequest, HelloReply]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Greeter_SayHelloAggregateClient = grpc.BidiStreamingClient[HelloRequest, HelloReply]
+
 // GreeterServer is the server API for Greeter service.
 // All implementations must embed UnimplementedGreeterServer
 // for forward compatibility.
 //
-// The greeting service definition
+// The greeting service definition. The REST route of each method is served
+// from the table in service/transcode.go, which must list the same paths.
 type GreeterServer interface {
 	// Sends a greeting
+	// REST: POST /v1/hello
 	SayHello(context.Context, *HelloRequest) (*HelloReply, error)
 	// Sends multiple greetings
+	// REST: POST /v1/hello-stream
 	SayHelloStream(*HelloRequest, grpc.ServerStreamingServer[HelloReply]) error
 	// Client sends multiple names, server responds with summary
+	// REST: POST /v1/hello-client-stream
 	SayHelloClientStream(grpc.ClientStreamingServer[HelloRequest, HelloReply]) error
 	// Bidirectional streaming - client sends names, server responds to each
+	// REST: POST /v1/hello-bidirectional
 	SayHelloBidirectional(grpc.BidiStreamingServer[HelloRequest, HelloReply]) error
+	// Sends a greeting in the requested language, falling back to English
+	// REST: POST /v1/hello-in-language
+	SayHelloInLanguage(context.Context, *HelloRequest) (*HelloReply, error)
+	// Bidirectional streaming - client sends names, server replies with a
+	// running summary every few names and a final one when the client is done
+	// REST: POST /v1/hello-aggregate
+	SayHelloAggregate(grpc.BidiStreamingServer[HelloRequest, HelloReply]) error
 	mustEmbedUnimplementedGreeterServer()
 }
 
@@ -140,6 +189,12 @@ func (UnimplementedGreeterServer) SayHelloClientStream(grpc.ClientStreamingServe
 func (UnimplementedGreeterServer) SayHelloBidirectional(grpc.BidiStreamingServer[HelloRequest, HelloReply]) error {
 	return status.Errorf(codes.Unimplemented, "method SayHelloBidirectional not implemented")
 }
+func (UnimplementedGreeterServer) SayHelloInLanguage(context.Context, *HelloRequest) (*HelloReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SayHelloInLanguage not implemented")
+}
+func (UnimplementedGreeterServer) SayHelloAggregate(grpc.BidiStreamingServer[HelloRequest, HelloReply]) error {
+	return status.Errorf(codes.Unimplemented, "method SayHelloAggregate not implemented")
+}
 func (UnimplementedGreeterServer) mustEmbedUnimplementedGreeterServer() {}
 func (UnimplementedGreeterServer) testEmbeddedByValue()                 {}
 
@@ -204,6 +259,31 @@ func _Greeter_SayHelloBidirectional_Handler(srv interface{}, stream grpc.ServerS
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type Greeter_SayHelloBidirectionalServer = grpc.BidiStreamingServer[HelloRequest, HelloReply]
 
+func _Greeter_SayHelloInLanguage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HelloRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GreeterServer).SayHelloInLanguage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Greeter_SayHelloInLanguage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GreeterServer).SayHelloInLanguage(ctx, req.(*HelloRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Greeter_SayHelloAggregate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GreeterServer).SayHelloAggregate(&grpc.GenericServerStream[HelloRequest, HelloReply]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Greeter_SayHelloAggregateServer = grpc.BidiStreamingServer[HelloRequest, HelloReply]
+
 // Greeter_ServiceDesc is the grpc.ServiceDesc for Greeter service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -215,6 +295,10 @@ var Greeter_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SayHello",
 			Handler:    _Greeter_SayHello_Handler,
 		},
+		{
+			MethodName: "SayHelloInLanguage",
+			Handler:    _Greeter_SayHelloInLanguage_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -233,6 +317,12 @@ var Greeter_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "SayHelloAggregate",
+			Handler:       _Greeter_SayHelloAggregate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "proto/hello/hello.proto",
 }
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+
+	"grpc-sample/config"
+)
+
+// bannerLogger writes the startup banner through the structured logger. In
+// plain mode the emoji prefixes are left out so log parsers and terminals
+// without emoji support see ASCII-only messages.
+type bannerLogger struct {
+	logger *slog.Logger
+	plain  bool
+}
+
+func (b bannerLogger) log(emoji, msg string, args ...interface{}) {
+	b.logger.Info(b.text(emoji, msg), args...)
+}
+
+// warn logs a banner line that operators should not miss at warning level.
+func (b bannerLogger) warn(emoji, msg string, args ...interface{}) {
+	b.logger.Warn(b.text(emoji, msg), args...)
+}
+
+// text prefixes msg with emoji unless in plain mode.
+func (b bannerLogger) text(emoji, msg string) string {
+	if b.plain {
+		return msg
+	}
+	return emoji + " " + msg
+}
+
+// Services a banner entry belongs to; entries for a disabled service are
+// left out. serviceHistory entries are shown when a history store is set.
+const (
+	serviceHello   = "hello"
+	serviceGoodbye = "goodbye"
+	serviceHistory = "history"
+)
+
+// httpEndpoints lists the REST routes announced in the startup banner. An
+// empty service means the route is always served.
+var httpEndpoints = []struct {
+	route       string
+	description string
+	service     string
+}{
+	{"GET/HEAD/POST /api/hello", "Say hello", serviceHello},
+	{"POST /api/hello/batch", "Say hello to several names", serviceHello},
+	{"GET/HEAD/POST /api/goodbye", "Say goodbye", serviceGoodbye},
+	{"GET /api/goodbye/stream", "Stream farewells as server-sent events", serviceGoodbye},
+	{"GET /api/history", "Recent greetings and farewells", serviceHistory},
+	{"POST /v1/...", "Every gRPC method as JSON, e.g. /v1/hello", ""},
+	{"GET /health", "Health check", ""},
+	{"GET /healthz", "Liveness check", ""},
+	{"GET /readyz", "Readiness check", ""},
+	{"POST /admin/drain", "Mark the server NOT_SERVING before shutdown", ""},
+	{"GET /admin/config", "Effective configuration, secrets redacted", ""},
+	{"GET /api/doc", "API documentation", ""},
+	{"GET /api/descriptors", "Proto descriptors without reflection", ""},
+	{"GET /openapi.json", "OpenAPI specification", ""},
+	{"GET /docs", "Swagger UI", ""},
+	{"GET /", "Welcome message", ""},
+}
+
+// logBanner logs what the server is serving and where, in the style set by
+// cfg.LogBannerStyle. grpcBound and httpBound are the same address unless
+// gRPC and REST are on separate ports.
+func logBanner(logger *slog.Logger, cfg config.Config, grpcBound, httpBound net.Addr) {
+	b := bannerLogger{logger: logger, plain: cfg.LogBannerStyle == config.BannerStylePlain}
+	grpcAddr := dialableAddr(grpcBound)
+	httpAddr := dialableAddr(httpBound)
+
+	b.log("🚀", "Unified server started", "service", cfg.ServiceName, "version", cfg.ServiceVersion, "address", grpcBound.String())
+	b.log("🔧", "gRPC: "+grpcAddr+" (use grpcurl)", "protocol", "grpc", "address", grpcAddr)
+	b.log("🌐", "HTTP: "+httpAddr+" (use curl)", "protocol", "http", "address", httpAddr)
+	enabled := map[string]bool{"": true, serviceHello: cfg.EnableHello, serviceGoodbye: cfg.EnableGoodbye, serviceHistory: cfg.HistoryStore != ""}
+	var services []string
+	if cfg.EnableHello {
+		services = append(services, "Greeter (hello)")
+	}
+	if cfg.EnableGoodbye {
+		services = append(services, "Farewell (goodbye)")
+	}
+	b.log("📋", "Available gRPC services: "+strings.Join(services, ", "))
+	for _, e := range httpEndpoints {
+		if enabled[e.service] {
+			b.log("📋", "HTTP endpoint "+e.route+" - "+e.description, "route", e.route)
+		}
+	}
+	if cfg.EnableReflection {
+		b.log("🔍", "gRPC reflection enabled for grpcurl support")
+	} else {
+		b.log("🔒", "gRPC reflection disabled (GRPC_ENABLE_REFLECTION=false)")
+	}
+	if cfg.EnableFaultInjection {
+		b.warn("⚠️", "Fault injection enabled: SayHello honors inject-error and inject-delay-ms metadata; do not use in production")
+	}
+	b.log("📖", "Visit http://"+httpAddr+"/api/doc for API documentation")
+	if cfg.SplitPorts() {
+		b.log("🎯", "gRPC and HTTP are served on separate ports")
+	} else {
+		b.log("🎯", "Both protocols are served on the same port using protocol multiplexing")
+	}
+
+	if cfg.TLSEnabled() {
+		b.log("🔐", "TLS enabled", "cert_file", cfg.TLSCertFile, "min_version", cfg.TLSMinVersion, "required", cfg.TLSRequired)
+	}
+}
+
+// dialableAddr returns the bound address in a form clients can connect to,
+// replacing an unspecified host such as "::" with localhost.
+func dialableAddr(addr net.Addr) string {
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
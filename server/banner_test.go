@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+
+	"grpc-sample/config"
+)
+
+// bannerOutput returns what logBanner logs for cfg, with every optional
+// line switched on.
+func bannerOutput(style string) string {
+	cfg := config.Default()
+	cfg.LogBannerStyle = style
+	cfg.EnableFaultInjection = true
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	cfg.TLSRequired = true
+	addr := &net.TCPAddr{IP: net.IPv6unspecified, Port: 50051}
+
+	var buf bytes.Buffer
+	logBanner(slog.New(slog.NewJSONHandler(&buf, nil)), cfg, addr, addr)
+	return buf.String()
+}
+
+func TestPlainBannerIsASCII(t *testing.T) {
+	out := bannerOutput(config.BannerStylePlain)
+	for i, r := range out {
+		if r > 0x7f {
+			t.Fatalf("plain banner has non-ASCII %q at byte %d in:\n%s", r, i, out)
+		}
+	}
+	if !strings.Contains(out, `"msg":"Unified server started"`) {
+		t.Errorf("plain banner lacks the startup message:\n%s", out)
+	}
+	if !strings.Contains(out, "localhost:50051") {
+		t.Errorf("banner does not give a dialable address:\n%s", out)
+	}
+}
+
+func TestDecoratedBannerHasEmojis(t *testing.T) {
+	if out := bannerOutput(config.BannerStyleDecorated); !strings.Contains(out, "🚀 Unified server started") {
+		t.Errorf("decorated banner lacks the emoji prefix:\n%s", out)
+	}
+}
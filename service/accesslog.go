@@ -0,0 +1,129 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// combinedTimeFormat is the timestamp format of Apache access logs, e.g.
+// "10/Oct/2000:13:55:36 -0700".
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogWriter records the status and size of a response for the access
+// log.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *accessLogWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessLogWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush lets the SSE handler, which asserts http.Flusher, stream through the
+// writer.
+func (w *accessLogWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack records the 101 Switching Protocols of WebSocket upgrades, which
+// are written to the hijacked connection rather than through WriteHeader.
+func (w *accessLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, which
+// RequestDeadlines uses.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logAccess serves each request through next and then hands it, with its
+// response, start time and a context carrying its request ID, to record.
+func logAccess(next http.Handler, record func(ctx context.Context, r *http.Request, w *accessLogWriter, start time.Time)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		// The request ID is set by the router, below this middleware, so
+		// it is read back from the response
+		ctx := r.Context()
+		if id := w.Header().Get(RequestIDHeader); id != "" {
+			ctx = WithRequestID(ctx, id)
+		}
+		record(ctx, r, rec, start)
+	})
+}
+
+// LogRequests logs every REST request next serves, once its response is
+// complete, as an info record through slog.
+func LogRequests(next http.Handler) http.Handler {
+	return logAccess(next, func(ctx context.Context, r *http.Request, w *accessLogWriter, start time.Time) {
+		slog.InfoContext(ctx, "HTTP: Access", "method", r.Method, "path", r.URL.Path,
+			"status", w.status, "bytes", w.bytes, "duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+	})
+}
+
+// LogRequestsCombined writes a line in the Apache Combined Log Format to out
+// for every REST request next serves, once its response is complete, e.g.
+//
+//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /api/hello?name=Alice HTTP/1.1" 200 43 "-" "curl/8.5.0"
+//
+// The identity and user fields are always "-". Quoted fields are escaped as
+// Go strings, so a request cannot forge log lines.
+func LogRequestsCombined(next http.Handler, out io.Writer) http.Handler {
+	logger := log.New(out, "", 0)
+	return logAccess(next, func(_ context.Context, r *http.Request, w *accessLogWriter, start time.Time) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		size := "-"
+		if w.bytes > 0 {
+			size = strconv.FormatInt(w.bytes, 10)
+		}
+		logger.Printf("%s - - [%s] %s %d %s %s %s", host, start.Format(combinedTimeFormat),
+			strconv.Quote(r.Method+" "+r.RequestURI+" "+r.Proto), w.status, size,
+			quoteOrDash(r.Referer()), quoteOrDash(r.UserAgent()))
+	})
+}
+
+// quoteOrDash quotes s for an access log line, or returns "-" quoted when s
+// is empty, as Apache does.
+func quoteOrDash(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
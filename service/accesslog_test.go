@@ -0,0 +1,77 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// combinedLine matches a Combined Log Format line, capturing the time,
+// request line, status, size, referer and user agent.
+var combinedLine = regexp.MustCompile(`^192\.0\.2\.1 - - \[([^\]]+)\] "([^"]*)" (\d{3}) (\d+|-) "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)"$`)
+
+func TestLogRequestsCombined(t *testing.T) {
+	var out bytes.Buffer
+	h := LogRequestsCombined(testRouter{}.handler(), &out)
+	req := httptest.NewRequest("GET", "/api/hello?name=Alice", nil)
+	req.RemoteAddr = "192.0.2.1:50000"
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	req.Header.Set("Referer", "https://app.example/")
+	rec := serve(h, req)
+
+	line := strings.TrimSuffix(out.String(), "\n")
+	m := combinedLine.FindStringSubmatch(line)
+	if m == nil {
+		t.Fatalf("access log line %q is not in Combined Log Format", line)
+	}
+	if _, err := time.Parse(combinedTimeFormat, m[1]); err != nil {
+		t.Errorf("timestamp %q: %v", m[1], err)
+	}
+	if m[2] != "GET /api/hello?name=Alice HTTP/1.1" {
+		t.Errorf("request line = %q", m[2])
+	}
+	if m[3] != "200" || m[4] != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("status, size = %s, %s, want 200, %d", m[3], m[4], rec.Body.Len())
+	}
+	if m[5] != "https://app.example/" || m[6] != "curl/8.5.0" {
+		t.Errorf("referer, user agent = %q, %q", m[5], m[6])
+	}
+
+	// No referer or agent logs "-", and quotes and newlines are escaped
+	out.Reset()
+	req = httptest.NewRequest("GET", "/api/nope", nil)
+	req.RemoteAddr = "192.0.2.1:50000"
+	req.Header.Set("User-Agent", "evil\"\n192.0.2.9 - - forged")
+	serve(h, req)
+	line = strings.TrimSuffix(out.String(), "\n")
+	if strings.Contains(line, "\n") {
+		t.Fatalf("a user agent with a newline split the log line: %q", out.String())
+	}
+	m = combinedLine.FindStringSubmatch(line)
+	if m == nil {
+		t.Fatalf("access log line %q is not in Combined Log Format", line)
+	}
+	if m[3] != strconv.Itoa(http.StatusNotFound) || m[5] != "-" || m[6] != `evil\"\n192.0.2.9 - - forged` {
+		t.Errorf("status, referer, user agent = %s, %q, %q", m[3], m[5], m[6])
+	}
+}
+
+func TestLogRequestsJSON(t *testing.T) {
+	logs := captureLogs(t)
+	req := httptest.NewRequest("GET", "/api/hello?name=Alice", nil)
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	rec := serve(LogRequests(testRouter{}.handler()), req)
+
+	record := logRecord(t, logs, "HTTP: Access")
+	if record["method"] != "GET" || record["path"] != "/api/hello" || record["status"] != float64(200) {
+		t.Errorf("method, path, status = %v, %v, %v", record["method"], record["path"], record["status"])
+	}
+	if record["bytes"] != float64(rec.Body.Len()) || record["user_agent"] != "curl/8.5.0" {
+		t.Errorf("bytes, user_agent = %v, %v, want %d, curl/8.5.0", record["bytes"], record["user_agent"], rec.Body.Len())
+	}
+}
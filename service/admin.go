@@ -0,0 +1,57 @@
+package service
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+
+	"grpc-sample/config"
+
+	"google.golang.org/grpc/codes"
+)
+
+// AdminAPIKeyHeader is the header that carries the key authorizing /admin
+// requests.
+const AdminAPIKeyHeader = "X-API-Key"
+
+// AdminOptions configures the /admin routes.
+type AdminOptions struct {
+	// APIKey must be sent in AdminAPIKeyHeader of every /admin request.
+	// Empty disables the admin routes.
+	APIKey string
+	// Config is the effective configuration served, redacted, at
+	// GET /admin/config.
+	Config config.Config
+}
+
+// requireAPIKey rejects requests whose AdminAPIKeyHeader does not match key
+// with a JSON 401. An empty key rejects every request with a JSON 403, so
+// the admin routes fail closed when no key is configured.
+func requireAPIKey(key string, next http.HandlerFunc) http.HandlerFunc {
+	if key == "" {
+		return func(w http.ResponseWriter, r *http.Request) {
+			slog.WarnContext(r.Context(), "HTTP: Rejected admin request, no admin API key is configured", "path", r.URL.Path)
+			writeError(w, http.StatusForbidden, codes.PermissionDenied, "admin routes are disabled; set ADMIN_API_KEY to enable them")
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(AdminAPIKeyHeader)), []byte(key)) != 1 {
+			slog.WarnContext(r.Context(), "HTTP: Rejected admin request without a valid API key", "path", r.URL.Path)
+			writeError(w, http.StatusUnauthorized, codes.Unauthenticated, "missing or invalid "+AdminAPIKeyHeader+" header")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminConfig serves GET /admin/config: cfg as JSON, with its secrets
+// redacted.
+func handleAdminConfig(cfg config.Config) http.HandlerFunc {
+	redacted := cfg.Redacted()
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "HTTP: Received config request", "remote_addr", r.RemoteAddr)
+
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, http.StatusOK, redacted)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"grpc-sample/config"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestAdminRoutesDisabledWithoutKey(t *testing.T) {
+	h := testRouter{}.handler()
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/admin/drain", nil),
+		httptest.NewRequest("DELETE", "/admin/drain", nil),
+		httptest.NewRequest("GET", "/admin/config", nil),
+	} {
+		if rec := serve(h, req); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s = %d, want 403", req.Method, req.URL.Path, rec.Code)
+		}
+	}
+}
+
+func TestAdminRoutesRequireKey(t *testing.T) {
+	h := testRouter{admin: AdminOptions{APIKey: "secret"}}.handler()
+
+	req := httptest.NewRequest("POST", "/admin/drain", nil)
+	req.Header.Set(AdminAPIKeyHeader, "wrong")
+	if rec := serve(h, req); rec.Code != http.StatusUnauthorized {
+		t.Errorf("drain with a wrong key = %d, want 401", rec.Code)
+	}
+}
+
+func TestDrainAndResume(t *testing.T) {
+	health := NewHealth()
+	health.MarkReady()
+	h := testRouter{health: health, admin: AdminOptions{APIKey: "secret"}}.handler()
+	admin := func(method string) int {
+		req := httptest.NewRequest(method, "/admin/drain", nil)
+		req.Header.Set(AdminAPIKeyHeader, "secret")
+		return serve(h, req).Code
+	}
+	grpcStatus := func() healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := health.grpc.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("health Check: %v", err)
+		}
+		return resp.GetStatus()
+	}
+
+	if code := admin("POST"); code != http.StatusAccepted {
+		t.Fatalf("POST /admin/drain = %d, want 202", code)
+	}
+	rec := serve(h, httptest.NewRequest("GET", "/health", nil))
+	if got := decodeBody(t, rec)["status"]; rec.Code != http.StatusServiceUnavailable || got != "draining" {
+		t.Errorf("/health while draining = %d %v, want 503 draining", rec.Code, got)
+	}
+	if got := grpcStatus(); got != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("gRPC health while draining = %v, want NOT_SERVING", got)
+	}
+	// Draining turns away new work at the load balancer, not here
+	if rec := serve(h, httptest.NewRequest("GET", "/api/hello?name=World", nil)); rec.Code != http.StatusOK {
+		t.Errorf("/api/hello while draining = %d, want 200", rec.Code)
+	}
+
+	if code := admin("DELETE"); code != http.StatusOK {
+		t.Fatalf("DELETE /admin/drain = %d, want 200", code)
+	}
+	if rec := serve(h, httptest.NewRequest("GET", "/readyz", nil)); rec.Code != http.StatusOK {
+		t.Errorf("/readyz after resume = %d, want 200", rec.Code)
+	}
+	if got := grpcStatus(); got != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("gRPC health after resume = %v, want SERVING", got)
+	}
+
+	// Once stopping, the drain is final
+	health.Shutdown()
+	if code := admin("DELETE"); code != http.StatusConflict {
+		t.Errorf("DELETE /admin/drain after Shutdown = %d, want 409", code)
+	}
+	if health.Ready() {
+		t.Error("Ready after Shutdown, want not ready")
+	}
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	health := NewHealth()
+	h := testRouter{health: health}.handler()
+	check := func(path string) (int, interface{}) {
+		rec := serve(h, httptest.NewRequest("GET", path, nil))
+		return rec.Code, decodeBody(t, rec)["status"]
+	}
+	expect := func(phase, path string, wantCode int, wantStatus string) {
+		t.Helper()
+		if code, status := check(path); code != wantCode || status != wantStatus {
+			t.Errorf("%s %s = %d %v, want %d %s", phase, path, code, status, wantCode, wantStatus)
+		}
+	}
+
+	expect("starting", "/healthz", http.StatusOK, "alive")
+	expect("starting", "/readyz", http.StatusServiceUnavailable, "starting")
+
+	health.MarkReady()
+	expect("ready", "/healthz", http.StatusOK, "alive")
+	expect("ready", "/readyz", http.StatusOK, "ready")
+
+	health.Drain()
+	expect("draining", "/healthz", http.StatusOK, "alive")
+	expect("draining", "/readyz", http.StatusServiceUnavailable, "draining")
+	expect("draining", "/health", http.StatusServiceUnavailable, "draining")
+}
+
+func TestAdminConfigRedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.Port = "50123"
+	cfg.AdminAPIKey = "admin-secret"
+	cfg.APIKey = "grpc-secret"
+	cfg.AuthToken = "token-secret"
+	h := testRouter{admin: AdminOptions{APIKey: cfg.AdminAPIKey, Config: cfg}}.handler()
+
+	req := httptest.NewRequest("GET", "/admin/config", nil)
+	req.Header.Set(AdminAPIKeyHeader, "admin-secret")
+	rec := serve(h, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/config = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	body := decodeBody(t, rec)
+	if body["port"] != "50123" {
+		t.Errorf("port = %v, want 50123", body["port"])
+	}
+	for _, field := range []string{"admin_api_key", "api_key", "auth_token"} {
+		if body[field] != config.RedactedValue {
+			t.Errorf("%s = %v, want %s", field, body[field], config.RedactedValue)
+		}
+	}
+}
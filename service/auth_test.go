@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authRegistry requires the API key "secret" on every call.
+func authRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(APIKeyInterceptor("secret"))
+	return registry
+}
+
+// recordingStream is a server stream that counts the messages the handler
+// tries to receive.
+type recordingStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	recvs int
+}
+
+func (s *recordingStream) Context() context.Context     { return s.ctx }
+func (s *recordingStream) SetHeader(metadata.MD) error  { return nil }
+func (s *recordingStream) SendHeader(metadata.MD) error { return nil }
+func (s *recordingStream) SetTrailer(metadata.MD)       {}
+
+func (s *recordingStream) RecvMsg(m interface{}) error {
+	s.recvs++
+	return context.Canceled
+}
+
+func TestAPIKeyRejectsStreamBeforeRecv(t *testing.T) {
+	chain := authRegistry().streamChain()
+	info := &grpc.StreamServerInfo{FullMethod: "/hello.Greeter/SayHelloBidirectional", IsClientStream: true, IsServerStream: true}
+	handler := func(srv interface{}, ss grpc.ServerStream) error {
+		return srv.(*HelloServer).SayHelloBidirectional(&grpc.GenericServerStream[hello.HelloRequest, hello.HelloReply]{ServerStream: ss})
+	}
+
+	stream := &recordingStream{ctx: context.Background()}
+	err := chain(NewHelloServer(), stream, info, handler)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("stream without a key: %v, want Unauthenticated", err)
+	}
+	if stream.recvs != 0 {
+		t.Errorf("handler called Recv %d times on a rejected stream, want 0", stream.recvs)
+	}
+
+	stream = &recordingStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs(APIKeyMetadataKey, "secret"))}
+	chain(NewHelloServer(), stream, info, handler)
+	if stream.recvs == 0 {
+		t.Error("handler never called Recv on a stream with the key")
+	}
+}
+
+func TestAPIKeyOverREST(t *testing.T) {
+	h := testRouter{interceptors: authRegistry(), cache: NewResponseCache(10, time.Minute, SystemClock{})}.handler()
+	get := func(target, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return serve(h, req)
+	}
+
+	for _, target := range []string{"/api/hello?name=World", "/api/goodbye?name=World", "/api/goodbye/stream?name=World"} {
+		rec := get(target, "", "")
+		if got := decodeBody(t, rec)["code"]; rec.Code != http.StatusUnauthorized || got != "Unauthenticated" {
+			t.Errorf("GET %s without a key = %d %v, want 401 Unauthenticated", target, rec.Code, got)
+		}
+	}
+
+	if rec := get("/api/hello?name=World", "Grpc-Metadata-X-Api-Key", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/hello with x-api-key = %d, want 200", rec.Code)
+	}
+	if rec := get("/api/hello?name=World", "Authorization", "Bearer secret"); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/hello with a bearer token = %d, want 200", rec.Code)
+	}
+	if rec := get("/api/hello?name=World", "Authorization", "Bearer wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/hello with a wrong key = %d, want 401", rec.Code)
+	}
+	// The responses cached for key holders are not replayed without a key
+	if rec := get("/api/hello?name=World", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /api/hello without a key after cached calls = %d, want 401", rec.Code)
+	}
+}
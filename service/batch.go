@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Batch greeting limits.
+const (
+	maxBatchSize     = 100
+	batchConcurrency = 8
+)
+
+// BatchHelloRequest is the body of POST /api/hello/batch.
+type BatchHelloRequest struct {
+	Names []string `json:"names"`
+}
+
+// BatchHelloResult is the outcome for one name; exactly one of Message and
+// Error is set.
+type BatchHelloResult struct {
+	Name    string         `json:"name"`
+	Message string         `json:"message,omitempty"`
+	Error   *ErrorResponse `json:"error,omitempty"`
+}
+
+// BatchHelloResponse lists the results in the order of the requested names.
+type BatchHelloResponse struct {
+	Results []BatchHelloResult `json:"results"`
+}
+
+// greetBatch calls SayHello through chain for each name with at most
+// batchConcurrency calls in flight. Failures are recorded per name instead of
+// aborting the batch.
+func (s *HelloServer) greetBatch(ctx context.Context, chain grpc.UnaryServerInterceptor, names []string) []BatchHelloResult {
+	results := make([]BatchHelloResult, len(names))
+	sem := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i] = BatchHelloResult{Name: name}
+			reply, err := s.sayHelloChecked(ctx, chain, name)
+			if err != nil {
+				resp := errorResponse(status.Convert(err))
+				results[i].Error = &resp
+				return
+			}
+			results[i].Message = reply.GetMessage()
+		}(i, name)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// sayHelloChecked applies the HelloRequest field rules, which the validation
+// interceptor enforces for gRPC callers, before calling SayHello through
+// chain.
+func (s *HelloServer) sayHelloChecked(ctx context.Context, chain grpc.UnaryServerInterceptor, name string) (*hello.HelloReply, error) {
+	req := &hello.HelloRequest{Name: name}
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	reply, err := invokeUnary(ctx, chain, s, "/"+hello.Greeter_ServiceDesc.ServiceName+"/SayHello", req, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.SayHello(ctx, req.(*hello.HelloRequest))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply.(*hello.HelloReply), nil
+}
+
+// handleSayHelloBatchHTTP greets every name in the request body, calling
+// SayHello through the unary interceptors of registry.
+func (s *HelloServer) handleSayHelloBatchHTTP(registry *Registry) http.HandlerFunc {
+	chain := registry.unaryChain()
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "HTTP: Received SayHello batch request", "method", r.Method, "path", r.URL.Path)
+
+		var req BatchHelloRequest
+		if err := decodeJSONBody(r, &req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if len(req.Names) == 0 {
+			writeError(w, http.StatusBadRequest, codes.InvalidArgument, "names must not be empty")
+			return
+		}
+		if len(req.Names) > maxBatchSize {
+			writeError(w, http.StatusBadRequest, codes.InvalidArgument,
+				fmt.Sprintf("at most %d names are allowed per batch, got %d", maxBatchSize, len(req.Names)))
+			return
+		}
+
+		ctx := metadata.NewIncomingContext(r.Context(), restIncomingMetadata(r))
+		results := s.greetBatch(ctx, chain, req.Names)
+
+		failed := 0
+		for _, result := range results {
+			if result.Error != nil {
+				failed++
+			}
+		}
+		slog.InfoContext(r.Context(), "HTTP: Completed SayHello batch request", "names", len(results), "failed", failed)
+
+		setServerHeaders(w, "SayHello")
+		writeJSON(w, http.StatusOK, BatchHelloResponse{Results: results})
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBatchReportsEachName(t *testing.T) {
+	h := testRouter{}.handler()
+	long := strings.Repeat("x", maxNameLen+1)
+	rec := serve(h, postJSON("/api/hello/batch", `{"names": ["Alice", "", "Bob", "`+long+`"]}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /api/hello/batch = %d, want 200 with per-name results", rec.Code)
+	}
+
+	var resp BatchHelloResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if len(resp.Results) != 4 {
+		t.Fatalf("got %d results, want 4", len(resp.Results))
+	}
+	for i, want := range []string{"Hello Alice", "", "Hello Bob", ""} {
+		result := resp.Results[i]
+		switch {
+		case want != "" && (result.Message != want || result.Error != nil):
+			t.Errorf("result %d = %+v, want message %q", i, result, want)
+		case want == "" && (result.Error == nil || result.Error.Code != "InvalidArgument" || result.Message != ""):
+			t.Errorf("result %d = %+v, want an InvalidArgument error", i, result)
+		}
+	}
+	if resp.Results[1].Error != nil && !strings.Contains(resp.Results[1].Error.Message, "HelloRequest.Name") {
+		t.Errorf("error for the empty name = %q, want it to name the field", resp.Results[1].Error.Message)
+	}
+}
+
+func TestBatchRejectsBadRequests(t *testing.T) {
+	h := testRouter{}.handler()
+	tooMany := `{"names": [` + strings.Repeat(`"a",`, maxBatchSize) + `"a"]}`
+	for _, body := range []string{`{"names": []}`, tooMany, `{"names": "Alice"}`} {
+		rec := serve(h, postJSON("/api/hello/batch", body))
+		if got := decodeBody(t, rec)["code"]; rec.Code != http.StatusBadRequest || got != "InvalidArgument" {
+			t.Errorf("batch %.40s... = %d %v, want 400 InvalidArgument", body, rec.Code, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Caller is the network identity of the client making a call.
+type Caller struct {
+	// Addr is the client's address, e.g. "127.0.0.1:53122".
+	Addr string
+	// UserAgent is the user-agent metadata of gRPC calls or the User-Agent
+	// header of REST requests.
+	UserAgent string
+}
+
+type callerContextKey struct{}
+
+// WithCaller returns a copy of ctx carrying the given caller.
+func WithCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerContextKey{}, c)
+}
+
+// CallerFromContext returns the caller stored in ctx and whether there was
+// one.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	c, ok := ctx.Value(callerContextKey{}).(Caller)
+	return c, ok
+}
+
+// withIncomingCaller stores the caller of a gRPC call, read from its peer
+// and user-agent metadata, in ctx. A caller already in ctx is kept, so REST
+// requests transcoded to gRPC methods report the HTTP client rather than
+// the in-process call.
+func withIncomingCaller(ctx context.Context) context.Context {
+	if _, ok := CallerFromContext(ctx); ok {
+		return ctx
+	}
+	var c Caller
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		c.Addr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			c.UserAgent = values[0]
+		}
+	}
+	return WithCaller(ctx, c)
+}
+
+// UnaryCallerInterceptor stores the caller's address and user agent in the
+// handler context, where handlers read them with CallerFromContext.
+func UnaryCallerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withIncomingCaller(ctx), req)
+}
+
+// StreamCallerInterceptor is the streaming counterpart of
+// UnaryCallerInterceptor.
+func StreamCallerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: withIncomingCaller(ss.Context())})
+}
+
+// callerMiddleware is the REST equivalent of the caller interceptors: it
+// stores the request's RemoteAddr and User-Agent in the request context.
+func callerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := Caller{Addr: r.RemoteAddr, UserAgent: r.UserAgent()}
+		next.ServeHTTP(w, r.WithContext(WithCaller(r.Context(), c)))
+	})
+}
+
+// callerAttr returns the caller in ctx as a log attribute grouping its peer
+// address and user agent. It is empty, and so left out of the record, when
+// ctx has no caller.
+func callerAttr(ctx context.Context) slog.Attr {
+	c, ok := CallerFromContext(ctx)
+	if !ok {
+		return slog.Attr{}
+	}
+	return slog.Group("caller", "peer", c.Addr, "user_agent", c.UserAgent)
+}
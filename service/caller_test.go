@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestSayHelloLogsCallerPeerAddress(t *testing.T) {
+	logs := captureLogs(t)
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	s := startServer(t, cfg)
+
+	// Remember the client's end of the connection to compare with the log
+	local := make(chan string, 1)
+	conn, err := grpc.NewClient(s.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUserAgent("caller-test/1.0"),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			c, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+			if err == nil {
+				local <- c.LocalAddr().String()
+			}
+			return c, err
+		}))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	caller, _ := logRecord(t, logs, "gRPC: Received SayHello request")["caller"].(map[string]any)
+	if want := <-local; caller["peer"] != want {
+		t.Errorf("logged peer = %v, want the client's address %s", caller["peer"], want)
+	}
+	if ua, _ := caller["user_agent"].(string); !strings.HasPrefix(ua, "caller-test/1.0") {
+		t.Errorf("logged user agent = %q, want it to start with caller-test/1.0", ua)
+	}
+}
+
+func TestRESTLogsCallerRemoteAddr(t *testing.T) {
+	logs := captureLogs(t)
+	req := httptest.NewRequest("GET", "/api/hello?name=World", nil)
+	req.RemoteAddr = "192.0.2.7:41000"
+	req.Header.Set("User-Agent", "curl/8.0")
+	serve(testRouter{}.handler(), req)
+
+	caller, _ := logRecord(t, logs, "gRPC: Received SayHello request")["caller"].(map[string]any)
+	if caller["peer"] != "192.0.2.7:41000" || caller["user_agent"] != "curl/8.0" {
+		t.Errorf("logged caller = %v, want the HTTP client 192.0.2.7:41000 with curl/8.0", caller)
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a TLS certificate from disk through
+// tls.Config.GetCertificate, reloading it when the certificate or key file
+// changes so rotations need no restart. Files are checked with stat at most
+// once per interval, during handshakes. A replacement that fails to load is
+// logged and skipped, and the previous certificate stays in use.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	stamp     certStamp
+	lastCheck time.Time
+}
+
+// certStamp identifies a version of the certificate and key files.
+type certStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// NewCertReloader loads the certificate and key, failing if they are
+// unreadable or do not match, and checks them for changes every interval.
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	stamp, err := r.statFiles()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	r.cert, r.stamp, r.lastCheck = &cert, stamp, time.Now()
+	return r, nil
+}
+
+// statFiles returns the current stamp of the certificate and key files.
+func (r *CertReloader) statFiles() (certStamp, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return certStamp{}, fmt.Errorf("reading TLS certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return certStamp{}, fmt.Errorf("reading TLS key: %w", err)
+	}
+	return certStamp{
+		certMod:  certInfo.ModTime(),
+		keyMod:   keyInfo.ModTime(),
+		certSize: certInfo.Size(),
+		keySize:  keyInfo.Size(),
+	}, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate, reloading the
+// certificate first if the files changed since the last check.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		r.reloadLocked()
+	}
+	return r.cert, nil
+}
+
+// reloadLocked loads the files if their stamp changed. Failed loads are
+// remembered by stamp so a broken rotation is only reported once.
+func (r *CertReloader) reloadLocked() {
+	stamp, err := r.statFiles()
+	if err != nil {
+		slog.Warn("TLS certificate check failed, keeping the current certificate", "error", err)
+		return
+	}
+	if stamp == r.stamp {
+		return
+	}
+	r.stamp = stamp
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		slog.Warn("Ignoring invalid TLS certificate, keeping the current one",
+			"cert_file", r.certFile, "key_file", r.keyFile, "error", err)
+		return
+	}
+	r.cert = &cert
+	slog.Info("Reloaded TLS certificate", "cert_file", r.certFile)
+}
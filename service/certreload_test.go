@@ -0,0 +1,78 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"os"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// copyFile replaces dst with the contents of src.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerPicksUpRotatedCertificate(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.TLSCertFile, cfg.TLSKeyFile = writeTestCert(t, t.TempDir())
+	cfg.TLSReloadInterval = config.Duration{Duration: time.Millisecond}
+	s := startServer(t, cfg)
+	addr := s.Addr().String()
+
+	// served returns the certificate a new connection is handed
+	served := func() []byte {
+		t.Helper()
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		if err != nil {
+			t.Fatalf("TLS handshake: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Raw
+	}
+	original := served()
+
+	newCert, newKey := writeTestCert(t, t.TempDir())
+	copyFile(t, newKey, cfg.TLSKeyFile)
+	copyFile(t, newCert, cfg.TLSCertFile)
+	time.Sleep(10 * time.Millisecond)
+	rotated := served()
+	if bytes.Equal(rotated, original) {
+		t.Fatal("new connection still got the original certificate after rotation")
+	}
+
+	// A broken replacement is ignored
+	if err := os.WriteFile(cfg.TLSCertFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := served(); !bytes.Equal(got, rotated) {
+		t.Error("an invalid certificate replaced the working one")
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Errorf("SayHello after rotations: %v", err)
+	}
+}
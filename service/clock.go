@@ -0,0 +1,63 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. HelloServer and GoodbyeServer read it for their
+// timestamps, stream IDs and durations, and ResponseCache and
+// IdempotencyCache for their expiry, so tests can fix what they report.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real Clock, backed by time.Now.
+type SystemClock struct{}
+
+// Now implements Clock.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FakeClock is a Clock that stands still at a set time until moved, for
+// tests. It is safe for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now implements Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set moves the clock to now.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// clockNow reads c, falling back to the system clock when c is nil, as it is
+// for servers built without their constructor.
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
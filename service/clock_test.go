@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestFakeClockFixesTimestampHeaders(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	conn := dialServices(t,
+		NewHelloServer(WithClock(NewFakeClock(at))),
+		NewGoodbyeServer(WithGoodbyeClock(NewFakeClock(at))))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var helloHeader, goodbyeHeader metadata.MD
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Header(&helloHeader)); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if _, err := goodbye.NewFarewellClient(conn).SayGoodbye(ctx, &goodbye.GoodbyeRequest{Name: "World"}, grpc.Header(&goodbyeHeader)); err != nil {
+		t.Fatalf("SayGoodbye: %v", err)
+	}
+
+	for method, header := range map[string]metadata.MD{"SayHello": helloHeader, "SayGoodbye": goodbyeHeader} {
+		if got := header.Get("timestamp"); len(got) != 1 || got[0] != "2024-03-15T09:30:00Z" {
+			t.Errorf("%s timestamp header = %v, want 2024-03-15T09:30:00Z", method, got)
+		}
+	}
+
+	// Response IDs do not come from the clock, so they differ even though
+	// it stands still
+	var again metadata.MD
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Header(&again)); err != nil {
+		t.Fatalf("second SayHello: %v", err)
+	}
+	first, second := helloHeader.Get("response-id"), again.Get("response-id")
+	if len(first) != 1 || len(second) != 1 || first[0] == second[0] {
+		t.Errorf("response-ids under a fixed clock = %v and %v, want two different IDs", first, second)
+	}
+	if got := goodbyeHeader.Get("response-id"); len(got) != 1 || !strings.HasPrefix(got[0], "goodbye-") {
+		t.Errorf("SayGoodbye response-id = %v, want goodbye-<uuid>", got)
+	}
+}
+
+func TestCachesExpireByTheirClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC))
+
+	idempotency := NewIdempotencyCache(10, time.Minute, clock)
+	_, done, _ := idempotency.claim(context.Background(), "k")
+	done(&idempotencyEntry{key: "k"})
+	clock.Advance(59 * time.Second)
+	entry, done, _ := idempotency.claim(context.Background(), "k")
+	if entry == nil {
+		t.Error("idempotency entry expired before its TTL")
+		done(nil)
+	}
+	clock.Advance(time.Second)
+	if entry, done, _ := idempotency.claim(context.Background(), "k"); entry != nil {
+		t.Error("idempotency entry still cached at its TTL")
+	} else {
+		done(nil)
+	}
+
+	clock.Set(time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC))
+	h := testRouter{cache: NewResponseCache(10, time.Minute, clock)}.handler()
+	serve(h, httptest.NewRequest("GET", "/api/hello?name=World", nil))
+	clock.Advance(45 * time.Second)
+	rec := serve(h, httptest.NewRequest("GET", "/api/hello?name=World", nil))
+	if got := rec.Header().Get(cacheStatusHeader); got != "HIT" {
+		t.Errorf("X-Cache 45s into a 1m TTL = %q, want HIT", got)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "private, max-age=15" {
+		t.Errorf("Cache-Control 45s into a 1m TTL = %q, want private, max-age=15", got)
+	}
+	clock.Advance(15 * time.Second)
+	if got := serve(h, httptest.NewRequest("GET", "/api/hello?name=World", nil)).Header().Get(cacheStatusHeader); got != "MISS" {
+		t.Errorf("X-Cache at the TTL = %q, want MISS", got)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// JSONCodecName is the content subtype of the JSON codec. Clients pick it
+// with the application/grpc+json content type, or in Go with
+// grpc.CallContentSubtype(JSONCodecName); replies then come back as JSON too.
+const JSONCodecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes gRPC messages in their protojson form instead of binary
+// protobuf, with the same field names and strictness as the transcoded /v1
+// routes. Calls without a +json content subtype keep the proto codec.
+type jsonCodec struct{}
+
+// Marshal implements encoding.Codec.
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("json codec: cannot marshal %T, which is not a proto message", v)
+	}
+	return protojson.Marshal(msg)
+}
+
+// Unmarshal implements encoding.Codec.
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("json codec: cannot unmarshal into %T, which is not a proto message", v)
+	}
+	return protojson.Unmarshal(data, msg)
+}
+
+// Name implements encoding.Codec.
+func (jsonCodec) Name() string {
+	return JSONCodecName
+}
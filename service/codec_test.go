@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+// grpcFrame prefixes msg with the uncompressed gRPC message header.
+func grpcFrame(msg []byte) []byte {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+func TestSayHelloOverGRPCJSON(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	s := startServer(t, cfg)
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	req, err := http.NewRequest("POST", "http://"+s.Addr().String()+"/grpc.hello.Greeter/SayHello",
+		bytes.NewReader(grpcFrame([]byte(`{"name":"Json"}`))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc+json")
+	req.Header.Set("TE", "trailers")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading reply: %v", err)
+	}
+
+	if got := resp.Header.Get("Content-Type"); got != "application/grpc+json" {
+		t.Errorf("Content-Type = %q, want application/grpc+json", got)
+	}
+	if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+		t.Fatalf("grpc-status = %q (%s), want 0", got, resp.Trailer.Get("Grpc-Message"))
+	}
+	if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:5])) != len(body)-5 {
+		t.Fatalf("reply %q is not one gRPC frame", body)
+	}
+	var reply map[string]interface{}
+	if err := json.Unmarshal(body[5:], &reply); err != nil {
+		t.Fatalf("reply %q is not JSON: %v", body[5:], err)
+	}
+	if reply["message"] != "Hello Json" {
+		t.Errorf("message = %v", reply["message"])
+	}
+}
+
+func TestSayHelloWithBothCodecs(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, subtype := range []string{"proto", JSONCodecName} {
+		reply, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.CallContentSubtype(subtype))
+		if err != nil {
+			t.Errorf("SayHello with the %s codec: %v", subtype, err)
+			continue
+		}
+		if reply.GetMessage() == "" {
+			t.Errorf("SayHello with the %s codec returned an empty greeting", subtype)
+		}
+	}
+}
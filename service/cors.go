@@ -0,0 +1,80 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORSOptions is the cross-origin policy applied to every REST route.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins allowed to call the API; "*" allows
+	// any origin.
+	AllowedOrigins []string
+	// AllowedMethods and AllowedHeaders are returned in preflight responses.
+	AllowedMethods []string
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send cookies and auth headers. It
+	// requires explicit origins; with "*" the request origin is echoed back.
+	AllowCredentials bool
+}
+
+// allowsOrigin reports whether origin may call the API.
+func (o CORSOptions) allowsOrigin(origin string) bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CORSOptions) wildcard() bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORS wraps next with the cross-origin policy in opts. It answers every
+// OPTIONS preflight itself with 200, before routing, so handlers never see
+// them.
+// Requests from origins outside the policy are still served but get no
+// Access-Control-Allow-Origin header, so browsers block the response.
+func CORS(next http.Handler, opts CORSOptions) http.Handler {
+	allowMethods := strings.Join(opts.AllowedMethods, ", ")
+	allowHeaders := strings.Join(opts.AllowedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && opts.allowsOrigin(origin)
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if allowed {
+			if opts.wildcard() && !opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if opts.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		if r.Method == http.MethodOptions {
+			if allowed {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				h.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		if allowed {
+			h.Set("Access-Control-Expose-Headers", "X-Request-ID, X-Response-ID, X-Request-Completed-ID, ETag, X-Cache")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	h := CORS(testRouter{}.handler(), CORSOptions{
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "X-Request-ID"},
+	})
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/health", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		return serve(h, req)
+	}
+
+	rec := preflight("https://app.example")
+	if rec.Code != http.StatusOK {
+		t.Errorf("OPTIONS /health = %d, want 200", rec.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example",
+		"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type, X-Request-ID",
+	} {
+		if got := rec.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	rec = preflight("https://evil.example")
+	if rec.Code != http.StatusOK {
+		t.Errorf("OPTIONS /health from another origin = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin for another origin = %q, want none", got)
+	}
+}
+
+func TestCORSAllowsOnlyConfiguredOrigins(t *testing.T) {
+	h := CORS(testRouter{}.handler(), CORSOptions{AllowedOrigins: []string{"https://app.example"}})
+	get := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/hello?name=World", nil)
+		req.Header.Set("Origin", origin)
+		return serve(h, req)
+	}
+
+	rec := get("https://app.example")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Access-Control-Allow-Origin for an allowed origin = %q, want it echoed", got)
+	}
+	rec = get("https://evil.example")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin for a disallowed origin = %q, want none", got)
+	}
+	// The request is still served; the browser withholds the response
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET from a disallowed origin = %d, want 200", rec.Code)
+	}
+}
+
+func TestCORSWildcardAndCredentials(t *testing.T) {
+	get := func(opts CORSOptions) http.Header {
+		req := httptest.NewRequest("GET", "/health", nil)
+		req.Header.Set("Origin", "https://app.example")
+		return serve(CORS(testRouter{}.handler(), opts), req).Header()
+	}
+
+	if got := get(CORSOptions{AllowedOrigins: []string{"*"}}).Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin with *, no credentials = %q, want *", got)
+	}
+	// Browsers refuse * with credentials, so the origin is echoed instead
+	header := get(CORSOptions{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Access-Control-Allow-Origin with credentials = %q, want the origin echoed", got)
+	}
+	if got := header.Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want true", got)
+	}
+}
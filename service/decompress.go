@@ -0,0 +1,61 @@
+package service
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+)
+
+// supportedContentEncodings is sent in the Accept-Encoding header of a 415
+// response, as RFC 7694 suggests.
+const supportedContentEncodings = "gzip, deflate"
+
+// DecompressRequestBody wraps next so that request bodies sent with
+// Content-Encoding gzip or deflate (zlib, as HTTP defines it) are
+// decompressed before next reads them. Handlers see the plain body without
+// the Content-Encoding header. Other encodings, including stacked ones such
+// as "gzip, gzip", get a 415 Unsupported Media Type; a body that is not valid
+// for its encoding gets a 400. Wrap the result of LimitRequestBody so the
+// limit applies to the decompressed body.
+func DecompressRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var body io.ReadCloser
+		var err error
+		switch encoding {
+		case "gzip", "x-gzip":
+			body, err = gzip.NewReader(r.Body)
+		case "deflate":
+			body, err = zlib.NewReader(r.Body)
+		default:
+			w.Header().Set("Accept-Encoding", supportedContentEncodings)
+			writeError(w, http.StatusUnsupportedMediaType, codes.InvalidArgument,
+				fmt.Sprintf("unsupported Content-Encoding %q: must be gzip or deflate", encoding))
+			return
+		}
+		if err != nil {
+			slog.WarnContext(r.Context(), "HTTP: Could not decompress request body", "encoding", encoding, "error", err)
+			writeError(w, http.StatusBadRequest, codes.InvalidArgument,
+				fmt.Sprintf("request body is not valid %s data", encoding))
+			return
+		}
+		defer body.Close()
+
+		r.Body = body
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// compressedPost returns a POST of body to /api/hello, compressed by a writer
+// from newWriter and labelled with encoding.
+func compressedPost(t *testing.T, encoding string, newWriter func(io.Writer) io.WriteCloser, body string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := newWriter(&buf)
+	if _, err := io.WriteString(zw, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := postJSON("/api/hello", buf.String())
+	req.Header.Set("Content-Encoding", encoding)
+	return req
+}
+
+func TestDecompressRequestBody(t *testing.T) {
+	h := DecompressRequestBody(testRouter{}.handler())
+	const body = `{"name": "Zipped"}`
+
+	for encoding, newWriter := range map[string]func(io.Writer) io.WriteCloser{
+		"gzip":    func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) },
+		"deflate": func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) },
+	} {
+		rec := serve(h, compressedPost(t, encoding, newWriter, body))
+		if rec.Code != http.StatusOK {
+			t.Errorf("POST with Content-Encoding %s = %d: %s", encoding, rec.Code, rec.Body)
+			continue
+		}
+		if msg, _ := decodeBody(t, rec)["message"].(string); !strings.Contains(msg, "Zipped") {
+			t.Errorf("POST with Content-Encoding %s greeted %q, want Zipped", encoding, msg)
+		}
+	}
+
+	req := postJSON("/api/hello", body)
+	req.Header.Set("Content-Encoding", "br")
+	rec := serve(h, req)
+	if rec.Code != http.StatusUnsupportedMediaType || rec.Header().Get("Accept-Encoding") != supportedContentEncodings {
+		t.Errorf("POST with Content-Encoding br = %d, Accept-Encoding %q, want 415 listing %s",
+			rec.Code, rec.Header().Get("Accept-Encoding"), supportedContentEncodings)
+	}
+
+	req = postJSON("/api/hello", body)
+	req.Header.Set("Content-Encoding", "gzip")
+	if rec := serve(h, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST of plain JSON labelled gzip = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"encoding/base64"
+	"net/http"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// descriptorSet returns the compiled hello and goodbye proto files, and any
+// files they import, as a FileDescriptorSet. Dependencies come before the
+// files that import them, as protodesc.NewFiles expects.
+func descriptorSet() *descriptorpb.FileDescriptorSet {
+	set := &descriptorpb.FileDescriptorSet{}
+	seen := map[string]bool{}
+
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		set.File = append(set.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	add(hello.File_proto_hello_hello_proto)
+	add(goodbye.File_proto_goodbye_goodbye_proto)
+
+	return set
+}
+
+// handleDescriptors serves the FileDescriptorSet of the hello and goodbye
+// services so clients can build dynamic messages without gRPC reflection.
+// The set is sent as binary protobuf, or base64 encoded with
+// ?format=base64.
+func handleDescriptors(w http.ResponseWriter, r *http.Request) {
+	data, err := proto.Marshal(descriptorSet())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codes.Internal, "Failed to encode descriptors")
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "binary":
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+	case "base64":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(base64.StdEncoding.EncodeToString(data)))
+	default:
+		writeError(w, http.StatusBadRequest, codes.InvalidArgument, "format must be binary or base64, got "+format)
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDescriptorsServeBothServices(t *testing.T) {
+	h := testRouter{}.handler()
+	decode := func(format string, data []byte) *descriptorpb.FileDescriptorSet {
+		t.Helper()
+		if format == "base64" {
+			var err error
+			if data, err = base64.StdEncoding.DecodeString(string(data)); err != nil {
+				t.Fatalf("format %s: decoding base64: %v", format, err)
+			}
+		}
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := proto.Unmarshal(data, set); err != nil {
+			t.Fatalf("format %s: decoding FileDescriptorSet: %v", format, err)
+		}
+		return set
+	}
+
+	for _, format := range []string{"binary", "base64"} {
+		rec := serve(h, httptest.NewRequest("GET", "/api/descriptors?format="+format, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/descriptors?format=%s = %d", format, rec.Code)
+		}
+		// The set must be self-contained to build dynamic messages from
+		files, err := protodesc.NewFiles(decode(format, rec.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("format %s: building files: %v", format, err)
+		}
+		for _, service := range []string{"grpc.hello.Greeter", "grpc.goodbye.Farewell"} {
+			if _, err := files.FindDescriptorByName(protoreflect.FullName(service)); err != nil {
+				t.Errorf("format %s: service %s missing: %v", format, service, err)
+			}
+		}
+	}
+
+	if rec := serve(h, httptest.NewRequest("GET", "/api/descriptors?format=yaml", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/descriptors?format=yaml = %d, want 400", rec.Code)
+	}
+}
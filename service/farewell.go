@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// DefaultFarewellTemplate is the farewell SayGoodbye and SayGoodbyeWithReason
+// send unless the server is given another template, e.g.
+// "Goodbye World! See you later!" or, with a reason,
+// "Goodbye World, off to lunch! See you later!".
+const DefaultFarewellTemplate = "Goodbye {{.Name}}{{with .Reason}}, {{.}}{{end}}! See you later!"
+
+// FarewellData is what a farewell template is executed with.
+type FarewellData struct {
+	Name string
+	// Reason is the reason given to SayGoodbyeWithReason; empty for
+	// SayGoodbye.
+	Reason string
+}
+
+// FarewellTemplate renders farewell messages from a text/template with
+// {{.Name}} and {{.Reason}} placeholders.
+type FarewellTemplate struct {
+	tmpl *template.Template
+}
+
+// defaultFarewell is the parsed DefaultFarewellTemplate.
+var defaultFarewell = mustParseFarewellTemplate(DefaultFarewellTemplate)
+
+// ParseFarewellTemplate parses text as a farewell template. It fails if text
+// is not a valid template, refers to fields other than Name and Reason, or
+// never renders the name.
+func ParseFarewellTemplate(text string) (*FarewellTemplate, error) {
+	tmpl, err := template.New("farewell").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid goodbye template %q: %w", text, err)
+	}
+	t := &FarewellTemplate{tmpl: tmpl}
+	// Dry-run the template so mistakes such as {{.Nmae}} fail at startup
+	// rather than on the first call
+	const probe = "\x00name\x00"
+	rendered, err := t.Render(FarewellData{Name: probe, Reason: "reason"})
+	if err != nil {
+		return nil, fmt.Errorf("invalid goodbye template %q: %w", text, err)
+	}
+	if !strings.Contains(rendered, probe) {
+		return nil, fmt.Errorf("invalid goodbye template %q: must include {{.Name}}", text)
+	}
+	return t, nil
+}
+
+func mustParseFarewellTemplate(text string) *FarewellTemplate {
+	t, err := ParseFarewellTemplate(text)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Render executes the template with data.
+func (t *FarewellTemplate) Render(data FarewellData) (string, error) {
+	var b strings.Builder
+	if err := t.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/goodbye"
+)
+
+func TestCustomFarewellTemplate(t *testing.T) {
+	tmpl, err := ParseFarewellTemplate("Farewell, {{.Name}}{{with .Reason}} ({{.}}){{end}}.")
+	if err != nil {
+		t.Fatalf("ParseFarewellTemplate: %v", err)
+	}
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer(WithFarewellTemplate(tmpl))))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reply, err := farewell.SayGoodbye(ctx, &goodbye.GoodbyeRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayGoodbye: %v", err)
+	}
+	if got := reply.GetMessage(); got != "Farewell, World." {
+		t.Errorf("SayGoodbye = %q, want Farewell, World.", got)
+	}
+	withReason, err := farewell.SayGoodbyeWithReason(ctx, &goodbye.GoodbyeWithReasonRequest{Name: "World", Reason: "off to lunch"})
+	if err != nil {
+		t.Fatalf("SayGoodbyeWithReason: %v", err)
+	}
+	if got := withReason.GetMessage(); got != "Farewell, World (off to lunch)." {
+		t.Errorf("SayGoodbyeWithReason = %q, want Farewell, World (off to lunch).", got)
+	}
+
+	// Without a template the built-in farewell is kept
+	reply, err = goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer())).SayGoodbye(ctx, &goodbye.GoodbyeRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayGoodbye with the default template: %v", err)
+	}
+	if got := reply.GetMessage(); got != "Goodbye World! See you later!" {
+		t.Errorf("default SayGoodbye = %q, want Goodbye World! See you later!", got)
+	}
+}
+
+func TestInvalidFarewellTemplatesFailAtStartup(t *testing.T) {
+	for _, text := range []string{
+		"Goodbye {{.Name",         // does not parse
+		"Goodbye {{.Nmae}}!",      // unknown field
+		"Goodbye everyone!",       // never renders the name
+		"Goodbye {{.Name | bad}}", // unknown function
+	} {
+		if _, err := ParseFarewellTemplate(text); err == nil {
+			t.Errorf("ParseFarewellTemplate(%q) succeeded, want an error", text)
+		}
+	}
+
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.GoodbyeTemplate = "Goodbye {{.Nmae}}!"
+	if _, err := NewServer(cfg); err == nil {
+		t.Error("NewServer accepted an invalid goodbye template")
+	}
+}
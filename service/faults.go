@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Fault injection metadata keys, honored by SayHello only when the server
+// runs with fault injection enabled.
+const (
+	injectErrorKey   = "inject-error"
+	injectDelayMSKey = "inject-delay-ms"
+)
+
+// errorCategoryKey is the trailer an injected error carries to tell clients
+// how to treat it, even though the call fails before any header is sent.
+const errorCategoryKey = "error-category"
+
+// Error categories reported in the error-category trailer.
+const (
+	errorCategoryTransient = "transient"
+	errorCategoryClient    = "client"
+	errorCategoryServer    = "server"
+)
+
+// errorCategory classifies code: transient errors are worth retrying, client
+// errors need a different request, and server errors need a server fix.
+func errorCategory(code codes.Code) string {
+	switch code {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return errorCategoryTransient
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+		return errorCategoryClient
+	default:
+		return errorCategoryServer
+	}
+}
+
+// maxInjectedDelay caps the inject-delay-ms latency SayHello will add.
+const maxInjectedDelay = 10 * time.Second
+
+// injectedFault reads the requested fault from incoming metadata: a latency
+// from inject-delay-ms and a status code from inject-error, named as in the
+// gRPC spec in any case, e.g. "unavailable" or "RESOURCE_EXHAUSTED". An
+// inject-error of "ok" injects no error.
+func injectedFault(md metadata.MD) (time.Duration, codes.Code, error) {
+	var delay time.Duration
+	if values := md.Get(injectDelayMSKey); len(values) > 0 {
+		ms, err := strconv.Atoi(values[0])
+		if err != nil || ms < 0 || time.Duration(ms)*time.Millisecond > maxInjectedDelay {
+			return 0, codes.OK, status.Errorf(codes.InvalidArgument,
+				"%s must be an integer between 0 and %d, got %q", injectDelayMSKey, maxInjectedDelay.Milliseconds(), values[0])
+		}
+		delay = time.Duration(ms) * time.Millisecond
+	}
+
+	code := codes.OK
+	if values := md.Get(injectErrorKey); len(values) > 0 {
+		name := strconv.Quote(strings.ToUpper(strings.TrimSpace(values[0])))
+		if err := code.UnmarshalJSON([]byte(name)); err != nil {
+			return 0, codes.OK, status.Errorf(codes.InvalidArgument,
+				"%s must be a gRPC status code name such as unavailable, got %q", injectErrorKey, values[0])
+		}
+	}
+	return delay, code, nil
+}
+
+// injectFault applies the fault requested in ctx's metadata: it waits out
+// the injected delay, returning early if ctx ends, then returns the injected
+// error, if any. An injected error sets the error-category trailer, which
+// reaches the client with the status even though SayHello fails before
+// sending its headers.
+func injectFault(ctx context.Context, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	delay, code, err := injectedFault(md)
+	if err != nil {
+		return err
+	}
+	if delay == 0 && code == codes.OK {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "gRPC: Injecting fault", "method", method, "delay_ms", delay.Milliseconds(), "code", code)
+	if delay > 0 {
+		if err := sleepContext(ctx, delay); err != nil {
+			return status.FromContextError(err).Err()
+		}
+	}
+	if code != codes.OK {
+		grpc.SetTrailer(ctx, metadata.Pairs(errorCategoryKey, errorCategory(code)))
+		return status.Errorf(code, "injected %s error", code)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// sayHelloWith calls SayHello on greeter with the metadata pairs kv.
+func sayHelloWith(greeter hello.GreeterClient, kv ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := greeter.SayHello(metadata.AppendToOutgoingContext(ctx, kv...), &hello.HelloRequest{Name: "World"})
+	return err
+}
+
+func TestFaultInjection(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(WithFaultInjection(true)), nil))
+
+	for value, want := range map[string]codes.Code{
+		"unavailable":        codes.Unavailable,
+		"RESOURCE_EXHAUSTED": codes.ResourceExhausted,
+		"ok":                 codes.OK,
+		"teapot":             codes.InvalidArgument,
+	} {
+		if err := sayHelloWith(greeter, injectErrorKey, value); status.Code(err) != want {
+			t.Errorf("%s %s: %v, want %v", injectErrorKey, value, err, want)
+		}
+	}
+
+	start := time.Now()
+	if err := sayHelloWith(greeter, injectDelayMSKey, "200"); err != nil {
+		t.Fatalf("%s 200: %v", injectDelayMSKey, err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("%s 200 answered after %s", injectDelayMSKey, elapsed)
+	}
+	if err := sayHelloWith(greeter, injectDelayMSKey, "60000"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("%s over the cap: %v, want InvalidArgument", injectDelayMSKey, err)
+	}
+}
+
+func TestFaultInjectionOffByDefault(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+
+	start := time.Now()
+	if err := sayHelloWith(greeter, injectErrorKey, "unavailable", injectDelayMSKey, "1000"); err != nil {
+		t.Errorf("SayHello with fault metadata on a normal server: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("normal server honored %s: answered after %s", injectDelayMSKey, elapsed)
+	}
+}
+
+func TestInjectedErrorCarriesErrorCategoryTrailer(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(WithFaultInjection(true)), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for value, want := range map[string]string{
+		"unavailable":      errorCategoryTransient,
+		"invalid_argument": errorCategoryClient,
+		"internal":         errorCategoryServer,
+	} {
+		var header, trailer metadata.MD
+		_, err := greeter.SayHello(metadata.AppendToOutgoingContext(ctx, injectErrorKey, value), &hello.HelloRequest{Name: "World"},
+			grpc.Header(&header), grpc.Trailer(&trailer))
+		if err == nil {
+			t.Errorf("%s %s succeeded, want an error", injectErrorKey, value)
+			continue
+		}
+		if got := trailer.Get(errorCategoryKey); len(got) != 1 || got[0] != want {
+			t.Errorf("%s %s: %s trailer = %v, want %s", injectErrorKey, value, errorCategoryKey, got, want)
+		}
+		// The call fails before SayHello sends its headers
+		if got := header.Get("response-id"); len(got) != 0 {
+			t.Errorf("%s %s: response-id header %v sent on the error path", injectErrorKey, value, got)
+		}
+	}
+}
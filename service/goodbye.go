@@ -0,0 +1,539 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// maxGoodbyePace caps the pace-ms delay SayGoodbyeBidirectional will honor.
+const maxGoodbyePace = 10 * time.Second
+
+// DefaultGoodbyeInterval is the pause between SayGoodbyeUntilCancelled
+// messages unless the server is given another interval.
+const DefaultGoodbyeInterval = time.Second
+
+// defaultGoodbyeStreamDelay is the pause between SayGoodbyeStream messages.
+const defaultGoodbyeStreamDelay = 1500 * time.Millisecond
+
+// GoodbyeServer is used to implement goodbye.FarewellServer.
+type GoodbyeServer struct {
+	goodbye.UnimplementedFarewellServer
+	blocked map[string]bool
+	// farewell renders the farewell messages; nil uses
+	// DefaultFarewellTemplate.
+	farewell *FarewellTemplate
+	// interval is the pause between SayGoodbyeUntilCancelled messages.
+	interval time.Duration
+	// streamDelay is the pause between SayGoodbyeStream messages.
+	streamDelay time.Duration
+	// history records each farewell when set.
+	history HistoryStore
+	// clock supplies timestamps, IDs and durations.
+	clock Clock
+	// shutdown ends the streaming calls early when the server shuts down.
+	shutdown *ShutdownSignal
+}
+
+// now reads the server's clock.
+func (s *GoodbyeServer) now() time.Time {
+	return clockNow(s.clock)
+}
+
+// GoodbyeServerOption customizes a GoodbyeServer.
+type GoodbyeServerOption func(*GoodbyeServer)
+
+// WithBlockedNames sets the names SayGoodbyeWithReason refuses. Names are
+// compared case-insensitively.
+func WithBlockedNames(names []string) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.blocked = make(map[string]bool, len(names))
+		for _, name := range names {
+			s.blocked[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// WithFarewellTemplate makes SayGoodbye and SayGoodbyeWithReason render their
+// farewells with t instead of DefaultFarewellTemplate.
+func WithFarewellTemplate(t *FarewellTemplate) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.farewell = t
+	}
+}
+
+// WithGoodbyeInterval sets the pause between SayGoodbyeUntilCancelled
+// messages, DefaultGoodbyeInterval by default.
+func WithGoodbyeInterval(d time.Duration) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.interval = d
+	}
+}
+
+// WithGoodbyeStreamDelay sets the pause between SayGoodbyeStream messages,
+// 1.5s by default. Zero sends without pausing, e.g. in tests.
+func WithGoodbyeStreamDelay(d time.Duration) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.streamDelay = d
+	}
+}
+
+// WithGoodbyeHistory records every SayGoodbye and SayGoodbyeWithReason
+// farewell in store.
+func WithGoodbyeHistory(store HistoryStore) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.history = store
+	}
+}
+
+// WithGoodbyeClock makes the server read the time from clock instead of the
+// system clock, e.g. a FakeClock in tests.
+func WithGoodbyeClock(clock Clock) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.clock = clock
+	}
+}
+
+// WithGoodbyeShutdownSignal makes the streaming methods end early, with the
+// shutdown-in-progress trailer, once shutdown fires.
+func WithGoodbyeShutdownSignal(shutdown *ShutdownSignal) GoodbyeServerOption {
+	return func(s *GoodbyeServer) {
+		s.shutdown = shutdown
+	}
+}
+
+// NewGoodbyeServer returns a ready-to-register Farewell implementation.
+// Without options no names are blocked and farewells follow
+// DefaultFarewellTemplate.
+func NewGoodbyeServer(opts ...GoodbyeServerOption) *GoodbyeServer {
+	s := &GoodbyeServer{farewell: defaultFarewell, interval: DefaultGoodbyeInterval,
+		streamDelay: defaultGoodbyeStreamDelay, clock: SystemClock{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// farewellMessage renders the farewell for name and reason, failing with
+// codes.Internal if the template cannot be executed.
+func (s *GoodbyeServer) farewellMessage(name, reason string) (string, error) {
+	farewell := s.farewell
+	if farewell == nil {
+		farewell = defaultFarewell
+	}
+	message, err := farewell.Render(FarewellData{Name: name, Reason: reason})
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "could not render farewell: %v", err)
+	}
+	return message, nil
+}
+
+// goodbyePace reads the optional delay SayGoodbyeBidirectional waits after
+// each reply from the pace-ms metadata key. Without it replies are sent as
+// fast as the client sends names.
+func goodbyePace(md metadata.MD) (time.Duration, error) {
+	values := md.Get("pace-ms")
+	if len(values) == 0 {
+		return 0, nil
+	}
+	ms, err := strconv.Atoi(values[0])
+	if err != nil || ms < 0 || time.Duration(ms)*time.Millisecond > maxGoodbyePace {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"pace-ms must be an integer between 0 and %d, got %q", maxGoodbyePace.Milliseconds(), values[0])
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// SayGoodbye implements goodbye.FarewellServer
+func (s *GoodbyeServer) SayGoodbye(ctx context.Context, in *goodbye.GoodbyeRequest) (_ *goodbye.GoodbyeReply, err error) {
+	start := s.now()
+	slog.InfoContext(ctx, "gRPC: Received goodbye request", "method", "SayGoodbye", "name", in.GetName(), callerAttr(ctx))
+	defer func() {
+		slog.InfoContext(ctx, "gRPC: Completed goodbye request", "method", "SayGoodbye", "name", in.GetName(),
+			"code", status.Code(err).String(), "duration_ms", s.now().Sub(start).Milliseconds())
+	}()
+
+	logIncomingMetadata(ctx, slog.Default(), "gRPC: Goodbye incoming metadata", "method", "SayGoodbye")
+
+	// Set response headers, with a response-id in the form SayHello uses
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayGoodbye",
+		"timestamp", s.now().Format(time.RFC3339),
+		"farewell-type", "friendly",
+		"response-id", newResponseID("goodbye"),
+	)
+	grpc.SendHeader(ctx, header)
+
+	// Set response trailers
+	trailer := metadata.Pairs(
+		"goodbye-processed", "true",
+		"session-ended", s.now().Format(time.RFC3339),
+	)
+	grpc.SetTrailer(ctx, trailer)
+
+	message, err := s.farewellMessage(in.GetName(), "")
+	if err != nil {
+		return nil, err
+	}
+
+	recordHistory(ctx, s.history, "SayGoodbye", in.GetName(), message, s.now())
+	return &goodbye.GoodbyeReply{Message: message}, nil
+}
+
+// nameBlockedReason is the ErrorInfo reason SayGoodbyeWithReason reports for
+// blocked names.
+const nameBlockedReason = "NAME_BLOCKED"
+
+// errorInfoDomain is the ErrorInfo domain of errors raised by this service.
+const errorInfoDomain = "grpc-sample"
+
+// SayGoodbyeWithReason implements goodbye.FarewellServer. A name on the
+// blocklist is refused with PermissionDenied; the status carries an
+// errdetails.ErrorInfo naming the blocked name so clients can tell the
+// refusal apart from other permission errors.
+func (s *GoodbyeServer) SayGoodbyeWithReason(ctx context.Context, in *goodbye.GoodbyeWithReasonRequest) (*goodbye.GoodbyeReply, error) {
+	slog.InfoContext(ctx, "gRPC: Received goodbye with reason request", "method", "SayGoodbyeWithReason",
+		"name", in.GetName(), "reason", in.GetReason())
+
+	if s.blocked[strings.ToLower(in.GetName())] {
+		st := status.Newf(codes.PermissionDenied, "%s is not allowed to say goodbye", in.GetName())
+		detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+			Reason:   nameBlockedReason,
+			Domain:   errorInfoDomain,
+			Metadata: map[string]string{"name": in.GetName()},
+		})
+		if err != nil {
+			return nil, st.Err()
+		}
+		return nil, detailed.Err()
+	}
+
+	grpc.SendHeader(ctx, metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayGoodbyeWithReason",
+	))
+
+	message, err := s.farewellMessage(in.GetName(), in.GetReason())
+	if err != nil {
+		return nil, err
+	}
+	recordHistory(ctx, s.history, "SayGoodbyeWithReason", in.GetName(), message, s.now())
+	return &goodbye.GoodbyeReply{Message: message}, nil
+}
+
+// SayGoodbyeStream implements goodbye.FarewellServer
+func (s *GoodbyeServer) SayGoodbyeStream(in *goodbye.GoodbyeRequest, stream goodbye.Farewell_SayGoodbyeStreamServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("goodbye-stream-%d", start.Unix())
+	logger := slog.With("method", "SayGoodbyeStream", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received goodbye stream request", "name", in.GetName())
+
+	logIncomingMetadata(ctx, logger, "gRPC: Goodbye stream incoming metadata")
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayGoodbyeStream",
+		"stream-id", streamID,
+		"expected-messages", "3",
+		"farewell-type", "streaming",
+	)
+	stream.SendHeader(header)
+
+	// Send multiple goodbye messages
+	goodbyeMessages := []string{
+		"Thanks for using our service, %s!",
+		"It was great having you, %s!",
+		"Until we meet again, %s! Farewell!",
+	}
+
+	for i, template := range goodbyeMessages {
+		reply := &goodbye.GoodbyeReply{
+			Message: fmt.Sprintf(template, in.GetName()),
+		}
+
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+
+		logger.DebugContext(ctx, "gRPC: Sent goodbye message", "message_number", i+1, "message", reply.Message)
+
+		// Add a delay between messages, stopping early if the client goes away
+		if err := sleepContext(ctx, s.streamDelay); err != nil {
+			if shuttingDown(ctx) {
+				return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+					"messages-sent", strconv.Itoa(i+1),
+					"stream-duration", streamDuration(s.now().Sub(start)),
+				))
+			}
+			logger.InfoContext(ctx, "gRPC: Goodbye stream terminated early", "messages_sent", i+1, "error", err)
+			return err
+		}
+	}
+
+	// Set stream trailers
+	trailer := metadata.Pairs(
+		"messages-sent", "3",
+		"stream-duration", streamDuration(s.now().Sub(start)),
+		"stream-status", "completed",
+		"farewell-completed", s.now().Format(time.RFC3339),
+	)
+	stream.SetTrailer(trailer)
+
+	logger.InfoContext(ctx, "gRPC: Completed goodbye stream request", "name", in.GetName(),
+		"duration_ms", s.now().Sub(start).Milliseconds())
+
+	return nil
+}
+
+// SayGoodbyeUntilCancelled implements goodbye.FarewellServer. Unlike
+// SayGoodbyeStream it has no last message: it sends a numbered farewell every
+// interval until the client cancels the call or its deadline passes, ending
+// the stream with CANCELLED or DEADLINE_EXCEEDED, or the server shuts down.
+func (s *GoodbyeServer) SayGoodbyeUntilCancelled(in *goodbye.GoodbyeRequest, stream goodbye.Farewell_SayGoodbyeUntilCancelledServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("goodbye-until-cancelled-%d", start.Unix())
+	logger := slog.With("method", "SayGoodbyeUntilCancelled", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received goodbye until cancelled request", "name", in.GetName())
+
+	logIncomingMetadata(ctx, logger, "gRPC: Goodbye until cancelled incoming metadata")
+
+	interval := s.interval
+	if interval <= 0 {
+		interval = DefaultGoodbyeInterval
+	}
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayGoodbyeUntilCancelled",
+		"stream-id", streamID,
+		"interval-ms", strconv.FormatInt(interval.Milliseconds(), 10),
+		"farewell-type", "open-ended",
+	)
+	stream.SendHeader(header)
+
+	message, err := s.farewellMessage(in.GetName(), "")
+	if err != nil {
+		return err
+	}
+
+	for sent := 1; ; sent++ {
+		reply := &goodbye.GoodbyeReply{Message: fmt.Sprintf("%s (Farewell %d)", message, sent)}
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+		logger.DebugContext(ctx, "gRPC: Sent goodbye message", "message_number", sent, "message", reply.Message)
+
+		// Wait for the next message, which only the client going away or
+		// the server shutting down can cut short
+		if err := sleepContext(ctx, interval); err != nil {
+			trailer := metadata.Pairs(
+				"messages-sent", strconv.Itoa(sent),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			)
+			if shuttingDown(ctx) {
+				return endStreamForShutdown(ctx, stream, logger, trailer)
+			}
+			stream.SetTrailer(trailer)
+			logger.InfoContext(ctx, "gRPC: Goodbye until cancelled stream ended by the client", "messages_sent", sent,
+				"duration_ms", s.now().Sub(start).Milliseconds(), "error", err)
+			return status.FromContextError(err).Err()
+		}
+	}
+}
+
+// SayGoodbyeClientStream implements goodbye.FarewellServer
+func (s *GoodbyeServer) SayGoodbyeClientStream(stream goodbye.Farewell_SayGoodbyeClientStreamServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("goodbye-client-stream-%d", start.Unix())
+	logger := slog.With("method", "SayGoodbyeClientStream", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received goodbye client stream request")
+
+	logIncomingMetadata(ctx, logger, "gRPC: Goodbye client stream incoming metadata")
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayGoodbyeClientStream",
+		"stream-id", streamID,
+		"stream-type", "client-streaming",
+		"farewell-type", "batch",
+	)
+	stream.SendHeader(header)
+
+	var names []string
+	messageCount := 0
+
+	// Receive all messages from client
+	recv := recvUntilShutdown(ctx, stream.Recv)
+	for {
+		req, err := recv()
+		if err == io.EOF {
+			// Client finished sending
+			break
+		}
+		if shuttingDown(ctx) {
+			return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+				"messages-received", strconv.Itoa(messageCount),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+		}
+		if err != nil {
+			return err
+		}
+		messageCount++
+		names = append(names, req.GetName())
+		logger.DebugContext(ctx, "gRPC: Received goodbye client stream message", "name", req.GetName(), "message_number", messageCount)
+	}
+
+	// Send single farewell response with summary
+	summary := fmt.Sprintf("Farewell to all %d wonderful people: %s! May your paths be bright!", len(names), strings.Join(names, ", "))
+
+	// Set response trailers
+	trailer := metadata.Pairs(
+		"messages-received", fmt.Sprintf("%d", messageCount),
+		"names-processed", strings.Join(names, ","),
+		"stream-status", "completed",
+		"farewell-type", "collective",
+		"session-ended", s.now().Format(time.RFC3339),
+		"stream-duration", streamDuration(s.now().Sub(start)),
+	)
+	stream.SetTrailer(trailer)
+
+	logger.InfoContext(ctx, "gRPC: Completed goodbye client stream request", "messages_received", messageCount,
+		"duration_ms", s.now().Sub(start).Milliseconds())
+
+	return stream.SendAndClose(&goodbye.GoodbyeReply{Message: summary})
+}
+
+// SayGoodbyeBidirectional implements goodbye.FarewellServer
+func (s *GoodbyeServer) SayGoodbyeBidirectional(stream goodbye.Farewell_SayGoodbyeBidirectionalServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("goodbye-bidi-stream-%d", start.Unix())
+	logger := slog.With("method", "SayGoodbyeBidirectional", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received goodbye bidirectional stream request")
+
+	logIncomingMetadata(ctx, logger, "gRPC: Goodbye bidirectional stream incoming metadata")
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	pace, err := goodbyePace(md)
+	if err != nil {
+		return err
+	}
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayGoodbyeBidirectional",
+		"stream-id", streamID,
+		"stream-type", "bidirectional",
+		"farewell-type", "interactive",
+	)
+	stream.SendHeader(header)
+
+	messageCount := 0
+	var processedNames []string
+	farewellMessages := []string{
+		"Take care, %s!",
+		"Safe travels, %s!",
+		"Until next time, %s!",
+		"Farewell, dear %s!",
+		"Goodbye and good luck, %s!",
+	}
+
+	// Handle bidirectional streaming
+	recv := recvUntilShutdown(ctx, stream.Recv)
+	for {
+		// Stop as soon as the client cancels or the server shuts down, even
+		// between messages
+		if shuttingDown(ctx) {
+			return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+				"farewells-exchanged", strconv.Itoa(messageCount),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+		}
+		if err := ctx.Err(); err != nil {
+			logger.InfoContext(ctx, "gRPC: Goodbye bidirectional stream terminated early", "farewells_exchanged", messageCount, "error", err)
+			return status.FromContextError(err).Err()
+		}
+
+		req, err := recv()
+		if err == io.EOF {
+			// Client finished sending
+			break
+		}
+		if shuttingDown(ctx) {
+			return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+				"farewells-exchanged", strconv.Itoa(messageCount),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+		}
+		if err != nil {
+			return err
+		}
+
+		messageCount++
+		name := req.GetName()
+		processedNames = append(processedNames, name)
+		logger.DebugContext(ctx, "gRPC: Received goodbye bidirectional message", "name", name, "message_number", messageCount)
+
+		// Send personalized farewell response for each received message
+		farewellTemplate := farewellMessages[(messageCount-1)%len(farewellMessages)]
+		response := fmt.Sprintf(farewellTemplate+" (Farewell %d)", name, messageCount)
+		if err := stream.Send(&goodbye.GoodbyeReply{Message: response}); err != nil {
+			return err
+		}
+
+		// Reply as fast as the client sends unless pace-ms asked for a delay,
+		// stopping early if the client goes away
+		if pace > 0 {
+			if err := sleepContext(ctx, pace); err != nil {
+				if shuttingDown(ctx) {
+					return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+						"farewells-exchanged", strconv.Itoa(messageCount),
+						"stream-duration", streamDuration(s.now().Sub(start)),
+					))
+				}
+				logger.InfoContext(ctx, "gRPC: Goodbye bidirectional stream terminated early", "farewells_exchanged", messageCount, "error", err)
+				return status.FromContextError(err).Err()
+			}
+		}
+	}
+
+	// Set stream trailers
+	trailer := metadata.Pairs(
+		"farewells-exchanged", fmt.Sprintf("%d", messageCount),
+		"names-processed", strings.Join(processedNames, ","),
+		"stream-status", "completed",
+		"stream-duration", streamDuration(s.now().Sub(start)),
+		"final-farewell", s.now().Format(time.RFC3339),
+	)
+	stream.SetTrailer(trailer)
+
+	logger.InfoContext(ctx, "gRPC: Completed goodbye bidirectional stream request", "farewells_exchanged", messageCount,
+		"duration_ms", s.now().Sub(start).Milliseconds())
+
+	return nil
+}
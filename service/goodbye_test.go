@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestSayGoodbyeStreamStopsWhenClientCancels(t *testing.T) {
+	logs := captureLogs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeStream{ctx: ctx, onSend: func(n int) {
+		if n == 1 {
+			cancel()
+		}
+	}}
+
+	start := time.Now()
+	err := NewGoodbyeServer().SayGoodbyeStream(&goodbye.GoodbyeRequest{Name: "World"},
+		&grpc.GenericServerStream[goodbye.GoodbyeRequest, goodbye.GoodbyeReply]{ServerStream: stream})
+	// Running to completion takes three 1.5-second pauses
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("handler returned after %s, want it to stop at the cancel", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SayGoodbyeStream = %v, want context.Canceled", err)
+	}
+	if len(stream.sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(stream.sent))
+	}
+	if record := logRecord(t, logs, "gRPC: Goodbye stream terminated early"); record["messages_sent"] != float64(1) {
+		t.Errorf("messages_sent = %v, want 1", record["messages_sent"])
+	}
+}
+
+// exchangeGoodbyes sends n names on a SayGoodbyeBidirectional stream with
+// ctx's metadata, reads a reply after each, and returns how long it took.
+func exchangeGoodbyes(t *testing.T, ctx context.Context, farewell goodbye.FarewellClient, n int) time.Duration {
+	t.Helper()
+	start := time.Now()
+	stream, err := farewell.SayGoodbyeBidirectional(ctx)
+	if err != nil {
+		t.Fatalf("SayGoodbyeBidirectional: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		if err := stream.Send(&goodbye.GoodbyeRequest{Name: "World"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv %d: %v", i+1, err)
+		}
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("stream ended with %v, want EOF", err)
+	}
+	return time.Since(start)
+}
+
+func TestSayGoodbyeBidirectionalRepliesWithoutPacing(t *testing.T) {
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The old fixed 750ms pause would make this take 7.5 seconds
+	if elapsed := exchangeGoodbyes(t, ctx, farewell, 10); elapsed > time.Second {
+		t.Errorf("10 unpaced farewells took %s", elapsed)
+	}
+	if elapsed := exchangeGoodbyes(t, metadata.AppendToOutgoingContext(ctx, "pace-ms", "100"), farewell, 3); elapsed < 300*time.Millisecond {
+		t.Errorf("3 farewells at pace-ms 100 took %s, want at least 300ms", elapsed)
+	}
+
+	stream, err := farewell.SayGoodbyeBidirectional(metadata.AppendToOutgoingContext(ctx, "pace-ms", "-1"))
+	if err != nil {
+		t.Fatalf("SayGoodbyeBidirectional: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("pace-ms -1: %v, want InvalidArgument", err)
+	}
+}
+
+func TestSayGoodbyeWithReasonBlockedNameCarriesErrorInfo(t *testing.T) {
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer(WithBlockedNames([]string{"Voldemort"}))))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := farewell.SayGoodbyeWithReason(ctx, &goodbye.GoodbyeWithReasonRequest{Name: "voldemort", Reason: "leaving"})
+	st := status.Convert(err)
+	if st.Code() != codes.PermissionDenied {
+		t.Fatalf("blocked name: %v, want PermissionDenied", err)
+	}
+	details := st.Details()
+	if len(details) != 1 {
+		t.Fatalf("got %d details, want 1: %v", len(details), details)
+	}
+	info, ok := details[0].(*errdetails.ErrorInfo)
+	if !ok {
+		t.Fatalf("detail is %T, want *errdetails.ErrorInfo", details[0])
+	}
+	if info.GetReason() != nameBlockedReason || info.GetDomain() != errorInfoDomain || info.GetMetadata()["name"] != "voldemort" {
+		t.Errorf("ErrorInfo = %v, want %s in %s for voldemort", info, nameBlockedReason, errorInfoDomain)
+	}
+
+	if _, err := farewell.SayGoodbyeWithReason(ctx, &goodbye.GoodbyeWithReasonRequest{Name: "World", Reason: "leaving"}); err != nil {
+		t.Errorf("allowed name: %v", err)
+	}
+}
+
+func TestStreamDurationTrailerMatchesWallClock(t *testing.T) {
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer()))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var trailer metadata.MD
+	stream, err := farewell.SayGoodbyeBidirectional(metadata.AppendToOutgoingContext(ctx, "pace-ms", "100"), grpc.Trailer(&trailer))
+	if err != nil {
+		t.Fatalf("SayGoodbyeBidirectional: %v", err)
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := stream.Send(&goodbye.GoodbyeRequest{Name: "World"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv %d: %v", i+1, err)
+		}
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("stream ended with %v, want EOF", err)
+	}
+	elapsed := time.Since(start)
+
+	got := trailer.Get("stream-duration")
+	if len(got) != 1 {
+		t.Fatalf("stream-duration trailer = %v, want one value", got)
+	}
+	reported, err := time.ParseDuration(got[0])
+	if err != nil {
+		t.Fatalf("stream-duration %q: %v", got[0], err)
+	}
+	// 4 messages at the old 0.75s each would report 3s
+	if diff := elapsed - reported; diff < -250*time.Millisecond || diff > 250*time.Millisecond {
+		t.Errorf("stream-duration = %s, want within 250ms of the measured %s", reported, elapsed)
+	}
+}
+
+func TestSayGoodbyeUntilCancelledStopsAtCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeStream{ctx: ctx, onSend: func(n int) {
+		if n == 5 {
+			cancel()
+		}
+	}}
+
+	err := NewGoodbyeServer(WithGoodbyeInterval(time.Millisecond)).SayGoodbyeUntilCancelled(&goodbye.GoodbyeRequest{Name: "World"},
+		&grpc.GenericServerStream[goodbye.GoodbyeRequest, goodbye.GoodbyeReply]{ServerStream: stream})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("SayGoodbyeUntilCancelled = %v, want Canceled", err)
+	}
+	if len(stream.sent) != 5 {
+		t.Fatalf("sent %d messages, want 5, the last before the cancel", len(stream.sent))
+	}
+	if got := stream.sent[4].(*goodbye.GoodbyeReply).GetMessage(); got != "Goodbye World! See you later! (Farewell 5)" {
+		t.Errorf("fifth message = %q", got)
+	}
+	if got := stream.header.Get("interval-ms"); len(got) != 1 || got[0] != "1" {
+		t.Errorf("interval-ms header = %v, want 1", got)
+	}
+	if got := stream.trailer.Get("messages-sent"); len(got) != 1 || got[0] != "5" {
+		t.Errorf("messages-sent trailer = %v, want 5", got)
+	}
+}
+
+func TestSayGoodbyeUntilCancelledOverGRPC(t *testing.T) {
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer(WithGoodbyeInterval(10*time.Millisecond))))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	streamCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	stream, err := farewell.SayGoodbyeUntilCancelled(streamCtx, &goodbye.GoodbyeRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayGoodbyeUntilCancelled: %v", err)
+	}
+	// More than the fixed three of SayGoodbyeStream
+	for i := 0; i < 4; i++ {
+		if _, err := stream.Recv(); err != nil {
+			t.Fatalf("Recv %d: %v", i+1, err)
+		}
+	}
+	stop()
+	// Whatever was in flight may still arrive, then the stream ends
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if status.Code(err) != codes.Canceled {
+				t.Errorf("stream after cancel ended with %v, want Canceled", err)
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Greeter produces the message SayHello replies with. Implementations must be
+// safe for concurrent use.
+type Greeter interface {
+	Greet(ctx context.Context, name string) string
+}
+
+// GreeterFunc adapts an ordinary function to the Greeter interface.
+type GreeterFunc func(ctx context.Context, name string) string
+
+// Greet calls f(ctx, name).
+func (f GreeterFunc) Greet(ctx context.Context, name string) string {
+	return f(ctx, name)
+}
+
+// PlainGreeter replies "Hello <name>". It is the default.
+type PlainGreeter struct{}
+
+// Greet implements Greeter.
+func (PlainGreeter) Greet(_ context.Context, name string) string {
+	return "Hello " + name
+}
+
+// EnthusiasticGreeter replies "Hello <name>!!!".
+type EnthusiasticGreeter struct{}
+
+// Greet implements Greeter.
+func (EnthusiasticGreeter) Greet(_ context.Context, name string) string {
+	return "Hello " + name + "!!!"
+}
+
+// TimeOfDayGreeter replies "Good morning/afternoon/evening <name>" based on
+// the server's local time. Now defaults to time.Now.
+type TimeOfDayGreeter struct {
+	Now func() time.Time
+}
+
+// Greet implements Greeter.
+func (g TimeOfDayGreeter) Greet(_ context.Context, name string) string {
+	now := time.Now
+	if g.Now != nil {
+		now = g.Now
+	}
+	switch hour := now().Hour(); {
+	case hour < 12:
+		return "Good morning " + name
+	case hour < 18:
+		return "Good afternoon " + name
+	default:
+		return "Good evening " + name
+	}
+}
+
+// CoalescingGreeter wraps a Greeter so that concurrent calls for the same
+// name share a single Greet call, for greeters that do real work per name.
+// The shared greeting is produced with the first caller's context, so only
+// wrap greeters whose result depends on the name alone.
+type CoalescingGreeter struct {
+	next      Greeter
+	group     singleflight.Group
+	coalesced atomic.Int64
+}
+
+// NewCoalescingGreeter returns a CoalescingGreeter in front of next.
+func NewCoalescingGreeter(next Greeter) *CoalescingGreeter {
+	return &CoalescingGreeter{next: next}
+}
+
+// Greet implements Greeter, waiting for an in-flight call for the same name
+// instead of starting another.
+func (g *CoalescingGreeter) Greet(ctx context.Context, name string) string {
+	ran := false
+	v, _, _ := g.group.Do(name, func() (interface{}, error) {
+		ran = true
+		return g.next.Greet(ctx, name), nil
+	})
+	if !ran {
+		g.coalesced.Add(1)
+		slog.DebugContext(ctx, "gRPC: Coalesced greeting with an in-flight call", "name", name)
+	}
+	return v.(string)
+}
+
+// Coalesced returns how many calls were answered with the greeting of
+// another call in flight for the same name.
+func (g *CoalescingGreeter) Coalesced() int64 {
+	return g.coalesced.Load()
+}
+
+// greetingStyles maps the names accepted in configuration and in the
+// greeting-style metadata key to the built-in greeters.
+var greetingStyles = map[string]Greeter{
+	"plain":        PlainGreeter{},
+	"enthusiastic": EnthusiasticGreeter{},
+	"time-of-day":  TimeOfDayGreeter{},
+}
+
+// GreeterForStyle returns the built-in greeter with the given style name.
+func GreeterForStyle(style string) (Greeter, error) {
+	g, ok := greetingStyles[strings.ToLower(strings.TrimSpace(style))]
+	if !ok {
+		return nil, fmt.Errorf("unknown greeting style %q: must be one of %s", style, strings.Join(greetingStyleNames(), ", "))
+	}
+	return g, nil
+}
+
+func greetingStyleNames() []string {
+	names := make([]string, 0, len(greetingStyles))
+	for name := range greetingStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// greeterFor returns the greeter for a call: the built-in style named by the
+// greeting-style metadata key when present, otherwise def.
+func greeterFor(ctx context.Context, def Greeter) (Greeter, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("greeting-style")
+	if len(values) == 0 || values[0] == "" {
+		return def, nil
+	}
+	g, err := GreeterForStyle(values[0])
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return g, nil
+}
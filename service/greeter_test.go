@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestSayHelloUsesInjectedGreeter(t *testing.T) {
+	var got string
+	srv := NewHelloServer(WithGreeter(GreeterFunc(func(_ context.Context, name string) string {
+		got = name
+		return "Howdy, " + name
+	})))
+
+	reply, err := srv.SayHello(context.Background(), &hello.HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if reply.GetMessage() != "Howdy, World" {
+		t.Errorf("message = %q, want %q", reply.GetMessage(), "Howdy, World")
+	}
+	if got != "World" {
+		t.Errorf("greeter called with %q, want World", got)
+	}
+
+	reply, err = NewHelloServer().SayHello(context.Background(), &hello.HelloRequest{Name: "World"})
+	if err != nil || reply.GetMessage() != "Hello World" {
+		t.Errorf("default SayHello = %q, %v, want %q", reply.GetMessage(), err, "Hello World")
+	}
+}
+
+func TestSayHelloGreetingStyleMetadata(t *testing.T) {
+	srv := NewHelloServer()
+	sayHello := func(style string) (string, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("greeting-style", style))
+		reply, err := srv.SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		return reply.GetMessage(), err
+	}
+
+	if got, err := sayHello("Enthusiastic"); err != nil || got != "Hello World!!!" {
+		t.Errorf("enthusiastic style = %q, %v, want %q", got, err, "Hello World!!!")
+	}
+	if _, err := sayHello("shouty"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown style = %v, want InvalidArgument", err)
+	}
+}
+
+func TestTimeOfDayGreeter(t *testing.T) {
+	for hour, want := range map[int]string{
+		9:  "Good morning World",
+		14: "Good afternoon World",
+		20: "Good evening World",
+	} {
+		g := TimeOfDayGreeter{Now: func() time.Time { return time.Date(2024, 1, 1, hour, 0, 0, 0, time.Local) }}
+		if got := g.Greet(context.Background(), "World"); got != want {
+			t.Errorf("at %d:00 = %q, want %q", hour, got, want)
+		}
+	}
+}
+
+func TestCoalescingRunsGeneratorOnceForConcurrentCalls(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	srv := NewHelloServer(WithCoalescing(true), WithGreeter(GreeterFunc(func(_ context.Context, name string) string {
+		runs.Add(1)
+		<-release
+		return "Hello " + name
+	})))
+
+	const n = 10
+	var wg sync.WaitGroup
+	replies := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, err := srv.SayHello(context.Background(), &hello.HelloRequest{Name: "World"})
+			if err != nil {
+				t.Errorf("SayHello: %v", err)
+				return
+			}
+			replies <- reply.GetMessage()
+		}()
+	}
+	// Let every call reach the greeter before the first one finishes
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(replies)
+
+	for msg := range replies {
+		if msg != "Hello World" {
+			t.Errorf("coalesced reply = %q, want Hello World", msg)
+		}
+	}
+	if got := runs.Load(); got != 1 {
+		t.Errorf("generator ran %d times for %d concurrent calls, want 1", got, n)
+	}
+	if got := srv.CoalescedGreetings(); got != n-1 {
+		t.Errorf("CoalescedGreetings = %d, want %d", got, n-1)
+	}
+
+	// Later calls are not coalesced with finished ones
+	if _, err := srv.SayHello(context.Background(), &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if got := runs.Load(); got != 2 {
+		t.Errorf("generator ran %d times after a later call, want 2", got)
+	}
+}
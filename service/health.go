@@ -0,0 +1,202 @@
+package service
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Health reports liveness and readiness. Liveness (GET /healthz) only says
+// the process is up. Readiness (GET /readyz, GET /health and the standard
+// gRPC health service) fails until MarkReady is called at startup and again
+// once draining, so load balancers only route new work to a server that is
+// accepting it, while connections and in-flight calls are left alone.
+// Draining can be undone with Resume until Shutdown.
+type Health struct {
+	grpc *health.Server
+	// services are the gRPC health service names reported on: "" for the
+	// server as a whole, then each registered service.
+	services []string
+
+	mu       sync.Mutex
+	ready    bool
+	draining bool
+	stopped  bool
+}
+
+// NewHealth returns a Health that is live but not yet ready: it reports
+// NOT_SERVING until MarkReady for the server as a whole and for each of
+// services, the full names of the registered gRPC services.
+func NewHealth(services ...string) *Health {
+	h := &Health{grpc: health.NewServer(), services: append([]string{""}, services...)}
+	h.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+func (h *Health) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range h.services {
+		h.grpc.SetServingStatus(service, status)
+	}
+}
+
+// Register adds the grpc.health.v1.Health service to s.
+func (h *Health) Register(s *grpc.Server) {
+	healthpb.RegisterHealthServer(s, h.grpc)
+}
+
+// MarkReady marks every service SERVING once the server is accepting
+// connections. While draining it only records that the server is ready, so
+// Resume can report SERVING.
+func (h *Health) MarkReady() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ready = true
+	if !h.draining {
+		h.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+	}
+}
+
+// Ready reports whether the server is ready for new work: MarkReady has been
+// called and the server is not draining.
+func (h *Health) Ready() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ready && !h.draining
+}
+
+// Drain marks every service NOT_SERVING without closing any connections.
+// It is idempotent, and Resume undoes it until Shutdown.
+func (h *Health) Drain() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.draining {
+		return
+	}
+	h.draining = true
+	h.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	slog.Info("Server draining: health checks now report NOT_SERVING")
+}
+
+// Resume undoes Drain, marking every service SERVING again if MarkReady has
+// been called. It fails with errShuttingDown after Shutdown.
+func (h *Health) Resume() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return errShuttingDown
+	}
+	if !h.draining {
+		return nil
+	}
+	h.draining = false
+	if h.ready {
+		h.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+	}
+	slog.Info("Server resumed: health checks report SERVING again")
+	return nil
+}
+
+// Shutdown drains for good: every service stays NOT_SERVING and Resume
+// fails from then on. Server.Stop calls it.
+func (h *Health) Shutdown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	h.draining = true
+	h.grpc.Shutdown()
+}
+
+// Draining reports whether the server is draining.
+func (h *Health) Draining() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.draining
+}
+
+// readiness returns the readiness status name and the HTTP status code to
+// report it with.
+func (h *Health) readiness() (string, int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case h.draining:
+		return "draining", http.StatusServiceUnavailable
+	case !h.ready:
+		return "starting", http.StatusServiceUnavailable
+	default:
+		return "ready", http.StatusOK
+	}
+}
+
+// handleLiveness serves GET /healthz, which succeeds whenever the process can
+// answer, including while starting up or draining.
+func (h *Health) handleLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// handleReadiness serves GET /readyz, answering 503 while starting up or
+// draining.
+func (h *Health) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	status, code := h.readiness()
+	writeJSON(w, code, map[string]string{"status": status})
+}
+
+// handleHealthCheck serves GET /health, the readiness check with more detail
+// including where each protocol is served and the server version. It answers
+// 503 while starting up or draining.
+func (h *Health) handleHealthCheck(welcome WelcomeInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topology := welcome.topology()
+		httpRunning := "running on " + topology.HTTPAddr + " (same port)"
+		note := "Both gRPC and HTTP protocols are served on the same port"
+		if topology.Split() {
+			httpRunning = "running on " + topology.HTTPAddr
+			note = "gRPC and HTTP protocols are served on separate ports"
+		}
+
+		status, code := h.readiness()
+		if status == "ready" {
+			status = "healthy"
+		}
+
+		health := map[string]interface{}{
+			"status":    status,
+			"timestamp": time.Now().Format(time.RFC3339),
+			"services": map[string]string{
+				"grpc": "running on " + topology.GRPCAddr,
+				"http": httpRunning,
+			},
+			"version": welcome.Version,
+			"note":    note,
+		}
+
+		writeJSON(w, code, health)
+	}
+}
+
+// handleDrain serves POST /admin/drain.
+func (h *Health) handleDrain(w http.ResponseWriter, r *http.Request) {
+	slog.InfoContext(r.Context(), "HTTP: Received drain request", "remote_addr", r.RemoteAddr)
+	h.Drain()
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "draining"})
+}
+
+// handleResume serves DELETE /admin/drain, answering 409 once the server is
+// stopping.
+func (h *Health) handleResume(w http.ResponseWriter, r *http.Request) {
+	slog.InfoContext(r.Context(), "HTTP: Received resume request", "remote_addr", r.RemoteAddr)
+	if err := h.Resume(); err != nil {
+		writeError(w, http.StatusConflict, codes.FailedPrecondition, err.Error())
+		return
+	}
+
+	status, _ := h.readiness()
+	writeJSON(w, http.StatusOK, map[string]string{"status": status})
+}
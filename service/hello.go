@@ -0,0 +1,735 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// SayHelloStream cadence defaults and limits. Clients can override the count
+// and delay with the stream-count and stream-delay-ms metadata keys.
+const (
+	defaultStreamCount = 5
+	defaultStreamDelay = 1 * time.Second
+	maxStreamCount     = 100
+	maxStreamDelay     = 10 * time.Second
+)
+
+// defaultBidiDelay is the simulated processing time SayHelloBidirectional
+// spends after each reply.
+const defaultBidiDelay = 500 * time.Millisecond
+
+// greetings maps primary language subtags to the greeting used by
+// SayHelloInLanguage. Unknown languages fall back to defaultLanguage.
+var greetings = map[string]string{
+	"en": "Hello",
+	"es": "Hola",
+	"fr": "Bonjour",
+	"de": "Hallo",
+	"it": "Ciao",
+	"pt": "Olá",
+	"ja": "こんにちは",
+	"zh": "你好",
+}
+
+const defaultLanguage = "en"
+
+// SayHelloAggregate window defaults and limits. Clients can change the window
+// with the flush-every metadata key.
+const (
+	defaultFlushEvery = 3
+	maxFlushEvery     = 100
+)
+
+// SayHelloClientStream logs its progress every clientStreamProgressEvery
+// names, so operators can follow long streams.
+const clientStreamProgressEvery = 1000
+
+// ClientStreamLimits bounds what SayHelloClientStream buffers before it
+// replies. A zero field leaves that dimension unbounded.
+type ClientStreamLimits struct {
+	// MaxNames caps the number of names one stream may send.
+	MaxNames int
+	// MaxBytes caps the total encoded size of the requests one stream may
+	// send.
+	MaxBytes int
+}
+
+// check fails with codes.ResourceExhausted once a stream has sent more than
+// the limits allow, given the names and bytes it has sent so far.
+func (l ClientStreamLimits) check(names, bytes int) error {
+	if l.MaxNames > 0 && names > l.MaxNames {
+		return status.Errorf(codes.ResourceExhausted, "client stream sent more than the limit of %d names", l.MaxNames)
+	}
+	if l.MaxBytes > 0 && bytes > l.MaxBytes {
+		return status.Errorf(codes.ResourceExhausted, "client stream sent more than the limit of %d bytes", l.MaxBytes)
+	}
+	return nil
+}
+
+// nameTransforms maps values of the transform metadata key to the change
+// SayHelloBidirectional applies to each name before replying.
+var nameTransforms = map[string]func(string) string{
+	"none":    func(name string) string { return name },
+	"upper":   strings.ToUpper,
+	"reverse": reverseRunes,
+}
+
+// reverseRunes reverses s by rune so multi-byte names stay valid UTF-8.
+func reverseRunes(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// nameTransform returns the transform requested with the transform metadata
+// key, defaulting to "none".
+func nameTransform(md metadata.MD) (string, func(string) string, error) {
+	name := "none"
+	if values := md.Get("transform"); len(values) > 0 {
+		name = strings.ToLower(strings.TrimSpace(values[0]))
+	}
+	transform, ok := nameTransforms[name]
+	if !ok {
+		return "", nil, status.Errorf(codes.InvalidArgument,
+			"unknown transform %q: must be one of none, upper or reverse", md.Get("transform")[0])
+	}
+	return name, transform, nil
+}
+
+// HelloServer is used to implement hello.GreeterServer.
+type HelloServer struct {
+	hello.UnimplementedGreeterServer
+	greeter Greeter
+	// coalesce wraps greeter in coalescer, which is nil otherwise.
+	coalesce  bool
+	coalescer *CoalescingGreeter
+	// history records each greeting when set.
+	history HistoryStore
+	// faultInjection makes SayHello honor the inject-error and
+	// inject-delay-ms metadata keys.
+	faultInjection bool
+	// clock supplies timestamps, IDs and durations.
+	clock Clock
+	// shutdown ends the streaming calls early when the server shuts down.
+	shutdown *ShutdownSignal
+	// clientStreamLimits bounds the names SayHelloClientStream collects.
+	clientStreamLimits ClientStreamLimits
+	// version is sent in the server-version trailer of SayHello.
+	version string
+	// streamDelay is the pause between SayHelloStream messages unless the
+	// caller sets stream-delay-ms; bidiDelay follows each
+	// SayHelloBidirectional reply.
+	streamDelay time.Duration
+	bidiDelay   time.Duration
+}
+
+// now reads the server's clock.
+func (s *HelloServer) now() time.Time {
+	return clockNow(s.clock)
+}
+
+// HelloServerOption customizes a HelloServer.
+type HelloServerOption func(*HelloServer)
+
+// WithGreeter sets the Greeter SayHello uses when the caller does not pick a
+// style with the greeting-style metadata key.
+func WithGreeter(g Greeter) HelloServerOption {
+	return func(s *HelloServer) {
+		s.greeter = g
+	}
+}
+
+// WithFaultInjection lets callers make SayHello fail with a chosen status
+// code or respond late through the inject-error and inject-delay-ms metadata
+// keys, for testing client error handling. Leave it off in production.
+func WithFaultInjection(enabled bool) HelloServerOption {
+	return func(s *HelloServer) {
+		s.faultInjection = enabled
+	}
+}
+
+// WithHistory records every SayHello and SayHelloInLanguage greeting in
+// store.
+func WithHistory(store HistoryStore) HelloServerOption {
+	return func(s *HelloServer) {
+		s.history = store
+	}
+}
+
+// WithClock makes the server read the time from clock instead of the system
+// clock, e.g. a FakeClock in tests.
+func WithClock(clock Clock) HelloServerOption {
+	return func(s *HelloServer) {
+		s.clock = clock
+	}
+}
+
+// WithShutdownSignal makes the streaming methods end early, with the
+// shutdown-in-progress trailer, once shutdown fires.
+func WithShutdownSignal(shutdown *ShutdownSignal) HelloServerOption {
+	return func(s *HelloServer) {
+		s.shutdown = shutdown
+	}
+}
+
+// WithClientStreamLimits makes SayHelloClientStream fail with
+// codes.ResourceExhausted once a stream sends more names or bytes than limits
+// allow, instead of buffering without bound.
+func WithClientStreamLimits(limits ClientStreamLimits) HelloServerOption {
+	return func(s *HelloServer) {
+		s.clientStreamLimits = limits
+	}
+}
+
+// WithVersion sets the server-version trailer of SayHello, config.Version
+// by default.
+func WithVersion(version string) HelloServerOption {
+	return func(s *HelloServer) {
+		s.version = version
+	}
+}
+
+// WithCoalescing makes concurrent SayHello calls for the same name share one
+// call to the greeter set with WithGreeter, through a CoalescingGreeter.
+// Calls that pick a style with the greeting-style metadata key are not
+// coalesced.
+func WithCoalescing(enabled bool) HelloServerOption {
+	return func(s *HelloServer) {
+		s.coalesce = enabled
+	}
+}
+
+// WithStreamDelays sets the pause between SayHelloStream messages, which
+// callers can still override with stream-delay-ms, and the pause after each
+// SayHelloBidirectional reply. Zero sends without pausing, e.g. in tests.
+func WithStreamDelays(stream, bidi time.Duration) HelloServerOption {
+	return func(s *HelloServer) {
+		s.streamDelay = stream
+		s.bidiDelay = bidi
+	}
+}
+
+// NewHelloServer returns a ready-to-register Greeter implementation. Without
+// options SayHello uses PlainGreeter.
+func NewHelloServer(opts ...HelloServerOption) *HelloServer {
+	s := &HelloServer{greeter: PlainGreeter{}, clock: SystemClock{}, version: config.Version,
+		streamDelay: defaultStreamDelay, bidiDelay: defaultBidiDelay}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.coalesce {
+		s.coalescer = NewCoalescingGreeter(s.greeter)
+		s.greeter = s.coalescer
+	}
+	return s
+}
+
+// CoalescedGreetings returns how many SayHello calls were answered with the
+// greeting of a concurrent call for the same name; always zero unless
+// WithCoalescing is enabled.
+func (s *HelloServer) CoalescedGreetings() int64 {
+	if s.coalescer == nil {
+		return 0
+	}
+	return s.coalescer.Coalesced()
+}
+
+// streamParams reads the requested message count and inter-message delay for
+// SayHelloStream from incoming metadata, falling back to defaultStreamCount
+// and delay.
+func streamParams(md metadata.MD, delay time.Duration) (int, time.Duration, error) {
+	count := defaultStreamCount
+
+	if values := md.Get("stream-count"); len(values) > 0 {
+		n, err := strconv.Atoi(values[0])
+		if err != nil || n < 1 || n > maxStreamCount {
+			return 0, 0, status.Errorf(codes.InvalidArgument,
+				"stream-count must be an integer between 1 and %d, got %q", maxStreamCount, values[0])
+		}
+		count = n
+	}
+
+	if values := md.Get("stream-delay-ms"); len(values) > 0 {
+		ms, err := strconv.Atoi(values[0])
+		if err != nil || ms < 0 || time.Duration(ms)*time.Millisecond > maxStreamDelay {
+			return 0, 0, status.Errorf(codes.InvalidArgument,
+				"stream-delay-ms must be an integer between 0 and %d, got %q", maxStreamDelay.Milliseconds(), values[0])
+		}
+		delay = time.Duration(ms) * time.Millisecond
+	}
+
+	return count, delay, nil
+}
+
+// flushEvery reads the SayHelloAggregate window size from incoming metadata,
+// falling back to defaultFlushEvery.
+func flushEvery(md metadata.MD) (int, error) {
+	values := md.Get("flush-every")
+	if len(values) == 0 {
+		return defaultFlushEvery, nil
+	}
+	n, err := strconv.Atoi(values[0])
+	if err != nil || n < 1 || n > maxFlushEvery {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"flush-every must be an integer between 1 and %d, got %q", maxFlushEvery, values[0])
+	}
+	return n, nil
+}
+
+// SayHello implements hello.GreeterServer
+func (s *HelloServer) SayHello(ctx context.Context, in *hello.HelloRequest) (_ *hello.HelloReply, err error) {
+	start := s.now()
+	slog.InfoContext(ctx, "gRPC: Received SayHello request", "method", "SayHello", "name", in.GetName(), callerAttr(ctx))
+	// Logged once the greeting is built, or the call fails, with its status
+	defer func() {
+		slog.InfoContext(ctx, "gRPC: Completed SayHello request", "method", "SayHello", "name", in.GetName(),
+			"code", status.Code(err).String(), "duration_ms", s.now().Sub(start).Milliseconds())
+	}()
+
+	greeter, err := greeterFor(ctx, s.greeter)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.faultInjection {
+		if err := injectFault(ctx, "SayHello"); err != nil {
+			return nil, err
+		}
+	}
+
+	logIncomingMetadata(ctx, slog.Default(), "gRPC: Incoming metadata", "method", "SayHello")
+
+	// Set response headers. The response-id is repeated in the
+	// request-completed-id trailer so clients can match the two up
+	responseID := newResponseID("hello")
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayHello",
+		"timestamp", s.now().Format(time.RFC3339),
+		"response-id", responseID,
+	)
+	grpc.SendHeader(ctx, header)
+
+	// Set response trailers. request-bytes is the serialized size of the
+	// request, to match the response size clients compute
+	trailer := metadata.Pairs(
+		"processing-time", "fast",
+		"server-version", s.version,
+		"request-completed-id", responseID,
+		"request-bytes", strconv.Itoa(proto.Size(in)),
+	)
+	grpc.SetTrailer(ctx, trailer)
+
+	message := greeter.Greet(ctx, in.GetName())
+	if tenant := TenantIDFromContext(ctx); tenant != "" {
+		message = "[" + tenant + "] " + message
+	}
+	recordHistory(ctx, s.history, "SayHello", in.GetName(), message, s.now())
+	return &hello.HelloReply{Message: message}, nil
+}
+
+// resolveLanguage picks the greeting language from the request field or, if
+// that is empty, the "language" metadata key. Tags are matched on their
+// primary subtag ("fr-CA" -> "fr"); unsupported tags fall back to English.
+func resolveLanguage(ctx context.Context, in *hello.HelloRequest) string {
+	tag := in.GetLanguage()
+	if tag == "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("language"); len(values) > 0 {
+				tag = values[0]
+			}
+		}
+	}
+
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	primary, _, _ = strings.Cut(primary, "_")
+	if _, ok := greetings[primary]; ok {
+		return primary
+	}
+	return defaultLanguage
+}
+
+// SayHelloInLanguage implements hello.GreeterServer
+func (s *HelloServer) SayHelloInLanguage(ctx context.Context, in *hello.HelloRequest) (_ *hello.HelloReply, err error) {
+	start := s.now()
+	language := resolveLanguage(ctx, in)
+	slog.InfoContext(ctx, "gRPC: Received SayHelloInLanguage request", "method", "SayHelloInLanguage",
+		"name", in.GetName(), "requested_language", in.GetLanguage(), "language", language)
+	defer func() {
+		slog.InfoContext(ctx, "gRPC: Completed SayHelloInLanguage request", "method", "SayHelloInLanguage",
+			"name", in.GetName(), "language", language, "code", status.Code(err).String(),
+			"duration_ms", s.now().Sub(start).Milliseconds())
+	}()
+
+	// Set response headers, with a response-id in the form SayHello uses
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayHelloInLanguage",
+		"content-language", language,
+		"response-id", newResponseID("hello"),
+	)
+	grpc.SendHeader(ctx, header)
+
+	message := greetings[language] + " " + in.GetName()
+	recordHistory(ctx, s.history, "SayHelloInLanguage", in.GetName(), message, s.now())
+	return &hello.HelloReply{Message: message}, nil
+}
+
+// SayHelloStream implements hello.GreeterServer
+func (s *HelloServer) SayHelloStream(in *hello.HelloRequest, stream hello.Greeter_SayHelloStreamServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("stream-%d", start.Unix())
+	logger := slog.With("method", "SayHelloStream", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received stream request", "name", in.GetName())
+
+	logIncomingMetadata(ctx, logger, "gRPC: Stream incoming metadata")
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// Resolve the requested cadence
+	count, delay, err := streamParams(md, s.streamDelay)
+	if err != nil {
+		logger.WarnContext(ctx, "gRPC: Rejected stream request", "error", err)
+		return err
+	}
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayHelloStream",
+		"stream-id", streamID,
+		"expected-messages", strconv.Itoa(count),
+	)
+	stream.SendHeader(header)
+
+	for i := 0; i < count; i++ {
+		reply := &hello.HelloReply{
+			Message: fmt.Sprintf("Hello %s - Message %d", in.GetName(), i+1),
+		}
+
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+		logger.DebugContext(ctx, "gRPC: Sent stream message", "name", in.GetName(), "message_number", i+1)
+
+		// Add a small delay between messages, stopping early if the client goes away
+		if err := sleepContext(ctx, delay); err != nil {
+			if shuttingDown(ctx) {
+				return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+					"messages-sent", strconv.Itoa(i+1),
+					"stream-duration", streamDuration(s.now().Sub(start)),
+				))
+			}
+			logger.InfoContext(ctx, "gRPC: Stream terminated early", "messages_sent", i+1, "error", err)
+			return err
+		}
+	}
+
+	// Set stream trailers
+	trailer := metadata.Pairs(
+		"messages-sent", strconv.Itoa(count),
+		"stream-duration", streamDuration(s.now().Sub(start)),
+		"stream-status", "completed",
+	)
+	stream.SetTrailer(trailer)
+
+	logger.InfoContext(ctx, "gRPC: Completed stream request", "name", in.GetName(),
+		"duration_ms", s.now().Sub(start).Milliseconds())
+
+	return nil
+}
+
+// SayHelloClientStream implements hello.GreeterServer
+func (s *HelloServer) SayHelloClientStream(stream hello.Greeter_SayHelloClientStreamServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("client-stream-%d", start.Unix())
+	logger := slog.With("method", "SayHelloClientStream", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received client stream request")
+
+	logIncomingMetadata(ctx, logger, "gRPC: Client stream incoming metadata")
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayHelloClientStream",
+		"stream-id", streamID,
+		"stream-type", "client-streaming",
+	)
+	// Tell the client its budget up front, so it can stop before failing
+	if limits := s.clientStreamLimits; limits.MaxNames > 0 {
+		header.Set("max-names", strconv.Itoa(limits.MaxNames))
+	}
+	if limits := s.clientStreamLimits; limits.MaxBytes > 0 {
+		header.Set("max-bytes", strconv.Itoa(limits.MaxBytes))
+	}
+	stream.SendHeader(header)
+
+	var names []string
+	messageCount := 0
+	bytesReceived := 0
+
+	// Receive all messages from client
+	recv := recvUntilShutdown(ctx, stream.Recv)
+	for {
+		req, err := recv()
+		if err == io.EOF {
+			// Client finished sending
+			break
+		}
+		if shuttingDown(ctx) {
+			return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+				"messages-received", strconv.Itoa(messageCount),
+				"bytes-received", strconv.Itoa(bytesReceived),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+		}
+		if isClientCancel(ctx, err) {
+			// The client gave up; nobody is left to answer, so this is not
+			// a server error
+			logger.InfoContext(ctx, "gRPC: Client cancelled client stream", "messages_received", messageCount,
+				"duration_ms", s.now().Sub(start).Milliseconds())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		messageCount++
+		bytesReceived += proto.Size(req)
+		if err := s.clientStreamLimits.check(messageCount, bytesReceived); err != nil {
+			stream.SetTrailer(metadata.Pairs(
+				"messages-received", strconv.Itoa(messageCount),
+				"bytes-received", strconv.Itoa(bytesReceived),
+				"stream-status", "limit-exceeded",
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+			logger.WarnContext(ctx, "gRPC: Client stream exceeded its limits", "messages_received", messageCount,
+				"bytes_received", bytesReceived, "error", err)
+			return err
+		}
+		names = append(names, req.GetName())
+		logger.DebugContext(ctx, "gRPC: Received client stream message", "name", req.GetName(), "message_number", messageCount)
+		if messageCount%clientStreamProgressEvery == 0 {
+			logger.InfoContext(ctx, "gRPC: Client stream progress", "messages_received", messageCount, "bytes_received", bytesReceived)
+		}
+	}
+
+	// Send single response with summary, or say so when the client closed
+	// the stream without sending any names
+	summary := fmt.Sprintf("Hello to all %d friends: %s!", len(names), strings.Join(names, ", "))
+	streamStatus := "completed"
+	if messageCount == 0 {
+		summary = "No names received, so there is nobody to greet"
+		streamStatus = "empty"
+	}
+
+	// Set response trailers
+	trailer := metadata.Pairs(
+		"messages-received", fmt.Sprintf("%d", messageCount),
+		"bytes-received", strconv.Itoa(bytesReceived),
+		"names-processed", strings.Join(names, ","),
+		"stream-status", streamStatus,
+		"processing-time", "batch",
+		"stream-duration", streamDuration(s.now().Sub(start)),
+	)
+	stream.SetTrailer(trailer)
+
+	logger.InfoContext(ctx, "gRPC: Completed client stream request", "messages_received", messageCount,
+		"duration_ms", s.now().Sub(start).Milliseconds())
+
+	return stream.SendAndClose(&hello.HelloReply{Message: summary})
+}
+
+// SayHelloBidirectional implements hello.GreeterServer
+func (s *HelloServer) SayHelloBidirectional(stream hello.Greeter_SayHelloBidirectionalServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	streamID := fmt.Sprintf("bidi-stream-%d", start.Unix())
+	logger := slog.With("method", "SayHelloBidirectional", "stream_id", streamID)
+	logger.InfoContext(ctx, "gRPC: Received bidirectional stream request")
+
+	logIncomingMetadata(ctx, logger, "gRPC: Bidirectional stream incoming metadata")
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// Pick the transform before replying so an unknown one fails the stream
+	// up front
+	transformName, transform, err := nameTransform(md)
+	if err != nil {
+		return err
+	}
+
+	// Set stream headers
+	header := metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayHelloBidirectional",
+		"stream-id", streamID,
+		"stream-type", "bidirectional",
+		"transform", transformName,
+	)
+	stream.SendHeader(header)
+
+	messageCount := 0
+	var processedNames []string
+
+	// Handle bidirectional streaming
+	recv := recvUntilShutdown(ctx, stream.Recv)
+	for {
+		req, err := recv()
+		if err == io.EOF {
+			// Client finished sending
+			break
+		}
+		if shuttingDown(ctx) {
+			return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+				"messages-exchanged", strconv.Itoa(messageCount),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+		}
+		if err != nil {
+			return err
+		}
+
+		messageCount++
+		name := req.GetName()
+		processedNames = append(processedNames, name)
+		logger.DebugContext(ctx, "gRPC: Received bidirectional message", "name", name, "message_number", messageCount)
+
+		// Send immediate response for each received message
+		response := fmt.Sprintf("Hello %s! (Message %d received)", transform(name), messageCount)
+		if err := stream.Send(&hello.HelloReply{Message: response}); err != nil {
+			return err
+		}
+
+		// Add a small delay to simulate processing, stopping as soon as the
+		// client's deadline passes or it goes away
+		if err := sleepContext(ctx, s.bidiDelay); err != nil {
+			if shuttingDown(ctx) {
+				return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+					"messages-exchanged", strconv.Itoa(messageCount),
+					"stream-duration", streamDuration(s.now().Sub(start)),
+				))
+			}
+			logger.InfoContext(ctx, "gRPC: Bidirectional stream terminated early", "messages_exchanged", messageCount, "error", err)
+			return status.FromContextError(err).Err()
+		}
+	}
+
+	// Set stream trailers
+	trailer := metadata.Pairs(
+		"messages-exchanged", fmt.Sprintf("%d", messageCount),
+		"names-processed", strings.Join(processedNames, ","),
+		"stream-status", "completed",
+		"stream-duration", streamDuration(s.now().Sub(start)),
+	)
+	stream.SetTrailer(trailer)
+
+	logger.InfoContext(ctx, "gRPC: Completed bidirectional stream request", "messages_exchanged", messageCount,
+		"duration_ms", s.now().Sub(start).Milliseconds())
+
+	return nil
+}
+
+// SayHelloAggregate implements hello.GreeterServer. Instead of answering each
+// name it collects them and, every flush-every names, replies with the running
+// total and the names received since the previous reply. A final summary with
+// any remaining names is always sent once the client closes its side.
+func (s *HelloServer) SayHelloAggregate(stream hello.Greeter_SayHelloAggregateServer) error {
+	ctx, stop := s.shutdown.streamContext(stream.Context())
+	defer stop()
+	start := s.now()
+	logger := slog.With("method", "SayHelloAggregate")
+	logger.InfoContext(ctx, "gRPC: Received aggregate stream request")
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	window, err := flushEvery(md)
+	if err != nil {
+		return err
+	}
+
+	stream.SendHeader(metadata.Pairs(
+		"server-name", "grpc-sample-server",
+		"method", "SayHelloAggregate",
+		"stream-type", "bidirectional",
+		"flush-every", strconv.Itoa(window),
+	))
+
+	total, flushes := 0, 0
+	var pending []string
+	flush := func(final bool) error {
+		flushes++
+		message := fmt.Sprintf("Hello to %d people so far, most recently %s", total, strings.Join(pending, ", "))
+		if final {
+			message = fmt.Sprintf("Hello to all %d people!", total)
+		}
+		err := stream.Send(&hello.HelloReply{
+			Message:     message,
+			TotalCount:  int32(total),
+			RecentNames: pending,
+		})
+		pending = nil
+		return err
+	}
+
+	recv := recvUntilShutdown(ctx, stream.Recv)
+	for {
+		req, err := recv()
+		if err == io.EOF {
+			break
+		}
+		if shuttingDown(ctx) {
+			return endStreamForShutdown(ctx, stream, logger, metadata.Pairs(
+				"names-received", strconv.Itoa(total),
+				"replies-sent", strconv.Itoa(flushes),
+				"stream-duration", streamDuration(s.now().Sub(start)),
+			))
+		}
+		if err != nil {
+			return err
+		}
+
+		total++
+		pending = append(pending, req.GetName())
+		logger.DebugContext(ctx, "gRPC: Received aggregate message", "name", req.GetName(), "total", total)
+
+		if len(pending) == window {
+			if err := flush(false); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(true); err != nil {
+		return err
+	}
+
+	stream.SetTrailer(metadata.Pairs(
+		"names-received", strconv.Itoa(total),
+		"replies-sent", strconv.Itoa(flushes),
+		"stream-status", "completed",
+		"stream-duration", streamDuration(s.now().Sub(start)),
+	))
+
+	logger.InfoContext(ctx, "gRPC: Completed aggregate stream request", "names_received", total,
+		"replies_sent", flushes, "duration_ms", s.now().Sub(start).Milliseconds())
+
+	return nil
+}
@@ -0,0 +1,527 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// captureLogs sends the default logger's output, down to debug level, to a
+// JSON buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(NewLogger(&buf, slog.LevelDebug))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logRecords returns the JSON log records whose msg is msg.
+func logRecords(t *testing.T, buf *bytes.Buffer, msg string) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// logRecord returns the first JSON log record whose msg is msg.
+func logRecord(t *testing.T, buf *bytes.Buffer, msg string) map[string]any {
+	t.Helper()
+	records := logRecords(t, buf, msg)
+	if len(records) == 0 {
+		t.Fatalf("no %q log record in:\n%s", msg, buf)
+	}
+	return records[0]
+}
+
+// fakeStream is a server stream over ctx that hands the handler the
+// messages in recv, then io.EOF, and records what it sends. onSend, if set,
+// runs after each message sent with the count so far.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	recv    []proto.Message
+	sent    []proto.Message
+	header  metadata.MD
+	trailer metadata.MD
+	onSend  func(n int)
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
+func (s *fakeStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+func (s *fakeStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+func (s *fakeStream) SetTrailer(md metadata.MD)       { s.trailer = metadata.Join(s.trailer, md) }
+
+func (s *fakeStream) SendMsg(m interface{}) error {
+	if err := s.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	s.sent = append(s.sent, proto.Clone(m.(proto.Message)))
+	if s.onSend != nil {
+		s.onSend(len(s.sent))
+	}
+	return nil
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	if err := s.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	if len(s.recv) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.recv[0])
+	s.recv = s.recv[1:]
+	return nil
+}
+
+func TestSayHelloLogsCompletionAfterTheWork(t *testing.T) {
+	logs := captureLogs(t)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	slow := GreeterFunc(func(ctx context.Context, name string) string {
+		clock.Advance(250 * time.Millisecond)
+		return "Hello " + name
+	})
+	s := NewHelloServer(WithGreeter(slow), WithClock(clock))
+
+	if _, err := s.SayHello(context.Background(), &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	record := logRecord(t, logs, "gRPC: Completed SayHello request")
+	if record["method"] != "SayHello" || record["name"] != "World" {
+		t.Errorf("method, name = %v, %v; want SayHello, World", record["method"], record["name"])
+	}
+	if record["code"] != "OK" {
+		t.Errorf("code = %v, want OK", record["code"])
+	}
+	// The greeter's time is included, so the log comes after Greet
+	if record["duration_ms"] != float64(250) {
+		t.Errorf("duration_ms = %v, want 250", record["duration_ms"])
+	}
+}
+
+func TestSayHelloLogsFailureCode(t *testing.T) {
+	logs := captureLogs(t)
+	s := NewHelloServer(WithFaultInjection(true))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("inject-error", "unavailable"))
+
+	if _, err := s.SayHello(ctx, &hello.HelloRequest{Name: "World"}); err == nil {
+		t.Fatal("SayHello succeeded, want the injected error")
+	}
+
+	record := logRecord(t, logs, "gRPC: Completed SayHello request")
+	if record["code"] != "Unavailable" {
+		t.Errorf("code = %v, want Unavailable", record["code"])
+	}
+}
+
+func TestSayHelloStreamHonorsCountAndDelay(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "stream-count", "3", "stream-delay-ms", "10")
+
+	start := time.Now()
+	stream, err := greeter.SayHelloStream(ctx, &hello.HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayHelloStream: %v", err)
+	}
+	var replies int
+	for {
+		_, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		replies++
+	}
+	if replies != 3 {
+		t.Errorf("got %d replies, want 3", replies)
+	}
+	// The default cadence would take 5 seconds
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stream took %s, want about 30ms", elapsed)
+	}
+	if got := stream.Trailer().Get("messages-sent"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("messages-sent trailer = %v, want 3", got)
+	}
+}
+
+func TestSayHelloStreamStopsWhenClientCancels(t *testing.T) {
+	logs := captureLogs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeStream{ctx: ctx, onSend: func(n int) {
+		if n == 1 {
+			cancel()
+		}
+	}}
+
+	start := time.Now()
+	err := NewHelloServer().SayHelloStream(&hello.HelloRequest{Name: "World"},
+		&grpc.GenericServerStream[hello.HelloRequest, hello.HelloReply]{ServerStream: stream})
+	// Running to completion takes five one-second pauses
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("handler returned after %s, want it to stop at the cancel", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("SayHelloStream = %v, want context.Canceled", err)
+	}
+	if len(stream.sent) != 1 {
+		t.Errorf("sent %d messages, want 1", len(stream.sent))
+	}
+	if record := logRecord(t, logs, "gRPC: Stream terminated early"); record["messages_sent"] != float64(1) {
+		t.Errorf("messages_sent = %v, want 1", record["messages_sent"])
+	}
+}
+
+func TestSayHelloInLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		metadata string
+		want     string
+	}{
+		{"es", "", "Hola Ana"},
+		{"fr-CA", "", "Bonjour Ana"},
+		{"de_AT", "", "Hallo Ana"},
+		{" JA ", "", "こんにちは Ana"},
+		{"", "it", "Ciao Ana"},
+		{"pt", "fr", "Olá Ana"},
+		{"xx", "", "Hello Ana"},
+		{"", "", "Hello Ana"},
+	}
+	s := NewHelloServer()
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.metadata != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("language", tt.metadata))
+		}
+		reply, err := s.SayHelloInLanguage(ctx, &hello.HelloRequest{Name: "Ana", Language: tt.language})
+		if err != nil {
+			t.Errorf("SayHelloInLanguage(%q, metadata %q): %v", tt.language, tt.metadata, err)
+			continue
+		}
+		if reply.GetMessage() != tt.want {
+			t.Errorf("SayHelloInLanguage(%q, metadata %q) = %q, want %q", tt.language, tt.metadata, reply.GetMessage(), tt.want)
+		}
+	}
+}
+
+func TestSayHelloInLanguageSendsContentLanguage(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var header metadata.MD
+	if _, err := greeter.SayHelloInLanguage(ctx, &hello.HelloRequest{Name: "Ana", Language: "fr-CA"}, grpc.Header(&header)); err != nil {
+		t.Fatalf("SayHelloInLanguage: %v", err)
+	}
+	if got := header.Get("content-language"); len(got) != 1 || got[0] != "fr" {
+		t.Errorf("content-language = %v, want fr", got)
+	}
+}
+
+func TestSayHelloBidirectionalTransforms(t *testing.T) {
+	tests := []struct {
+		transform string
+		want      []string
+	}{
+		{"upper", []string{"ALICE", "ÉMILE"}},
+		{"Reverse", []string{"ecilA", "elimÉ"}},
+		{"", []string{"Alice", "Émile"}},
+	}
+	s := NewHelloServer(WithStreamDelays(0, 0))
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.transform != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("transform", tt.transform))
+		}
+		stream := &fakeStream{ctx: ctx, recv: []proto.Message{&hello.HelloRequest{Name: "Alice"}, &hello.HelloRequest{Name: "Émile"}}}
+		if err := s.SayHelloBidirectional(&grpc.GenericServerStream[hello.HelloRequest, hello.HelloReply]{ServerStream: stream}); err != nil {
+			t.Errorf("transform %q: %v", tt.transform, err)
+			continue
+		}
+		if len(stream.sent) != len(tt.want) {
+			t.Errorf("transform %q: got %d replies, want %d", tt.transform, len(stream.sent), len(tt.want))
+			continue
+		}
+		for i, name := range tt.want {
+			want := fmt.Sprintf("Hello %s! (Message %d received)", name, i+1)
+			if got := stream.sent[i].(*hello.HelloReply).GetMessage(); got != want {
+				t.Errorf("transform %q: reply %d = %q, want %q", tt.transform, i, got, want)
+			}
+		}
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("transform", "shout"))
+	err := s.SayHelloBidirectional(&grpc.GenericServerStream[hello.HelloRequest, hello.HelloReply]{ServerStream: &fakeStream{ctx: ctx}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown transform: %v, want InvalidArgument", err)
+	}
+}
+
+func TestSayHelloAggregateFlushesEveryWindow(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := greeter.SayHelloAggregate(metadata.AppendToOutgoingContext(ctx, "flush-every", "3"))
+	if err != nil {
+		t.Fatalf("SayHelloAggregate: %v", err)
+	}
+	for _, name := range strings.Split("a b c d e f g", " ") {
+		if err := stream.Send(&hello.HelloRequest{Name: name}); err != nil {
+			t.Fatalf("Send(%s): %v", name, err)
+		}
+	}
+	stream.CloseSend()
+
+	var replies []*hello.HelloReply
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		replies = append(replies, reply)
+	}
+	want := []struct {
+		total int32
+		names string
+	}{{3, "a,b,c"}, {6, "d,e,f"}, {7, "g"}}
+	if len(replies) != len(want) {
+		t.Fatalf("got %d replies, want %d: %v", len(replies), len(want), replies)
+	}
+	for i, w := range want {
+		if got := replies[i]; got.GetTotalCount() != w.total || strings.Join(got.GetRecentNames(), ",") != w.names {
+			t.Errorf("reply %d = %d %v, want %d [%s]", i, got.GetTotalCount(), got.GetRecentNames(), w.total, w.names)
+		}
+	}
+	if got := replies[2].GetMessage(); got != "Hello to all 7 people!" {
+		t.Errorf("final summary = %q", got)
+	}
+	if got := stream.Trailer().Get("replies-sent"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("replies-sent trailer = %v, want 3", got)
+	}
+}
+
+func TestSayHelloAggregateRejectsBadWindow(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, window := range []string{"0", "101", "three"} {
+		stream, err := greeter.SayHelloAggregate(metadata.AppendToOutgoingContext(ctx, "flush-every", window))
+		if err != nil {
+			t.Fatalf("SayHelloAggregate: %v", err)
+		}
+		if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("flush-every %s: %v, want InvalidArgument", window, err)
+		}
+	}
+}
+
+func TestSayHelloResponseIDMatchesTrailer(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var header, trailer metadata.MD
+	if _, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	id, completed := header.Get("response-id"), trailer.Get("request-completed-id")
+	if len(id) != 1 || !strings.HasPrefix(id[0], "hello-") {
+		t.Fatalf("response-id header = %v, want hello-<uuid>", id)
+	}
+	if len(completed) != 1 || completed[0] != id[0] {
+		t.Errorf("request-completed-id trailer = %v, want %s", completed, id[0])
+	}
+
+	rec := serve(testRouter{}.handler(), httptest.NewRequest("GET", "/api/hello?name=World", nil))
+	restID := rec.Header().Get("X-Response-ID")
+	if restID == "" {
+		t.Fatal("GET /api/hello has no X-Response-ID")
+	}
+	if got := rec.Header().Get("X-Request-Completed-ID"); got != restID {
+		t.Errorf("X-Request-Completed-ID = %q, want %q", got, restID)
+	}
+}
+
+func TestSayHelloClientStreamWithoutNames(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := greeter.SayHelloClientStream(ctx)
+	if err != nil {
+		t.Fatalf("SayHelloClientStream: %v", err)
+	}
+	reply, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv with no names: %v", err)
+	}
+	if got := reply.GetMessage(); got != "No names received, so there is nobody to greet" {
+		t.Errorf("reply = %q", got)
+	}
+	if got := stream.Trailer().Get("stream-status"); len(got) != 1 || got[0] != "empty" {
+		t.Errorf("stream-status trailer = %v, want empty", got)
+	}
+}
+
+func TestSayHelloClientStreamLimits(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t,
+		NewHelloServer(WithClientStreamLimits(ClientStreamLimits{MaxNames: 3, MaxBytes: 100})), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// sendNames streams names and returns the call's outcome. Sends fail
+	// with io.EOF once the server has rejected the stream, which
+	// CloseAndRecv then reports.
+	sendNames := func(names ...string) (grpc.ClientStreamingClient[hello.HelloRequest, hello.HelloReply], error) {
+		stream, err := greeter.SayHelloClientStream(ctx)
+		if err != nil {
+			t.Fatalf("SayHelloClientStream: %v", err)
+		}
+		for _, name := range names {
+			if err := stream.Send(&hello.HelloRequest{Name: name}); err != nil {
+				break
+			}
+		}
+		_, err = stream.CloseAndRecv()
+		return stream, err
+	}
+
+	stream, err := sendNames("Ann", "Bob", "Cy")
+	if err != nil {
+		t.Fatalf("3 names within the limits: %v", err)
+	}
+	header, _ := stream.Header()
+	if got := header.Get("max-names"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("max-names header = %v, want 3", got)
+	}
+	if got := header.Get("max-bytes"); len(got) != 1 || got[0] != "100" {
+		t.Errorf("max-bytes header = %v, want 100", got)
+	}
+
+	stream, err = sendNames("Ann", "Bob", "Cy", "Di", "Ed")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("5 names with a limit of 3 = %v, want ResourceExhausted", err)
+	}
+	trailer := stream.Trailer()
+	if got := trailer.Get("stream-status"); len(got) != 1 || got[0] != "limit-exceeded" {
+		t.Errorf("stream-status trailer = %v, want limit-exceeded", got)
+	}
+	if got := trailer.Get("messages-received"); len(got) != 1 || got[0] != "4" {
+		t.Errorf("messages-received trailer = %v, want 4, where the limit was crossed", got)
+	}
+
+	long := strings.Repeat("x", 60)
+	if _, err := sendNames(long, long); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("2 names of 60 bytes with a limit of 100 bytes = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestSayHelloClientStreamCancelledIsNotAnError(t *testing.T) {
+	logs := captureLogs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	stream := &fakeStream{ctx: ctx, recv: []proto.Message{&hello.HelloRequest{Name: "World"}}}
+
+	err := NewHelloServer().SayHelloClientStream(&grpc.GenericServerStream[hello.HelloRequest, hello.HelloReply]{ServerStream: stream})
+	if err != nil {
+		t.Errorf("SayHelloClientStream after the client cancelled = %v, want nil", err)
+	}
+	if len(stream.sent) != 0 {
+		t.Errorf("sent %d replies to a cancelled client, want none", len(stream.sent))
+	}
+	if record := logRecord(t, logs, "gRPC: Client cancelled client stream"); record["level"] != "INFO" {
+		t.Errorf("cancellation logged at %v, want INFO", record["level"])
+	}
+}
+
+func TestSayHelloBidirectionalStopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	var names []proto.Message
+	for i := 0; i < 10; i++ {
+		names = append(names, &hello.HelloRequest{Name: fmt.Sprint("Name", i)})
+	}
+	stream := &fakeStream{ctx: ctx, recv: names}
+
+	start := time.Now()
+	err := NewHelloServer(WithStreamDelays(0, 200*time.Millisecond)).SayHelloBidirectional(
+		&grpc.GenericServerStream[hello.HelloRequest, hello.HelloReply]{ServerStream: stream})
+	elapsed := time.Since(start)
+
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("SayHelloBidirectional past its deadline = %v, want DeadlineExceeded", err)
+	}
+	// Answering all ten names would take two seconds
+	if elapsed < 250*time.Millisecond || elapsed > time.Second {
+		t.Errorf("handler returned after %s, want about the 300ms deadline", elapsed)
+	}
+	if n := len(stream.sent); n == 0 || n >= len(names) {
+		t.Errorf("sent %d replies, want some but not all %d", n, len(names))
+	}
+}
+
+func TestSayHelloRequestBytesTrailer(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, req := range []*hello.HelloRequest{
+		{Name: "World"},
+		{Name: strings.Repeat("é", 100)},
+		{Name: "World", Language: "es-MX"},
+	} {
+		wire, err := proto.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var trailer metadata.MD
+		if _, err := greeter.SayHello(ctx, req, grpc.Trailer(&trailer)); err != nil {
+			t.Fatalf("SayHello(%v): %v", req, err)
+		}
+		if got := trailer.Get("request-bytes"); len(got) != 1 || got[0] != strconv.Itoa(len(wire)) {
+			t.Errorf("request-bytes for a %d-byte request = %v", len(wire), got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// HistoryRecord is one greeting or farewell sent by the server.
+type HistoryRecord struct {
+	// Method is the RPC that produced the message, e.g. "SayHello".
+	Method  string    `json:"method"`
+	Name    string    `json:"name"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// HistoryStore records greetings and farewells. Implementations must be safe
+// for concurrent use.
+type HistoryStore interface {
+	// Record stores rec.
+	Record(ctx context.Context, rec HistoryRecord) error
+	// Recent returns up to limit records, newest first.
+	Recent(ctx context.Context, limit int) ([]HistoryRecord, error)
+}
+
+// History store kinds accepted by OpenHistoryStore.
+const (
+	HistoryStoreMemory = "memory"
+	HistoryStoreSQLite = "sqlite"
+)
+
+// historyOpeners maps the store kinds built into the binary to their
+// constructors. The SQLite store needs cgo and registers itself only in
+// builds with the sqlite tag.
+var historyOpeners = map[string]func(path string, size int) (HistoryStore, error){
+	HistoryStoreMemory: func(_ string, size int) (HistoryStore, error) {
+		return NewMemoryHistory(size), nil
+	},
+}
+
+// OpenHistoryStore opens the store of the given kind: HistoryStoreMemory,
+// keeping the latest size records, or HistoryStoreSQLite, persisting every
+// record in the database file at path.
+func OpenHistoryStore(kind, path string, size int) (HistoryStore, error) {
+	open, ok := historyOpeners[kind]
+	if !ok {
+		if kind == HistoryStoreSQLite {
+			return nil, fmt.Errorf("history store %q is not built in: rebuild with -tags sqlite and cgo enabled", kind)
+		}
+		return nil, fmt.Errorf("unknown history store %q", kind)
+	}
+	return open(path, size)
+}
+
+// recordHistory records a greeting or farewell sent at now in store, which
+// may be nil. A failure is logged rather than failing the call that produced
+// it.
+func recordHistory(ctx context.Context, store HistoryStore, method, name, message string, now time.Time) {
+	if store == nil {
+		return
+	}
+	rec := HistoryRecord{Method: method, Name: name, Message: message, Time: now.UTC()}
+	if err := store.Record(ctx, rec); err != nil {
+		slog.WarnContext(ctx, "Could not record history", "method", method, "error", err)
+	}
+}
+
+// MemoryHistory is an in-memory HistoryStore holding the latest records up
+// to a fixed capacity; older records are dropped.
+type MemoryHistory struct {
+	mu      sync.Mutex
+	records []HistoryRecord
+	// next is the index the next record is written to once records is full.
+	next int
+	size int
+}
+
+// NewMemoryHistory returns a MemoryHistory keeping up to size records.
+func NewMemoryHistory(size int) *MemoryHistory {
+	return &MemoryHistory{size: size}
+}
+
+// Record implements HistoryStore.
+func (h *MemoryHistory) Record(_ context.Context, rec HistoryRecord) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.records) < h.size {
+		h.records = append(h.records, rec)
+		return nil
+	}
+	h.records[h.next] = rec
+	h.next = (h.next + 1) % h.size
+	return nil
+}
+
+// Recent implements HistoryStore.
+func (h *MemoryHistory) Recent(_ context.Context, limit int) ([]HistoryRecord, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := min(limit, len(h.records))
+	recent := make([]HistoryRecord, 0, n)
+	// Walk back from the newest record, which sits just before next
+	for i := 0; i < n; i++ {
+		idx := (h.next - 1 - i + len(h.records)) % len(h.records)
+		recent = append(recent, h.records[idx])
+	}
+	return recent, nil
+}
+
+// Limits on the number of records GET /api/history returns.
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 1000
+)
+
+// handleHistory serves GET /api/history: the most recent greetings and
+// farewells in store, newest first, up to the limit query parameter.
+func handleHistory(store HistoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultHistoryLimit
+		if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxHistoryLimit {
+				writeError(w, http.StatusBadRequest, codes.InvalidArgument,
+					fmt.Sprintf("limit must be an integer between 1 and %d, got %q", maxHistoryLimit, raw))
+				return
+			}
+			limit = n
+		}
+
+		records, err := store.Recent(r.Context(), limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "HTTP: Could not read history", "error", err)
+			writeError(w, http.StatusInternalServerError, codes.Internal, "could not read history")
+			return
+		}
+		if records == nil {
+			records = []HistoryRecord{}
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{"records": records})
+	}
+}
@@ -0,0 +1,79 @@
+//go:build sqlite && cgo
+
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	historyOpeners[HistoryStoreSQLite] = func(path string, _ int) (HistoryStore, error) {
+		return NewSQLiteHistory(path)
+	}
+}
+
+// SQLiteHistory is a HistoryStore persisting every record in a SQLite
+// database file, so history survives restarts. It is only built with the
+// sqlite tag, as the driver needs cgo.
+type SQLiteHistory struct {
+	db *sql.DB
+}
+
+// NewSQLiteHistory opens, creating if needed, the SQLite database at path.
+// Call Close when done.
+func NewSQLiteHistory(path string) (*SQLiteHistory, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, fmt.Errorf("opening history database %s: %w", path, err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS history (
+		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		method  TEXT    NOT NULL,
+		name    TEXT    NOT NULL,
+		message TEXT    NOT NULL,
+		time    INTEGER NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating history table in %s: %w", path, err)
+	}
+	return &SQLiteHistory{db: db}, nil
+}
+
+// Record implements HistoryStore.
+func (h *SQLiteHistory) Record(ctx context.Context, rec HistoryRecord) error {
+	_, err := h.db.ExecContext(ctx, `INSERT INTO history (method, name, message, time) VALUES (?, ?, ?, ?)`,
+		rec.Method, rec.Name, rec.Message, rec.Time.UnixNano())
+	return err
+}
+
+// Recent implements HistoryStore.
+func (h *SQLiteHistory) Recent(ctx context.Context, limit int) ([]HistoryRecord, error) {
+	rows, err := h.db.QueryContext(ctx, `SELECT method, name, message, time FROM history ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []HistoryRecord
+	for rows.Next() {
+		var rec HistoryRecord
+		var nanos int64
+		if err := rows.Scan(&rec.Method, &rec.Name, &rec.Message, &nanos); err != nil {
+			return nil, err
+		}
+		rec.Time = time.Unix(0, nanos).UTC()
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// Close closes the database.
+func (h *SQLiteHistory) Close() error {
+	return h.db.Close()
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+)
+
+func TestSayHelloIsRecordedInHistory(t *testing.T) {
+	store := NewMemoryHistory(10)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	helloSrv := NewHelloServer(WithHistory(store), WithClock(NewFakeClock(at)))
+	goodbyeSrv := NewGoodbyeServer(WithGoodbyeHistory(store))
+	ctx := context.Background()
+
+	if _, err := helloSrv.SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if _, err := goodbyeSrv.SayGoodbye(ctx, &goodbye.GoodbyeRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayGoodbye: %v", err)
+	}
+
+	rec := serve(testRouter{hello: helloSrv, goodbye: goodbyeSrv, history: store}.handler(), httptest.NewRequest("GET", "/api/history", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/history = %d", rec.Code)
+	}
+	var body struct{ Records []HistoryRecord }
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding history: %v", err)
+	}
+	if len(body.Records) != 2 {
+		t.Fatalf("got %d records, want 2: %+v", len(body.Records), body.Records)
+	}
+	// Newest first
+	if got := body.Records[1]; got != (HistoryRecord{Method: "SayHello", Name: "World", Message: "Hello World", Time: at}) {
+		t.Errorf("SayHello record = %+v", got)
+	}
+	if got := body.Records[0]; got.Method != "SayGoodbye" || got.Name != "World" {
+		t.Errorf("SayGoodbye record = %+v", got)
+	}
+}
+
+func TestMemoryHistoryKeepsLatestRecords(t *testing.T) {
+	store := NewMemoryHistory(3)
+	for i := 1; i <= 5; i++ {
+		store.Record(context.Background(), HistoryRecord{Name: fmt.Sprint(i)})
+	}
+	recent, err := store.Recent(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names string
+	for _, rec := range recent {
+		names += rec.Name
+	}
+	if names != "543" {
+		t.Errorf("recent names = %s, want 543", names)
+	}
+	if recent, _ := store.Recent(context.Background(), 1); len(recent) != 1 || recent[0].Name != "5" {
+		t.Errorf("Recent(1) = %+v, want only 5", recent)
+	}
+}
+
+func TestHistoryRejectsBadLimit(t *testing.T) {
+	h := testRouter{history: NewMemoryHistory(10)}.handler()
+	for _, limit := range []string{"0", "1001", "many"} {
+		if rec := serve(h, httptest.NewRequest("GET", "/api/history?limit="+limit, nil)); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/history?limit=%s = %d, want 400", limit, rec.Code)
+		}
+	}
+}
+
+func TestHistoryRequiresCallerAuth(t *testing.T) {
+	store := NewMemoryHistory(10)
+	store.Record(context.Background(), HistoryRecord{Method: "SayHello", Name: "World", Message: "[acme] Hello World"})
+
+	h := testRouter{history: store, interceptors: authRegistry()}.handler()
+	rec := serve(h, httptest.NewRequest("GET", "/api/history", nil))
+	if got := decodeBody(t, rec)["code"]; rec.Code != http.StatusUnauthorized || got != "Unauthenticated" {
+		t.Errorf("GET /api/history without an API key = %d %v, want 401 Unauthenticated", rec.Code, got)
+	}
+	req := httptest.NewRequest("GET", "/api/history", nil)
+	req.Header.Set("Grpc-Metadata-X-Api-Key", "secret")
+	if rec := serve(h, req); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "World") {
+		t.Errorf("GET /api/history with the API key = %d %s, want 200 with the records", rec.Code, rec.Body)
+	}
+
+	h = testRouter{history: store, interceptors: tenantRegistry()}.handler()
+	if rec := serve(h, httptest.NewRequest("GET", "/api/history", nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/history without tenant-id = %d, want 400", rec.Code)
+	}
+	req = httptest.NewRequest("GET", "/api/history", nil)
+	req.Header.Set("Grpc-Metadata-Tenant-Id", "acme")
+	if rec := serve(h, req); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/history with tenant-id = %d %s, want 200", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,917 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// HTTP request/response structs for REST API
+type HelloRequest struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+}
+
+type HelloResponse struct {
+	Message string `json:"message"`
+}
+
+type GoodbyeRequest struct {
+	Name string `json:"name"`
+}
+
+type GoodbyeResponse struct {
+	Message string `json:"message"`
+}
+
+// restIncomingMetadata returns the gRPC metadata a REST request carries to
+// the interceptors and handlers: its Grpc-Metadata-* headers without the
+// prefix, its Authorization header and its request ID.
+func restIncomingMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range r.Header {
+		switch {
+		case strings.HasPrefix(key, "Grpc-Metadata-"):
+			md.Append(strings.TrimPrefix(key, "Grpc-Metadata-"), values...)
+		case key == "Authorization":
+			md.Append(key, values...)
+		}
+	}
+	// requestIDMiddleware has already read or generated the request ID
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		md.Set(requestIDMetadataKey, id)
+	}
+	return md
+}
+
+// invokeUnary calls handler for fullMethod on srv through chain, so REST
+// handlers get the same auth, required metadata and other checks as gRPC
+// callers. A nil chain calls handler directly.
+func invokeUnary(ctx context.Context, chain grpc.UnaryServerInterceptor, srv interface{}, fullMethod string, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	if chain == nil {
+		return handler(ctx, req)
+	}
+	return chain(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+}
+
+// restTransportStream collects the headers and trailers a gRPC method sets
+// when a REST handler calls it directly, so they can be copied onto the HTTP
+// response.
+type restTransportStream struct {
+	method  string
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *restTransportStream) Method() string { return s.method }
+
+func (s *restTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *restTransportStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *restTransportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+// HTTP REST API handlers
+
+// handleSayHelloHTTP serves /api/hello, calling SayHello, or
+// SayHelloInLanguage when a language is requested, through the unary
+// interceptors of registry. A POST body must carry a name; a GET without
+// ?name= greets "World" on purpose, so the bare URL works in a browser.
+func (s *HelloServer) handleSayHelloHTTP(registry *Registry) http.HandlerFunc {
+	chain := registry.unaryChain()
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "HTTP: Received SayHello request", "method", r.Method, "path", r.URL.Path)
+
+		var req HelloRequest
+		var name, language string
+
+		if r.Method == "GET" || r.Method == "HEAD" {
+			// Handle GET request with query parameter; HEAD gets the same
+			// headers, and net/http drops the body
+			name = r.URL.Query().Get("name")
+			if name == "" {
+				name = "World"
+			}
+			language = r.URL.Query().Get("lang")
+		} else if r.Method == "POST" {
+			// Handle POST request with JSON body
+			if err := decodeJSONBody(r, &req); err != nil {
+				writeDecodeError(w, err)
+				return
+			}
+			if req.Name == "" {
+				writeMissingField(w, "name")
+				return
+			}
+			name = req.Name
+			language = req.Language
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		slog.DebugContext(r.Context(), "HTTP: Processing hello request", "name", name)
+
+		// Create gRPC request and call the gRPC method, using the localized
+		// variant when a language was requested
+		method := "SayHello"
+		grpcReq := &hello.HelloRequest{Name: name, Language: language}
+		if err := validate(grpcReq); err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+		if language != "" {
+			method = "SayHelloInLanguage"
+		}
+		ts := &restTransportStream{method: "/" + hello.Greeter_ServiceDesc.ServiceName + "/" + method}
+		ctx := metadata.NewIncomingContext(r.Context(), restIncomingMetadata(r))
+		ctx = grpc.NewContextWithServerTransportStream(ctx, ts)
+		reply, err := invokeUnary(ctx, chain, s, ts.method, grpcReq, func(ctx context.Context, req interface{}) (interface{}, error) {
+			if language != "" {
+				return s.SayHelloInLanguage(ctx, req.(*hello.HelloRequest))
+			}
+			return s.SayHello(ctx, req.(*hello.HelloRequest))
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "HTTP: "+method+" failed", "name", name, "error", err)
+			writeGRPCError(w, err)
+			return
+		}
+
+		// Convert to HTTP response
+		resp := HelloResponse{Message: reply.(*hello.HelloReply).GetMessage()}
+
+		// Add custom headers before writeJSON sends them
+		setServerHeaders(w, method)
+		w.Header().Set("X-Timestamp", s.now().Format(time.RFC3339))
+		if ids := ts.header.Get("response-id"); len(ids) > 0 {
+			w.Header().Set("X-Response-ID", ids[0])
+		}
+		if ids := ts.trailer.Get("request-completed-id"); len(ids) > 0 {
+			w.Header().Set("X-Request-Completed-ID", ids[0])
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// recordCachedHello records a GET /api/hello answered from the response
+// cache in the history, as SayHello or SayHelloInLanguage would have.
+func (s *HelloServer) recordCachedHello(r *http.Request, body []byte) {
+	var resp HelloResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "World"
+	}
+	method := "SayHello"
+	if r.URL.Query().Get("lang") != "" {
+		method = "SayHelloInLanguage"
+	}
+	recordHistory(r.Context(), s.history, method, name, resp.Message, s.now())
+}
+
+// handleSayGoodbyeHTTP serves /api/goodbye, calling SayGoodbye through the
+// unary interceptors of registry. A POST body must carry a name; a GET
+// without ?name= says goodbye to "Friend" on purpose, as /api/hello does.
+func (s *GoodbyeServer) handleSayGoodbyeHTTP(registry *Registry) http.HandlerFunc {
+	chain := registry.unaryChain()
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "HTTP: Received SayGoodbye request", "method", r.Method, "path", r.URL.Path)
+
+		var req GoodbyeRequest
+		var name string
+
+		if r.Method == "GET" || r.Method == "HEAD" {
+			// Handle GET request with query parameter; HEAD gets the same
+			// headers, and net/http drops the body
+			name = r.URL.Query().Get("name")
+			if name == "" {
+				name = "Friend"
+			}
+		} else if r.Method == "POST" {
+			// Handle POST request with JSON body
+			if err := decodeJSONBody(r, &req); err != nil {
+				writeDecodeError(w, err)
+				return
+			}
+			if req.Name == "" {
+				writeMissingField(w, "name")
+				return
+			}
+			name = req.Name
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		slog.DebugContext(r.Context(), "HTTP: Processing goodbye request", "name", name)
+
+		// Create gRPC request and call the gRPC method
+		grpcReq := &goodbye.GoodbyeRequest{Name: name}
+		if err := validate(grpcReq); err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+		ts := &restTransportStream{method: "/" + goodbye.Farewell_ServiceDesc.ServiceName + "/SayGoodbye"}
+		ctx := metadata.NewIncomingContext(r.Context(), restIncomingMetadata(r))
+		ctx = grpc.NewContextWithServerTransportStream(ctx, ts)
+		reply, err := invokeUnary(ctx, chain, s, ts.method, grpcReq, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return s.SayGoodbye(ctx, req.(*goodbye.GoodbyeRequest))
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "HTTP: SayGoodbye failed", "name", name, "error", err)
+			writeGRPCError(w, err)
+			return
+		}
+
+		// Convert to HTTP response
+		resp := GoodbyeResponse{Message: reply.(*goodbye.GoodbyeReply).GetMessage()}
+
+		// Add custom headers before writeJSON sends them
+		setServerHeaders(w, "SayGoodbye")
+		w.Header().Set("X-Timestamp", s.now().Format(time.RFC3339))
+		if ids := ts.header.Get("response-id"); len(ids) > 0 {
+			w.Header().Set("X-Response-ID", ids[0])
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// recordCachedGoodbye records a GET /api/goodbye answered from the response
+// cache in the history, as SayGoodbye would have.
+func (s *GoodbyeServer) recordCachedGoodbye(r *http.Request, body []byte) {
+	var resp GoodbyeResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "Friend"
+	}
+	recordHistory(r.Context(), s.history, "SayGoodbye", name, resp.Message, s.now())
+}
+
+// API documentation endpoint, describing the version and ports of welcome
+// and only the services in enabled. /api/history is listed only when
+// withHistory is set.
+func handleAPIDoc(welcome WelcomeInfo, enabled []restService, withHistory bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topology := welcome.topology()
+		description := "Unified server supporting both gRPC and HTTP REST APIs on the same port"
+		if topology.Split() {
+			description = "Server supporting both gRPC and HTTP REST APIs on separate ports"
+		}
+
+		grpcServices := []map[string]interface{}{
+			{
+				"name":    "grpc.hello.Greeter",
+				"methods": []string{"SayHello", "SayHelloStream", "SayHelloClientStream", "SayHelloBidirectional", "SayHelloInLanguage", "SayHelloAggregate"},
+			},
+			{
+				"name":    "grpc.goodbye.Farewell",
+				"methods": []string{"SayGoodbye", "SayGoodbyeStream", "SayGoodbyeClientStream", "SayGoodbyeBidirectional", "SayGoodbyeWithReason", "SayGoodbyeUntilCancelled"},
+			},
+		}
+		httpRoutes := []map[string]interface{}{
+			{
+				"path":        "/api/hello",
+				"methods":     []string{"GET", "HEAD", "POST"},
+				"description": "Say hello to someone",
+				"parameters": map[string]string{
+					"name": "Name of the person to greet (query param for GET, defaulting to World; required in the JSON body for POST)",
+					"lang": "Optional language tag such as fr or es (query param for GET, \"language\" in JSON body for POST)",
+				},
+			},
+			{
+				"path":        "/api/hello/batch",
+				"methods":     []string{"POST"},
+				"description": "Say hello to several people in one request; failures are reported per name",
+				"parameters": map[string]string{
+					"names": "JSON array of names (at most 100)",
+				},
+			},
+			{
+				"path":        "/ws/hello",
+				"methods":     []string{"GET"},
+				"description": "WebSocket bridged to SayHelloBidirectional: send names as text messages, receive {\"message\"} greetings, then a final {\"trailers\", \"error\"} message",
+				"parameters": map[string]string{
+					"transform": "Optional upper or reverse, applied to names in replies (query param, passed as metadata like any other except credentials)",
+				},
+			},
+			{
+				"path":        "/api/goodbye",
+				"methods":     []string{"GET", "HEAD", "POST"},
+				"description": "Say goodbye to someone",
+				"parameters": map[string]string{
+					"name": "Name of the person to bid farewell (query param for GET, defaulting to Friend; required in the JSON body for POST)",
+				},
+			},
+			{
+				"path":        "/api/goodbye/stream",
+				"methods":     []string{"GET"},
+				"description": "Stream the SayGoodbyeStream farewells as server-sent events, ending with a done event carrying the stream trailers",
+				"parameters": map[string]string{
+					"name": "Name of the person to bid farewell (query param)",
+				},
+			},
+			{
+				"path":        "/api/history",
+				"methods":     []string{"GET"},
+				"description": "Most recent greetings and farewells, newest first",
+				"parameters": map[string]string{
+					"limit": "Number of records to return, 1 to 1000 (query param, defaults to 50)",
+				},
+			},
+			{
+				"path":        "/v1/{method}",
+				"methods":     []string{"POST"},
+				"description": "Every gRPC method transcoded to JSON, e.g. /v1/hello for SayHello and /v1/goodbye-stream for SayGoodbyeStream",
+			},
+			{
+				"path":        "/health",
+				"methods":     []string{"GET"},
+				"description": "Readiness check with server details; answers 503 with status starting before the server is accepting connections or draining after /admin/drain",
+			},
+			{
+				"path":        "/healthz",
+				"methods":     []string{"GET"},
+				"description": "Liveness check; answers 200 whenever the process is up, even while draining",
+			},
+			{
+				"path":        "/readyz",
+				"methods":     []string{"GET"},
+				"description": "Readiness check; answers 503 while starting up or draining",
+			},
+			{
+				"path":        "/metrics",
+				"methods":     []string{"GET"},
+				"description": "Stream counts, message totals and histograms of stream duration and messages per stream, per gRPC method, in the Prometheus text format",
+			},
+			{
+				"path":        "/admin/drain",
+				"methods":     []string{"POST", "DELETE"},
+				"description": "POST marks the server NOT_SERVING ahead of shutdown without closing connections; DELETE undoes it until the server stops",
+			},
+			{
+				"path":        "/admin/config",
+				"methods":     []string{"GET"},
+				"description": "Effective configuration as JSON, with secrets such as the admin API key redacted",
+			},
+			{
+				"path":        "/api/doc",
+				"methods":     []string{"GET"},
+				"description": "API documentation",
+			},
+			{
+				"path":        "/api/descriptors",
+				"methods":     []string{"GET"},
+				"description": "FileDescriptorSet of the hello and goodbye protos, available without gRPC reflection",
+				"parameters": map[string]string{
+					"format": "binary (default) or base64 (query param)",
+				},
+			},
+			{
+				"path":        "/openapi.json",
+				"methods":     []string{"GET"},
+				"description": "OpenAPI 3.0 specification",
+			},
+			{
+				"path":        "/docs",
+				"methods":     []string{"GET"},
+				"description": "Swagger UI",
+			},
+		}
+		grpcExamples := map[string]string{
+			"list_services": "grpcurl -plaintext " + topology.GRPCAddr + " list",
+			"say_hello":     "grpcurl -plaintext -d '{\"name\":\"World\"}' " + topology.GRPCAddr + " grpc.hello.Greeter/SayHello",
+			"say_goodbye":   "grpcurl -plaintext -d '{\"name\":\"Friend\"}' " + topology.GRPCAddr + " grpc.goodbye.Farewell/SayGoodbye",
+		}
+		httpExamples := map[string]string{
+			"say_hello_get":  "curl 'http://" + topology.HTTPAddr + "/api/hello?name=World'",
+			"say_hello_lang": "curl 'http://" + topology.HTTPAddr + "/api/hello?name=World&lang=fr'",
+			"say_hello_post": "curl -X POST -H 'Content-Type: application/json' -d '{\"name\":\"World\"}' http://" + topology.HTTPAddr + "/api/hello",
+			"say_goodbye":    "curl 'http://" + topology.HTTPAddr + "/api/goodbye?name=Friend'",
+			"health_check":   "curl http://" + topology.HTTPAddr + "/health",
+		}
+		grpcServices, httpRoutes = filterAPIDoc(grpcServices, httpRoutes, grpcExamples, httpExamples, enabled)
+		if !withHistory {
+			httpRoutes = slices.DeleteFunc(httpRoutes, func(m map[string]interface{}) bool {
+				return m["path"] == "/api/history"
+			})
+		}
+
+		apiDoc := map[string]interface{}{
+			"title":       "gRPC Sample Server API",
+			"version":     welcome.Version,
+			"description": description,
+			"protocols":   []string{"gRPC", "HTTP"},
+			"ports":       topology.ports(),
+			"note":        topology.note(),
+			"endpoints": map[string]interface{}{
+				"grpc": map[string]interface{}{
+					"address":  topology.GRPCAddr,
+					"services": grpcServices,
+				},
+				"http": map[string]interface{}{
+					"address": topology.HTTPAddr,
+					"routes":  httpRoutes,
+				},
+			},
+			"examples": map[string]interface{}{
+				"grpc": grpcExamples,
+				"http": httpExamples,
+			},
+		}
+
+		writeJSON(w, http.StatusOK, apiDoc)
+	}
+}
+
+// restService ties a gRPC service to the REST routes and /api/doc examples
+// that front it, so they can be left out when the service is disabled.
+type restService struct {
+	name       string
+	pathPrefix string
+	// wsPath is the service's WebSocket route, outside pathPrefix; empty
+	// if it has none.
+	wsPath        string
+	examplePrefix string
+}
+
+var (
+	helloREST   = restService{name: hello.Greeter_ServiceDesc.ServiceName, pathPrefix: "/api/hello", wsPath: "/ws/hello", examplePrefix: "say_hello"}
+	goodbyeREST = restService{name: goodbye.Farewell_ServiceDesc.ServiceName, pathPrefix: "/api/goodbye", examplePrefix: "say_goodbye"}
+)
+
+// filterAPIDoc drops the services, routes and examples of every service not
+// in enabled. Examples are removed from the maps in place.
+func filterAPIDoc(services, routes []map[string]interface{}, grpcExamples, httpExamples map[string]string, enabled []restService) ([]map[string]interface{}, []map[string]interface{}) {
+	for _, svc := range []restService{helloREST, goodbyeREST} {
+		if slices.Contains(enabled, svc) {
+			continue
+		}
+		services = slices.DeleteFunc(services, func(m map[string]interface{}) bool {
+			return m["name"] == svc.name
+		})
+		routes = slices.DeleteFunc(routes, func(m map[string]interface{}) bool {
+			path, _ := m["path"].(string)
+			return path == svc.pathPrefix || strings.HasPrefix(path, svc.pathPrefix+"/") ||
+				(svc.wsPath != "" && path == svc.wsPath)
+		})
+		for _, examples := range []map[string]string{grpcExamples, httpExamples} {
+			for key := range examples {
+				if strings.HasPrefix(key, svc.examplePrefix) {
+					delete(examples, key)
+				}
+			}
+		}
+	}
+	return services, routes
+}
+
+// newH2CHandler serves next over HTTP/1.1 and cleartext HTTP/2, whether the
+// client starts HTTP/2 with prior knowledge or through the HTTP/1.1
+// "Upgrade: h2c" handshake (RFC 7540 Section 3.2). h2c answers the upgrade
+// request on the first stream of the new HTTP/2 connection but passes it on
+// as it arrived: still HTTP/1.1 and carrying the upgrade headers. It is
+// rewritten here as the HTTP/2 request it became, so gRPC accepts it and
+// REST handlers never see the hop-by-hop headers. The body of an upgrade
+// request is read in full before the switch, so streaming gRPC calls still
+// need prior knowledge, as gRPC clients use anyway.
+func newH2CHandler(next http.Handler, h2s *http2.Server) http.Handler {
+	return h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 1 && isH2CUpgrade(r.Header) {
+			r = r.Clone(r.Context())
+			r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+			for _, h := range []string{"Upgrade", "Connection", "HTTP2-Settings"} {
+				r.Header.Del(h)
+			}
+		}
+		next.ServeHTTP(w, r)
+	}), h2s)
+}
+
+// isH2CUpgrade reports whether h asks to upgrade to h2c. h2c takes over every
+// such request, so one reaching a handler on an HTTP/1.1 connection can only
+// be the request the connection was upgraded for.
+func isH2CUpgrade(h http.Header) bool {
+	return httpguts.HeaderValuesContainsToken(h.Values("Upgrade"), "h2c") &&
+		httpguts.HeaderValuesContainsToken(h.Values("Connection"), "HTTP2-Settings")
+}
+
+// CreateMultiplexedHandler returns a protocol multiplexer that can handle both gRPC and HTTP on the same port.
+// h2s configures cleartext HTTP/2 (h2c) connections, e.g. their
+// MaxConcurrentStreams; TLS connections are configured on the http.Server.
+// gRPC is accepted over h2c with prior knowledge or after an Upgrade: h2c.
+func CreateMultiplexedHandler(grpcServer *grpc.Server, httpHandler http.Handler, h2s *http2.Server) http.Handler {
+	return newH2CHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Check if this is a gRPC request
+		contentType := r.Header.Get("Content-Type")
+		if isGRPCContentType(contentType) {
+			if r.ProtoMajor != 2 {
+				// gRPC cannot run over HTTP/1.x; say so instead of letting
+				// the REST router answer with a 404
+				writeGRPCOverHTTP1Error(w, r, fmt.Sprintf("gRPC requires HTTP/2, but this request used %s", r.Proto))
+				return
+			}
+			// This is a gRPC request
+			grpcServer.ServeHTTP(w, r)
+		} else if r.ProtoMajor != 2 && r.Method == http.MethodPost && isGRPCMethodPath(grpcServer, r.URL.Path) && hasGRPCFrame(r) {
+			// A gRPC call that lost its content type as well, e.g. through
+			// a proxy that only speaks HTTP/1.1
+			writeGRPCOverHTTP1Error(w, r, fmt.Sprintf("this looks like a gRPC call, which requires HTTP/2 and Content-Type application/grpc, but it used %s with Content-Type %q",
+				r.Proto, contentType))
+		} else {
+			// This is an HTTP request
+			httpHandler.ServeHTTP(w, r)
+		}
+	}), h2s)
+}
+
+// CreateGRPCHandler returns a handler that serves only gRPC, for the gRPC
+// port in split-port mode. Other requests get a JSON 404 saying REST is
+// served elsewhere. h2s is used as in CreateMultiplexedHandler.
+func CreateGRPCHandler(grpcServer *grpc.Server, h2s *http2.Server) http.Handler {
+	return CreateMultiplexedHandler(grpcServer, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, codes.NotFound, "this port only serves gRPC; REST is served on the HTTP port")
+	}), h2s)
+}
+
+// CreateRESTHandler returns a handler that serves only REST, for the HTTP
+// port in split-port mode. It accepts h2c like CreateMultiplexedHandler so
+// gRPC requests reach it and get a 404, which gRPC clients see as
+// Unimplemented, instead of failing at the connection preface. h2s is used
+// as in CreateMultiplexedHandler.
+func CreateRESTHandler(httpHandler http.Handler, h2s *http2.Server) http.Handler {
+	return newH2CHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPCContentType(r.Header.Get("Content-Type")) {
+			writeError(w, http.StatusNotFound, codes.Unimplemented, "this port only serves REST; gRPC is served on the gRPC port")
+			return
+		}
+		httpHandler.ServeHTTP(w, r)
+	}), h2s)
+}
+
+// isGRPCContentType reports whether contentType is application/grpc or one of
+// its subtypes such as application/grpc+proto and application/grpc+json.
+// gRPC-Web's application/grpc-web is not included.
+func isGRPCContentType(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if !strings.HasPrefix(contentType, "application/grpc") {
+		return false
+	}
+	rest := contentType[len("application/grpc"):]
+	return rest == "" || rest[0] == '+' || rest[0] == ';'
+}
+
+// grpcFrameHeaderLen is the length of the prefix of every gRPC message: a
+// compressed flag byte and the message length as a 4-byte big-endian integer.
+const grpcFrameHeaderLen = 5
+
+// isGRPCMethodPath reports whether path, such as
+// "/grpc.hello.Greeter/SayHello", names a method registered on grpcServer.
+func isGRPCMethodPath(grpcServer *grpc.Server, path string) bool {
+	service, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return false
+	}
+	for _, m := range grpcServer.GetServiceInfo()[service].Methods {
+		if m.Name == method {
+			return true
+		}
+	}
+	return false
+}
+
+// hasGRPCFrame reports whether the body of r starts like a gRPC message: a
+// compressed flag of 0 or 1, then a length that fits in the Content-Length,
+// if declared. JSON and the other REST bodies never start with either flag.
+// The bytes read are put back in front of the body.
+func hasGRPCFrame(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	header := make([]byte, grpcFrameHeaderLen)
+	n, err := io.ReadFull(r.Body, header)
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(header[:n]), r.Body), r.Body}
+	if err != nil || header[0] > 1 {
+		return false
+	}
+	length := int64(binary.BigEndian.Uint32(header[1:]))
+	return r.ContentLength < 0 || grpcFrameHeaderLen+length <= r.ContentLength
+}
+
+// writeGRPCOverHTTP1Error answers a gRPC call made over HTTP/1.x with a 505
+// and a JSON ErrorResponse explaining why. The same FailedPrecondition status
+// is set in the Grpc-Status and Grpc-Message headers, where gRPC-aware tools
+// look for it.
+func writeGRPCOverHTTP1Error(w http.ResponseWriter, r *http.Request, message string) {
+	slog.WarnContext(r.Context(), "HTTP: Rejected gRPC call over HTTP/1.x", "path", r.URL.Path,
+		"proto", r.Proto, "remote_addr", r.RemoteAddr)
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(codes.FailedPrecondition)))
+	w.Header().Set("Grpc-Message", encodeGRPCMessage(message))
+	writeError(w, http.StatusHTTPVersionNotSupported, codes.FailedPrecondition, message)
+}
+
+// encodeGRPCMessage percent-encodes message for the Grpc-Message header, as
+// the gRPC protocol requires for bytes outside printable ASCII and for '%'.
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// requireJSONMiddleware answers POST requests that carry a body without
+// declaring Content-Type application/json, charset parameters allowed, with a
+// 415 instead of letting the handler fail to decode, say, form data as JSON.
+// Body-less POSTs such as /admin/drain pass.
+func requireJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		contentType := r.Header.Get("Content-Type")
+		if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, codes.InvalidArgument,
+				fmt.Sprintf("unsupported Content-Type %q: POST bodies must be application/json", contentType))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// prettyJSONMiddleware indents the JSON responses of requests sent with
+// ?pretty=true, for reading them in a terminal; the default stays compact.
+// Responses of any other Content-Type, such as server-sent events and the
+// Swagger UI page, are left alone.
+func prettyJSONMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); !pretty {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&prettyJSONWriter{ResponseWriter: w}, r)
+	})
+}
+
+// prettyJSONWriter indents each JSON write. Handlers write a whole JSON value,
+// or one line of a streamed response, per call, so writes are indented one at
+// a time and streams keep flushing as they go. A write that is not a
+// complete value is passed through as is.
+type prettyJSONWriter struct {
+	http.ResponseWriter
+}
+
+func (w *prettyJSONWriter) Write(p []byte) (int, error) {
+	if mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type")); mediaType != "application/json" {
+		return w.ResponseWriter.Write(p)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, p, "", "  "); err != nil {
+		return w.ResponseWriter.Write(p)
+	}
+	if _, err := w.ResponseWriter.Write(indented.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush lets the SSE handler, which asserts http.Flusher, stream through the
+// writer.
+func (w *prettyJSONWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, which
+// RequestDeadlines uses.
+func (w *prettyJSONWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// RequestDeadlines bounds reading each REST request body to read and writing
+// its response to write, measured from when the handler starts. Handlers that
+// stream, such as the SSE endpoint, clear both deadlines themselves.
+// gRPC requests never pass through here, so long-lived RPC streams are not
+// affected.
+func RequestDeadlines(next http.Handler, read, write time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		now := time.Now()
+		// Not every connection supports deadlines (e.g. REST over h2c); the
+		// server-wide header and idle timeouts still apply there
+		rc.SetReadDeadline(now.Add(read))
+		rc.SetWriteDeadline(now.Add(write))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WelcomeInfo identifies the server in the welcome message served at /.
+type WelcomeInfo struct {
+	ServiceName string
+	Version     string
+	// Topology returns where the server is listening, as reported by /,
+	// /health and /api/doc. It is read on every request, so a Server can
+	// report the addresses its listeners are bound to, including ports
+	// picked by the OS for ":0".
+	Topology func() Topology
+}
+
+// topology returns the result of w.Topology, or an empty Topology when it
+// is nil.
+func (w WelcomeInfo) topology() Topology {
+	if w.Topology == nil {
+		return Topology{}
+	}
+	return w.Topology()
+}
+
+// Topology records where the server serves each protocol. Both addresses are
+// the same when gRPC and REST share one port.
+type Topology struct {
+	GRPCAddr string
+	HTTPAddr string
+}
+
+// NewTopology returns the topology for the given listen addresses, with
+// unspecified hosts shown as localhost so the addresses can be dialed.
+func NewTopology(grpcAddr, httpAddr string) Topology {
+	return Topology{GRPCAddr: dialableAddress(grpcAddr), HTTPAddr: dialableAddress(httpAddr)}
+}
+
+// Split reports whether gRPC and REST are served on separate ports.
+func (t Topology) Split() bool {
+	return t.GRPCAddr != t.HTTPAddr
+}
+
+// ports returns the port of each protocol.
+func (t Topology) ports() map[string]string {
+	_, grpcPort, _ := net.SplitHostPort(t.GRPCAddr)
+	_, httpPort, _ := net.SplitHostPort(t.HTTPAddr)
+	return map[string]string{"grpc": grpcPort, "http": httpPort}
+}
+
+// note describes the topology in one sentence.
+func (t Topology) note() string {
+	if t.Split() {
+		return "gRPC and HTTP are served on separate ports; each port rejects the other protocol"
+	}
+	return "Both protocols are served on the same port using protocol multiplexing"
+}
+
+// dialableAddress replaces an empty or unspecified host in addr, such as
+// ":50051", with localhost.
+func dialableAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// RouterOptions configures the REST router SetupHTTPRouter builds. Health
+// and Metrics are required; the other fields may be left zero.
+type RouterOptions struct {
+	// Hello and Goodbye back the REST routes of each service. A nil server
+	// leaves out that service's routes, which then get the JSON 404 of
+	// unknown routes, and its entries in /api/doc.
+	Hello   *HelloServer
+	Goodbye *GoodbyeServer
+	// Health answers /health, /healthz, /readyz and /admin/drain.
+	Health *Health
+	// Interceptors run around the backend calls of the API and transcoded
+	// /v1 routes; their auth stage also guards /api/history.
+	Interceptors *Registry
+	// Welcome describes the server on /, /health and /api/doc.
+	Welcome WelcomeInfo
+	// Cache serves GET /api/hello and GET /api/goodbye; nil disables
+	// caching.
+	Cache *ResponseCache
+	// Admin guards the /admin routes, which are disabled without an API key.
+	Admin AdminOptions
+	// History is served at GET /api/history, which is left out when it is
+	// nil.
+	History HistoryStore
+	// Timeouts bound each route's backend calls.
+	Timeouts RouteTimeouts
+	// Metrics is served at GET /metrics in the Prometheus text format.
+	Metrics *StreamMetrics
+	// CORS lists the origins /ws/hello accepts upgrades from.
+	CORS CORSOptions
+}
+
+// SetupHTTPRouter builds the REST router described by opts. Every JSON
+// response is indented when the request has ?pretty=true.
+func SetupHTTPRouter(opts RouterOptions) http.Handler {
+	router := mux.NewRouter()
+	router.Use(requestIDMiddleware)
+	router.Use(callerMiddleware)
+	router.Use(requireJSONMiddleware)
+	router.Use(routeTimeoutMiddleware(opts.Timeouts))
+	router.NotFoundHandler = http.HandlerFunc(handleRouteNotFound)
+	router.MethodNotAllowedHandler = handleMethodNotAllowed(router)
+
+	// API routes, with transcoded routes for every gRPC method of each
+	// enabled service
+	var enabled []restService
+	if opts.Hello != nil {
+		enabled = append(enabled, helloREST)
+		router.HandleFunc("/api/hello", opts.Cache.Cached(opts.Hello.handleSayHelloHTTP(opts.Interceptors), opts.Hello.recordCachedHello)).Methods("GET", "HEAD", "POST")
+		router.HandleFunc("/api/hello/batch", opts.Hello.handleSayHelloBatchHTTP(opts.Interceptors)).Methods("POST")
+		router.HandleFunc("/ws/hello", opts.Hello.handleSayHelloWebSocket(opts.Interceptors, opts.CORS)).Methods("GET")
+		registerTranscodedRoutes(router, opts.Interceptors, &hello.Greeter_ServiceDesc, opts.Hello)
+	}
+	if opts.Goodbye != nil {
+		enabled = append(enabled, goodbyeREST)
+		router.HandleFunc("/api/goodbye", opts.Cache.Cached(opts.Goodbye.handleSayGoodbyeHTTP(opts.Interceptors), opts.Goodbye.recordCachedGoodbye)).Methods("GET", "HEAD", "POST")
+		router.HandleFunc("/api/goodbye/stream", opts.Goodbye.handleSayGoodbyeStreamHTTP(opts.Interceptors)).Methods("GET")
+		registerTranscodedRoutes(router, opts.Interceptors, &goodbye.Farewell_ServiceDesc, opts.Goodbye)
+	}
+
+	if opts.History != nil {
+		router.HandleFunc("/api/history", requireAuth(opts.Interceptors, "/api/history", handleHistory(opts.History))).Methods("GET")
+	}
+
+	// Utility routes
+	router.HandleFunc("/health", opts.Health.handleHealthCheck(opts.Welcome)).Methods("GET")
+	router.HandleFunc("/healthz", opts.Health.handleLiveness).Methods("GET")
+	router.HandleFunc("/readyz", opts.Health.handleReadiness).Methods("GET")
+	router.HandleFunc("/metrics", handleMetrics(opts.Metrics)).Methods("GET")
+	router.HandleFunc("/admin/drain", requireAPIKey(opts.Admin.APIKey, opts.Health.handleDrain)).Methods("POST")
+	router.HandleFunc("/admin/drain", requireAPIKey(opts.Admin.APIKey, opts.Health.handleResume)).Methods("DELETE")
+	router.HandleFunc("/admin/config", requireAPIKey(opts.Admin.APIKey, handleAdminConfig(opts.Admin.Config))).Methods("GET")
+	router.HandleFunc("/api/doc", handleAPIDoc(opts.Welcome, enabled, opts.History != nil)).Methods("GET")
+	router.HandleFunc("/api/descriptors", handleDescriptors).Methods("GET")
+	router.HandleFunc("/openapi.json", handleOpenAPISpec(router, opts.Welcome.Version)).Methods("GET")
+	router.HandleFunc("/docs", handleSwaggerUI).Methods("GET")
+
+	// Root route
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		topology := opts.Welcome.topology()
+		body := map[string]interface{}{
+			"message": "Welcome to " + opts.Welcome.ServiceName,
+			"service": opts.Welcome.ServiceName,
+			"version": opts.Welcome.Version,
+			"ports":   topology.ports(),
+			"protocols": map[string]string{
+				"grpc": topology.GRPCAddr + " (use grpcurl)",
+				"http": topology.HTTPAddr + " (use curl)",
+			},
+			"note":          topology.note(),
+			"documentation": "/api/doc",
+			"openapi":       "/openapi.json",
+			"swagger_ui":    "/docs",
+			"health":        "/health",
+		}
+
+		writeJSON(w, http.StatusOK, body)
+	}).Methods("GET")
+
+	// Wrapping the router rather than using router.Use also indents the
+	// 404 and 405 errors, which bypass mux middleware
+	return prettyJSONMiddleware(router)
+}
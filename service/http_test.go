@@ -0,0 +1,468 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// testRouter holds the SetupHTTPRouter arguments a test cares about; nil
+// services and health get defaults.
+type testRouter struct {
+	hello        *HelloServer
+	goodbye      *GoodbyeServer
+	health       *Health
+	interceptors *Registry
+	welcome      WelcomeInfo
+	cache        *ResponseCache
+	admin        AdminOptions
+	history      HistoryStore
+	timeouts     RouteTimeouts
+	metrics      *StreamMetrics
+	cors         CORSOptions
+}
+
+func (r testRouter) handler() http.Handler {
+	if r.hello == nil {
+		r.hello = NewHelloServer()
+	}
+	if r.goodbye == nil {
+		r.goodbye = NewGoodbyeServer()
+	}
+	if r.health == nil {
+		r.health = NewHealth()
+	}
+	if r.metrics == nil {
+		r.metrics = NewStreamMetrics()
+	}
+	return SetupHTTPRouter(RouterOptions{
+		Hello:        r.hello,
+		Goodbye:      r.goodbye,
+		Health:       r.health,
+		Interceptors: r.interceptors,
+		Welcome:      r.welcome,
+		Cache:        r.cache,
+		Admin:        r.admin,
+		History:      r.history,
+		Timeouts:     r.timeouts,
+		Metrics:      r.metrics,
+		CORS:         r.cors,
+	})
+}
+
+// serve sends req to h and returns the recorded response.
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// postJSON returns a POST request to target with the JSON body.
+func postJSON(target, body string) *http.Request {
+	req := httptest.NewRequest("POST", target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// decodeBody decodes the JSON body of rec into a map.
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	data, _ := io.ReadAll(rec.Body)
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("body %q is not a JSON object: %v", data, err)
+	}
+	return body
+}
+
+func TestGRPCOverHTTP1GetsInformativeError(t *testing.T) {
+	grpcServer := grpc.NewServer()
+	Register(grpcServer, NewHelloServer(), NewGoodbyeServer())
+	h := CreateMultiplexedHandler(grpcServer, testRouter{}.handler(), &http2.Server{})
+	framed := string([]byte{0, 0, 0, 0, 7, 0x0a, 5, 'W', 'o', 'r', 'l', 'd'})
+
+	for _, contentType := range []string{"application/grpc", "application/grpc+proto", "application/grpc+json", ""} {
+		req := httptest.NewRequest("POST", "/grpc.hello.Greeter/SayHello", strings.NewReader(framed))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := serve(h, req)
+		if rec.Code != http.StatusHTTPVersionNotSupported {
+			t.Errorf("HTTP/1.1 call with Content-Type %q = %d, want 505", contentType, rec.Code)
+			continue
+		}
+		if got := rec.Header().Get("Grpc-Status"); got != strconv.Itoa(int(codes.FailedPrecondition)) {
+			t.Errorf("Grpc-Status = %q, want FailedPrecondition", got)
+		}
+		if msg, _ := decodeBody(t, rec)["message"].(string); !strings.Contains(msg, "HTTP/2") {
+			t.Errorf("error message %q does not say gRPC needs HTTP/2", msg)
+		}
+	}
+
+	// REST calls still reach the router
+	if rec := serve(h, postJSON("/api/hello", `{"name": "World"}`)); rec.Code != http.StatusOK {
+		t.Errorf("POST /api/hello over HTTP/1.1 = %d, want 200", rec.Code)
+	}
+	if rec := serve(h, httptest.NewRequest("GET", "/api/hello?name=World", nil)); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/hello over HTTP/1.1 = %d, want 200", rec.Code)
+	}
+}
+
+func TestFramedGRPCOverHTTP1WithoutGRPCContentType(t *testing.T) {
+	grpcServer := grpc.NewServer()
+	Register(grpcServer, NewHelloServer(), NewGoodbyeServer())
+	srv := httptest.NewServer(CreateMultiplexedHandler(grpcServer, testRouter{}.handler(), &http2.Server{}))
+	defer srv.Close()
+	framed := string([]byte{0, 0, 0, 0, 7, 0x0a, 5, 'W', 'o', 'r', 'l', 'd'})
+	post := func(path, contentType, body string) (*http.Response, map[string]interface{}) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+path, contentType, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("POST %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&decoded)
+		return resp, decoded
+	}
+
+	// A proxy that only speaks HTTP/1.1 may also rewrite the content type
+	resp, body := post("/grpc.hello.Greeter/SayHello", "application/octet-stream", framed)
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Fatalf("framed call with Content-Type application/octet-stream = %d, want 505", resp.StatusCode)
+	}
+	msg, _ := body["message"].(string)
+	for _, want := range []string{"HTTP/2", "application/grpc", "HTTP/1.1", `"application/octet-stream"`} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error message %q does not mention %s", msg, want)
+		}
+	}
+	if got := resp.Header.Get("Grpc-Status"); got != strconv.Itoa(int(codes.FailedPrecondition)) {
+		t.Errorf("Grpc-Status = %q, want FailedPrecondition", got)
+	}
+	// The quotes are printable ASCII, so only a '%' would be escaped
+	if got := resp.Header.Get("Grpc-Message"); got != msg {
+		t.Errorf("Grpc-Message = %q, want %q", got, msg)
+	}
+
+	// Bodies that are not a gRPC frame, or paths that are not a gRPC
+	// method, still reach the REST router
+	if resp, _ := post("/grpc.hello.Greeter/SayHello", "application/json", `{"name": "World"}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("JSON POST to a gRPC method path = %d, want the router's 404", resp.StatusCode)
+	}
+	if resp, _ := post("/grpc.hello.Greeter/NoSuchMethod", "application/octet-stream", framed); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("framed POST to an unknown method = %d, want the router's 404", resp.StatusCode)
+	}
+	// A frame claiming more bytes than the body holds is not a gRPC call
+	if resp, _ := post("/grpc.hello.Greeter/SayHello", "application/octet-stream", string([]byte{0, 0, 0, 1, 0, 'x'})); resp.StatusCode == http.StatusHTTPVersionNotSupported {
+		t.Error("POST with a truncated frame was taken for a gRPC call")
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain message": "plain message",
+		"100% done":     "100%25 done",
+		"line\nbreak":   "line%0Abreak",
+		"tab\there":     "tab%09here",
+		"caf\u00e9":     "caf%C3%A9",
+	} {
+		if got := encodeGRPCMessage(in); got != want {
+			t.Errorf("encodeGRPCMessage(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWelcomeUsesConfiguredIdentity(t *testing.T) {
+	rec := serve(testRouter{welcome: WelcomeInfo{ServiceName: "acme-greeter", Version: "9.9.9"}}.handler(), httptest.NewRequest("GET", "/", nil))
+	body := decodeBody(t, rec)
+	for key, want := range map[string]string{
+		"message": "Welcome to acme-greeter",
+		"service": "acme-greeter",
+		"version": "9.9.9",
+	} {
+		if body[key] != want {
+			t.Errorf("%s = %v, want %q", key, body[key], want)
+		}
+	}
+}
+
+func TestPOSTRequiresJSONContentType(t *testing.T) {
+	h := testRouter{}.handler()
+	post := func(contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/hello", strings.NewReader(`{"name": "World"}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		return serve(h, req)
+	}
+
+	for _, contentType := range []string{"text/plain", "application/x-www-form-urlencoded", ""} {
+		rec := post(contentType)
+		if rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("POST with Content-Type %q = %d, want 415", contentType, rec.Code)
+			continue
+		}
+		if got := decodeBody(t, rec)["code"]; got != "InvalidArgument" {
+			t.Errorf("415 code = %v, want InvalidArgument", got)
+		}
+	}
+	for _, contentType := range []string{"application/json", "application/json; charset=utf-8", "Application/JSON"} {
+		if rec := post(contentType); rec.Code != http.StatusOK {
+			t.Errorf("POST with Content-Type %q = %d, want 200", contentType, rec.Code)
+		}
+	}
+	// Body-less POSTs need no Content-Type
+	if rec := serve(h, httptest.NewRequest("POST", "/api/hello", nil)); rec.Code == http.StatusUnsupportedMediaType {
+		t.Error("body-less POST rejected for its Content-Type")
+	}
+}
+
+// multiplexedServer serves grpc-sample's gRPC services and rest from one
+// httptest server, as Server does on a single port.
+func multiplexedServer(t *testing.T, rest http.Handler) *httptest.Server {
+	t.Helper()
+	grpcServer := grpc.NewServer()
+	Register(grpcServer, NewHelloServer(), NewGoodbyeServer())
+	srv := httptest.NewServer(CreateMultiplexedHandler(grpcServer, rest, &http2.Server{}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMultiplexedHandlerServesH2CGRPCAndHTTP1REST(t *testing.T) {
+	srv := multiplexedServer(t, testRouter{}.handler())
+
+	// gRPC clients speak h2c with prior knowledge
+	conn, err := grpc.NewClient(srv.Listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Errorf("SayHello over h2c: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/api/hello?name=World")
+	if err != nil {
+		t.Fatalf("GET /api/hello: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ProtoMajor != 1 {
+		t.Errorf("GET /api/hello = %d over %s, want 200 over HTTP/1.1", resp.StatusCode, resp.Proto)
+	}
+}
+
+func TestMultiplexedHandlerRewritesH2CUpgradeRequest(t *testing.T) {
+	seen := make(chan *http.Request, 1)
+	srv := multiplexedServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	c, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(c, "GET /health HTTP/1.1\r\nHost: test\r\nConnection: Upgrade, HTTP2-Settings\r\n"+
+		"Upgrade: h2c\r\nHTTP2-Settings: AAMAAABkAARAAAAAAAIAAAAA\r\n\r\n")
+	br := bufio.NewReader(c)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("reading upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade response = %s, want 101", resp.Status)
+	}
+
+	// The upgraded request is answered on stream 1 of the HTTP/2 connection
+	io.WriteString(c, http2.ClientPreface)
+	framer := http2.NewFramer(c, br)
+	framer.WriteSettings()
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			t.Fatalf("reading HTTP/2 frames: %v", err)
+		}
+		if headers, ok := frame.(*http2.HeadersFrame); ok && headers.StreamID == 1 {
+			fields, err := hpack.NewDecoder(4096, nil).DecodeFull(headers.HeaderBlockFragment())
+			if err != nil {
+				t.Fatalf("decoding headers: %v", err)
+			}
+			if len(fields) == 0 || fields[0].Name != ":status" || fields[0].Value != "204" {
+				t.Errorf("stream 1 headers = %v, want :status 204", fields)
+			}
+			break
+		}
+	}
+
+	r := <-seen
+	if r.ProtoMajor != 2 {
+		t.Errorf("handler saw %s, want the upgraded request as HTTP/2", r.Proto)
+	}
+	for _, h := range []string{"Upgrade", "Connection", "HTTP2-Settings"} {
+		if v := r.Header.Get(h); v != "" {
+			t.Errorf("handler saw hop-by-hop %s: %q", h, v)
+		}
+	}
+}
+
+func TestPrettyJSON(t *testing.T) {
+	h := testRouter{}.handler()
+	for _, path := range []string{"/health", "/api/doc", "/api/hello?name=World"} {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		compact := serve(h, httptest.NewRequest("GET", path, nil)).Body.String()
+		pretty := serve(h, httptest.NewRequest("GET", path+sep+"pretty=true", nil)).Body.String()
+
+		if strings.Contains(strings.TrimSpace(compact), "\n") {
+			t.Errorf("GET %s is not compact by default:\n%s", path, compact)
+		}
+		if !strings.Contains(pretty, "\n  \"") {
+			t.Errorf("GET %s with pretty=true is not indented:\n%s", path, pretty)
+		}
+		var a, b interface{}
+		if json.Unmarshal([]byte(compact), &a) != nil || json.Unmarshal([]byte(pretty), &b) != nil {
+			t.Errorf("GET %s: compact or pretty body is not JSON", path)
+		}
+	}
+}
+
+func TestHEADMatchesGETWithoutBody(t *testing.T) {
+	// net/http, not the handlers, drops HEAD bodies, so use a real server
+	srv := httptest.NewServer(testRouter{}.handler())
+	defer srv.Close()
+	do := func(method, path string) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+
+	for _, path := range []string{"/api/hello?name=World", "/api/goodbye?name=World"} {
+		get, _ := do("GET", path)
+		head, body := do("HEAD", path)
+
+		if head.StatusCode != http.StatusOK {
+			t.Errorf("HEAD %s = %d, want 200", path, head.StatusCode)
+		}
+		if len(body) != 0 {
+			t.Errorf("HEAD %s has a %d-byte body", path, len(body))
+		}
+		for _, header := range []string{"X-Server-Name", "Content-Type"} {
+			if got, want := head.Header.Get(header), get.Header.Get(header); got == "" || got != want {
+				t.Errorf("HEAD %s %s = %q, GET has %q", path, header, got, want)
+			}
+		}
+	}
+}
+
+func TestRESTResponseHeadersReachTheClient(t *testing.T) {
+	at := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	srv := httptest.NewServer(testRouter{
+		hello:   NewHelloServer(WithClock(NewFakeClock(at))),
+		goodbye: NewGoodbyeServer(WithGoodbyeClock(NewFakeClock(at))),
+		cache:   NewResponseCache(10, time.Minute, SystemClock{}),
+	}.handler())
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		method, path, body, rpc string
+		timestamp               bool
+	}{
+		{"GET", "/api/hello?name=World", "", "SayHello", true},
+		// The second GET is answered from the cache
+		{"GET", "/api/hello?name=World", "", "SayHello", true},
+		{"POST", "/api/hello", `{"name": "World"}`, "SayHello", true},
+		{"GET", "/api/goodbye?name=World", "", "SayGoodbye", true},
+		{"GET", "/api/goodbye?name=World", "", "SayGoodbye", true},
+		{"POST", "/api/goodbye", `{"name": "World"}`, "SayGoodbye", true},
+		{"POST", "/v1/hello", `{"name": "World"}`, "SayHello", false},
+	} {
+		req, _ := http.NewRequest(tc.method, srv.URL+tc.path, strings.NewReader(tc.body))
+		if tc.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+
+		want := map[string]string{"X-Server-Name": "grpc-sample-server", "X-Method": tc.rpc, "X-Protocol": "HTTP"}
+		if tc.timestamp {
+			want["X-Timestamp"] = "2024-03-15T09:30:00Z"
+		}
+		for header, value := range want {
+			if got := resp.Header.Get(header); got != value {
+				t.Errorf("%s %s %s = %q, want %q", tc.method, tc.path, header, got, value)
+			}
+		}
+	}
+}
+
+func TestRESTResponsesCarryUniqueResponseIDs(t *testing.T) {
+	h := testRouter{cache: NewResponseCache(10, time.Minute, SystemClock{})}.handler()
+	format := regexp.MustCompile(`^(hello|goodbye)-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+	requests := []*http.Request{
+		httptest.NewRequest("GET", "/api/hello?name=World", nil),
+		// Served from the cache, which must not replay the first ID
+		httptest.NewRequest("GET", "/api/hello?name=World", nil),
+		httptest.NewRequest("GET", "/api/hello?name=World&lang=fr", nil),
+		postJSON("/api/hello", `{"name": "World"}`),
+		httptest.NewRequest("GET", "/api/goodbye?name=World", nil),
+		postJSON("/api/goodbye", `{"name": "World"}`),
+	}
+	seen := make(map[string]string)
+	for i, req := range requests {
+		target := req.Method + " " + req.URL.String()
+		rec := serve(h, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s = %d, want 200", target, rec.Code)
+		}
+		if got := rec.Header().Get(cacheStatusHeader); i == 1 && got != "HIT" {
+			t.Fatalf("repeated %s X-Cache = %q, want HIT", target, got)
+		}
+		id := rec.Header().Get("X-Response-ID")
+		if !format.MatchString(id) {
+			t.Errorf("%s X-Response-ID = %q, want <service>-<uuid>", target, id)
+			continue
+		}
+		if want := "goodbye-"; strings.Contains(req.URL.Path, "goodbye") && !strings.HasPrefix(id, want) {
+			t.Errorf("%s X-Response-ID = %q, want the %s prefix", target, id, want)
+		}
+		if prev, ok := seen[id]; ok {
+			t.Errorf("%s X-Response-ID %q repeats the one from %s", target, id, prev)
+		}
+		seen[id] = target
+	}
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheStatusHeader tells clients whether a cacheable GET was answered from
+// the response cache (HIT) or by the handler (MISS).
+const cacheStatusHeader = "X-Cache"
+
+// ResponseCache remembers successful REST GET responses by path, query and
+// caller for a fixed TTL. It holds at most size responses, evicting the least
+// recently used one when full.
+type ResponseCache struct {
+	ttl   time.Duration
+	size  int
+	clock Clock
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// cachedResponse is one cached GET response with the headers its handler set.
+type cachedResponse struct {
+	key     string
+	header  http.Header
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// NewResponseCache returns a cache holding up to size responses for ttl, as
+// told by clock.
+func NewResponseCache(size int, ttl time.Duration, clock Clock) *ResponseCache {
+	return &ResponseCache{
+		ttl:     ttl,
+		size:    size,
+		clock:   clock,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+// get returns the live entry for key, marking it recently used. Expired
+// entries are dropped.
+func (c *ResponseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cachedResponse)
+	if !clockNow(c.clock).Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+// add stores entry, replacing any entry with the same key and evicting the
+// least recently used entries beyond the size limit.
+func (c *ResponseCache) add(entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.expires = clockNow(c.clock).Add(c.ttl)
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// responseRecorder buffers a handler's response so it can be cached before
+// it is written.
+type responseRecorder struct {
+	header http.Header
+	// sent snapshots header at the first WriteHeader, as a real
+	// ResponseWriter would send it, so headers set too late are dropped
+	// here as well rather than only on uncached responses.
+	sent   http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header { return r.header }
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.sent = r.header.Clone()
+	}
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(p)
+}
+
+// Cached serves GET requests for next from the cache, keyed by cacheKey.
+// Successful responses carry an ETag and a private Cache-Control max-age of
+// the time left until they expire, and a request whose If-None-Match lists
+// the ETag gets a 304 without a body. onHit, if not nil, is called with the
+// cached body of every hit, so work the handler does besides responding,
+// such as recording history, is not skipped. HEAD requests share the GET
+// entries, so they get the same headers. Other methods, such as POST,
+// bypass the cache, as do all requests when the cache is nil.
+func (c *ResponseCache) Cached(next http.HandlerFunc, onHit func(r *http.Request, body []byte)) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		key := cacheKey(r)
+		if entry, ok := c.get(key); ok {
+			if onHit != nil {
+				onHit(r, entry.body)
+			}
+			c.writeCachedResponse(w, r, entry, "HIT")
+			return
+		}
+
+		rec := &responseRecorder{header: http.Header{}}
+		next(rec, r)
+		rec.WriteHeader(http.StatusOK)
+		if rec.status != http.StatusOK {
+			for k, v := range rec.sent {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(rec.status)
+			w.Write(rec.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(rec.body.Bytes())
+		entry := &cachedResponse{
+			key:    key,
+			header: rec.sent,
+			body:   rec.body.Bytes(),
+			etag:   `"` + hex.EncodeToString(sum[:16]) + `"`,
+		}
+		c.add(entry)
+		c.writeCachedResponse(w, r, entry, "MISS")
+	}
+}
+
+// cacheKey identifies the response to r by path and query, e.g.
+// /api/hello?lang=fr&name=Alice, and by the headers that say who is calling:
+// Authorization and the Grpc-Metadata-* headers, which carry API keys and
+// tenant-id, so responses to one caller are never replayed to another. The
+// caller headers are hashed so no credentials are kept in memory.
+func cacheKey(r *http.Request) string {
+	key := r.URL.Path + "?" + r.URL.Query().Encode()
+
+	var caller []string
+	for name, values := range r.Header {
+		if name == "Authorization" || strings.HasPrefix(name, "Grpc-Metadata-") {
+			caller = append(caller, name+": "+strings.Join(values, ", "))
+		}
+	}
+	if len(caller) == 0 {
+		return key
+	}
+	sort.Strings(caller)
+	sum := sha256.Sum256([]byte(strings.Join(caller, "\n")))
+	return key + "#" + hex.EncodeToString(sum[:])
+}
+
+// writeCachedResponse writes entry with its caching headers, or a 304 when
+// the request's If-None-Match matches its ETag.
+func (c *ResponseCache) writeCachedResponse(w http.ResponseWriter, r *http.Request, entry *cachedResponse, cacheStatus string) {
+	for k, v := range entry.header {
+		w.Header()[k] = v
+	}
+	if cacheStatus == "HIT" {
+		refreshResponseID(w.Header())
+	}
+	maxAge := int(entry.expires.Sub(clockNow(c.clock)).Round(time.Second) / time.Second)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	// Responses can depend on the caller, e.g. their tenant, so only the
+	// caller's own cache may keep them
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+	w.Header().Set("ETag", entry.etag)
+	w.Header().Set(cacheStatusHeader, cacheStatus)
+
+	if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.body)
+}
+
+// refreshResponseID gives a replayed response an X-Response-ID of its own,
+// and the X-Request-Completed-ID that repeats it, so every REST response can
+// be told apart in logs. The new ID keeps the service prefix of the cached
+// one, e.g. hello in "hello-<uuid>".
+func refreshResponseID(h http.Header) {
+	prefix, _, ok := strings.Cut(h.Get("X-Response-ID"), "-")
+	if !ok {
+		return
+	}
+	fresh := newResponseID(prefix)
+	h.Set("X-Response-ID", fresh)
+	if h.Get("X-Request-Completed-ID") != "" {
+		h.Set("X-Request-Completed-ID", fresh)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag, using
+// the weak comparison RFC 9110 requires for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
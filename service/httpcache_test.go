@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachedGetIsServedFromCache(t *testing.T) {
+	history := NewMemoryHistory(10)
+	h := testRouter{hello: NewHelloServer(WithHistory(history)), cache: NewResponseCache(10, time.Minute, SystemClock{})}.handler()
+
+	first := serve(h, httptest.NewRequest("GET", "/api/hello?name=Alice", nil))
+	if first.Code != http.StatusOK || first.Header().Get(cacheStatusHeader) != "MISS" {
+		t.Fatalf("first GET = %d X-Cache %q, want 200 MISS", first.Code, first.Header().Get(cacheStatusHeader))
+	}
+	second := serve(h, httptest.NewRequest("GET", "/api/hello?name=Alice", nil))
+	if second.Header().Get(cacheStatusHeader) != "HIT" {
+		t.Fatalf("second GET X-Cache = %q, want HIT", second.Header().Get(cacheStatusHeader))
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("cached body %q differs from %q", second.Body, first.Body)
+	}
+	if got := second.Header().Get("Cache-Control"); !strings.HasPrefix(got, "private, max-age=") {
+		t.Errorf("Cache-Control = %q, want private with a max-age", got)
+	}
+	if first.Header().Get("X-Response-ID") == second.Header().Get("X-Response-ID") {
+		t.Error("cache hit reused the X-Response-ID of the original response")
+	}
+
+	// The hit is recorded like the call it replays
+	records, err := history.Recent(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(records) != 2 || records[0].Method != "SayHello" || records[0].Name != "Alice" || records[0].Message != "Hello Alice" {
+		t.Errorf("history = %+v, want two SayHello records for Alice", records)
+	}
+}
+
+func TestCachedGetHonorsIfNoneMatch(t *testing.T) {
+	h := testRouter{cache: NewResponseCache(10, time.Minute, SystemClock{})}.handler()
+
+	first := serve(h, httptest.NewRequest("GET", "/api/goodbye?name=Bob", nil))
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on a cacheable response")
+	}
+
+	req := httptest.NewRequest("GET", "/api/goodbye?name=Bob", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec := serve(h, req)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("conditional GET = %d with %d body bytes, want 304 without a body", rec.Code, rec.Body.Len())
+	}
+
+	req = httptest.NewRequest("GET", "/api/goodbye?name=Bob", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	if rec := serve(h, req); rec.Code != http.StatusOK {
+		t.Errorf("GET with a stale ETag = %d, want 200", rec.Code)
+	}
+}
+
+func TestCacheKeyedByCaller(t *testing.T) {
+	h := testRouter{cache: NewResponseCache(10, time.Minute, SystemClock{})}.handler()
+	get := func(header, value string) string {
+		req := httptest.NewRequest("GET", "/api/hello?name=Alice", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return serve(h, req).Header().Get(cacheStatusHeader)
+	}
+
+	get("", "")
+	if got := get("Authorization", "Bearer one"); got != "MISS" {
+		t.Errorf("GET with credentials after an anonymous GET: X-Cache = %q, want MISS", got)
+	}
+	if got := get("Authorization", "Bearer two"); got != "MISS" {
+		t.Errorf("GET with other credentials: X-Cache = %q, want MISS", got)
+	}
+	if got := get("Grpc-Metadata-Tenant-Id", "acme"); got != "MISS" {
+		t.Errorf("GET for a tenant: X-Cache = %q, want MISS", got)
+	}
+	if got := get("Authorization", "Bearer one"); got != "HIT" {
+		t.Errorf("repeated GET with the same credentials: X-Cache = %q, want HIT", got)
+	}
+}
+
+func TestCacheBypassedByPost(t *testing.T) {
+	h := testRouter{cache: NewResponseCache(10, time.Minute, SystemClock{})}.handler()
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/hello", strings.NewReader(`{"name":"Alice"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := serve(h, req)
+		if rec.Code != http.StatusOK || rec.Header().Get(cacheStatusHeader) != "" {
+			t.Errorf("POST %d = %d X-Cache %q, want 200 without X-Cache", i, rec.Code, rec.Header().Get(cacheStatusHeader))
+		}
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	h := testRouter{cache: NewResponseCache(2, time.Minute, SystemClock{})}.handler()
+	get := func(name string) string {
+		return serve(h, httptest.NewRequest("GET", "/api/hello?name="+name, nil)).Header().Get(cacheStatusHeader)
+	}
+
+	get("a")
+	get("b")
+	get("a") // a is now the most recently used
+	get("c") // evicts b
+	if got := get("a"); got != "HIT" {
+		t.Errorf("a: X-Cache = %q, want HIT", got)
+	}
+	if got := get("b"); got != "MISS" {
+		t.Errorf("b: X-Cache = %q, want MISS after eviction", got)
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ErrorResponse is the JSON body returned by the REST API when a call fails.
+type ErrorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Path is the requested path, set when no route matched it.
+	Path string `json:"path,omitempty"`
+	// Details holds the status details, such as an errdetails.ErrorInfo, in
+	// their protojson form with an "@type" field naming the detail type.
+	Details []json.RawMessage `json:"details,omitempty"`
+}
+
+// errorResponse converts st to an ErrorResponse, keeping the details whose
+// types are linked into the binary. Details of unknown types are dropped.
+func errorResponse(st *status.Status) ErrorResponse {
+	resp := ErrorResponse{Code: st.Code().String(), Message: st.Message()}
+	for _, detail := range st.Proto().GetDetails() {
+		data, err := protojson.Marshal(detail)
+		if err != nil {
+			continue
+		}
+		resp.Details = append(resp.Details, data)
+	}
+	return resp
+}
+
+// HTTPStatusFromCode maps a gRPC status code to the closest HTTP status,
+// following the mapping used by grpc-gateway.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		// Unknown, Internal, DataLoss and anything unrecognised
+		return http.StatusInternalServerError
+	}
+}
+
+// writeJSON writes body as JSON with the given HTTP status. Set any other
+// headers on w before calling it: they are sent with the status line, and
+// headers set afterwards are silently dropped.
+func writeJSON(w http.ResponseWriter, httpStatus int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(body)
+}
+
+// setServerHeaders sets the X-Server-Name, X-Method and X-Protocol headers
+// the REST handlers of a gRPC method add to their responses.
+func setServerHeaders(w http.ResponseWriter, method string) {
+	w.Header().Set("X-Server-Name", "grpc-sample-server")
+	w.Header().Set("X-Method", method)
+	w.Header().Set("X-Protocol", "HTTP")
+}
+
+// writeGRPCError writes err as a JSON ErrorResponse, including its status
+// details, with the HTTP status matching its gRPC code. Errors that do not
+// carry a gRPC status are reported as Unknown.
+func writeGRPCError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	writeJSON(w, HTTPStatusFromCode(st.Code()), errorResponse(st))
+}
+
+// writeError writes a JSON ErrorResponse with the given HTTP status.
+func writeError(w http.ResponseWriter, httpStatus int, code codes.Code, message string) {
+	writeJSON(w, httpStatus, ErrorResponse{Code: code.String(), Message: message})
+}
+
+// routeMethods are the methods checked when building the Allow header of a
+// 405 response. OPTIONS is answered by the CORS middleware for every path.
+var routeMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// handleRouteNotFound answers requests for paths with no route with a JSON
+// 404 naming the path.
+func handleRouteNotFound(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusNotFound, ErrorResponse{
+		Code:    codes.NotFound.String(),
+		Message: "no route for " + r.URL.Path,
+		Path:    r.URL.Path,
+	})
+}
+
+// handleMethodNotAllowed returns a handler answering requests whose path
+// matches a route of router but not with their method: a JSON 405 naming the
+// path, with the methods that would match in the Allow header.
+func handleMethodNotAllowed(router *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range routeMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			var match mux.RouteMatch
+			if router.Match(probe, &match) && match.MatchErr == nil {
+				allowed = append(allowed, method)
+			}
+		}
+
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{
+			Code:    codes.Unimplemented.String(),
+			Message: fmt.Sprintf("method %s not allowed for %s", r.Method, r.URL.Path),
+			Path:    r.URL.Path,
+		})
+	}
+}
+
+// errTrailingJSON reports a request body with more after its JSON object.
+var errTrailingJSON = errors.New("request body must hold a single JSON object")
+
+// decodeJSONBody decodes the JSON object in the body of r into v, strictly:
+// fields v does not declare, values of the wrong type and anything after the
+// object are errors, which writeDecodeError describes.
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errTrailingJSON
+	}
+	return nil
+}
+
+// writeDecodeError reports a failure to decode a JSON request body: 413 when
+// the body exceeded the configured limit, 400 otherwise, saying what was
+// wrong with it, e.g. `Invalid JSON: unknown field "nmae"`.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, codes.ResourceExhausted,
+			fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, codes.InvalidArgument, "Invalid JSON: "+describeDecodeError(err))
+}
+
+// writeMissingField reports a JSON body without a value for the required
+// field with a 400, e.g. `field "name" is required and must not be empty`.
+func writeMissingField(w http.ResponseWriter, field string) {
+	writeError(w, http.StatusBadRequest, codes.InvalidArgument,
+		fmt.Sprintf("field %q is required and must not be empty", field))
+}
+
+// describeDecodeError explains a decodeJSONBody error for a client.
+func describeDecodeError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "request body ends before the JSON object does"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("%s at byte %d", syntaxErr.Error(), syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("field %q must be %s, got %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind()), typeErr.Value)
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("request body must be a JSON object, got %s", typeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields
+		return strings.TrimPrefix(err.Error(), "json: ")
+	}
+	return err.Error()
+}
+
+// jsonTypeName names the JSON type that decodes into a Go value of kind,
+// e.g. "an array" for reflect.Slice.
+func jsonTypeName(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	}
+	return "a number"
+}
+
+// headerBytes returns the size of h as sent over HTTP/1.1: each field's
+// name and value plus the ": " and CRLF around them.
+func headerBytes(h http.Header) int {
+	n := 0
+	for name, values := range h {
+		for _, v := range values {
+			n += len(name) + len(v) + len(": \r\n")
+		}
+	}
+	return n
+}
+
+// LimitRequestHeaders answers requests whose headers add up to more than
+// limit bytes with 431 Request Header Fields Too Large. The http.Server's
+// MaxHeaderBytes already refuses far larger headers before they are read,
+// but allows some slack, and HTTP/2 counts headers differently; this check
+// holds every REST request to the same exact limit, with a JSON error. A
+// non-positive limit disables the check.
+func LimitRequestHeaders(next http.Handler, limit int) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if headerBytes(r.Header) > limit {
+			writeError(w, http.StatusRequestHeaderFieldsTooLarge, codes.ResourceExhausted,
+				fmt.Sprintf("request headers exceed %d bytes", limit))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitRequestBody wraps next so that reading more than limit bytes of a
+// request body fails with *http.MaxBytesError, which the REST handlers turn
+// into 413 Request Entity Too Large. A non-positive limit disables the check.
+func LimitRequestBody(next http.Handler, limit int64) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
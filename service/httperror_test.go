@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grpc-sample/config"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	for code, want := range map[codes.Code]int{
+		codes.OK:                 http.StatusOK,
+		codes.InvalidArgument:    http.StatusBadRequest,
+		codes.NotFound:           http.StatusNotFound,
+		codes.Unauthenticated:    http.StatusUnauthorized,
+		codes.PermissionDenied:   http.StatusForbidden,
+		codes.ResourceExhausted:  http.StatusTooManyRequests,
+		codes.FailedPrecondition: http.StatusPreconditionFailed,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+		codes.DeadlineExceeded:   http.StatusGatewayTimeout,
+		codes.Internal:           http.StatusInternalServerError,
+		codes.Code(99):           http.StatusInternalServerError,
+	} {
+		if got := HTTPStatusFromCode(code); got != want {
+			t.Errorf("HTTPStatusFromCode(%v) = %d, want %d", code, got, want)
+		}
+	}
+}
+
+func TestRESTMapsBackendErrors(t *testing.T) {
+	h := testRouter{hello: NewHelloServer(WithFaultInjection(true))}.handler()
+
+	req := httptest.NewRequest("GET", "/api/hello?name=World", nil)
+	req.Header.Set("Grpc-Metadata-Inject-Error", "invalid_argument")
+	rec := serve(h, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/hello failing with InvalidArgument = %d, want 400", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	body := decodeBody(t, rec)
+	if body["code"] != "InvalidArgument" || body["message"] == "" {
+		t.Errorf("body = %v, want code InvalidArgument and a message", body)
+	}
+}
+
+func TestUnknownRoutesGetJSONErrors(t *testing.T) {
+	h := testRouter{}.handler()
+
+	rec := serve(h, httptest.NewRequest("GET", "/api/nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/nope = %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("404 Content-Type = %q, want JSON", ct)
+	}
+	if body := decodeBody(t, rec); body["code"] != "NotFound" || body["path"] != "/api/nope" {
+		t.Errorf("404 body = %v, want NotFound for /api/nope", body)
+	}
+
+	rec = serve(h, httptest.NewRequest("DELETE", "/api/hello", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /api/hello = %d, want 405", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); !strings.Contains(allow, "GET") || !strings.Contains(allow, "POST") || strings.Contains(allow, "DELETE") {
+		t.Errorf("Allow = %q, want GET and POST", allow)
+	}
+	if body := decodeBody(t, rec); body["path"] != "/api/hello" || !strings.Contains(body["message"].(string), "DELETE") {
+		t.Errorf("405 body = %v, want the method and path named", body)
+	}
+}
+
+func TestRESTErrorIncludesStatusDetails(t *testing.T) {
+	h := testRouter{goodbye: NewGoodbyeServer(WithBlockedNames([]string{"Voldemort"}))}.handler()
+	rec := serve(h, postJSON("/v1/goodbye-with-reason", `{"name": "Voldemort", "reason": "leaving"}`))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("POST /v1/goodbye-with-reason for a blocked name = %d, want 403", rec.Code)
+	}
+
+	body := decodeBody(t, rec)
+	details, _ := body["details"].([]interface{})
+	if len(details) != 1 {
+		t.Fatalf("details = %v, want one ErrorInfo", body["details"])
+	}
+	info, _ := details[0].(map[string]interface{})
+	if info["@type"] != "type.googleapis.com/google.rpc.ErrorInfo" || info["reason"] != nameBlockedReason || info["domain"] != errorInfoDomain {
+		t.Errorf("detail = %v, want an ErrorInfo with reason %s", info, nameBlockedReason)
+	}
+	if metadata, _ := info["metadata"].(map[string]interface{}); metadata["name"] != "Voldemort" {
+		t.Errorf("detail metadata = %v, want the blocked name", info["metadata"])
+	}
+}
+
+func TestErrorResponseDropsUnknownDetails(t *testing.T) {
+	st := status.New(codes.PermissionDenied, "blocked")
+	st, err := st.WithDetails(&errdetails.ErrorInfo{Reason: "R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pb := st.Proto()
+	pb.Details = append(pb.Details, &anypb.Any{TypeUrl: "type.googleapis.com/example.Unlinked", Value: []byte{1}})
+
+	resp := errorResponse(status.FromProto(pb))
+	if resp.Code != "PermissionDenied" || resp.Message != "blocked" {
+		t.Errorf("code, message = %s, %s", resp.Code, resp.Message)
+	}
+	if len(resp.Details) != 1 || !strings.Contains(string(resp.Details[0]), "ErrorInfo") {
+		t.Errorf("details = %s, want only the ErrorInfo", resp.Details)
+	}
+}
+
+func TestOversizedHeadersGet431(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.MaxHTTPHeaderBytes = 4096
+	s := startServer(t, cfg)
+	get := func(headerSize int) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest("GET", "http://"+s.Addr().String()+"/health", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Padding", strings.Repeat("a", headerSize))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /health with a %d byte header: %v", headerSize, err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	if resp := get(100); resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /health with small headers = %d, want 200", resp.StatusCode)
+	}
+
+	// Within the http.Server's slack, so the middleware answers in JSON
+	resp := get(6000)
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("GET /health with 6000 bytes of headers = %d, want 431", resp.StatusCode)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["code"] != "ResourceExhausted" {
+		t.Errorf("431 body = %v, %v, want a ResourceExhausted JSON error", body, err)
+	}
+
+	// Far past the limit, the http.Server refuses the request before reading it
+	if resp := get(64 << 10); resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("GET /health with 64KiB of headers = %d, want 431", resp.StatusCode)
+	}
+}
+
+func TestRESTResponsesCarryServerName(t *testing.T) {
+	h := testRouter{}.handler()
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/hello?name=World", nil),
+		postJSON("/api/hello", `{"name": "World"}`),
+		httptest.NewRequest("GET", "/api/goodbye?name=World", nil),
+		postJSON("/api/goodbye", `{"name": "World"}`),
+	} {
+		// Result holds the headers as sent, so any set after WriteHeader
+		// are missing from it
+		resp := serve(h, req).Result()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s %s = %d, want 200", req.Method, req.URL, resp.StatusCode)
+		}
+		if got := resp.Header.Get("X-Server-Name"); got != "grpc-sample-server" {
+			t.Errorf("%s %s X-Server-Name = %q, want grpc-sample-server", req.Method, req.URL, got)
+		}
+		if got := resp.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s %s Content-Type = %q, want application/json", req.Method, req.URL, got)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Custom", "kept")
+	writeJSON(rec, http.StatusCreated, map[string]string{"message": "hi"})
+	resp := rec.Result()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want 201", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := resp.Header.Get("X-Custom"); got != "kept" {
+		t.Errorf("X-Custom = %q, want the header set before writeJSON", got)
+	}
+	if got := decodeBody(t, rec)["message"]; got != "hi" {
+		t.Errorf("body message = %v, want hi", got)
+	}
+}
+
+func TestRESTRejectsMalformedBodies(t *testing.T) {
+	h := testRouter{}.handler()
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"name": "World", "nmae": "x"}`, `Invalid JSON: unknown field "nmae"`},
+		{`{"name": 5}`, `Invalid JSON: field "name" must be a string, got number`},
+		{`{"name": "a"} {"name": "b"}`, "Invalid JSON: request body must hold a single JSON object"},
+		{``, "Invalid JSON: request body is empty"},
+		{`[1]`, "Invalid JSON: request body must be a JSON object, got array"},
+		{`{"name": "Wor`, "Invalid JSON: request body ends before the JSON object does"},
+		{`{}`, `field "name" is required and must not be empty`},
+		{`{"name": ""}`, `field "name" is required and must not be empty`},
+	}
+	for _, path := range []string{"/api/hello", "/api/goodbye"} {
+		for _, tt := range tests {
+			rec := serve(h, postJSON(path, tt.body))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("POST %s %q = %d, want 400", path, tt.body, rec.Code)
+				continue
+			}
+			body := decodeBody(t, rec)
+			if body["code"] != "InvalidArgument" || body["message"] != tt.want {
+				t.Errorf("POST %s %q body = %v, want InvalidArgument %q", path, tt.body, body, tt.want)
+			}
+		}
+
+		if rec := serve(h, postJSON(path, `{"name": "World"}`)); rec.Code != http.StatusOK {
+			t.Errorf("POST %s with a valid body = %d, want 200", path, rec.Code)
+		}
+
+		long := `{"name": "` + strings.Repeat("x", 101) + `"}`
+		rec := serve(h, postJSON(path, long))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s with a 101-character name = %d, want 400", path, rec.Code)
+			continue
+		}
+		if msg, _ := decodeBody(t, rec)["message"].(string); !strings.Contains(msg, "Name") {
+			t.Errorf("POST %s with a 101-character name message = %q, want the field named", path, msg)
+		}
+	}
+}
+
+func TestRESTGetWithoutNameUsesDefault(t *testing.T) {
+	h := testRouter{}.handler()
+	for path, want := range map[string]string{
+		"/api/hello":   "Hello World",
+		"/api/goodbye": "Friend",
+	} {
+		rec := serve(h, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, rec.Code)
+			continue
+		}
+		if msg, _ := decodeBody(t, rec)["message"].(string); !strings.Contains(msg, want) {
+			t.Errorf("GET %s message = %q, want the default name in %q", path, msg, want)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// IdempotencyKeyMetadataKey is the metadata key a client sets to make a
+// unary call safe to retry.
+const IdempotencyKeyMetadataKey = "idempotency-key"
+
+// idempotencyReplayedKey marks response headers of calls answered from the
+// idempotency cache.
+const idempotencyReplayedKey = "idempotency-replayed"
+
+// IdempotencyCache remembers the replies of unary calls by idempotency key
+// for a fixed TTL. It holds at most size entries, evicting the least
+// recently used one when full. While a call with a key is running, later
+// calls with the same key wait for it rather than running the handler too.
+type IdempotencyCache struct {
+	ttl   time.Duration
+	size  int
+	clock Clock
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	// inflight holds a channel for each key whose first call is running,
+	// closed when it finishes.
+	inflight map[string]chan struct{}
+}
+
+// idempotencyEntry is one cached call: the request it answered, so a key
+// reused for a different request can be rejected, and the reply with the
+// headers and trailers the handler set.
+type idempotencyEntry struct {
+	key     string
+	req     proto.Message
+	reply   proto.Message
+	header  metadata.MD
+	trailer metadata.MD
+	expires time.Time
+}
+
+// NewIdempotencyCache returns a cache holding up to size replies for ttl, as
+// told by clock.
+func NewIdempotencyCache(size int, ttl time.Duration, clock Clock) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:      ttl,
+		size:     size,
+		clock:    clock,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		inflight: map[string]chan struct{}{},
+	}
+}
+
+// claim returns the live entry for key. Without one, it waits for any call
+// already running with key and checks again; once no call is running, it
+// marks key in flight and returns a done func the caller must call with the
+// entry to store, or nil if its call failed. It returns ctx's error if ctx
+// ends while waiting.
+func (c *IdempotencyCache) claim(ctx context.Context, key string) (*idempotencyEntry, func(*idempotencyEntry), error) {
+	for {
+		c.mu.Lock()
+		if entry, ok := c.get(key); ok {
+			c.mu.Unlock()
+			return entry, nil, nil
+		}
+		running, ok := c.inflight[key]
+		if !ok {
+			finished := make(chan struct{})
+			c.inflight[key] = finished
+			c.mu.Unlock()
+			return nil, func(entry *idempotencyEntry) {
+				c.mu.Lock()
+				if entry != nil {
+					c.add(entry)
+				}
+				delete(c.inflight, key)
+				c.mu.Unlock()
+				close(finished)
+			}, nil
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return nil, nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// get returns the live entry for key, marking it recently used. Expired
+// entries are dropped. c.mu must be held.
+func (c *IdempotencyCache) get(key string) (*idempotencyEntry, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*idempotencyEntry)
+	if !clockNow(c.clock).Before(entry.expires) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+// add stores entry, replacing any entry with the same key and evicting the
+// least recently used entries beyond the size limit. c.mu must be held.
+func (c *IdempotencyCache) add(entry *idempotencyEntry) {
+	entry.expires = clockNow(c.clock).Add(c.ttl)
+	if el, ok := c.entries[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*idempotencyEntry).key)
+	}
+}
+
+// recordingTransportStream passes headers and trailers through to the real
+// stream while keeping a copy for the idempotency cache.
+type recordingTransportStream struct {
+	grpc.ServerTransportStream
+	header  metadata.MD
+	trailer metadata.MD
+}
+
+func (s *recordingTransportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return s.ServerTransportStream.SetHeader(md)
+}
+
+func (s *recordingTransportStream) SendHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return s.ServerTransportStream.SendHeader(md)
+}
+
+func (s *recordingTransportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return s.ServerTransportStream.SetTrailer(md)
+}
+
+// idempotencyKey returns the first non-empty idempotency-key in the incoming
+// metadata, or "" if the call did not send one.
+func idempotencyKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get(IdempotencyKeyMetadataKey) {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// IdempotencyInterceptor answers unary calls that repeat an idempotency-key
+// with the cached reply, headers and trailers of the first successful call
+// instead of running the handler again; replays carry an
+// idempotency-replayed header. Keys are scoped to the method and tenant,
+// failed calls are not cached, and reusing a key for a different request
+// fails with InvalidArgument. Calls that arrive while the first call with
+// their key is running wait for its reply, or run the handler themselves if
+// it fails. Streaming calls are not affected.
+func IdempotencyInterceptor(cache *IdempotencyCache) Interceptor {
+	return Interceptor{
+		Name:  "idempotency",
+		Stage: StageIdempotency,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			key := idempotencyKey(ctx)
+			msg, ok := req.(proto.Message)
+			if key == "" || !ok {
+				return handler(ctx, req)
+			}
+			key = info.FullMethod + "\x00" + TenantIDFromContext(ctx) + "\x00" + key
+
+			entry, done, err := cache.claim(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			if entry != nil {
+				if !proto.Equal(entry.req, msg) {
+					return nil, status.Errorf(codes.InvalidArgument,
+						"%s was already used for a different request", IdempotencyKeyMetadataKey)
+				}
+				grpc.SetHeader(ctx, metadata.Join(entry.header, metadata.Pairs(idempotencyReplayedKey, "true")))
+				if entry.trailer != nil {
+					grpc.SetTrailer(ctx, entry.trailer)
+				}
+				return proto.Clone(entry.reply), nil
+			}
+
+			stream := grpc.ServerTransportStreamFromContext(ctx)
+			if stream == nil {
+				done(nil)
+				return handler(ctx, req)
+			}
+			rec := &recordingTransportStream{ServerTransportStream: stream}
+			var stored *idempotencyEntry
+			defer func() { done(stored) }()
+			resp, err := handler(grpc.NewContextWithServerTransportStream(ctx, rec), req)
+			if err != nil {
+				return resp, err
+			}
+			if reply, ok := resp.(proto.Message); ok {
+				stored = &idempotencyEntry{
+					key:     key,
+					req:     proto.Clone(msg),
+					reply:   proto.Clone(reply),
+					header:  rec.header,
+					trailer: rec.trailer,
+				}
+			}
+			return resp, nil
+		},
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// countingHello returns a Greeter client whose server counts the greetings
+// it produces, behind an IdempotencyInterceptor holding size replies.
+func countingHello(t *testing.T, size int) (hello.GreeterClient, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := NewHelloServer(WithGreeter(GreeterFunc(func(_ context.Context, name string) string {
+		calls.Add(1)
+		return "Hello " + name
+	})))
+	registry := NewRegistry()
+	registry.Register(IdempotencyInterceptor(NewIdempotencyCache(size, time.Minute, SystemClock{})))
+	return hello.NewGreeterClient(dialServices(t, srv, nil, registry.ServerOptions()...)), &calls
+}
+
+func TestIdempotencyReplaysRepeatedKey(t *testing.T) {
+	greeter, calls := countingHello(t, 10)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sayHello := func(key, name string) (metadata.MD, error) {
+		var header metadata.MD
+		_, err := greeter.SayHello(metadata.AppendToOutgoingContext(ctx, IdempotencyKeyMetadataKey, key),
+			&hello.HelloRequest{Name: name}, grpc.Header(&header))
+		return header, err
+	}
+
+	first, err := sayHello("k1", "World")
+	if err != nil {
+		t.Fatalf("first SayHello: %v", err)
+	}
+	second, err := sayHello("k1", "World")
+	if err != nil {
+		t.Fatalf("repeated SayHello: %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("greeter ran %d times for one key, want 1", n)
+	}
+	if got, want := second.Get("response-id"), first.Get("response-id"); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("replayed response-id = %v, want the original %v", got, want)
+	}
+	if got := second.Get(idempotencyReplayedKey); len(got) != 1 || got[0] != "true" {
+		t.Errorf("%s header = %v, want true", idempotencyReplayedKey, got)
+	}
+	if got := first.Get(idempotencyReplayedKey); len(got) != 0 {
+		t.Errorf("first call has %s header %v", idempotencyReplayedKey, got)
+	}
+
+	if _, err := sayHello("k1", "Mars"); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("key reused for another name: %v, want InvalidArgument", err)
+	}
+	if _, err := sayHello("k2", "World"); err != nil || calls.Load() != 2 {
+		t.Errorf("new key: err %v, greeter ran %d times, want 2", err, calls.Load())
+	}
+}
+
+func TestIdempotencyCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	greeter, calls := countingHello(t, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sayHello := func(key string) {
+		t.Helper()
+		if _, err := greeter.SayHello(metadata.AppendToOutgoingContext(ctx, IdempotencyKeyMetadataKey, key),
+			&hello.HelloRequest{Name: "World"}); err != nil {
+			t.Fatalf("SayHello with key %s: %v", key, err)
+		}
+	}
+
+	sayHello("a")
+	sayHello("b")
+	sayHello("a") // replayed, so b is now the least recently used
+	sayHello("c") // evicts b
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("greeter ran %d times, want 3", n)
+	}
+	sayHello("a")
+	if n := calls.Load(); n != 3 {
+		t.Errorf("a was evicted: greeter ran %d times, want 3", n)
+	}
+	sayHello("b")
+	if n := calls.Load(); n != 4 {
+		t.Errorf("b was kept: greeter ran %d times, want 4", n)
+	}
+}
+
+// concurrentIdempotentCalls starts n calls of the idempotency interceptor
+// with the same key, all running handler, and returns their replies and
+// errors once handler has been entered and release closed.
+func concurrentIdempotentCalls(t *testing.T, n int, handler grpc.UnaryHandler) ([]interface{}, []error) {
+	t.Helper()
+	unary := IdempotencyInterceptor(NewIdempotencyCache(10, time.Minute, SystemClock{})).Unary
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.hello.Greeter/SayHello"}
+	replies, errs := make([]interface{}, n), make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyMetadataKey, "k"))
+			ctx = grpc.NewContextWithServerTransportStream(ctx, &restTransportStream{method: info.FullMethod})
+			replies[i], errs[i] = unary(ctx, &hello.HelloRequest{Name: "World"}, info, handler)
+		}()
+	}
+	wg.Wait()
+	return replies, errs
+}
+
+func TestIdempotencyRunsConcurrentRetriesOnce(t *testing.T) {
+	var calls atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		<-entered
+		// Give the other calls time to arrive while the first one runs
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	replies, errs := concurrentIdempotentCalls(t, 5, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			close(entered)
+		}
+		<-release
+		return &hello.HelloReply{Message: "Hello World"}, nil
+	})
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times for 5 concurrent calls with one key, want 1", n)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("call %d: %v", i, err)
+			continue
+		}
+		if got := replies[i].(*hello.HelloReply).GetMessage(); got != "Hello World" {
+			t.Errorf("call %d reply = %q, want Hello World", i, got)
+		}
+	}
+}
+
+func TestIdempotencyRetriesAfterFailedCall(t *testing.T) {
+	var calls atomic.Int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		<-entered
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	_, errs := concurrentIdempotentCalls(t, 2, func(ctx context.Context, req interface{}) (interface{}, error) {
+		if calls.Add(1) == 1 {
+			close(entered)
+			<-release
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+		return &hello.HelloReply{Message: "Hello World"}, nil
+	})
+
+	// The waiting call runs the handler itself once the first one fails
+	if n := calls.Load(); n != 2 {
+		t.Errorf("handler ran %d times, want 2", n)
+	}
+	var failed int
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("errors = %v, want only the first call to fail", errs)
+	}
+}
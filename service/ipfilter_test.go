@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestIPFilterAllowed(t *testing.T) {
+	f, err := NewIPFilter([]string{"10.0.0.0/8", "192.168.1.7"}, []string{"10.9.0.0/16"})
+	if err != nil {
+		t.Fatalf("NewIPFilter: %v", err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3":           true,
+		"::ffff:10.1.2.3":    true, // as a dual-stack listener reports it
+		"192.168.1.7":        true,
+		"192.168.1.8":        false,
+		"10.9.1.1":           false, // denied inside an allowed range
+		"172.16.0.1":         false,
+		"2001:db8::1":        false,
+		"fe80::1%eth0":       false,
+		"::ffff:192.168.1.7": true,
+	} {
+		if got := f.Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("Allowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	// With no allow list, only the deny list applies
+	f, _ = NewIPFilter(nil, []string{"203.0.113.0/24"})
+	if !f.Allowed(netip.MustParseAddr("198.51.100.1")) || f.Allowed(netip.MustParseAddr("203.0.113.9")) {
+		t.Error("deny-only filter: want everything but 203.0.113.0/24 allowed")
+	}
+
+	if _, err := NewIPFilter([]string{"10.0.0.0/33"}, nil); err == nil {
+		t.Error("NewIPFilter accepted 10.0.0.0/33")
+	}
+}
+
+func TestIPFilterInterceptorAndMiddleware(t *testing.T) {
+	f, err := NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	if err != nil {
+		t.Fatalf("NewIPFilter: %v", err)
+	}
+	from := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+	unary := IPFilterInterceptor(f).Unary
+	info := &grpc.UnaryServerInfo{FullMethod: "/grpc.hello.Greeter/SayHello"}
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) { return "done", nil }
+
+	if resp, err := unary(from("10.1.2.3"), nil, info, handler); err != nil || resp != "done" {
+		t.Errorf("call from an allowed IP = %v, %v, want done", resp, err)
+	}
+	if _, err := unary(from("172.16.0.1"), nil, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("call from a blocked IP = %v, want PermissionDenied", err)
+	}
+	if _, err := unary(context.Background(), nil, info, handler); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("call without a peer = %v, want PermissionDenied", err)
+	}
+
+	stream := IPFilterInterceptor(f).Stream
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/grpc.hello.Greeter/SayHelloStream", IsServerStream: true}
+	ran := false
+	streamHandler := func(interface{}, grpc.ServerStream) error { ran = true; return nil }
+	if err := stream(nil, &fakeStream{ctx: from("172.16.0.1")}, streamInfo, streamHandler); status.Code(err) != codes.PermissionDenied || ran {
+		t.Errorf("stream from a blocked IP = %v, ran %v, want PermissionDenied before the handler", err, ran)
+	}
+	if err := stream(nil, &fakeStream{ctx: from("10.1.2.3")}, streamInfo, streamHandler); err != nil || !ran {
+		t.Errorf("stream from an allowed IP = %v, ran %v, want the handler run", err, ran)
+	}
+
+	h := RestrictPeerIPs(testRouter{}.handler(), f)
+	get := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/healthz", nil)
+		req.RemoteAddr = remote
+		return serve(h, req)
+	}
+	if rec := get("10.1.2.3:50000"); rec.Code != http.StatusOK {
+		t.Errorf("GET /healthz from an allowed IP = %d, want 200", rec.Code)
+	}
+	rec := get("172.16.0.1:50000")
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("GET /healthz from a blocked IP = %d, want 403", rec.Code)
+	}
+	if got := decodeBody(t, rec)["code"]; got != "PermissionDenied" {
+		t.Errorf("403 body code = %v, want PermissionDenied", got)
+	}
+}
+
+func TestServerDeniesBlockedPeers(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.IPDenyList = []string{"127.0.0.1"}
+	s := startServer(t, cfg)
+	addr := s.Addr().String()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("SayHello from a denied IP = %v, want PermissionDenied", err)
+	}
+
+	resp, err := http.Get("http://" + addr + "/api/hello?name=World")
+	if err != nil {
+		t.Fatalf("GET /api/hello: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET /api/hello from a denied IP = %d, want 403", resp.StatusCode)
+	}
+}
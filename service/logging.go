@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// redactedValue replaces the values of sensitive metadata keys in logs.
+const redactedValue = "[REDACTED]"
+
+// DefaultRedactedMetadataKeys are the metadata keys whose values are never
+// logged unless SetRedactedMetadataKeys replaces the set.
+var DefaultRedactedMetadataKeys = []string{"authorization", "x-api-key", "cookie", "proxy-authorization"}
+
+var (
+	redactedKeysMu sync.RWMutex
+	redactedKeys   = keySet(DefaultRedactedMetadataKeys)
+)
+
+func keySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[strings.ToLower(key)] = true
+	}
+	return set
+}
+
+// SetRedactedMetadataKeys replaces the set of metadata keys whose values are
+// redacted in logs. Keys are matched case-insensitively.
+func SetRedactedMetadataKeys(keys []string) {
+	redactedKeysMu.Lock()
+	defer redactedKeysMu.Unlock()
+	redactedKeys = keySet(keys)
+}
+
+// RedactMetadataValues returns values unchanged, or a single "[REDACTED]"
+// placeholder when key is sensitive. Handlers pass incoming metadata through
+// it before logging.
+func RedactMetadataValues(key string, values []string) []string {
+	redactedKeysMu.RLock()
+	sensitive := redactedKeys[strings.ToLower(key)]
+	redactedKeysMu.RUnlock()
+	if sensitive {
+		return []string{redactedValue}
+	}
+	return values
+}
+
+// metadataLogging gates the handlers' dump of incoming metadata; see
+// SetMetadataLogging.
+var metadataLogging atomic.Bool
+
+// SetMetadataLogging turns the per-call dump of incoming metadata in every
+// handler on or off. It is off by default, as the dump is noisy and costly
+// under load; when on, each key is logged at debug level with sensitive
+// values redacted.
+func SetMetadataLogging(enabled bool) {
+	metadataLogging.Store(enabled)
+}
+
+// logIncomingMetadata logs each key of ctx's incoming metadata as msg through
+// logger, with args and redacted values, if metadata logging is enabled.
+func logIncomingMetadata(ctx context.Context, logger *slog.Logger, msg string, args ...any) {
+	if !metadataLogging.Load() {
+		return
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		logger.DebugContext(ctx, msg, append(args, "key", key, "values", RedactMetadataValues(key, values))...)
+	}
+}
+
+// NewLogger returns a JSON logger writing to w that drops records below level.
+// Records logged with a context carrying a request ID get a request_id field.
+// The handlers log through slog's default logger, so callers typically pass
+// the result to slog.SetDefault.
+func NewLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(&contextHandler{Handler: slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})})
+}
+
+// contextHandler adds request-scoped values from the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler.
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// UnaryLoggingInterceptor logs the outcome and duration of every unary call:
+// successful calls at debug level, failed ones at warn.
+func UnaryLoggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+// StreamLoggingInterceptor is the streaming counterpart of
+// UnaryLoggingInterceptor.
+func StreamLoggingInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logCall(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelDebug
+	if code != codes.OK {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "gRPC: Finished call", "grpc_method", method, "code", code.String(),
+		"duration_ms", time.Since(start).Milliseconds())
+}
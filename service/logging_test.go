@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// enableMetadataLogging turns on the incoming metadata dump for the rest of
+// the test.
+func enableMetadataLogging(t *testing.T) {
+	SetMetadataLogging(true)
+	t.Cleanup(func() { SetMetadataLogging(false) })
+}
+
+func TestMetadataLogRedactsSensitiveKeys(t *testing.T) {
+	logs := captureLogs(t)
+	enableMetadataLogging(t)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"authorization", "Bearer secret",
+		"tenant-id", "acme",
+	))
+
+	if _, err := NewHelloServer().SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	values := map[string]interface{}{}
+	for _, record := range logRecords(t, logs, "gRPC: Incoming metadata") {
+		values[record["key"].(string)] = record["values"]
+	}
+	if got, _ := values["authorization"].([]interface{}); len(got) != 1 || got[0] != redactedValue {
+		t.Errorf("authorization logged as %v, want [%s]", values["authorization"], redactedValue)
+	}
+	if got, _ := values["tenant-id"].([]interface{}); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("tenant-id logged as %v, want [acme]", values["tenant-id"])
+	}
+}
+
+func TestRedactMetadataValuesUsesConfiguredKeys(t *testing.T) {
+	SetRedactedMetadataKeys([]string{"X-Session"})
+	t.Cleanup(func() { SetRedactedMetadataKeys(DefaultRedactedMetadataKeys) })
+
+	if got := RedactMetadataValues("x-session", []string{"abc"}); len(got) != 1 || got[0] != redactedValue {
+		t.Errorf("x-session = %q, want it redacted", got)
+	}
+	// The configured set replaces the defaults
+	if got := RedactMetadataValues("authorization", []string{"Bearer secret"}); got[0] != "Bearer secret" {
+		t.Errorf("authorization = %q, want it left alone once the set is replaced", got)
+	}
+}
+
+func TestMetadataLoggingOffByDefault(t *testing.T) {
+	logs := captureLogs(t)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant-id", "acme"))
+	sayHello := func() {
+		t.Helper()
+		if _, err := NewHelloServer().SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+	}
+
+	sayHello()
+	if records := logRecords(t, logs, "gRPC: Incoming metadata"); len(records) != 0 {
+		t.Errorf("metadata logged with the flag off: %v", records)
+	}
+	if !strings.Contains(logs.String(), "gRPC: Completed SayHello request") {
+		t.Fatal("SayHello logged nothing; is logging captured?")
+	}
+
+	enableMetadataLogging(t)
+	sayHello()
+	if records := logRecords(t, logs, "gRPC: Incoming metadata"); len(records) == 0 {
+		t.Error("no metadata logged with the flag on")
+	}
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// MessageCounts totals the finished streams of a method and the messages
+// they sent to and received from clients.
+type MessageCounts struct {
+	Streams  int64
+	Sent     int64
+	Received int64
+}
+
+// Histogram counts observations in buckets with fixed upper bounds.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing
+	// order.
+	Bounds []float64
+	// Counts holds the observations of each bucket, not cumulative, with a
+	// last bucket for values above every bound.
+	Counts []int64
+	Sum    float64
+	Count  int64
+}
+
+func newHistogram(bounds []float64) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+func (h *Histogram) observe(v float64) {
+	h.Counts[sort.SearchFloat64s(h.Bounds, v)]++
+	h.Sum += v
+	h.Count++
+}
+
+// clone returns a copy of h that does not share its counts.
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]int64(nil), h.Counts...)
+	return h
+}
+
+// Bucket bounds of the stream histograms. Streams last from milliseconds,
+// for a quick bidirectional exchange, to minutes for SayGoodbyeUntilCancelled.
+var (
+	streamDurationBounds = []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+	streamMessageBounds  = []float64{0, 1, 2, 5, 10, 25, 50, 100, 500, 1000, 10000}
+)
+
+// streamStats is what StreamMetrics records for one method.
+type streamStats struct {
+	counts MessageCounts
+	// duration is in seconds; messages counts those sent and received.
+	duration Histogram
+	messages Histogram
+}
+
+// StreamMetrics accumulates MessageCounts, and histograms of stream duration
+// and messages per stream, per streaming method.
+type StreamMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*streamStats
+}
+
+// NewStreamMetrics returns an empty StreamMetrics.
+func NewStreamMetrics() *StreamMetrics {
+	return &StreamMetrics{stats: map[string]*streamStats{}}
+}
+
+// record adds one finished stream of fullMethod.
+func (m *StreamMetrics) record(fullMethod string, sent, received int64, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.stats[fullMethod]
+	if st == nil {
+		st = &streamStats{duration: newHistogram(streamDurationBounds), messages: newHistogram(streamMessageBounds)}
+		m.stats[fullMethod] = st
+	}
+	st.counts.Streams++
+	st.counts.Sent += sent
+	st.counts.Received += received
+	st.duration.observe(duration.Seconds())
+	st.messages.observe(float64(sent + received))
+}
+
+// Counts returns the totals recorded for fullMethod, e.g.
+// "/grpc.goodbye.Farewell/SayGoodbyeBidirectional".
+func (m *StreamMetrics) Counts(fullMethod string) MessageCounts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st := m.stats[fullMethod]; st != nil {
+		return st.counts
+	}
+	return MessageCounts{}
+}
+
+// Durations returns the histogram of how long the streams of fullMethod
+// lasted, in seconds.
+func (m *StreamMetrics) Durations(fullMethod string) Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st := m.stats[fullMethod]; st != nil {
+		return st.duration.clone()
+	}
+	return newHistogram(streamDurationBounds)
+}
+
+// Messages returns the histogram of how many messages the streams of
+// fullMethod sent and received in all.
+func (m *StreamMetrics) Messages(fullMethod string) Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st := m.stats[fullMethod]; st != nil {
+		return st.messages.clone()
+	}
+	return newHistogram(streamMessageBounds)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format, labelled by grpc_method and sorted by method.
+func (m *StreamMetrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	methods := make([]string, 0, len(m.stats))
+	stats := make(map[string]streamStats, len(m.stats))
+	for method, st := range m.stats {
+		methods = append(methods, method)
+		stats[method] = streamStats{counts: st.counts, duration: st.duration.clone(), messages: st.messages.clone()}
+	}
+	m.mu.Unlock()
+	sort.Strings(methods)
+
+	pw := &promWriter{w: w}
+	counters := []struct {
+		name, help string
+		value      func(MessageCounts) int64
+	}{
+		{"grpc_server_streams_total", "Streaming calls finished.", func(c MessageCounts) int64 { return c.Streams }},
+		{"grpc_server_stream_messages_sent_total", "Messages sent to clients by streaming calls.", func(c MessageCounts) int64 { return c.Sent }},
+		{"grpc_server_stream_messages_received_total", "Messages received from clients by streaming calls.", func(c MessageCounts) int64 { return c.Received }},
+	}
+	for _, c := range counters {
+		pw.printf("# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, method := range methods {
+			pw.printf("%s{grpc_method=%q} %d\n", c.name, method, c.value(stats[method].counts))
+		}
+	}
+	histograms := []struct {
+		name, help string
+		value      func(streamStats) Histogram
+	}{
+		{"grpc_server_stream_duration_seconds", "How long streaming calls lasted.", func(st streamStats) Histogram { return st.duration }},
+		{"grpc_server_stream_messages", "Messages sent and received per streaming call.", func(st streamStats) Histogram { return st.messages }},
+	}
+	for _, h := range histograms {
+		pw.printf("# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for _, method := range methods {
+			pw.histogram(h.name, method, h.value(stats[method]))
+		}
+	}
+	return pw.err
+}
+
+// promWriter writes Prometheus text lines, keeping the first error.
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+// histogram writes the cumulative buckets, sum and count of h.
+func (p *promWriter) histogram(name, method string, h Histogram) {
+	var cumulative int64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		p.printf("%s_bucket{grpc_method=%q,le=%q} %d\n", name, method, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+	}
+	p.printf("%s_bucket{grpc_method=%q,le=\"+Inf\"} %d\n", name, method, h.Count)
+	p.printf("%s_sum{grpc_method=%q} %s\n", name, method, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+	p.printf("%s_count{grpc_method=%q} %d\n", name, method, h.Count)
+}
+
+// handleMetrics serves GET /metrics, the stream metrics in the Prometheus
+// text exposition format.
+func handleMetrics(metrics *StreamMetrics) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := metrics.WritePrometheus(w); err != nil {
+			slog.WarnContext(r.Context(), "HTTP: Could not write metrics", "error", err)
+		}
+	}
+}
+
+// countingServerStream counts the messages that pass through a stream.
+type countingServerStream struct {
+	grpc.ServerStream
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func (s *countingServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent.Add(1)
+	}
+	return err
+}
+
+func (s *countingServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received.Add(1)
+	}
+	return err
+}
+
+// StreamMessageCountInterceptor counts the messages each streaming call
+// sends and receives, so handlers need not track their own counts, and times
+// the call. When the stream ends the totals are logged at debug level and
+// added to metrics along with the duration.
+// Only successful sends and receives are counted; the io.EOF that ends a
+// client stream is not a message. Unary calls are not affected.
+func StreamMessageCountInterceptor(metrics *StreamMetrics) Interceptor {
+	return Interceptor{
+		Name:  "message-count",
+		Stage: StageMetrics,
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			start := time.Now()
+			counting := &countingServerStream{ServerStream: ss}
+			err := handler(srv, counting)
+			duration := time.Since(start)
+			sent, received := counting.sent.Load(), counting.received.Load()
+			metrics.record(info.FullMethod, sent, received, duration)
+			slog.DebugContext(ss.Context(), "gRPC: Stream messages", "grpc_method", info.FullMethod,
+				"messages_sent", sent, "messages_received", received, "duration_ms", duration.Milliseconds())
+			return err
+		},
+	}
+}
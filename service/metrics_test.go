@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// waitForStreams waits until metrics has recorded n streams of fullMethod;
+// the interceptor records a stream just after the client sees it end.
+func waitForStreams(t *testing.T, metrics *StreamMetrics, fullMethod string, n int64) MessageCounts {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		counts := metrics.Counts(fullMethod)
+		if counts.Streams >= n {
+			return counts
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d streams of %s recorded, want %d", counts.Streams, fullMethod, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStreamMessageCountInterceptorCountsBidiMessages(t *testing.T) {
+	metrics := NewStreamMetrics()
+	registry := NewRegistry()
+	registry.Register(StreamMessageCountInterceptor(metrics))
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer(), registry.ServerOptions()...))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const n = 5
+	exchangeGoodbyes(t, ctx, farewell, n)
+
+	counts := waitForStreams(t, metrics, "/grpc.goodbye.Farewell/SayGoodbyeBidirectional", 1)
+	if counts != (MessageCounts{Streams: 1, Sent: n, Received: n}) {
+		t.Errorf("counts = %+v, want 1 stream with %d sent and %d received", counts, n, n)
+	}
+	if other := metrics.Counts("/grpc.goodbye.Farewell/SayGoodbyeStream"); other != (MessageCounts{}) {
+		t.Errorf("counts for a method never called = %+v, want zero", other)
+	}
+}
+
+func TestStreamMetricsHistograms(t *testing.T) {
+	metrics := NewStreamMetrics()
+	registry := NewRegistry()
+	registry.Register(StreamMessageCountInterceptor(metrics))
+	farewell := goodbye.NewFarewellClient(dialServices(t, nil, NewGoodbyeServer(), registry.ServerOptions()...))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const method = "/grpc.goodbye.Farewell/SayGoodbyeBidirectional"
+	// 5 farewells paced 20ms apart last at least 100ms
+	elapsed := exchangeGoodbyes(t, metadata.AppendToOutgoingContext(ctx, "pace-ms", "20"), farewell, 5)
+	waitForStreams(t, metrics, method, 1)
+
+	durations := metrics.Durations(method)
+	if durations.Count != 1 {
+		t.Fatalf("duration histogram has %d samples, want 1", durations.Count)
+	}
+	if durations.Sum < 0.1 || durations.Sum > elapsed.Seconds() {
+		t.Errorf("stream duration = %gs, want between 0.1s and the client's %gs", durations.Sum, elapsed.Seconds())
+	}
+	// 0.1-0.5s falls in the bucket with upper bound 0.5
+	if got := durations.Counts[sort.SearchFloat64s(durations.Bounds, 0.5)]; got != 1 {
+		t.Errorf("0.5s bucket holds %d streams, want 1: %v", got, durations.Counts)
+	}
+	messages := metrics.Messages(method)
+	if messages.Count != 1 || messages.Sum != 10 {
+		t.Errorf("messages histogram count, sum = %d, %g, want 1 stream of 10 messages", messages.Count, messages.Sum)
+	}
+
+	rec := serve(testRouter{metrics: metrics}.handler(), httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE grpc_server_stream_duration_seconds histogram\n",
+		`grpc_server_stream_duration_seconds_bucket{grpc_method="` + method + `",le="0.5"} 1` + "\n",
+		`grpc_server_stream_duration_seconds_count{grpc_method="` + method + `"} 1` + "\n",
+		`grpc_server_stream_messages_bucket{grpc_method="` + method + `",le="10"} 1` + "\n",
+		`grpc_server_stream_messages_sum{grpc_method="` + method + `"} 10` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics is missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"google.golang.org/grpc"
+)
+
+// Stage determines where an interceptor runs in the chain. Lower stages wrap
+// higher ones, so StageRecovery is outermost and sees panics from everything
+// below it, and StageIdempotency runs last, right before the handler, so only
+// valid, authorized calls are cached.
+type Stage int
+
+// Interceptor stages, outermost first.
+const (
+	StageRecovery Stage = iota
+	StageRequestID
+	StageMetrics
+	StageLogging
+	StageTimeout
+	StageAuth
+	StageValidation
+	StageIdempotency
+)
+
+// Interceptor is a named unary/stream interceptor pair registered at a stage.
+// Either function may be nil if the middleware only applies to one kind of
+// RPC.
+type Interceptor struct {
+	Name   string
+	Stage  Stage
+	Unary  grpc.UnaryServerInterceptor
+	Stream grpc.StreamServerInterceptor
+}
+
+// Registry assembles interceptors into a deterministic chain: ordered by
+// stage, then by registration order within a stage. Interceptors can be
+// disabled by name, typically from configuration.
+type Registry struct {
+	interceptors []Interceptor
+	disabled     map[string]bool
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{disabled: map[string]bool{}}
+}
+
+// DefaultRegistry returns a registry holding the production interceptors.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register(Interceptor{Name: "recovery", Stage: StageRecovery, Unary: UnaryRecoveryInterceptor, Stream: StreamRecoveryInterceptor})
+	r.Register(Interceptor{Name: "request-id", Stage: StageRequestID, Unary: UnaryRequestIDInterceptor, Stream: StreamRequestIDInterceptor})
+	r.Register(Interceptor{Name: "caller", Stage: StageRequestID, Unary: UnaryCallerInterceptor, Stream: StreamCallerInterceptor})
+	r.Register(Interceptor{Name: "logging", Stage: StageLogging, Unary: UnaryLoggingInterceptor, Stream: StreamLoggingInterceptor})
+	r.Register(Interceptor{Name: "validation", Stage: StageValidation, Unary: UnaryValidationInterceptor, Stream: StreamValidationInterceptor})
+	return r
+}
+
+// Register adds ic to the registry. Registering a name twice replaces the
+// earlier entry.
+func (r *Registry) Register(ic Interceptor) {
+	for i, existing := range r.interceptors {
+		if existing.Name == ic.Name {
+			r.interceptors[i] = ic
+			return
+		}
+	}
+	r.interceptors = append(r.interceptors, ic)
+}
+
+// Disable excludes the named interceptors from the chain. It returns an error
+// naming the first unknown interceptor so typos in configuration are caught.
+func (r *Registry) Disable(names ...string) error {
+	for _, name := range names {
+		if !r.has(name) {
+			return fmt.Errorf("unknown interceptor %q", name)
+		}
+		r.disabled[name] = true
+	}
+	return nil
+}
+
+func (r *Registry) has(name string) bool {
+	for _, ic := range r.interceptors {
+		if ic.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Chain returns the enabled interceptors in execution order, outermost first.
+func (r *Registry) Chain() []Interceptor {
+	var chain []Interceptor
+	for _, ic := range r.interceptors {
+		if !r.disabled[ic.Name] {
+			chain = append(chain, ic)
+		}
+	}
+	sort.SliceStable(chain, func(i, j int) bool {
+		return chain[i].Stage < chain[j].Stage
+	})
+	return chain
+}
+
+// ServerOptions returns the unary and stream interceptor chains as gRPC
+// server options.
+func (r *Registry) ServerOptions() []grpc.ServerOption {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	for _, ic := range r.Chain() {
+		if ic.Unary != nil {
+			unary = append(unary, ic.Unary)
+		}
+		if ic.Stream != nil {
+			stream = append(stream, ic.Stream)
+		}
+	}
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
+
+// unaryChain combines the enabled unary interceptors into one, for callers
+// that invoke handlers directly rather than through a grpc.Server. It returns
+// nil when r is nil or has no unary interceptors.
+func (r *Registry) unaryChain() grpc.UnaryServerInterceptor {
+	return r.unaryChainWhere(func(Interceptor) bool { return true })
+}
+
+// authChain is like unaryChain with only the auth-stage interceptors, for
+// REST routes that check the caller but do not call a gRPC method.
+func (r *Registry) authChain() grpc.UnaryServerInterceptor {
+	return r.unaryChainWhere(func(ic Interceptor) bool { return ic.Stage == StageAuth })
+}
+
+// unaryChainWhere combines the enabled unary interceptors that keep selects.
+func (r *Registry) unaryChainWhere(keep func(Interceptor) bool) grpc.UnaryServerInterceptor {
+	if r == nil {
+		return nil
+	}
+	var unary []grpc.UnaryServerInterceptor
+	for _, ic := range r.Chain() {
+		if ic.Unary != nil && keep(ic) {
+			unary = append(unary, ic.Unary)
+		}
+	}
+	if len(unary) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(unary) - 1; i >= 0; i-- {
+			ic, inner := unary[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return ic(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// streamChain is the streaming counterpart of unaryChain.
+func (r *Registry) streamChain() grpc.StreamServerInterceptor {
+	if r == nil {
+		return nil
+	}
+	var stream []grpc.StreamServerInterceptor
+	for _, ic := range r.Chain() {
+		if ic.Stream != nil {
+			stream = append(stream, ic.Stream)
+		}
+	}
+	if len(stream) == 0 {
+		return nil
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		next := handler
+		for i := len(stream) - 1; i >= 0; i-- {
+			ic, inner := stream[i], next
+			next = func(srv interface{}, ss grpc.ServerStream) error {
+				return ic(srv, ss, info, inner)
+			}
+		}
+		return next(srv, ss)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+)
+
+// spyLog records the order in which spy interceptors and the handler run.
+type spyLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *spyLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+// spy returns an interceptor named name at stage that records when it is
+// entered and left.
+func (l *spyLog) spy(name string, stage Stage) Interceptor {
+	return Interceptor{Name: name, Stage: stage, Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		l.add(name + " in")
+		defer l.add(name + " out")
+		return handler(ctx, req)
+	}}
+}
+
+func TestRegistryRunsInterceptorsInStageOrder(t *testing.T) {
+	var log spyLog
+	registry := NewRegistry()
+	// Registered out of order; the stages decide
+	registry.Register(log.spy("auth", StageAuth))
+	registry.Register(log.spy("logging", StageLogging))
+	registry.Register(log.spy("recovery", StageRecovery))
+	registry.Register(log.spy("metrics", StageMetrics))
+	registry.Register(log.spy("logging-2", StageLogging))
+
+	greeter := GreeterFunc(func(ctx context.Context, name string) string {
+		log.add("handler")
+		return "Hello " + name
+	})
+	client := hello.NewGreeterClient(dialServices(t, NewHelloServer(WithGreeter(greeter)), nil, registry.ServerOptions()...))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := client.SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	want := []string{
+		"recovery in", "metrics in", "logging in", "logging-2 in", "auth in",
+		"handler",
+		"auth out", "logging-2 out", "logging out", "metrics out", "recovery out",
+	}
+	if !reflect.DeepEqual(log.calls, want) {
+		t.Errorf("calls = %q\nwant %q", log.calls, want)
+	}
+}
+
+func TestRegistryDisable(t *testing.T) {
+	var log spyLog
+	registry := NewRegistry()
+	registry.Register(log.spy("logging", StageLogging))
+	registry.Register(log.spy("auth", StageAuth))
+
+	if err := registry.Disable("logging"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if chain := registry.Chain(); len(chain) != 1 || chain[0].Name != "auth" {
+		t.Errorf("chain after disabling logging = %v, want only auth", chain)
+	}
+	if err := registry.Disable("loging"); err == nil {
+		t.Error("Disable accepted an unknown interceptor name")
+	}
+}
@@ -0,0 +1,420 @@
+package service
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// operationDoc describes one REST operation in the OpenAPI document.
+type operationDoc struct {
+	summary     string
+	parameters  []interface{}
+	requestBody interface{}
+	response    interface{}
+}
+
+// langQueryParam documents the optional ?lang= parameter of /api/hello.
+var langQueryParam = map[string]interface{}{
+	"name":        "lang",
+	"in":          "query",
+	"required":    false,
+	"description": "Language tag such as fr or es-MX; unsupported languages fall back to English",
+	"schema":      map[string]interface{}{"type": "string"},
+}
+
+// nameQueryParam documents the optional ?name= parameter of the GET routes.
+func nameQueryParam(description string) []interface{} {
+	return []interface{}{
+		map[string]interface{}{
+			"name":        "name",
+			"in":          "query",
+			"required":    false,
+			"description": description,
+			"schema":      map[string]interface{}{"type": "string"},
+		},
+	}
+}
+
+// jsonBody returns a request body or response referring to a schema in
+// components.schemas.
+func jsonBody(description, schema string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{"$ref": "#/components/schemas/" + schema},
+			},
+		},
+	}
+}
+
+// operationDocs documents the REST operations, keyed by path and then by
+// lower-case HTTP method. Routes registered on the router but missing here are
+// still listed in the spec with a generic description.
+var operationDocs = map[string]map[string]operationDoc{
+	"/api/hello": {
+		"get": {
+			summary:    "Say hello to someone",
+			parameters: append(nameQueryParam("Name of the person to greet (defaults to World)"), langQueryParam),
+			response:   jsonBody("Greeting", "HelloResponse"),
+		},
+		"head": {
+			summary:    "Headers of the GET response, without the body",
+			parameters: append(nameQueryParam("Name of the person to greet (defaults to World)"), langQueryParam),
+		},
+		"post": {
+			summary:     "Say hello to someone",
+			requestBody: jsonBody("Name of the person to greet", "HelloRequest"),
+			response:    jsonBody("Greeting", "HelloResponse"),
+		},
+	},
+	"/api/hello/batch": {
+		"post": {
+			summary:     "Say hello to several people; failures are reported per name",
+			requestBody: jsonBody("Names to greet (at most 100)", "BatchHelloRequest"),
+			response:    jsonBody("Per-name results in request order", "BatchHelloResponse"),
+		},
+	},
+	"/ws/hello": {
+		"get": {
+			summary: "Upgrade to a WebSocket bridged to SayHelloBidirectional",
+			parameters: []interface{}{
+				map[string]interface{}{
+					"name":        "transform",
+					"in":          "query",
+					"required":    false,
+					"description": "upper or reverse, applied to names in replies; every query parameter except authorization and x-api-key is passed to the method as metadata",
+					"schema":      map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	},
+	"/api/goodbye": {
+		"get": {
+			summary:    "Say goodbye to someone",
+			parameters: nameQueryParam("Name of the person to bid farewell (defaults to Friend)"),
+			response:   jsonBody("Farewell", "GoodbyeResponse"),
+		},
+		"head": {
+			summary:    "Headers of the GET response, without the body",
+			parameters: nameQueryParam("Name of the person to bid farewell (defaults to Friend)"),
+		},
+		"post": {
+			summary:     "Say goodbye to someone",
+			requestBody: jsonBody("Name of the person to bid farewell", "GoodbyeRequest"),
+			response:    jsonBody("Farewell", "GoodbyeResponse"),
+		},
+	},
+	"/api/goodbye/stream": {
+		"get": {
+			summary:    "Stream farewells as server-sent events",
+			parameters: nameQueryParam("Name of the person to bid farewell (defaults to Friend)"),
+			response: map[string]interface{}{
+				"description": "message events carrying a GoodbyeResponse, then a done event with the stream trailers",
+				"content": map[string]interface{}{
+					"text/event-stream": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	},
+	"/api/history": {
+		"get": {
+			summary: "List the most recent greetings and farewells, newest first",
+			parameters: []interface{}{
+				map[string]interface{}{
+					"name":        "limit",
+					"in":          "query",
+					"required":    false,
+					"description": "Number of records to return (defaults to 50)",
+					"schema":      map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxHistoryLimit},
+				},
+			},
+			response: jsonBody("Greetings and farewells", "HistoryResponse"),
+		},
+	},
+	"/health": {
+		"get": {summary: "Readiness check with server details"},
+	},
+	"/healthz": {
+		"get": {summary: "Liveness check"},
+	},
+	"/readyz": {
+		"get": {summary: "Readiness check; 503 while starting up or draining"},
+	},
+	"/admin/drain": {
+		"post":   {summary: "Mark the server NOT_SERVING ahead of shutdown"},
+		"delete": {summary: "Undo a drain, unless the server is stopping"},
+	},
+	"/admin/config": {
+		"get": {summary: "Show the effective configuration with secrets redacted"},
+	},
+	"/api/doc": {
+		"get": {summary: "Legacy API documentation"},
+	},
+	"/api/descriptors": {
+		"get": {
+			summary: "FileDescriptorSet of the hello and goodbye protos",
+			parameters: []interface{}{
+				map[string]interface{}{
+					"name":        "format",
+					"in":          "query",
+					"required":    false,
+					"description": "binary (default) or base64",
+					"schema":      map[string]interface{}{"type": "string", "enum": []string{"binary", "base64"}},
+				},
+			},
+			response: map[string]interface{}{
+				"description": "Serialized google.protobuf.FileDescriptorSet",
+				"content": map[string]interface{}{
+					"application/x-protobuf": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string", "format": "binary"},
+					},
+					"text/plain": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string", "format": "byte"},
+					},
+				},
+			},
+		},
+	},
+	"/metrics": {
+		"get": {
+			summary: "Stream metrics in the Prometheus text format",
+			response: map[string]interface{}{
+				"description": "Prometheus text exposition format 0.0.4",
+				"content": map[string]interface{}{
+					"text/plain": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		},
+	},
+	"/openapi.json": {
+		"get": {summary: "This OpenAPI document"},
+	},
+	"/docs": {
+		"get": {summary: "Swagger UI"},
+	},
+	"/": {
+		"get": {summary: "Welcome message"},
+	},
+}
+
+// openAPISchemas holds the JSON schemas of the REST request and response
+// bodies.
+var openAPISchemas = map[string]interface{}{
+	"HelloRequest": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name":     map[string]interface{}{"type": "string", "minLength": 1, "description": "Name of the person to greet"},
+			"language": map[string]interface{}{"type": "string", "description": "Language tag such as fr or es-MX"},
+		},
+	},
+	"HelloResponse":   messageSchema("Greeting message"),
+	"GoodbyeRequest":  nameSchema("Name of the person to bid farewell"),
+	"GoodbyeResponse": messageSchema("Farewell message"),
+	"BatchHelloRequest": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"names"},
+		"properties": map[string]interface{}{
+			"names": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "maxItems": maxBatchSize},
+		},
+	},
+	"BatchHelloResponse": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"results"},
+		"properties": map[string]interface{}{
+			"results": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"name"},
+					"properties": map[string]interface{}{
+						"name":    map[string]interface{}{"type": "string"},
+						"message": map[string]interface{}{"type": "string", "description": "Greeting, set on success"},
+						"error":   map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"},
+					},
+				},
+			},
+		},
+	},
+	"HistoryResponse": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"records"},
+		"properties": map[string]interface{}{
+			"records": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []string{"method", "name", "message", "time"},
+					"properties": map[string]interface{}{
+						"method":  map[string]interface{}{"type": "string", "description": "RPC that produced the message, e.g. SayHello"},
+						"name":    map[string]interface{}{"type": "string"},
+						"message": map[string]interface{}{"type": "string"},
+						"time":    map[string]interface{}{"type": "string", "format": "date-time"},
+					},
+				},
+			},
+		},
+	},
+	"ErrorResponse": map[string]interface{}{
+		"type":     "object",
+		"required": []string{"code", "message"},
+		"properties": map[string]interface{}{
+			"code":    map[string]interface{}{"type": "string", "description": "gRPC status code name, e.g. InvalidArgument"},
+			"message": map[string]interface{}{"type": "string", "description": "Error message"},
+			"path":    map[string]interface{}{"type": "string", "description": "Requested path, set when no route matched"},
+			"details": map[string]interface{}{
+				"type":        "array",
+				"description": "gRPC status details, e.g. google.rpc.ErrorInfo, each with an @type field naming its type",
+				"items":       map[string]interface{}{"type": "object"},
+			},
+		},
+	},
+}
+
+func nameSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name"},
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string", "minLength": 1, "description": description},
+		},
+	}
+}
+
+func messageSchema(description string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []string{"message"},
+		"properties": map[string]interface{}{
+			"message": map[string]interface{}{"type": "string", "description": description},
+		},
+	}
+}
+
+// buildOpenAPISpec returns an OpenAPI 3.0 document, at the given server
+// version, for the routes registered on router. Paths and methods come from the router itself so the document
+// cannot drift from SetupHTTPRouter. OPTIONS preflights are answered by the
+// CORS middleware and are not listed.
+func buildOpenAPISpec(router *mux.Router, version string) (map[string]interface{}, error) {
+	paths := map[string]interface{}{}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		sort.Strings(methods)
+
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+		}
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			item[method] = openAPIOperation(operationDocs[path][method])
+		}
+		if len(item) > 0 {
+			paths[path] = item
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "gRPC Sample Server API",
+			"version":     version,
+			"description": "REST API served alongside the Greeter and Farewell gRPC services on the same port",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": openAPISchemas,
+		},
+	}, nil
+}
+
+// openAPIOperation converts doc to an OpenAPI operation object.
+func openAPIOperation(doc operationDoc) map[string]interface{} {
+	summary := doc.summary
+	if summary == "" {
+		summary = "Undocumented route"
+	}
+	response := doc.response
+	if response == nil {
+		response = map[string]interface{}{"description": "OK"}
+	}
+
+	op := map[string]interface{}{
+		"summary": summary,
+		"responses": map[string]interface{}{
+			"200": response,
+		},
+	}
+	if doc.parameters != nil {
+		op["parameters"] = doc.parameters
+	}
+	if doc.requestBody != nil {
+		op["requestBody"] = doc.requestBody
+		op["responses"].(map[string]interface{})["400"] = jsonBody("Invalid JSON or argument", "ErrorResponse")
+		op["responses"].(map[string]interface{})["415"] = jsonBody("Content-Type is not application/json", "ErrorResponse")
+	}
+	if doc.response != nil {
+		op["responses"].(map[string]interface{})["default"] = jsonBody("gRPC error mapped to an HTTP status", "ErrorResponse")
+	}
+	return op
+}
+
+// handleOpenAPISpec serves the OpenAPI document for router.
+func handleOpenAPISpec(router *mux.Router, version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec, err := buildOpenAPISpec(router, version)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, spec)
+	}
+}
+
+// swaggerUIPage renders Swagger UI against /openapi.json.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>gRPC Sample Server API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.onload = function () {
+      SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// handleSwaggerUI serves the Swagger UI page.
+func handleSwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
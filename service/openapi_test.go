@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// refs returns every "$ref" in the decoded JSON value v.
+func refs(v interface{}) []string {
+	var found []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				found = append(found, ref)
+			}
+			found = append(found, refs(child)...)
+		}
+	case []interface{}:
+		for _, child := range v {
+			found = append(found, refs(child)...)
+		}
+	}
+	return found
+}
+
+func TestOpenAPISpecDocumentsBothServices(t *testing.T) {
+	h := testRouter{welcome: WelcomeInfo{Version: "9.9.9"}}.handler()
+	rec := serve(h, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d, want 200", rec.Code)
+	}
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Title   string `json:"title"`
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	body := rec.Body.Bytes()
+	if err := json.Unmarshal(body, &spec); err != nil {
+		t.Fatalf("spec is not an OpenAPI document: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") || spec.Info.Title == "" || spec.Info.Version != "9.9.9" {
+		t.Errorf("openapi, title, version = %q, %q, %q; want 3.x, a title and 9.9.9", spec.OpenAPI, spec.Info.Title, spec.Info.Version)
+	}
+	for _, path := range []string{"/api/hello", "/api/goodbye"} {
+		for _, method := range []string{"get", "post"} {
+			op, ok := spec.Paths[path][method]
+			if !ok {
+				t.Errorf("spec has no %s %s", method, path)
+				continue
+			}
+			responses, _ := op["responses"].(map[string]interface{})
+			if _, ok := responses["200"]; !ok {
+				t.Errorf("%s %s documents no 200 response", method, path)
+			}
+		}
+	}
+
+	var doc interface{}
+	json.Unmarshal(body, &doc)
+	for _, ref := range refs(doc) {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if _, defined := spec.Components.Schemas[name]; !ok || !defined {
+			t.Errorf("$ref %q does not resolve", ref)
+		}
+	}
+}
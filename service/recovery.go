@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryRecoveryInterceptor turns a panic in a handler into a codes.Internal
+// error instead of crashing the server.
+func UnaryRecoveryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recoveredError(ctx, info.FullMethod, p)
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// StreamRecoveryInterceptor is the streaming counterpart of
+// UnaryRecoveryInterceptor.
+func StreamRecoveryInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = recoveredError(ss.Context(), info.FullMethod, p)
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// recoveredError logs a recovered panic with its stack and returns the error
+// sent to the client, which deliberately omits the panic value.
+func recoveredError(ctx context.Context, method string, p interface{}) error {
+	slog.ErrorContext(ctx, "gRPC: Recovered from panic", "method", method, "panic", p, "stack", string(debug.Stack()))
+	return status.Error(codes.Internal, "internal server error")
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// requestIDMetadataKey is the gRPC metadata key carrying the request ID.
+	requestIDMetadataKey = "x-request-id"
+	// RequestIDHeader is the HTTP header carrying the request ID.
+	RequestIDHeader = "X-Request-ID"
+)
+
+type requestIDContextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or "" if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// incomingRequestID returns the caller-supplied request ID, generating a new
+// one when the metadata does not carry it.
+func incomingRequestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return uuid.NewString()
+}
+
+// UnaryRequestIDInterceptor stores the request ID in the handler context and
+// echoes it back in both the response header and trailer.
+func UnaryRequestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := incomingRequestID(ctx)
+	md := metadata.Pairs(requestIDMetadataKey, id)
+	grpc.SetHeader(ctx, md)
+	defer grpc.SetTrailer(ctx, md)
+
+	return handler(WithRequestID(ctx, id), req)
+}
+
+// StreamRequestIDInterceptor is the streaming counterpart of
+// UnaryRequestIDInterceptor.
+func StreamRequestIDInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id := incomingRequestID(ss.Context())
+	md := metadata.Pairs(requestIDMetadataKey, id)
+	ss.SetHeader(md)
+	defer ss.SetTrailer(md)
+
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: WithRequestID(ss.Context(), id)})
+}
+
+// contextServerStream overrides the context of a grpc.ServerStream so that
+// stream interceptors can pass values down to handlers.
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the wrapped context.
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// requestIDMiddleware is the REST equivalent of the request ID interceptors:
+// it reads or generates X-Request-ID, stores it in the request context and
+// echoes it in the response.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
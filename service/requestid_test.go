@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDInterceptorEchoesOrGenerates(t *testing.T) {
+	greeter := hello.NewGreeterClient(dialServices(t, NewHelloServer(), nil, grpc.UnaryInterceptor(UnaryRequestIDInterceptor)))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sayHello := func(ctx context.Context) (header, trailer metadata.MD) {
+		t.Helper()
+		if _, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+			t.Fatalf("SayHello: %v", err)
+		}
+		return header, trailer
+	}
+
+	header, trailer := sayHello(metadata.AppendToOutgoingContext(ctx, requestIDMetadataKey, "req-123"))
+	if got := header.Get(requestIDMetadataKey); len(got) != 1 || got[0] != "req-123" {
+		t.Errorf("header %s = %v, want the supplied req-123", requestIDMetadataKey, got)
+	}
+	if got := trailer.Get(requestIDMetadataKey); len(got) != 1 || got[0] != "req-123" {
+		t.Errorf("trailer %s = %v, want the supplied req-123", requestIDMetadataKey, got)
+	}
+
+	header, trailer = sayHello(ctx)
+	generated := header.Get(requestIDMetadataKey)
+	if len(generated) != 1 || uuid.Validate(generated[0]) != nil {
+		t.Fatalf("header %s = %v, want one generated UUID", requestIDMetadataKey, generated)
+	}
+	if got := trailer.Get(requestIDMetadataKey); len(got) != 1 || got[0] != generated[0] {
+		t.Errorf("trailer %s = %v, want the header's %s", requestIDMetadataKey, got, generated[0])
+	}
+	if other, _ := sayHello(ctx); other.Get(requestIDMetadataKey)[0] == generated[0] {
+		t.Errorf("two calls were given the same request ID %s", generated[0])
+	}
+}
+
+func TestRequestIDMiddlewareEchoesOrGenerates(t *testing.T) {
+	h := testRouter{}.handler()
+
+	req := httptest.NewRequest("GET", "/api/hello?name=World", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	if got := serve(h, req).Header().Get(RequestIDHeader); got != "req-123" {
+		t.Errorf("%s = %q, want the supplied req-123", RequestIDHeader, got)
+	}
+
+	got := serve(h, httptest.NewRequest("GET", "/api/hello?name=World", nil)).Header().Get(RequestIDHeader)
+	if uuid.Validate(got) != nil {
+		t.Errorf("%s = %q, want a generated UUID", RequestIDHeader, got)
+	}
+}
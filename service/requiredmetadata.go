@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantIDMetadataKey is the metadata key deployments typically require to
+// identify the calling tenant.
+const TenantIDMetadataKey = "tenant-id"
+
+type requiredMetadataContextKey struct{}
+
+// WithRequiredMetadata returns a copy of ctx carrying the values of the
+// required metadata keys.
+func WithRequiredMetadata(ctx context.Context, values map[string]string) context.Context {
+	return context.WithValue(ctx, requiredMetadataContextKey{}, values)
+}
+
+// RequiredMetadataFromContext returns the value of a required metadata key
+// stored in ctx by the required-metadata interceptor, or "" if none.
+func RequiredMetadataFromContext(ctx context.Context, key string) string {
+	values, _ := ctx.Value(requiredMetadataContextKey{}).(map[string]string)
+	return values[key]
+}
+
+// TenantIDFromContext returns the caller's tenant-id when it is a required
+// metadata key, or "" otherwise.
+func TenantIDFromContext(ctx context.Context) string {
+	return RequiredMetadataFromContext(ctx, TenantIDMetadataKey)
+}
+
+// exemptFromRequiredMetadata reports whether method belongs to an
+// infrastructure service, such as health checking or reflection, whose
+// callers cannot be expected to send application metadata.
+func exemptFromRequiredMetadata(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.") || strings.HasPrefix(method, "/grpc.reflection.")
+}
+
+// requiredMetadata collects the first non-empty value of each key from the
+// incoming metadata, failing with codes.InvalidArgument on the first key
+// that is missing.
+func requiredMetadata(ctx context.Context, keys []string) (map[string]string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		var value string
+		for _, v := range md.Get(key) {
+			if v != "" {
+				value = v
+				break
+			}
+		}
+		if value == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "missing required metadata %q", key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// RequiredMetadataInterceptor rejects calls that do not carry every one of
+// keys in their metadata and makes the values available to handlers through
+// RequiredMetadataFromContext. Health and reflection calls are exempt. It
+// runs at the auth stage, ahead of validation.
+func RequiredMetadataInterceptor(keys []string) Interceptor {
+	return Interceptor{
+		Name:  "required-metadata",
+		Stage: StageAuth,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if exemptFromRequiredMetadata(info.FullMethod) {
+				return handler(ctx, req)
+			}
+			values, err := requiredMetadata(ctx, keys)
+			if err != nil {
+				return nil, err
+			}
+			return handler(WithRequiredMetadata(ctx, values), req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if exemptFromRequiredMetadata(info.FullMethod) {
+				return handler(srv, ss)
+			}
+			values, err := requiredMetadata(ss.Context(), keys)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &contextServerStream{ServerStream: ss, ctx: WithRequiredMetadata(ss.Context(), values)})
+		},
+	}
+}
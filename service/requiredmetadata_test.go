@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// tenantRegistry requires tenant-id on every call.
+func tenantRegistry() *Registry {
+	registry := NewRegistry()
+	registry.Register(RequiredMetadataInterceptor([]string{TenantIDMetadataKey}))
+	return registry
+}
+
+func TestRequiredMetadataInterceptor(t *testing.T) {
+	s := NewHelloServer()
+	chain := tenantRegistry().unaryChain()
+	info := &grpc.UnaryServerInfo{Server: s, FullMethod: "/hello.Greeter/SayHello"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return s.SayHello(ctx, req.(*hello.HelloRequest))
+	}
+
+	_, err := chain(context.Background(), &hello.HelloRequest{Name: "World"}, info, handler)
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), "tenant-id") {
+		t.Errorf("call without tenant-id: %v, want InvalidArgument naming tenant-id", err)
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("tenant-id", "acme"))
+	reply, err := chain(ctx, &hello.HelloRequest{Name: "World"}, info, handler)
+	if err != nil {
+		t.Fatalf("call with tenant-id: %v", err)
+	}
+	if got, want := reply.(*hello.HelloReply).GetMessage(), "[acme] Hello World"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+
+	// Health checks are exempt
+	info = &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	if _, err := chain(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) { return nil, nil }); err != nil {
+		t.Errorf("health check without tenant-id: %v, want exempt", err)
+	}
+}
+
+func TestRequiredMetadataOverREST(t *testing.T) {
+	h := testRouter{interceptors: tenantRegistry()}.handler()
+	newRequest := func(method, target, body string) *http.Request {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req
+	}
+
+	for _, req := range []*http.Request{
+		newRequest("GET", "/api/hello?name=World", ""),
+		newRequest("POST", "/api/hello", `{"name":"World"}`),
+		newRequest("GET", "/api/goodbye?name=World", ""),
+		newRequest("GET", "/api/goodbye/stream?name=World", ""),
+	} {
+		rec := serve(h, req)
+		if got := decodeBody(t, rec)["code"]; rec.Code != http.StatusBadRequest || got != "InvalidArgument" {
+			t.Errorf("%s %s without tenant-id = %d %v, want 400 InvalidArgument", req.Method, req.URL, rec.Code, got)
+		}
+	}
+
+	// The batch reports the error for each name
+	rec := serve(h, newRequest("POST", "/api/hello/batch", `{"names":["a"]}`))
+	if !strings.Contains(rec.Body.String(), "tenant-id") {
+		t.Errorf("batch without tenant-id = %s, want a per-name tenant-id error", rec.Body)
+	}
+
+	req := newRequest("GET", "/api/hello?name=World", "")
+	req.Header.Set("Grpc-Metadata-Tenant-Id", "acme")
+	rec = serve(h, req)
+	if got := decodeBody(t, rec)["message"]; rec.Code != http.StatusOK || got != "[acme] Hello World" {
+		t.Errorf("GET /api/hello with tenant-id = %d %v, want 200 [acme] Hello World", rec.Code, got)
+	}
+}
@@ -0,0 +1,543 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server is the unified gRPC and REST server. By default both protocols
+// share one listener through CreateMultiplexedHandler; in split-port mode
+// (config HTTPPort) gRPC and REST each get their own listener.
+type Server struct {
+	cfg        config.Config
+	grpcServer *grpc.Server
+	// httpServer serves the gRPC port, multiplexed with REST unless
+	// restServer is set.
+	httpServer *http.Server
+	// restServer serves REST on its own port in split-port mode, and is nil
+	// otherwise.
+	restServer *http.Server
+	health     *Health
+	// h2s configures HTTP/2 on every port, over TLS and h2c alike.
+	h2s *http2.Server
+	// streamMetrics holds the per-method message counts and histograms of
+	// streaming calls, served at /metrics.
+	streamMetrics *StreamMetrics
+	// slowCalls counts the unary calls over the slow-call threshold.
+	slowCalls *SlowCalls
+	// hello is the Greeter implementation, nil when the service is disabled.
+	hello *HelloServer
+	// history records greetings and farewells, nil when disabled.
+	history HistoryStore
+	// shutdown tells the streaming handlers that Stop was called.
+	shutdown *ShutdownSignal
+	// addrs is the topology reported by /, /health and /api/doc.
+	addrs *listenAddrs
+
+	mu           sync.Mutex
+	listener     net.Listener
+	restListener net.Listener
+	ready        chan struct{}
+	done         chan struct{}
+	serveErr     error
+}
+
+// serverInterceptors is the interceptor chain NewServer installs, along
+// with the state some of its interceptors keep.
+type serverInterceptors struct {
+	registry      *Registry
+	streamMetrics *StreamMetrics
+	slowCalls     *SlowCalls
+	// ipFilter is nil unless cfg sets an allow or deny list.
+	ipFilter *IPFilter
+}
+
+// newServerInterceptors assembles the interceptor chain for cfg, leaving out
+// any disabled in config.
+func newServerInterceptors(cfg config.Config) (serverInterceptors, error) {
+	interceptors := DefaultRegistry()
+	streamMetrics := NewStreamMetrics()
+	interceptors.Register(StreamMessageCountInterceptor(streamMetrics))
+	slowCalls := NewSlowCalls()
+	if cfg.SlowCallThreshold.Duration > 0 {
+		interceptors.Register(SlowCallInterceptor(cfg.SlowCallThreshold.Duration, slowCalls))
+	}
+	var ipFilter *IPFilter
+	if len(cfg.IPAllowList) > 0 || len(cfg.IPDenyList) > 0 {
+		var err error
+		ipFilter, err = NewIPFilter(cfg.IPAllowList, cfg.IPDenyList)
+		if err != nil {
+			return serverInterceptors{}, err
+		}
+		interceptors.Register(IPFilterInterceptor(ipFilter))
+	}
+	// Older TLS versions are refused during the handshake, and TLSRequired
+	// is only valid with a certificate, so a plaintext-only server has
+	// nothing to check
+	if cfg.TLSEnabled() {
+		interceptors.Register(TLSPolicyInterceptor(TLSPolicy{RequireTLS: cfg.TLSRequired}))
+	}
+	if cfg.APIKey != "" {
+		interceptors.Register(APIKeyInterceptor(cfg.APIKey))
+	}
+	if cfg.MinDeadline.Duration > 0 {
+		interceptors.Register(MinDeadlineInterceptor(cfg.MinDeadline.Duration, cfg.RejectShortDeadlines))
+	}
+	if len(cfg.MethodTimeouts) > 0 {
+		timeouts := make(map[string]time.Duration, len(cfg.MethodTimeouts))
+		for method, d := range cfg.MethodTimeouts {
+			timeouts[method] = d.Duration
+		}
+		interceptors.Register(MethodTimeoutInterceptor(timeouts))
+	}
+	if len(cfg.RequiredMetadataKeys) > 0 {
+		interceptors.Register(RequiredMetadataInterceptor(cfg.RequiredMetadataKeys))
+	}
+	interceptors.Register(IdempotencyInterceptor(NewIdempotencyCache(cfg.IdempotencyCacheSize, cfg.IdempotencyTTL.Duration, SystemClock{})))
+	if err := interceptors.Disable(cfg.DisabledInterceptors...); err != nil {
+		return serverInterceptors{}, err
+	}
+	return serverInterceptors{registry: interceptors, streamMetrics: streamMetrics, slowCalls: slowCalls, ipFilter: ipFilter}, nil
+}
+
+// grpcServerOptions returns the gRPC server options for cfg that install
+// interceptors.
+func grpcServerOptions(cfg config.Config, interceptors *Registry) []grpc.ServerOption {
+	return append(interceptors.ServerOptions(), grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+}
+
+// ServerOptionsFor returns the gRPC server options NewServer uses for cfg:
+// the whole interceptor chain, including the interceptors cfg turns on or
+// disables, and the message size limit. Tests pass them to grpc.NewServer to
+// exercise the same middleware as a server built from cfg.
+func ServerOptionsFor(cfg config.Config) ([]grpc.ServerOption, error) {
+	ics, err := newServerInterceptors(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return grpcServerOptions(cfg, ics.registry), nil
+}
+
+// NewServer builds the gRPC server, REST router and multiplexed handler
+// described by cfg without binding a port. Call Start to begin serving.
+func NewServer(cfg config.Config) (*Server, error) {
+	ics, err := newServerInterceptors(cfg)
+	if err != nil {
+		return nil, err
+	}
+	interceptors, streamMetrics, slowCalls, ipFilter := ics.registry, ics.streamMetrics, ics.slowCalls, ics.ipFilter
+
+	// Create gRPC server
+	grpcServer := grpc.NewServer(grpcServerOptions(cfg, interceptors)...)
+
+	var history HistoryStore
+	if cfg.HistoryStore != "" {
+		var err error
+		history, err = OpenHistoryStore(cfg.HistoryStore, cfg.HistorySQLitePath, cfg.HistorySize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Register the enabled services; a disabled one stays nil. Both share
+	// the signal Stop fires to end their streams early
+	shutdown := NewShutdownSignal()
+	var (
+		helloSrv   *HelloServer
+		goodbyeSrv *GoodbyeServer
+		services   []string
+	)
+	if cfg.EnableHello {
+		greeter, err := GreeterForStyle(cfg.GreetingStyle)
+		if err != nil {
+			return nil, err
+		}
+		helloSrv = NewHelloServer(
+			WithGreeter(greeter),
+			WithFaultInjection(cfg.EnableFaultInjection),
+			WithCoalescing(cfg.CoalesceGreetings),
+			WithHistory(history),
+			WithShutdownSignal(shutdown),
+			WithVersion(cfg.ServiceVersion),
+			WithStreamDelays(cfg.HelloStreamDelay.Duration, cfg.HelloBidiDelay.Duration),
+			WithClientStreamLimits(ClientStreamLimits{MaxNames: cfg.MaxStreamedNames, MaxBytes: cfg.MaxStreamedBytes}),
+		)
+		services = append(services, hello.Greeter_ServiceDesc.ServiceName)
+	}
+	if cfg.EnableGoodbye {
+		farewell := defaultFarewell
+		if cfg.GoodbyeTemplate != "" {
+			var err error
+			if farewell, err = ParseFarewellTemplate(cfg.GoodbyeTemplate); err != nil {
+				return nil, err
+			}
+		}
+		goodbyeSrv = NewGoodbyeServer(
+			WithBlockedNames(cfg.BlockedNames),
+			WithFarewellTemplate(farewell),
+			WithGoodbyeInterval(cfg.GoodbyeInterval.Duration),
+			WithGoodbyeStreamDelay(cfg.GoodbyeStreamDelay.Duration),
+			WithGoodbyeHistory(history),
+			WithGoodbyeShutdownSignal(shutdown),
+		)
+		services = append(services, goodbye.Farewell_ServiceDesc.ServiceName)
+	}
+	Register(grpcServer, helloSrv, goodbyeSrv)
+	health := NewHealth(services...)
+	health.Register(grpcServer)
+
+	// Register reflection service on gRPC server unless disabled
+	if cfg.EnableReflection {
+		reflection.Register(grpcServer)
+	}
+
+	// Setup HTTP router and the handler that serves both protocols
+	addrs := &listenAddrs{topology: NewTopology(cfg.ListenAddress(), cfg.HTTPListenAddress())}
+	welcome := WelcomeInfo{
+		ServiceName: cfg.ServiceName,
+		Version:     cfg.ServiceVersion,
+		Topology:    addrs.get,
+	}
+	var cache *ResponseCache
+	if cfg.HTTPCacheTTL.Duration > 0 {
+		cache = NewResponseCache(cfg.HTTPCacheSize, cfg.HTTPCacheTTL.Duration, SystemClock{})
+	}
+	routeTimeouts := RouteTimeouts{
+		Routes:  make(map[string]time.Duration, len(cfg.HTTPRouteTimeouts)),
+		Default: cfg.HTTPBackendTimeout.Duration,
+	}
+	for route, d := range cfg.HTTPRouteTimeouts {
+		routeTimeouts.Routes[route] = d.Duration
+	}
+	cors := CORSOptions{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+	}
+	router := SetupHTTPRouter(RouterOptions{
+		Hello:        helloSrv,
+		Goodbye:      goodbyeSrv,
+		Health:       health,
+		Interceptors: interceptors,
+		Welcome:      welcome,
+		Cache:        cache,
+		Admin:        AdminOptions{APIKey: cfg.AdminAPIKey, Config: cfg},
+		History:      history,
+		Timeouts:     routeTimeouts,
+		Metrics:      streamMetrics,
+		CORS:         cors,
+	})
+	httpHandler := LimitRequestBody(router, cfg.MaxHTTPBodyBytes)
+	httpHandler = LimitRequestHeaders(httpHandler, cfg.MaxHTTPHeaderBytes)
+	httpHandler = DecompressRequestBody(httpHandler)
+	httpHandler = RequestDeadlines(httpHandler, cfg.HTTPReadTimeout.Duration, cfg.HTTPWriteTimeout.Duration)
+	httpHandler = CORS(httpHandler, cors)
+	if ipFilter != nil {
+		httpHandler = RestrictPeerIPs(httpHandler, ipFilter)
+	}
+	// Outermost, so requests rejected by the other middleware are logged too
+	switch cfg.LogAccessFormat {
+	case config.AccessLogJSON:
+		httpHandler = LogRequests(httpHandler)
+	case config.AccessLogCombined:
+		httpHandler = LogRequestsCombined(httpHandler, os.Stderr)
+	}
+
+	// gRPC is served through ServeHTTP, so the HTTP/2 server rather than
+	// grpc.MaxConcurrentStreams limits the streams per connection
+	h2s := &http2.Server{MaxConcurrentStreams: cfg.MaxConcurrentStreams}
+
+	s := &Server{
+		cfg:           cfg,
+		grpcServer:    grpcServer,
+		health:        health,
+		h2s:           h2s,
+		streamMetrics: streamMetrics,
+		slowCalls:     slowCalls,
+		hello:         helloSrv,
+		history:       history,
+		shutdown:      shutdown,
+		addrs:         addrs,
+		httpServer: &http.Server{
+			Addr:    cfg.ListenAddress(),
+			Handler: CreateMultiplexedHandler(grpcServer, httpHandler, h2s),
+			// ReadTimeout and WriteTimeout are deliberately left unset: they
+			// would also cut off gRPC streams sharing the connection. REST
+			// requests get per-request deadlines from RequestDeadlines
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout.Duration,
+			IdleTimeout:       cfg.HTTPIdleTimeout.Duration,
+			MaxHeaderBytes:    cfg.MaxHTTPHeaderBytes,
+		},
+	}
+	if cfg.SplitPorts() {
+		s.httpServer.Handler = CreateGRPCHandler(grpcServer, h2s)
+		s.restServer = &http.Server{
+			Addr:              cfg.HTTPListenAddress(),
+			Handler:           CreateRESTHandler(httpHandler, h2s),
+			ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout.Duration,
+			IdleTimeout:       cfg.HTTPIdleTimeout.Duration,
+			MaxHeaderBytes:    cfg.MaxHTTPHeaderBytes,
+		}
+	}
+	return s, nil
+}
+
+// servers returns the HTTP servers behind each port, the gRPC port first.
+func (s *Server) servers() []*http.Server {
+	if s.restServer == nil {
+		return []*http.Server{s.httpServer}
+	}
+	return []*http.Server{s.httpServer, s.restServer}
+}
+
+// Start listens on the configured port, or ports in split-port mode, and
+// serves in the background, over TLS when a certificate is configured. The
+// certificate is reloaded when its files change, checked at most every
+// TLSReloadInterval. Start blocks until every port is accepting connections,
+// so callers can connect as soon as it returns, or returns the error that
+// prevented serving, such as an unreadable certificate. Use Wait to block
+// until the server stops; if one port fails, the others are closed too.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	if s.listener != nil {
+		s.mu.Unlock()
+		return errors.New("server already started")
+	}
+
+	servers := s.servers()
+	if s.cfg.TLSEnabled() {
+		certs, err := NewCertReloader(s.cfg.TLSCertFile, s.cfg.TLSKeyFile, s.cfg.TLSReloadInterval.Duration)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		for _, srv := range servers {
+			srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate, MinVersion: s.cfg.MinTLSVersion()}
+			if err := http2.ConfigureServer(srv, s.h2s); err != nil {
+				s.mu.Unlock()
+				return fmt.Errorf("configuring HTTP/2: %w", err)
+			}
+		}
+	}
+
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		lis, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			s.mu.Unlock()
+			return fmt.Errorf("failed to listen on %s: %w", srv.Addr, err)
+		}
+		listeners = append(listeners, lis)
+	}
+	s.listener = listeners[0]
+	s.restListener = listeners[len(listeners)-1]
+	s.addrs.bind(s.listener.Addr(), s.restListener.Addr())
+	s.ready = make(chan struct{})
+	s.done = make(chan struct{})
+	ready, done := s.ready, s.done
+	s.mu.Unlock()
+
+	var pending atomic.Int32
+	pending.Store(int32(len(servers)))
+	markReady := func() {
+		if pending.Add(-1) == 0 {
+			close(ready)
+		}
+	}
+
+	// The group keeps the first error of any port, which closes the others
+	// so that every serve loop returns
+	var group errgroup.Group
+	for i, srv := range servers {
+		lis := &readyListener{Listener: listeners[i], ready: markReady}
+		group.Go(func() error {
+			var err error
+			if s.cfg.TLSEnabled() {
+				// The certificate comes from TLSConfig.GetCertificate
+				err = srv.ServeTLS(lis, "", "")
+			} else {
+				err = srv.Serve(lis)
+			}
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			for _, other := range servers {
+				other.Close()
+			}
+			return fmt.Errorf("serving on %s: %w", lis.Addr(), err)
+		})
+	}
+	go func() {
+		err := group.Wait()
+		s.mu.Lock()
+		s.serveErr = err
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	select {
+	case <-ready:
+		s.health.MarkReady()
+		return nil
+	case <-done:
+		if err := s.Wait(); err != nil {
+			return err
+		}
+		return errors.New("server stopped before it was ready")
+	}
+}
+
+// Ready returns a channel that is closed once the server is accepting
+// connections. It returns nil before Start.
+func (s *Server) Ready() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ready
+}
+
+// listenAddrs holds the Topology a Server reports: its configured listen
+// addresses until Start binds them, then the listeners' own addresses.
+type listenAddrs struct {
+	mu       sync.Mutex
+	topology Topology
+}
+
+func (a *listenAddrs) get() Topology {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.topology
+}
+
+// bind records the addresses the listeners are bound to.
+func (a *listenAddrs) bind(grpcAddr, httpAddr net.Addr) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.topology = NewTopology(grpcAddr.String(), httpAddr.String())
+}
+
+// readyListener calls ready the first time the serve loop calls Accept,
+// which is the point from which connections are being served.
+type readyListener struct {
+	net.Listener
+	ready func()
+	once  sync.Once
+}
+
+func (l *readyListener) Accept() (net.Conn, error) {
+	l.once.Do(l.ready)
+	return l.Listener.Accept()
+}
+
+// Addr returns the address the server is listening on for gRPC, which
+// reports the actual port when the configured port is 0. It returns nil
+// before Start.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// HTTPAddr returns the address the server is listening on for REST, which
+// is Addr unless gRPC and REST are on separate ports. It returns nil before
+// Start.
+func (s *Server) HTTPAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.restListener == nil {
+		return nil
+	}
+	return s.restListener.Addr()
+}
+
+// StreamMetrics returns the message counts and histograms recorded for
+// streaming calls.
+func (s *Server) StreamMetrics() *StreamMetrics {
+	return s.streamMetrics
+}
+
+// SlowCalls returns the counts of unary calls slower than
+// config.SlowCallThreshold; they stay zero when it is disabled.
+func (s *Server) SlowCalls() *SlowCalls {
+	return s.slowCalls
+}
+
+// CoalescedGreetings returns how many SayHello calls were answered with the
+// greeting of a concurrent call for the same name; see
+// config.CoalesceGreetings.
+func (s *Server) CoalescedGreetings() int64 {
+	if s.hello == nil {
+		return 0
+	}
+	return s.hello.CoalescedGreetings()
+}
+
+// Wait blocks until the server stops and returns the error that stopped it,
+// or nil after Stop.
+func (s *Server) Wait() error {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done == nil {
+		return errors.New("server not started")
+	}
+
+	<-done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serveErr
+}
+
+// Drain marks the server NOT_SERVING on its health checks without closing
+// any connections, so load balancers stop routing to it before Stop.
+func (s *Server) Drain() {
+	s.health.Drain()
+}
+
+// Resume undoes Drain. It fails once Stop has been called.
+func (s *Server) Resume() error {
+	return s.health.Resume()
+}
+
+// Stop drains the server, ends the streaming calls in flight early with the
+// shutdown-in-progress trailer and waits for them to finish, stops accepting
+// connections, waits for in-flight REST requests until ctx is done, then
+// closes any remaining gRPC streams and the history store.
+func (s *Server) Stop(ctx context.Context) error {
+	s.health.Shutdown()
+	s.shutdown.Begin()
+	s.shutdown.wait(ctx)
+	var errs []error
+	for _, srv := range s.servers() {
+		errs = append(errs, srv.Shutdown(ctx))
+	}
+	s.grpcServer.Stop()
+	if closer, ok := s.history.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,628 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer starts a Server for cfg and stops it when the test ends.
+func startServer(t *testing.T, cfg config.Config) *Server {
+	t.Helper()
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Stop(ctx)
+	})
+	return s
+}
+
+// dialServices serves helloSrv and goodbyeSrv, either of which may be nil,
+// with opts on an in-memory listener and returns a connection to them. Both
+// are closed when the test ends.
+func dialServices(t *testing.T, helloSrv *HelloServer, goodbyeSrv *GoodbyeServer, opts ...grpc.ServerOption) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer(opts...)
+	Register(grpcServer, helloSrv, goodbyeSrv)
+	go grpcServer.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing bufconn: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		grpcServer.Stop()
+	})
+	return conn
+}
+
+// getJSON fetches url and decodes its JSON body into v.
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s: decoding body: %v", url, err)
+	}
+}
+
+// nonLoopbackIP returns an address of this host other than loopback, or nil
+// if it has none.
+func nonLoopbackIP() net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+			return ipNet.IP
+		}
+	}
+	return nil
+}
+
+func TestListenAddrReportsBoundAddress(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	s := startServer(t, cfg)
+
+	addr := s.Addr().(*net.TCPAddr)
+	if !addr.IP.IsLoopback() || addr.Port == 0 {
+		t.Fatalf("bound to %s, want a loopback address with a chosen port", addr)
+	}
+
+	var doc struct {
+		Endpoints struct {
+			GRPC struct{ Address string } `json:"grpc"`
+			HTTP struct{ Address string } `json:"http"`
+		} `json:"endpoints"`
+	}
+	getJSON(t, "http://"+addr.String()+"/api/doc", &doc)
+	if doc.Endpoints.GRPC.Address != addr.String() || doc.Endpoints.HTTP.Address != addr.String() {
+		t.Errorf("/api/doc addresses = %q, %q, want the bound %s", doc.Endpoints.GRPC.Address, doc.Endpoints.HTTP.Address, addr)
+	}
+
+	ip := nonLoopbackIP()
+	if ip == nil {
+		t.Skip("no non-loopback interface to dial")
+	}
+	other := net.JoinHostPort(ip.String(), strconv.Itoa(addr.Port))
+	if conn, err := net.DialTimeout("tcp", other, time.Second); err == nil {
+		conn.Close()
+		t.Errorf("server bound to %s answered on %s", addr, other)
+	}
+}
+
+func TestSplitPortsReportAndServeEachProtocol(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.HTTPPort = "0"
+	s := startServer(t, cfg)
+	grpcAddr, httpAddr := s.Addr().String(), s.HTTPAddr().String()
+	if grpcAddr == httpAddr {
+		t.Fatalf("gRPC and REST share %s in split-port mode", grpcAddr)
+	}
+
+	var health struct {
+		Services struct{ GRPC, HTTP string } `json:"services"`
+	}
+	getJSON(t, "http://"+httpAddr+"/health", &health)
+	if want := "running on " + grpcAddr; health.Services.GRPC != want {
+		t.Errorf("/health grpc = %q, want %q", health.Services.GRPC, want)
+	}
+	if want := "running on " + httpAddr; health.Services.HTTP != want {
+		t.Errorf("/health http = %q, want %q", health.Services.HTTP, want)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sayHello := func(addr string) error {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatalf("dialing %s: %v", addr, err)
+		}
+		defer conn.Close()
+		_, err = hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		return err
+	}
+	if err := sayHello(grpcAddr); err != nil {
+		t.Errorf("SayHello on the gRPC port: %v", err)
+	}
+	if err := sayHello(httpAddr); err == nil {
+		t.Error("SayHello on the REST port succeeded, want it rejected")
+	}
+	if resp, err := http.Get("http://" + grpcAddr + "/health"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			t.Error("REST /health on the gRPC port answered 200, want it rejected")
+		}
+	}
+}
+
+func TestReflectionCanBeDisabled(t *testing.T) {
+	registered := func(enable bool) bool {
+		cfg := config.Default()
+		cfg.EnableReflection = enable
+		s, err := NewServer(cfg)
+		if err != nil {
+			t.Fatalf("NewServer: %v", err)
+		}
+		_, ok := s.grpcServer.GetServiceInfo()[reflectionpb.ServerReflection_ServiceDesc.ServiceName]
+		return ok
+	}
+
+	if registered(false) {
+		t.Error("reflection registered with EnableReflection false")
+	}
+	if !registered(true) {
+		t.Error("reflection not registered with EnableReflection true")
+	}
+}
+
+func TestServerRejectsOversizedRequests(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.MaxRecvMsgSize = 1024
+	cfg.MaxHTTPBodyBytes = 1024
+	s := startServer(t, cfg)
+	addr := s.Addr().String()
+	large := strings.Repeat("x", 2048)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: large})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("SayHello with a 2 KiB message = %v, want ResourceExhausted", err)
+	}
+
+	resp, err := http.Post("http://"+addr+"/api/hello", "application/json", strings.NewReader(`{"name": "`+large+`"}`))
+	if err != nil {
+		t.Fatalf("POST /api/hello: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /api/hello with a 2 KiB body = %d, want 413", resp.StatusCode)
+	}
+}
+
+func TestServerStartsAndStopsCleanly(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := s.Start(); err == nil {
+		t.Error("second Start succeeded, want an error")
+	}
+	addr := s.Addr().String()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	if err := s.Stop(ctx); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+	if err := s.Wait(); err != nil {
+		t.Errorf("Wait after Stop = %v, want nil", err)
+	}
+	if c, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		c.Close()
+		t.Errorf("%s still accepts connections after Stop", addr)
+	}
+}
+
+func TestSlowHeadersAreCutOffButStreamsSurvive(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.HTTPReadHeaderTimeout = config.Duration{Duration: 200 * time.Millisecond}
+	s := startServer(t, cfg)
+	addr := s.Addr().String()
+
+	// A client that never finishes its headers is disconnected
+	slow, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer slow.Close()
+	if _, err := slow.Write([]byte("GET /health HTTP/1.1\r\nHost: x\r\n")); err != nil {
+		t.Fatalf("writing partial headers: %v", err)
+	}
+	slow.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	if _, err := io.ReadAll(slow); err != nil {
+		t.Fatalf("slow client still connected after %s: %v", time.Since(start), err)
+	}
+
+	// A stream that outlives the header timeout runs to completion
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "stream-count", "3", "stream-delay-ms", "200")
+	stream, err := hello.NewGreeterClient(conn).SayHelloStream(ctx, &hello.HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayHelloStream: %v", err)
+	}
+	var replies int
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Recv after %d replies: %v", replies, err)
+		}
+		replies++
+	}
+	if replies != 3 {
+		t.Errorf("got %d replies, want 3", replies)
+	}
+}
+
+func TestStartBlocksUntilReady(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if s.Ready() != nil {
+		t.Error("Ready before Start is not nil")
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		s.Stop(ctx)
+	})
+	select {
+	case <-s.Ready():
+	default:
+		t.Fatal("Ready not closed when Start returned")
+	}
+
+	// No retries and no waiting for the connection: the first call must land
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.WaitForReady(false)); err != nil {
+		t.Errorf("SayHello right after Start: %v", err)
+	}
+}
+
+func TestStartReportsListenFailure(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer taken.Close()
+
+	cfg := config.Default()
+	cfg.ListenAddr = taken.Addr().String()
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.Start(); err == nil {
+		s.Stop(context.Background())
+		t.Fatalf("Start on a port in use succeeded, want an error")
+	}
+}
+
+func TestSplitPortsReleaseEveryPortWhenOneFailsToBind(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer taken.Close()
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	freeAddr := free.Addr().String()
+	free.Close()
+
+	cfg := config.Default()
+	cfg.ListenAddr = freeAddr
+	_, cfg.HTTPPort, _ = net.SplitHostPort(taken.Addr().String())
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.Start(); err == nil {
+		s.Stop(context.Background())
+		t.Fatalf("Start with the REST port in use succeeded, want an error")
+	}
+
+	// The gRPC port bound before the failure must have been closed again
+	lis, err := net.Listen("tcp", freeAddr)
+	if err != nil {
+		t.Fatalf("gRPC port %s still held after Start failed: %v", freeAddr, err)
+	}
+	lis.Close()
+}
+
+func TestServeFailureStopsEveryPort(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.HTTPPort = "0"
+	s := startServer(t, cfg)
+	grpcAddr := s.Addr().String()
+
+	// Closing the REST listener under its server makes that serve loop fail
+	s.mu.Lock()
+	s.restListener.Close()
+	s.mu.Unlock()
+
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- s.Wait() }()
+	select {
+	case err := <-waitErr:
+		if err == nil || !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Wait = %v, want the REST port's serve error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after the REST port failed")
+	}
+
+	if conn, err := net.DialTimeout("tcp", grpcAddr, time.Second); err == nil {
+		conn.Close()
+		t.Errorf("gRPC port %s still accepting after the REST port failed", grpcAddr)
+	}
+}
+
+func TestGoodbyeCanBeDisabled(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.EnableGoodbye = false
+	s := startServer(t, cfg)
+	addr := s.Addr().String()
+
+	services := s.grpcServer.GetServiceInfo()
+	if _, ok := services[goodbye.Farewell_ServiceDesc.ServiceName]; ok {
+		t.Error("Farewell registered with goodbye disabled")
+	}
+	if _, ok := services[hello.Greeter_ServiceDesc.ServiceName]; !ok {
+		t.Error("Greeter not registered")
+	}
+
+	for _, path := range []string{"/api/goodbye?name=World", "/v1/goodbye"} {
+		resp, err := http.Get("http://" + addr + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+			t.Errorf("GET %s = %d %s, want a JSON 404", path, resp.StatusCode, resp.Header.Get("Content-Type"))
+		}
+	}
+
+	resp, err := http.Get("http://" + addr + "/api/doc")
+	if err != nil {
+		t.Fatalf("GET /api/doc: %v", err)
+	}
+	doc, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if strings.Contains(string(doc), "Farewell") || strings.Contains(string(doc), "/api/goodbye") {
+		t.Errorf("/api/doc lists the disabled goodbye service:\n%s", doc)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing %s: %v", addr, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := goodbye.NewFarewellClient(conn).SayGoodbye(ctx, &goodbye.GoodbyeRequest{Name: "World"}); status.Code(err) != codes.Unimplemented {
+		t.Errorf("SayGoodbye with goodbye disabled: %v, want Unimplemented", err)
+	}
+}
+
+func TestMaxConcurrentStreamsQueuesExcessStreams(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.MaxConcurrentStreams = 2
+	cfg.HelloBidiDelay = config.Duration{}
+	s := startServer(t, cfg)
+
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	greeter := hello.NewGreeterClient(conn)
+	// open starts a bidirectional stream on the shared connection and
+	// exchanges one greeting, so it holds a stream until cancelled.
+	open := func(ctx context.Context) error {
+		stream, err := greeter.SayHelloBidirectional(ctx)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&hello.HelloRequest{Name: "World"}); err != nil {
+			return err
+		}
+		_, err = stream.Recv()
+		return err
+	}
+
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		cancels = append(cancels, cancel)
+		if err := open(ctx); err != nil {
+			t.Fatalf("stream %d within the limit: %v", i+1, err)
+		}
+	}
+
+	// A third stream waits for a free slot rather than running
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := open(ctx); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("stream beyond the limit: %v, want it held until its deadline", err)
+	}
+
+	// Ending one stream lets the next through
+	cancels[0]()
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := open(ctx); err != nil {
+		t.Errorf("stream after another ended: %v", err)
+	}
+}
+
+func TestServerReportsOneVersion(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.ServiceVersion = "7.8.9"
+	s := startServer(t, cfg)
+	base := "http://" + s.Addr().String()
+
+	var health, doc struct {
+		Version string `json:"version"`
+	}
+	getJSON(t, base+"/health", &health)
+	getJSON(t, base+"/api/doc", &doc)
+	var spec struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	getJSON(t, base+"/openapi.json", &spec)
+
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var trailer metadata.MD
+	if _, err := hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Trailer(&trailer)); err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	for source, got := range map[string]string{
+		"/health":                health.Version,
+		"/api/doc":               doc.Version,
+		"/openapi.json":          spec.Info.Version,
+		"server-version trailer": strings.Join(trailer.Get("server-version"), ","),
+	} {
+		if got != "7.8.9" {
+			t.Errorf("%s version = %q, want 7.8.9", source, got)
+		}
+	}
+}
+
+func TestZeroStreamDelaysFromEnvironment(t *testing.T) {
+	t.Setenv("HELLO_STREAM_DELAY", "0s")
+	t.Setenv("HELLO_BIDI_DELAY", "0s")
+	t.Setenv("GOODBYE_STREAM_DELAY", "0s")
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	cfg.ListenAddr = "127.0.0.1:0"
+	s := startServer(t, cfg)
+
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// drain reads a server stream to its end and returns how many messages
+	// it carried
+	drain := func(recv func() error) int {
+		n := 0
+		for {
+			if err := recv(); err == io.EOF {
+				return n
+			} else if err != nil {
+				t.Fatalf("Recv: %v", err)
+			}
+			n++
+		}
+	}
+
+	start := time.Now()
+	helloStream, err := hello.NewGreeterClient(conn).SayHelloStream(ctx, &hello.HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayHelloStream: %v", err)
+	}
+	if n := drain(func() error { _, err := helloStream.Recv(); return err }); n != defaultStreamCount {
+		t.Errorf("SayHelloStream sent %d messages, want %d", n, defaultStreamCount)
+	}
+	goodbyeStream, err := goodbye.NewFarewellClient(conn).SayGoodbyeStream(ctx, &goodbye.GoodbyeRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayGoodbyeStream: %v", err)
+	}
+	drain(func() error { _, err := goodbyeStream.Recv(); return err })
+	// The default delays would stretch these two streams over several seconds
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("streams with zero delays took %s", elapsed)
+	}
+}
@@ -0,0 +1,70 @@
+// Package service implements the Greeter and Farewell gRPC services together
+// with the REST API that fronts them, so the server binary, tests and other
+// programs can all wire up the same handlers.
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Register registers both services on grpcServer. A nil helloSrv or
+// goodbyeSrv leaves that service unregistered, so calls to it fail with
+// Unimplemented and reflection does not list it.
+func Register(grpcServer *grpc.Server, helloSrv *HelloServer, goodbyeSrv *GoodbyeServer) {
+	if helloSrv != nil {
+		hello.RegisterGreeterServer(grpcServer, helloSrv)
+	}
+	if goodbyeSrv != nil {
+		goodbye.RegisterFarewellServer(grpcServer, goodbyeSrv)
+	}
+}
+
+// isClientCancel reports whether err, returned while receiving from a stream
+// with context ctx, means the client cancelled the call rather than that
+// something went wrong.
+func isClientCancel(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	return status.Code(err) == codes.Canceled || errors.Is(ctx.Err(), context.Canceled)
+}
+
+// streamDuration formats how long a stream ran, rounded to the millisecond,
+// for the stream-duration trailer every streaming handler sets, e.g.
+// "4.503s".
+func streamDuration(elapsed time.Duration) string {
+	return elapsed.Round(time.Millisecond).String()
+}
+
+// newResponseID returns a response-id for the service named by prefix, such
+// as "hello-6ba7b810-9dad-11d1-80b4-00c04fd430c8". IDs are random rather than
+// taken from the clock, so calls in the same instant, or under a FakeClock,
+// still get IDs of their own.
+func newResponseID(prefix string) string {
+	return prefix + "-" + uuid.NewString()
+}
+
+// sleepContext pauses for d or until ctx is done, whichever comes first. It
+// returns ctx.Err() if the context ended the wait early.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// shutdownTrailerKey is set to "true" in the trailer of a stream that ended
+// early because the server began shutting down, so clients can tell it from
+// a stream that ran to completion.
+const shutdownTrailerKey = "shutdown-in-progress"
+
+// errShuttingDown is the cause of the stream contexts a ShutdownSignal
+// cancels.
+var errShuttingDown = errors.New("server is shutting down")
+
+// ShutdownSignal tells the streaming handlers that the server has begun a
+// graceful shutdown. Streams still running end early with the
+// shutdown-in-progress trailer and codes.Unavailable, rather than being cut
+// off without trailers when the server finally closes; handlers notice at
+// once, whether sending, pausing or waiting on the client. A nil
+// *ShutdownSignal never fires.
+type ShutdownSignal struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu sync.Mutex
+	// streams counts the streams given a context by streamContext that have
+	// not finished; changed is closed and replaced whenever it drops.
+	streams int
+	changed chan struct{}
+}
+
+// NewShutdownSignal returns a signal that has not fired.
+func NewShutdownSignal() *ShutdownSignal {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ShutdownSignal{ctx: ctx, cancel: cancel, changed: make(chan struct{})}
+}
+
+// Begin fires the signal. It is idempotent.
+func (s *ShutdownSignal) Begin() {
+	if s != nil {
+		s.cancel()
+	}
+}
+
+// wait blocks until every stream counted by streamContext has finished, or
+// until ctx is done.
+func (s *ShutdownSignal) wait(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	for {
+		s.mu.Lock()
+		streams, changed := s.streams, s.changed
+		s.mu.Unlock()
+		if streams == 0 {
+			return
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// streamContext returns a copy of stream context ctx that is also cancelled,
+// with cause errShuttingDown, once the signal fires. Call stop when the
+// handler returns. The stream counts as running until ctx itself ends,
+// which is only after its status and trailers have been written.
+func (s *ShutdownSignal) streamContext(ctx context.Context) (_ context.Context, stop func()) {
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	if s == nil {
+		return streamCtx, func() { cancel(nil) }
+	}
+	s.mu.Lock()
+	s.streams++
+	s.mu.Unlock()
+	context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.streams--
+		close(s.changed)
+		s.changed = make(chan struct{})
+	})
+	unregister := context.AfterFunc(s.ctx, func() { cancel(errShuttingDown) })
+	return streamCtx, func() {
+		unregister()
+		cancel(nil)
+	}
+}
+
+// shuttingDown reports whether ctx, returned by streamContext, ended because
+// the server is shutting down.
+func shuttingDown(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errShuttingDown)
+}
+
+// received is one result of a stream's Recv.
+type received[T any] struct {
+	msg *T
+	err error
+}
+
+// recvUntilShutdown starts the one goroutine that calls recv, the Recv of a
+// stream whose context ctx came from streamContext, for the life of the
+// stream, and returns a function that takes its next message in place of
+// recv. That function returns errShuttingDown as soon as the server starts
+// shutting down instead of waiting for the client's next message. The
+// goroutine stops after recv fails or once ctx ends, so the handler must
+// call the returned function rather than recv from then on.
+func recvUntilShutdown[T any](ctx context.Context, recv func() (*T, error)) func() (*T, error) {
+	results := make(chan received[T])
+	go func() {
+		for {
+			msg, err := recv()
+			select {
+			case results <- received[T]{msg, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return func() (*T, error) {
+		select {
+		case r := <-results:
+			return r.msg, r.err
+		case <-ctx.Done():
+			if shuttingDown(ctx) {
+				return nil, errShuttingDown
+			}
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// endStreamForShutdown ends a stream cut short by shutdown: it sets trailer
+// along with the shutdown-in-progress trailer and stream-status "shutdown",
+// and returns codes.Unavailable so clients know to retry elsewhere.
+func endStreamForShutdown(ctx context.Context, stream grpc.ServerStream, logger *slog.Logger, trailer metadata.MD) error {
+	stream.SetTrailer(metadata.Join(trailer, metadata.Pairs(
+		shutdownTrailerKey, "true",
+		"stream-status", "shutdown",
+	)))
+	logger.InfoContext(ctx, "gRPC: Stream ended early by server shutdown")
+	return status.Error(codes.Unavailable, errShuttingDown.Error())
+}
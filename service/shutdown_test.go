@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecvUntilShutdownReadsFromOneGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+	signal := NewShutdownSignal()
+	ctx, stop := signal.streamContext(context.Background())
+	defer stop()
+
+	msgs := make(chan string, 2)
+	msgs <- "a"
+	msgs <- "b"
+	var active, overlaps atomic.Int32
+	recv := recvUntilShutdown(ctx, func() (*string, error) {
+		if active.Add(1) > 1 {
+			overlaps.Add(1)
+		}
+		defer active.Add(-1)
+		msg, ok := <-msgs
+		if !ok {
+			return nil, io.EOF
+		}
+		return &msg, nil
+	})
+
+	for _, want := range []string{"a", "b"} {
+		if msg, err := recv(); err != nil || *msg != want {
+			t.Fatalf("recv = %v, %v, want %q", msg, err, want)
+		}
+	}
+	signal.Begin()
+	if _, err := recv(); !errors.Is(err, errShuttingDown) {
+		t.Fatalf("recv after Begin = %v, want errShuttingDown", err)
+	}
+	if n := overlaps.Load(); n != 0 {
+		t.Errorf("recv ran concurrently %d times, want never", n)
+	}
+
+	// Tearing the stream down ends the blocked Recv and its goroutine
+	close(msgs)
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopEndsClientStreamWithShutdownTrailer(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	s, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	conn, err := grpc.NewClient(s.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := hello.NewGreeterClient(conn).SayHelloClientStream(ctx)
+	if err != nil {
+		t.Fatalf("SayHelloClientStream: %v", err)
+	}
+	if err := stream.Send(&hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	// The header is sent once the handler runs, so the stream is counted
+	if _, err := stream.Header(); err != nil {
+		t.Fatalf("Header: %v", err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Stop(ctx) }()
+
+	// Wait for the reply without closing the stream, so only Stop can end it
+	err = stream.RecvMsg(new(hello.HelloReply))
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("stream open during Stop ended with %v, want Unavailable", err)
+	}
+	if got := stream.Trailer().Get(shutdownTrailerKey); len(got) != 1 || got[0] != "true" {
+		t.Errorf("%s trailer = %v, want true", shutdownTrailerKey, got)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+	if err := s.Wait(); err != nil {
+		t.Errorf("Wait after Stop: %v", err)
+	}
+}
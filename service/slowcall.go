@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// SlowCalls counts, per method, the unary calls that took longer than the
+// slow-call threshold.
+type SlowCalls struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewSlowCalls returns an empty SlowCalls.
+func NewSlowCalls() *SlowCalls {
+	return &SlowCalls{counts: map[string]int64{}}
+}
+
+// record adds one slow call of fullMethod.
+func (c *SlowCalls) record(fullMethod string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[fullMethod]++
+}
+
+// Count returns the slow calls recorded for fullMethod, e.g.
+// "/grpc.hello.Greeter/SayHello".
+func (c *SlowCalls) Count(fullMethod string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[fullMethod]
+}
+
+// Total returns the slow calls recorded for every method.
+func (c *SlowCalls) Total() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var total int64
+	for _, n := range c.counts {
+		total += n
+	}
+	return total
+}
+
+// SlowCallInterceptor logs a warning and adds to calls whenever a unary call
+// takes longer than threshold, whatever its outcome, for SLO monitoring. The
+// time covers every interceptor below the metrics stage as well as the
+// handler. Streams are not timed, as their length is up to the client.
+func SlowCallInterceptor(threshold time.Duration, calls *SlowCalls) Interceptor {
+	return Interceptor{
+		Name:  "slow-call",
+		Stage: StageMetrics,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			start := time.Now()
+			resp, err := handler(ctx, req)
+			if elapsed := time.Since(start); elapsed > threshold {
+				calls.record(info.FullMethod)
+				slog.WarnContext(ctx, "gRPC: Slow call", "grpc_method", info.FullMethod,
+					"code", status.Code(err).String(), "duration_ms", elapsed.Milliseconds(),
+					"threshold_ms", threshold.Milliseconds())
+			}
+			return resp, err
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSlowCallInterceptorWarnsOnlyAboveThreshold(t *testing.T) {
+	logs := captureLogs(t)
+	calls := NewSlowCalls()
+	unary := SlowCallInterceptor(50*time.Millisecond, calls).Unary
+	call := func(method string, handler grpc.UnaryHandler) {
+		unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	call("/grpc.hello.Greeter/SayHello", slowHandler(time.Millisecond))
+	if records := logRecords(t, logs, "gRPC: Slow call"); len(records) != 0 {
+		t.Errorf("fast call logged %d slow-call warnings, want none", len(records))
+	}
+	if n := calls.Total(); n != 0 {
+		t.Errorf("fast call counted %d slow calls, want 0", n)
+	}
+
+	call("/grpc.hello.Greeter/SayHello", slowHandler(100*time.Millisecond))
+	record := logRecord(t, logs, "gRPC: Slow call")
+	if record["level"] != "WARN" || record["grpc_method"] != "/grpc.hello.Greeter/SayHello" {
+		t.Errorf("slow-call record = %v, want a WARN for SayHello", record)
+	}
+	if ms, _ := record["duration_ms"].(float64); ms < 100 {
+		t.Errorf("duration_ms = %v, want at least 100", record["duration_ms"])
+	}
+	if record["threshold_ms"] != float64(50) || record["code"] != "OK" {
+		t.Errorf("threshold_ms, code = %v, %v, want 50, OK", record["threshold_ms"], record["code"])
+	}
+
+	// Slow failures count too
+	call("/grpc.goodbye.Farewell/SayGoodbye", func(ctx context.Context, _ interface{}) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return nil, status.Error(codes.Unavailable, "down")
+	})
+	if got := calls.Count("/grpc.hello.Greeter/SayHello"); got != 1 {
+		t.Errorf("SayHello slow calls = %d, want 1", got)
+	}
+	if got := calls.Count("/grpc.goodbye.Farewell/SayGoodbye"); got != 1 {
+		t.Errorf("SayGoodbye slow calls = %d, want 1", got)
+	}
+	if got := calls.Total(); got != 2 {
+		t.Errorf("total slow calls = %d, want 2", got)
+	}
+}
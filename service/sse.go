@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// sseStream adapts a text/event-stream response to the server side of a
+// gRPC server streaming call, so REST clients can consume the same stream
+// handler. Each Send becomes a "message" event; headers are ignored and
+// trailers are kept for the terminal "done" event. The 200 response is only
+// sent with the first event, so a call the interceptors reject still gets a
+// plain JSON error.
+type sseStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	w       http.ResponseWriter
+	flusher http.Flusher
+	trailer metadata.MD
+	started bool
+}
+
+func (s *sseStream) Context() context.Context           { return s.ctx }
+func (s *sseStream) SetHeader(metadata.MD) error        { return nil }
+func (s *sseStream) SendHeader(metadata.MD) error       { return nil }
+func (s *sseStream) SetTrailer(md metadata.MD)          { s.trailer = metadata.Join(s.trailer, md) }
+func (s *sseStream) Send(m *goodbye.GoodbyeReply) error { return s.SendMsg(m) }
+
+func (s *sseStream) SendMsg(m interface{}) error {
+	reply, ok := m.(*goodbye.GoodbyeReply)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", m)
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.event("message", GoodbyeResponse{Message: reply.GetMessage()})
+}
+
+// start sends the event stream's 200 response headers, once.
+func (s *sseStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", "text/event-stream")
+	s.w.Header().Set("Cache-Control", "no-cache")
+	s.w.Header().Set("Connection", "keep-alive")
+	setServerHeaders(s.w, "SayGoodbyeStream")
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// event writes one SSE event with a JSON payload and flushes it to the client.
+func (s *sseStream) event(name string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	s.start()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// trailers flattens the collected trailers to their first value.
+func (s *sseStream) trailers() map[string]string {
+	flat := make(map[string]string, len(s.trailer))
+	for key, values := range s.trailer {
+		if len(values) > 0 {
+			flat[key] = values[0]
+		}
+	}
+	return flat
+}
+
+// handleSayGoodbyeStreamHTTP drives SayGoodbyeStream through the stream
+// interceptors of registry and pushes each farewell as a server-sent event,
+// ending with a "done" event carrying the stream trailers or an "error"
+// event. A call that fails before its first event gets a JSON error instead.
+// The stream stops when the client disconnects.
+func (s *GoodbyeServer) handleSayGoodbyeStreamHTTP(registry *Registry) http.HandlerFunc {
+	chain := registry.streamChain()
+	info := &grpc.StreamServerInfo{FullMethod: "/" + goodbye.Farewell_ServiceDesc.ServiceName + "/SayGoodbyeStream", IsServerStream: true}
+	return func(w http.ResponseWriter, r *http.Request) {
+		slog.InfoContext(r.Context(), "HTTP: Received SayGoodbyeStream request", "method", r.Method, "path", r.URL.Path)
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, http.StatusInternalServerError, codes.Internal, "streaming is not supported by this connection")
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = "Friend"
+		}
+		req := &goodbye.GoodbyeRequest{Name: name}
+		if err := validate(req); err != nil {
+			writeGRPCError(w, err)
+			return
+		}
+
+		// The stream outlives the REST deadlines; the client disconnecting is
+		// what ends it early. An expired read deadline would look like a
+		// disconnect, so both are cleared
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		ctx := metadata.NewIncomingContext(r.Context(), restIncomingMetadata(r))
+		stream := &sseStream{ctx: ctx, w: w, flusher: flusher}
+		handler := func(srv interface{}, ss grpc.ServerStream) error {
+			return s.SayGoodbyeStream(req, &grpc.GenericServerStream[goodbye.GoodbyeRequest, goodbye.GoodbyeReply]{ServerStream: ss})
+		}
+		var err error
+		if chain != nil {
+			err = chain(s, stream, info, handler)
+		} else {
+			err = handler(s, stream)
+		}
+		if err != nil {
+			if r.Context().Err() != nil {
+				slog.InfoContext(ctx, "HTTP: SayGoodbyeStream client disconnected", "name", name)
+				return
+			}
+			if !stream.started {
+				writeGRPCError(w, err)
+				return
+			}
+			stream.event("error", errorResponse(status.Convert(err)))
+			return
+		}
+		stream.event("done", map[string]interface{}{"trailers": stream.trailers()})
+	}
+}
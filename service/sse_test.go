@@ -0,0 +1,74 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// sseEvent is one parsed server-sent event.
+type sseEvent struct {
+	name string
+	data map[string]interface{}
+}
+
+// parseSSE splits an event stream body into its events.
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.data); err != nil {
+				t.Fatalf("event data %q is not JSON: %v", line, err)
+			}
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+func TestGoodbyeSSEStreamsFarewellsThenDone(t *testing.T) {
+	h := testRouter{goodbye: NewGoodbyeServer(WithGoodbyeStreamDelay(0))}.handler()
+	rec := serve(h, httptest.NewRequest("GET", "/api/goodbye/stream?name=Alice", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/goodbye/stream = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	events := parseSSE(t, rec.Body.String())
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 3 farewells and done:\n%s", len(events), rec.Body)
+	}
+	for _, event := range events[:3] {
+		message, _ := event.data["message"].(string)
+		if event.name != "message" || !strings.Contains(message, "Alice") {
+			t.Errorf("event = %s %v, want a message greeting Alice", event.name, event.data)
+		}
+	}
+	done := events[3]
+	trailers, _ := done.data["trailers"].(map[string]interface{})
+	if done.name != "done" || trailers["messages-sent"] != "3" || trailers["stream-status"] != "completed" {
+		t.Errorf("last event = %s %v, want done with the completed trailers", done.name, done.data)
+	}
+}
+
+func TestGoodbyeSSERejectsInvalidName(t *testing.T) {
+	h := testRouter{}.handler()
+	rec := serve(h, httptest.NewRequest("GET", "/api/goodbye/stream?name="+strings.Repeat("x", maxNameLen+1), nil))
+	if got := decodeBody(t, rec)["code"]; rec.Code != http.StatusBadRequest || got != "InvalidArgument" {
+		t.Errorf("stream with a long name = %d %v, want a 400 InvalidArgument JSON error", rec.Code, got)
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methodTimeout returns the limit for fullMethod, matching either the full
+// method name, e.g. "/grpc.hello.Greeter/SayHello", or the bare method name
+// "SayHello". The full name takes precedence.
+func methodTimeout(timeouts map[string]time.Duration, fullMethod string) (time.Duration, bool) {
+	if d, ok := timeouts[fullMethod]; ok {
+		return d, true
+	}
+	d, ok := timeouts[fullMethod[strings.LastIndex(fullMethod, "/")+1:]]
+	return d, ok
+}
+
+// timeoutError reports a call that ran past its server-side limit as
+// DeadlineExceeded. Errors from calls that finished in time, or that ended
+// because the caller's own context ended, are returned unchanged.
+func timeoutError(parent, ctx context.Context, limit time.Duration, err error) error {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) || parent.Err() != nil {
+		return err
+	}
+	return status.Errorf(codes.DeadlineExceeded, "call exceeded the server limit of %s", limit)
+}
+
+// MethodTimeoutInterceptor caps how long the methods in timeouts may run,
+// whatever deadline the client sent. The handler context is cancelled at the
+// limit, and the call fails with DeadlineExceeded. Keys are full or bare
+// method names; methods not listed are unaffected. It runs just inside
+// logging so timed-out calls are logged with their status.
+func MethodTimeoutInterceptor(timeouts map[string]time.Duration) Interceptor {
+	return Interceptor{
+		Name:  "method-timeout",
+		Stage: StageTimeout,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			limit, ok := methodTimeout(timeouts, info.FullMethod)
+			if !ok {
+				return handler(ctx, req)
+			}
+			callCtx, cancel := context.WithTimeout(ctx, limit)
+			defer cancel()
+
+			resp, err := handler(callCtx, req)
+			if err := timeoutError(ctx, callCtx, limit, err); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			limit, ok := methodTimeout(timeouts, info.FullMethod)
+			if !ok {
+				return handler(srv, ss)
+			}
+			callCtx, cancel := context.WithTimeout(ss.Context(), limit)
+			defer cancel()
+
+			err := handler(srv, &contextServerStream{ServerStream: ss, ctx: callCtx})
+			return timeoutError(ss.Context(), callCtx, limit, err)
+		},
+	}
+}
+
+// checkDeadline logs a warning when the deadline of a call to method leaves
+// less than floor, and fails it with InvalidArgument as well when reject is
+// set. Calls without a deadline pass, as do health and reflection calls,
+// like they do required metadata.
+func checkDeadline(ctx context.Context, method string, floor time.Duration, reject bool) error {
+	deadline, ok := ctx.Deadline()
+	if !ok || exemptFromRequiredMetadata(method) {
+		return nil
+	}
+	remaining := time.Until(deadline)
+	if remaining >= floor {
+		return nil
+	}
+	slog.WarnContext(ctx, "gRPC: Deadline below floor", "grpc_method", method,
+		"deadline_ms", remaining.Milliseconds(), "floor_ms", floor.Milliseconds(), "rejected", reject)
+	if reject {
+		return status.Errorf(codes.InvalidArgument, "deadline too short: %s left, the server needs at least %s",
+			remaining.Round(time.Millisecond), floor)
+	}
+	return nil
+}
+
+// MinDeadlineInterceptor warns about calls whose client deadline leaves less
+// than floor, so tight deadlines show up before they turn into
+// DeadlineExceeded failures. With reject set such calls fail with
+// InvalidArgument before the handler runs. It runs ahead of method-timeout,
+// which would otherwise shorten the deadline it inspects.
+func MinDeadlineInterceptor(floor time.Duration, reject bool) Interceptor {
+	return Interceptor{
+		Name:  "min-deadline",
+		Stage: StageTimeout,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := checkDeadline(ctx, info.FullMethod, floor, reject); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkDeadline(ss.Context(), info.FullMethod, floor, reject); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		},
+	}
+}
+
+// RouteTimeouts bounds the context REST handlers pass to the gRPC methods
+// behind them, so each route can give its backend a different limit.
+type RouteTimeouts struct {
+	// Routes maps a route's path template, e.g. "/api/hello" or
+	// "/v1/goodbye", to its limit.
+	Routes map[string]time.Duration
+	// Default applies to routes not in Routes; zero leaves them unbounded.
+	Default time.Duration
+}
+
+// limit returns the limit for the route with the given path template, or
+// zero when it has none.
+func (t RouteTimeouts) limit(route string) time.Duration {
+	if d, ok := t.Routes[route]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// routeTimeoutMiddleware cancels the request context at the limit of the
+// matched route. Handlers derive their backend context from the request, so
+// a call still running at the limit fails with DeadlineExceeded, answered
+// with 504 Gateway Timeout. Streaming routes, such as the SSE endpoint, are
+// bounded too when they have a limit.
+func routeTimeoutMiddleware(timeouts RouteTimeouts) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var route string
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+			limit := timeouts.limit(route)
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), limit)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
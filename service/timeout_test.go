@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// slowHandler waits for its context to end, or returns "done" after d.
+func slowHandler(d time.Duration) grpc.UnaryHandler {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(d):
+			return "done", nil
+		}
+	}
+}
+
+func TestMethodTimeoutCutsOffSlowHandler(t *testing.T) {
+	unary := MethodTimeoutInterceptor(map[string]time.Duration{"SayHello": 50 * time.Millisecond}).Unary
+	call := func(ctx context.Context, method string, handler grpc.UnaryHandler) (interface{}, error) {
+		return unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	start := time.Now()
+	_, err := call(context.Background(), "/grpc.hello.Greeter/SayHello", slowHandler(time.Hour))
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("slow SayHello = %v, want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("slow SayHello ran %s past a 50ms limit", elapsed)
+	}
+
+	if resp, err := call(context.Background(), "/grpc.hello.Greeter/SayHello", slowHandler(time.Millisecond)); err != nil || resp != "done" {
+		t.Errorf("fast SayHello = %v, %v, want done", resp, err)
+	}
+	if resp, err := call(context.Background(), "/grpc.goodbye.Farewell/SayGoodbye", slowHandler(100*time.Millisecond)); err != nil || resp != "done" {
+		t.Errorf("unlisted method = %v, %v, want it to run to completion", resp, err)
+	}
+
+	// A caller that gives up first gets its own error, not the server limit
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := call(ctx, "/grpc.hello.Greeter/SayHello", slowHandler(time.Hour)); status.Code(err) != codes.Canceled {
+		t.Errorf("cancelled SayHello = %v, want Canceled", err)
+	}
+}
+
+func TestMethodTimeoutCutsOffSlowStream(t *testing.T) {
+	stream := MethodTimeoutInterceptor(map[string]time.Duration{"SayHelloStream": 50 * time.Millisecond}).Stream
+	ss := &fakeStream{ctx: context.Background()}
+	err := stream(nil, ss, &grpc.StreamServerInfo{FullMethod: "/grpc.hello.Greeter/SayHelloStream"}, func(_ interface{}, ss grpc.ServerStream) error {
+		<-ss.Context().Done()
+		return ss.Context().Err()
+	})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("slow stream = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestMethodTimeoutFullNameTakesPrecedence(t *testing.T) {
+	timeouts := map[string]time.Duration{
+		"SayHello":                     time.Second,
+		"/grpc.hello.Greeter/SayHello": 2 * time.Second,
+	}
+	if d, ok := methodTimeout(timeouts, "/grpc.hello.Greeter/SayHello"); !ok || d != 2*time.Second {
+		t.Errorf("full name = %s, %v, want 2s", d, ok)
+	}
+	if d, ok := methodTimeout(timeouts, "/other.Greeter/SayHello"); !ok || d != time.Second {
+		t.Errorf("bare name = %s, %v, want 1s", d, ok)
+	}
+	if _, ok := methodTimeout(timeouts, "/grpc.hello.Greeter/SayHelloStream"); ok {
+		t.Error("unlisted method has a limit")
+	}
+}
+
+func TestRouteTimeoutsApplyPerRoute(t *testing.T) {
+	// Every call takes 200ms, through an injected delay that honors the
+	// backend context
+	helloSrv := NewHelloServer(WithFaultInjection(true))
+	slow := func(req *http.Request) *http.Request {
+		req.Header.Set("Grpc-Metadata-Inject-Delay-Ms", "200")
+		return req
+	}
+	h := testRouter{hello: helloSrv, timeouts: RouteTimeouts{
+		Routes: map[string]time.Duration{"/api/hello": 50 * time.Millisecond, "/v1/hello": 2 * time.Second},
+	}}.handler()
+
+	if rec := serve(h, slow(httptest.NewRequest("GET", "/api/hello?name=World", nil))); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("GET /api/hello with a 50ms limit = %d, want 504", rec.Code)
+	}
+	if rec := serve(h, slow(postJSON("/v1/hello", `{"name": "World"}`))); rec.Code != http.StatusOK {
+		t.Errorf("POST /v1/hello with a 2s limit = %d, want 200", rec.Code)
+	}
+
+	// Routes without their own limit fall back to the default
+	h = testRouter{hello: helloSrv, timeouts: RouteTimeouts{
+		Routes:  map[string]time.Duration{"/api/hello": 2 * time.Second},
+		Default: 50 * time.Millisecond,
+	}}.handler()
+	if rec := serve(h, slow(httptest.NewRequest("GET", "/api/hello?name=World", nil))); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/hello with a 2s limit = %d, want 200", rec.Code)
+	}
+	if rec := serve(h, slow(postJSON("/v1/hello", `{"name": "World"}`))); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("POST /v1/hello with the 50ms default = %d, want 504", rec.Code)
+	}
+}
+
+func TestMinDeadlineWarnsOrRejects(t *testing.T) {
+	const method = "/grpc.hello.Greeter/SayHello"
+	info := &grpc.UnaryServerInfo{FullMethod: method}
+	var ran bool
+	handler := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		ran = true
+		return "done", nil
+	}
+	withDeadline := func(d time.Duration) context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), d)
+		t.Cleanup(cancel)
+		return ctx
+	}
+
+	// Warn mode lets the call through
+	logs := captureLogs(t)
+	warn := MinDeadlineInterceptor(100*time.Millisecond, false).Unary
+	if resp, err := warn(withDeadline(time.Millisecond), nil, info, handler); err != nil || resp != "done" || !ran {
+		t.Errorf("call with a 1ms deadline in warn mode = %v, %v, want it run", resp, err)
+	}
+	record := logRecord(t, logs, "gRPC: Deadline below floor")
+	if record["level"] != "WARN" || record["grpc_method"] != method || record["floor_ms"] != float64(100) || record["rejected"] != false {
+		t.Errorf("warning = %v, want a WARN for SayHello with floor_ms 100, not rejected", record)
+	}
+
+	// Reject mode fails the call before the handler runs
+	logs = captureLogs(t)
+	ran = false
+	reject := MinDeadlineInterceptor(100*time.Millisecond, true).Unary
+	_, err := reject(withDeadline(time.Millisecond), nil, info, handler)
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(status.Convert(err).Message(), "deadline too short") {
+		t.Errorf("call with a 1ms deadline in reject mode = %v, want InvalidArgument deadline too short", err)
+	}
+	if ran {
+		t.Error("handler ran for a rejected call")
+	}
+	if record := logRecord(t, logs, "gRPC: Deadline below floor"); record["rejected"] != true {
+		t.Errorf("rejected = %v, want true", record["rejected"])
+	}
+
+	// Roomy deadlines, no deadline at all and health checks pass quietly
+	logs = captureLogs(t)
+	if _, err := reject(withDeadline(time.Minute), nil, info, handler); err != nil {
+		t.Errorf("call with a 1m deadline = %v, want it run", err)
+	}
+	if _, err := reject(context.Background(), nil, info, handler); err != nil {
+		t.Errorf("call without a deadline = %v, want it run", err)
+	}
+	healthInfo := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+	if _, err := reject(withDeadline(time.Millisecond), nil, healthInfo, handler); err != nil {
+		t.Errorf("health check with a 1ms deadline = %v, want it exempt", err)
+	}
+	if records := logRecords(t, logs, "gRPC: Deadline below floor"); len(records) != 0 {
+		t.Errorf("logged %d deadline warnings for calls within the floor or exempt, want none", len(records))
+	}
+
+	stream := MinDeadlineInterceptor(100*time.Millisecond, true).Stream
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/grpc.hello.Greeter/SayHelloStream", IsServerStream: true}
+	err = stream(nil, &fakeStream{ctx: withDeadline(time.Millisecond)}, streamInfo, func(interface{}, grpc.ServerStream) error {
+		t.Error("stream handler ran for a rejected stream")
+		return nil
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("stream with a 1ms deadline in reject mode = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// TLSPolicy is the transport security a gRPC call's connection must meet.
+// The minimum TLS version is not part of it: tls.Config.MinVersion already
+// refuses older handshakes.
+type TLSPolicy struct {
+	// RequireTLS rejects calls over plaintext connections. When false,
+	// plaintext calls pass unchecked.
+	RequireTLS bool
+}
+
+// connectionState returns the TLS state of the connection a call arrived on,
+// or false for plaintext connections.
+func connectionState(ctx context.Context) (tls.ConnectionState, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return info.State, true
+}
+
+// checkTLS applies policy to the connection of a call to method, logging the
+// negotiated version and cipher suite, and fails with
+// codes.PermissionDenied if the connection falls short.
+func checkTLS(ctx context.Context, method string, policy TLSPolicy) error {
+	state, ok := connectionState(ctx)
+	if !ok {
+		if policy.RequireTLS {
+			slog.WarnContext(ctx, "gRPC: Rejected plaintext call", "method", method)
+			return status.Error(codes.PermissionDenied, "TLS is required")
+		}
+		return nil
+	}
+	slog.DebugContext(ctx, "gRPC: TLS connection", "method", method,
+		"tls_version", tls.VersionName(state.Version), "cipher_suite", tls.CipherSuiteName(state.CipherSuite))
+	return nil
+}
+
+// TLSPolicyInterceptor logs the TLS version and cipher suite of each call at
+// debug level, recording what clients negotiated, and rejects plaintext
+// calls with codes.PermissionDenied when policy requires TLS. NewServer
+// installs it only when TLS is enabled or required. It runs at the auth
+// stage.
+func TLSPolicyInterceptor(policy TLSPolicy) Interceptor {
+	return Interceptor{
+		Name:  "tls-policy",
+		Stage: StageAuth,
+		Unary: func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := checkTLS(ctx, info.FullMethod, policy); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		},
+		Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkTLS(ss.Context(), info.FullMethod, policy); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		},
+	}
+}
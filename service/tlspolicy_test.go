@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+// to dir and returns their paths.
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "grpc-sample test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCheckTLS(t *testing.T) {
+	plaintext := peer.NewContext(context.Background(), &peer.Peer{})
+	secure := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{Version: tls.VersionTLS13, CipherSuite: tls.TLS_AES_128_GCM_SHA256}},
+	})
+
+	if err := checkTLS(plaintext, "/m", TLSPolicy{}); err != nil {
+		t.Errorf("plaintext call without RequireTLS: %v, want nil", err)
+	}
+	if err := checkTLS(plaintext, "/m", TLSPolicy{RequireTLS: true}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("plaintext call with RequireTLS: %v, want PermissionDenied", err)
+	}
+	if err := checkTLS(secure, "/m", TLSPolicy{RequireTLS: true}); err != nil {
+		t.Errorf("TLS call with RequireTLS: %v, want nil", err)
+	}
+}
+
+func TestTLSServerRejectsOldClients(t *testing.T) {
+	cfg := config.Default()
+	cfg.ListenAddr = "127.0.0.1:0"
+	cfg.TLSCertFile, cfg.TLSKeyFile = writeTestCert(t, t.TempDir())
+	s := startServer(t, cfg)
+	addr := s.Addr().String()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sayHello := func(maxVersion uint16) error {
+		// crypto/tls clients only offer TLS 1.2 and up unless told otherwise
+		creds := credentials.NewTLS(&tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS10, MaxVersion: maxVersion})
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			t.Fatalf("dialing %s: %v", addr, err)
+		}
+		defer conn.Close()
+		_, err = hello.NewGreeterClient(conn).SayHello(ctx, &hello.HelloRequest{Name: "World"})
+		return err
+	}
+
+	err := sayHello(tls.VersionTLS11)
+	if status.Code(err) != codes.Unavailable || !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("SayHello over TLS 1.1: %v, want the handshake refused for its protocol version", err)
+	}
+	if err := sayHello(tls.VersionTLS12); err != nil {
+		t.Errorf("SayHello over TLS 1.2: %v", err)
+	}
+}
+
+func TestTLSPolicyInstalledOnlyWhenNeeded(t *testing.T) {
+	installed := func(cfg config.Config) bool {
+		ics, err := newServerInterceptors(cfg)
+		if err != nil {
+			t.Fatalf("newServerInterceptors: %v", err)
+		}
+		return ics.registry.has("tls-policy")
+	}
+
+	if installed(config.Default()) {
+		t.Error("tls-policy installed on a plaintext server")
+	}
+	cfg := config.Default()
+	cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+	if !installed(cfg) {
+		t.Error("tls-policy not installed with TLS enabled")
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Transcoded routes expose every gRPC method as POST on the path listed for
+// it in transcodedRoutes, such as /v1/hello for SayHello. Request and response
+// bodies are the proto messages in protojson form. Methods with a streamed
+// request read a sequence of JSON objects from the body; methods with a
+// streamed response write newline-delimited JSON.
+//
+// Headers prefixed with Grpc-Metadata- and the Authorization and X-Request-ID
+// headers are passed to the method as incoming metadata. Response headers and
+// trailers come back as Grpc-Metadata-<key> and Grpc-Trailer-<key>.
+//
+// Calls run through the same interceptors as native gRPC calls. Streaming
+// methods are exempt from the REST request deadlines.
+
+// transcodedRoutes maps the full name of each gRPC method to its REST path.
+// The paths are public URLs, so they are spelled out here and next to each
+// rpc in the .proto files rather than derived from the method names:
+// renaming an RPC must not move its route.
+var transcodedRoutes = map[string]string{
+	"/grpc.hello.Greeter/SayHello":              "/v1/hello",
+	"/grpc.hello.Greeter/SayHelloStream":        "/v1/hello-stream",
+	"/grpc.hello.Greeter/SayHelloClientStream":  "/v1/hello-client-stream",
+	"/grpc.hello.Greeter/SayHelloBidirectional": "/v1/hello-bidirectional",
+	"/grpc.hello.Greeter/SayHelloInLanguage":    "/v1/hello-in-language",
+	"/grpc.hello.Greeter/SayHelloAggregate":     "/v1/hello-aggregate",
+
+	"/grpc.goodbye.Farewell/SayGoodbye":               "/v1/goodbye",
+	"/grpc.goodbye.Farewell/SayGoodbyeStream":         "/v1/goodbye-stream",
+	"/grpc.goodbye.Farewell/SayGoodbyeClientStream":   "/v1/goodbye-client-stream",
+	"/grpc.goodbye.Farewell/SayGoodbyeBidirectional":  "/v1/goodbye-bidirectional",
+	"/grpc.goodbye.Farewell/SayGoodbyeWithReason":     "/v1/goodbye-with-reason",
+	"/grpc.goodbye.Farewell/SayGoodbyeUntilCancelled": "/v1/goodbye-until-cancelled",
+}
+
+// registerTranscodedRoutes adds a transcoded route for each method of desc
+// listed in transcodedRoutes, served by impl through the interceptors in
+// registry, which may be nil.
+func registerTranscodedRoutes(router *mux.Router, registry *Registry, desc *grpc.ServiceDesc, impl interface{}) {
+	unary, stream := registry.unaryChain(), registry.streamChain()
+
+	for _, m := range desc.Methods {
+		m := m
+		fullMethod := "/" + desc.ServiceName + "/" + m.MethodName
+		path, ok := transcodedRoutes[fullMethod]
+		if !ok {
+			slog.Warn("No REST route for gRPC method, not transcoding it", "method", fullMethod)
+			continue
+		}
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			call := newTranscodedCall(w, r, fullMethod, false)
+			dec := func(msg interface{}) error { return call.recv(msg) }
+			resp, err := m.Handler(impl, call.ctx, dec, unary)
+			if err != nil {
+				call.fail(err)
+				return
+			}
+			// The whole response is known, so trailers can go out as headers
+			call.promoteTrailers()
+			if err := call.send(resp); err != nil {
+				call.fail(err)
+			}
+		}).Methods("POST")
+	}
+
+	for _, s := range desc.Streams {
+		s := s
+		fullMethod := "/" + desc.ServiceName + "/" + s.StreamName
+		path, ok := transcodedRoutes[fullMethod]
+		if !ok {
+			slog.Warn("No REST route for gRPC method, not transcoding it", "method", fullMethod)
+			continue
+		}
+		info := &grpc.StreamServerInfo{FullMethod: fullMethod, IsClientStream: s.ClientStreams, IsServerStream: s.ServerStreams}
+		router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			// Like the SSE endpoint, streams are not bound by the REST
+			// request deadlines
+			rc := http.NewResponseController(w)
+			rc.SetReadDeadline(time.Time{})
+			rc.SetWriteDeadline(time.Time{})
+
+			call := newTranscodedCall(w, r, fullMethod, s.ClientStreams)
+			ss := &transcodedServerStream{call}
+			var err error
+			if stream != nil {
+				err = stream(impl, ss, info, s.Handler)
+			} else {
+				err = s.Handler(impl, ss)
+			}
+			if err != nil {
+				call.fail(err)
+				return
+			}
+			call.finish()
+		}).Methods("POST")
+	}
+}
+
+// transcodedCall carries one gRPC call over an HTTP request and response.
+type transcodedCall struct {
+	ctx    context.Context
+	method string
+	w      http.ResponseWriter
+	body   *json.Decoder
+	// clientStreams is set when the method reads a stream of requests; the
+	// other methods treat an empty body as an empty request.
+	clientStreams bool
+
+	mu       sync.Mutex
+	received bool
+	header   metadata.MD
+	trailer  metadata.MD
+	// headerSent is set by SendHeader; the HTTP headers themselves are only
+	// written with the first message, or with the error or trailers, so a
+	// unary response can still carry its trailers as headers.
+	headerSent  bool
+	wroteHeader bool
+}
+
+func newTranscodedCall(w http.ResponseWriter, r *http.Request, method string, clientStreams bool) *transcodedCall {
+	c := &transcodedCall{
+		method:        method,
+		w:             w,
+		body:          json.NewDecoder(r.Body),
+		clientStreams: clientStreams,
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), restIncomingMetadata(r))
+	c.ctx = grpc.NewContextWithServerTransportStream(ctx, &transcodedTransportStream{c})
+	return c
+}
+
+// recv decodes the next JSON object of the request body into msg.
+func (c *transcodedCall) recv(msg interface{}) error {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", msg)
+	}
+	first := !c.received
+	c.received = true
+
+	var raw json.RawMessage
+	if err := c.body.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			if first && !c.clientStreams {
+				return nil
+			}
+			return io.EOF
+		}
+		if errors.As(err, new(*http.MaxBytesError)) {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return status.Errorf(codes.InvalidArgument, "invalid JSON: %v", err)
+	}
+	if err := protojson.Unmarshal(raw, m); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid %s: %v", m.ProtoReflect().Descriptor().Name(), err)
+	}
+	return nil
+}
+
+// send writes msg as one line of JSON, sending the response headers first.
+func (c *transcodedCall) send(msg interface{}) error {
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", msg)
+	}
+	data, err := protojson.Marshal(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "encoding response: %v", err)
+	}
+	if err := c.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeaderLocked()
+	if _, err := c.w.Write(append(data, '\n')); err != nil {
+		return status.Errorf(codes.Unavailable, "writing response: %v", err)
+	}
+	http.NewResponseController(c.w).Flush()
+	return nil
+}
+
+// writeHeaderLocked writes the status line and headers once. Trailers are
+// announced so they can follow a streamed body.
+func (c *transcodedCall) writeHeaderLocked() {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	h := c.w.Header()
+	h.Set("Content-Type", "application/json")
+	setServerHeaders(c.w, c.method[strings.LastIndex(c.method, "/")+1:])
+	for key, values := range c.header {
+		for _, v := range values {
+			h.Add("Grpc-Metadata-"+key, v)
+		}
+	}
+	c.w.WriteHeader(http.StatusOK)
+}
+
+// promoteTrailers moves the trailers collected so far into the response
+// headers as Grpc-Trailer-<key>, for responses whose body is a single message.
+func (c *transcodedCall) promoteTrailers() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, values := range c.trailer {
+		for _, v := range values {
+			c.w.Header().Add("Grpc-Trailer-"+key, v)
+		}
+	}
+	c.trailer = nil
+}
+
+// finish sends any headers not yet written and the trailers.
+func (c *transcodedCall) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeaderLocked()
+	c.writeTrailerLocked()
+}
+
+func (c *transcodedCall) writeTrailerLocked() {
+	for key, values := range c.trailer {
+		for _, v := range values {
+			c.w.Header().Add(http.TrailerPrefix+"Grpc-Trailer-"+key, v)
+		}
+	}
+}
+
+// fail reports err: as an ErrorResponse with the matching HTTP status when
+// nothing has been written yet, otherwise as a final {"error": ...} line.
+func (c *transcodedCall) fail(err error) {
+	st := status.Convert(err)
+	slog.WarnContext(c.ctx, "HTTP: Transcoded call failed", "method", c.method, "code", st.Code().String(), "error", st.Message())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.wroteHeader {
+		json.NewEncoder(c.w).Encode(map[string]ErrorResponse{
+			"error": errorResponse(st),
+		})
+		c.writeTrailerLocked()
+		return
+	}
+	for key, values := range c.header {
+		for _, v := range values {
+			c.w.Header().Add("Grpc-Metadata-"+key, v)
+		}
+	}
+	for key, values := range c.trailer {
+		for _, v := range values {
+			c.w.Header().Add("Grpc-Trailer-"+key, v)
+		}
+	}
+	c.wroteHeader = true
+	writeGRPCError(c.w, err)
+}
+
+func (c *transcodedCall) setHeader(md metadata.MD) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.headerSent || c.wroteHeader {
+		return errors.New("transcode: headers already sent")
+	}
+	c.header = metadata.Join(c.header, md)
+	return nil
+}
+
+func (c *transcodedCall) sendHeader(md metadata.MD) error {
+	if err := c.setHeader(md); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headerSent = true
+	return nil
+}
+
+func (c *transcodedCall) setTrailer(md metadata.MD) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trailer = metadata.Join(c.trailer, md)
+}
+
+// transcodedTransportStream lets unary handlers and interceptors use
+// grpc.SetHeader, grpc.SendHeader and grpc.SetTrailer.
+type transcodedTransportStream struct {
+	call *transcodedCall
+}
+
+func (s *transcodedTransportStream) Method() string                  { return s.call.method }
+func (s *transcodedTransportStream) SetHeader(md metadata.MD) error  { return s.call.setHeader(md) }
+func (s *transcodedTransportStream) SendHeader(md metadata.MD) error { return s.call.sendHeader(md) }
+func (s *transcodedTransportStream) SetTrailer(md metadata.MD) error {
+	s.call.setTrailer(md)
+	return nil
+}
+
+// transcodedServerStream is the grpc.ServerStream handed to stream handlers.
+type transcodedServerStream struct {
+	call *transcodedCall
+}
+
+func (s *transcodedServerStream) Context() context.Context        { return s.call.ctx }
+func (s *transcodedServerStream) SetHeader(md metadata.MD) error  { return s.call.setHeader(md) }
+func (s *transcodedServerStream) SendHeader(md metadata.MD) error { return s.call.sendHeader(md) }
+func (s *transcodedServerStream) SetTrailer(md metadata.MD)       { s.call.setTrailer(md) }
+func (s *transcodedServerStream) SendMsg(m interface{}) error     { return s.call.send(m) }
+func (s *transcodedServerStream) RecvMsg(m interface{}) error     { return s.call.recv(m) }
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// The transcoded paths are public URLs, so they are pinned here: a change
+// to one must be deliberate.
+var wantTranscodedRoutes = map[string]string{
+	"/grpc.hello.Greeter/SayHello":                    "/v1/hello",
+	"/grpc.hello.Greeter/SayHelloStream":              "/v1/hello-stream",
+	"/grpc.hello.Greeter/SayHelloClientStream":        "/v1/hello-client-stream",
+	"/grpc.hello.Greeter/SayHelloBidirectional":       "/v1/hello-bidirectional",
+	"/grpc.hello.Greeter/SayHelloInLanguage":          "/v1/hello-in-language",
+	"/grpc.hello.Greeter/SayHelloAggregate":           "/v1/hello-aggregate",
+	"/grpc.goodbye.Farewell/SayGoodbye":               "/v1/goodbye",
+	"/grpc.goodbye.Farewell/SayGoodbyeStream":         "/v1/goodbye-stream",
+	"/grpc.goodbye.Farewell/SayGoodbyeClientStream":   "/v1/goodbye-client-stream",
+	"/grpc.goodbye.Farewell/SayGoodbyeBidirectional":  "/v1/goodbye-bidirectional",
+	"/grpc.goodbye.Farewell/SayGoodbyeWithReason":     "/v1/goodbye-with-reason",
+	"/grpc.goodbye.Farewell/SayGoodbyeUntilCancelled": "/v1/goodbye-until-cancelled",
+}
+
+func TestTranscodedRoutesArePinned(t *testing.T) {
+	if !maps.Equal(transcodedRoutes, wantTranscodedRoutes) {
+		t.Errorf("transcodedRoutes = %v, want %v", transcodedRoutes, wantTranscodedRoutes)
+	}
+
+	// A cancelled request context ends the streams, such as
+	// SayGoodbyeUntilCancelled, as soon as they start
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h := testRouter{}.handler()
+	for _, desc := range []*grpc.ServiceDesc{&hello.Greeter_ServiceDesc, &goodbye.Farewell_ServiceDesc} {
+		var names []string
+		for _, m := range desc.Methods {
+			names = append(names, m.MethodName)
+		}
+		for _, s := range desc.Streams {
+			names = append(names, s.StreamName)
+		}
+		for _, name := range names {
+			fullMethod := "/" + desc.ServiceName + "/" + name
+			path, ok := wantTranscodedRoutes[fullMethod]
+			if !ok {
+				t.Errorf("%s has no pinned REST route", fullMethod)
+				continue
+			}
+			rec := serve(h, postJSON(path, `{"name": "World"}`).WithContext(ctx))
+			if rec.Code == http.StatusNotFound || rec.Code == http.StatusMethodNotAllowed {
+				t.Errorf("POST %s for %s = %d, want the route served", path, fullMethod, rec.Code)
+			}
+		}
+	}
+}
+
+func TestProtoFilesListTranscodedRoutes(t *testing.T) {
+	rpc := regexp.MustCompile(`^\s*rpc (\w+) `)
+	note := regexp.MustCompile(`^\s*// REST: POST (\S+)$`)
+	for file, service := range map[string]string{
+		"../proto/hello/hello.proto":     hello.Greeter_ServiceDesc.ServiceName,
+		"../proto/goodbye/goodbye.proto": goodbye.Farewell_ServiceDesc.ServiceName,
+	} {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+		var documented string
+		for _, line := range strings.Split(string(data), "\n") {
+			if m := note.FindStringSubmatch(line); m != nil {
+				documented = m[1]
+				continue
+			}
+			m := rpc.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			fullMethod := "/" + service + "/" + m[1]
+			if want := transcodedRoutes[fullMethod]; documented != want {
+				t.Errorf("%s documents %s as %q, want %q", file, fullMethod, documented, want)
+			}
+			documented = ""
+		}
+	}
+}
+
+func TestTranscodedHelloMatchesGRPC(t *testing.T) {
+	srv := NewHelloServer()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	want, err := hello.NewGreeterClient(dialServices(t, srv, nil)).SayHello(ctx, &hello.HelloRequest{Name: "World", Language: "fr"})
+	if err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+
+	rec := serve(testRouter{hello: srv}.handler(), postJSON("/v1/hello", `{"name": "World", "language": "fr"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /v1/hello = %d: %s", rec.Code, rec.Body)
+	}
+	got := new(hello.HelloReply)
+	if err := protojson.Unmarshal(rec.Body.Bytes(), got); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body, err)
+	}
+	if got.GetMessage() != want.GetMessage() {
+		t.Errorf("POST /v1/hello = %q, gRPC SayHello = %q", got.GetMessage(), want.GetMessage())
+	}
+	if rec.Header().Get("Grpc-Metadata-Server-Name") == "" {
+		t.Error("response header metadata not returned as Grpc-Metadata-Server-Name")
+	}
+}
+
+func TestTranscodedServerStreamWritesNDJSON(t *testing.T) {
+	req := postJSON("/v1/hello-stream", `{"name": "World"}`)
+	req.Header.Set("Grpc-Metadata-Stream-Count", "2")
+	req.Header.Set("Grpc-Metadata-Stream-Delay-Ms", "1")
+	rec := serve(testRouter{}.handler(), req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /v1/hello-stream = %d: %s", rec.Code, rec.Body)
+	}
+
+	var lines int
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var reply map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		if reply["message"] == nil {
+			t.Errorf("line %q has no message", scanner.Text())
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("got %d lines, want 2", lines)
+	}
+}
+
+func TestTranscodedRoutesCoverEveryMethod(t *testing.T) {
+	h := testRouter{}.handler()
+	for _, path := range []string{"/v1/hello", "/v1/hello-bidirectional", "/v1/goodbye", "/v1/goodbye-stream"} {
+		rec := serve(h, httptest.NewRequest("GET", path, nil))
+		if rec.Code == http.StatusNotFound {
+			t.Errorf("GET %s = 404, want the route to exist", path)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Field length limits, as documented in hello.proto and goodbye.proto.
+const (
+	maxNameLen     = 100
+	maxLanguageLen = 35
+	maxReasonLen   = 200
+)
+
+// fieldError describes the first field of a request message that broke its
+// rules.
+type fieldError struct {
+	message string
+	field   string
+	reason  string
+}
+
+func (e fieldError) Error() string {
+	return fmt.Sprintf("invalid %s.%s: %s", e.message, e.field, e.reason)
+}
+
+// checkName enforces the 1-100 rune rule every request's name field shares.
+func checkName(message, name string) error {
+	if l := utf8.RuneCountInString(name); l < 1 || l > maxNameLen {
+		return fieldError{message, "Name", fmt.Sprintf("value length must be between 1 and %d runes, inclusive", maxNameLen)}
+	}
+	return nil
+}
+
+// checkMaxLen enforces an upper bound on an optional field.
+func checkMaxLen(message, field, value string, max int) error {
+	if utf8.RuneCountInString(value) > max {
+		return fieldError{message, field, fmt.Sprintf("value length must be at most %d runes", max)}
+	}
+	return nil
+}
+
+// validateFields returns a fieldError for the first rule msg breaks. The
+// rules live here rather than in the proto packages because those hold only
+// protoc output; messages without rules always pass.
+func validateFields(msg interface{}) error {
+	switch m := msg.(type) {
+	case *hello.HelloRequest:
+		if err := checkName("HelloRequest", m.GetName()); err != nil {
+			return err
+		}
+		return checkMaxLen("HelloRequest", "Language", m.GetLanguage(), maxLanguageLen)
+	case *goodbye.GoodbyeRequest:
+		return checkName("GoodbyeRequest", m.GetName())
+	case *goodbye.GoodbyeWithReasonRequest:
+		if err := checkName("GoodbyeWithReasonRequest", m.GetName()); err != nil {
+			return err
+		}
+		return checkMaxLen("GoodbyeWithReasonRequest", "Reason", m.GetReason(), maxReasonLen)
+	}
+	return nil
+}
+
+// validate returns codes.InvalidArgument naming the offending field when msg
+// breaks its field rules. Messages without rules always pass.
+func validate(msg interface{}) error {
+	if err := validateFields(msg); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// UnaryValidationInterceptor rejects invalid requests before they reach the
+// handler.
+func UnaryValidationInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := validate(req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamValidationInterceptor validates every message received on a stream;
+// the handler sees an invalid message as a codes.InvalidArgument error from
+// Recv.
+func StreamValidationInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &validatingStream{ServerStream: ss})
+}
+
+// validatingStream validates each message after it is received.
+type validatingStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(m)
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"grpc-sample/proto/goodbye"
+	"grpc-sample/proto/hello"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     interface{}
+		wantErr string
+	}{
+		{"hello ok", &hello.HelloRequest{Name: "World", Language: "fr"}, ""},
+		{"hello empty name", &hello.HelloRequest{}, "invalid HelloRequest.Name"},
+		{"hello long name", &hello.HelloRequest{Name: strings.Repeat("a", maxNameLen+1)}, "invalid HelloRequest.Name"},
+		{"hello multibyte name at limit", &hello.HelloRequest{Name: strings.Repeat("é", maxNameLen)}, ""},
+		{"hello long language", &hello.HelloRequest{Name: "World", Language: strings.Repeat("x", maxLanguageLen+1)}, "invalid HelloRequest.Language"},
+		{"goodbye ok", &goodbye.GoodbyeRequest{Name: "World"}, ""},
+		{"goodbye empty name", &goodbye.GoodbyeRequest{}, "invalid GoodbyeRequest.Name"},
+		{"reason ok", &goodbye.GoodbyeWithReasonRequest{Name: "World", Reason: "done"}, ""},
+		{"reason too long", &goodbye.GoodbyeWithReasonRequest{Name: "World", Reason: strings.Repeat("r", maxReasonLen+1)}, "invalid GoodbyeWithReasonRequest.Reason"},
+		{"message without rules", &hello.HelloReply{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(tt.msg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate: %v, want nil", err)
+				}
+				return
+			}
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("code = %v, want InvalidArgument", status.Code(err))
+			}
+			if msg := status.Convert(err).Message(); !strings.HasPrefix(msg, tt.wantErr) {
+				t.Errorf("message = %q, want prefix %q", msg, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"grpc-sample/proto/hello"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// maxWebSocketMessageBytes caps the WebSocket messages /ws/hello accepts.
+// Each one is a single name, so anything larger is a mistake or abuse.
+const maxWebSocketMessageBytes = 64 << 10
+
+// wsSubprotocol is the WebSocket subprotocol /ws/hello answers with.
+// Browsers cannot set headers on a WebSocket, so they send their API key as
+// a second subprotocol, wsBearerPrefix followed by the key, and offer
+// wsSubprotocol alongside it for the server to echo back.
+const (
+	wsSubprotocol  = "grpc-sample"
+	wsBearerPrefix = "bearer."
+)
+
+// wsEnd is the last message of a /ws/hello conversation: the stream
+// trailers, and the error that ended the stream if it failed.
+type wsEnd struct {
+	Trailers map[string]string `json:"trailers"`
+	Error    *ErrorResponse    `json:"error,omitempty"`
+}
+
+// wsHelloStream adapts a WebSocket connection to the server side of a
+// SayHelloBidirectional call, so browsers can hold the same conversation as
+// gRPC clients. Each message received is one name, handed to the handler as
+// a HelloRequest; each reply goes back as a HelloResponse in JSON. Headers
+// are ignored and trailers are kept for the final wsEnd message.
+type wsHelloStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	cancel  context.CancelFunc
+	conn    *websocket.Conn
+	trailer metadata.MD
+}
+
+func (s *wsHelloStream) Context() context.Context     { return s.ctx }
+func (s *wsHelloStream) SetHeader(metadata.MD) error  { return nil }
+func (s *wsHelloStream) SendHeader(metadata.MD) error { return nil }
+func (s *wsHelloStream) SetTrailer(md metadata.MD)    { s.trailer = metadata.Join(s.trailer, md) }
+
+func (s *wsHelloStream) RecvMsg(m interface{}) error {
+	req, ok := m.(*hello.HelloRequest)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	var name string
+	if err := websocket.Message.Receive(s.conn, &name); err != nil {
+		// Closing the socket ends the client's side of the stream, and
+		// with it the handler's replies, which could no longer be sent
+		s.cancel()
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		if errors.Is(err, websocket.ErrFrameTooLarge) {
+			return status.Errorf(codes.ResourceExhausted, "WebSocket messages must not exceed %d bytes", maxWebSocketMessageBytes)
+		}
+		return status.Errorf(codes.Unavailable, "reading WebSocket message: %v", err)
+	}
+	req.Name = name
+	return nil
+}
+
+func (s *wsHelloStream) SendMsg(m interface{}) error {
+	reply, ok := m.(*hello.HelloReply)
+	if !ok {
+		return status.Errorf(codes.Internal, "unexpected message type %T", m)
+	}
+	if err := s.ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return websocket.JSON.Send(s.conn, HelloResponse{Message: reply.GetMessage()})
+}
+
+// end sends the final wsEnd message for a stream that returned err.
+func (s *wsHelloStream) end(err error) {
+	msg := wsEnd{Trailers: map[string]string{}}
+	for key, values := range s.trailer {
+		if len(values) > 0 {
+			msg.Trailers[key] = values[0]
+		}
+	}
+	if err != nil {
+		resp := errorResponse(status.Convert(err))
+		msg.Error = &resp
+	}
+	websocket.JSON.Send(s.conn, msg)
+}
+
+// hijackableWriter hands websocket.Server, which asserts http.Hijacker, the
+// hijacking http.ResponseController finds through wrappers such as
+// prettyJSONWriter.
+type hijackableWriter struct {
+	http.ResponseWriter
+}
+
+func (w hijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// wsHandshake accepts a WebSocket upgrade only from the origins cors
+// allows, or from clients that send no Origin, which browsers always do. It
+// answers with wsSubprotocol when the client offered it, and never echoes
+// back a credential.
+func wsHandshake(cors CORSOptions) func(*websocket.Config, *http.Request) error {
+	return func(config *websocket.Config, r *http.Request) error {
+		if origin := r.Header.Get("Origin"); origin != "" && !cors.allowsOrigin(origin) {
+			return fmt.Errorf("origin %q is not allowed", origin)
+		}
+		offered := config.Protocol
+		config.Protocol = nil
+		for _, protocol := range offered {
+			if protocol == wsSubprotocol {
+				config.Protocol = []string{wsSubprotocol}
+			}
+		}
+		return nil
+	}
+}
+
+// wsIncomingMetadata returns the incoming metadata of a /ws/hello call: the
+// query parameters, e.g. ?transform=upper, then the headers
+// restIncomingMetadata passes on, and an API key sent as a wsBearerPrefix
+// subprotocol as "authorization: Bearer <key>". Credentials in the query
+// are dropped, since URLs end up in access logs and browser history.
+func wsIncomingMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range r.URL.Query() {
+		key = strings.ToLower(key)
+		if key == "authorization" || key == APIKeyMetadataKey {
+			continue
+		}
+		md.Append(key, values...)
+	}
+	for key, values := range restIncomingMetadata(r) {
+		md.Set(key, values...)
+	}
+	for _, protocol := range strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",") {
+		if key, ok := strings.CutPrefix(strings.TrimSpace(protocol), wsBearerPrefix); ok {
+			md.Append("authorization", "Bearer "+key)
+		}
+	}
+	return md
+}
+
+// handleSayHelloWebSocket serves GET /ws/hello, which upgrades to a WebSocket
+// bridged to SayHelloBidirectional through the stream interceptors in
+// registry: every message sent is a name, answered with a {"message": ...}
+// greeting, and once the stream ends a final {"trailers": ...} message, with
+// an "error" when it failed, precedes the server closing the socket. Closing
+// the socket from the browser ends the stream. Upgrades from origins cors
+// does not allow are refused; see wsIncomingMetadata for the metadata the
+// method sees.
+func (s *HelloServer) handleSayHelloWebSocket(registry *Registry, cors CORSOptions) http.HandlerFunc {
+	chain := registry.streamChain()
+	fullMethod := "/" + hello.Greeter_ServiceDesc.ServiceName + "/SayHelloBidirectional"
+	info := &grpc.StreamServerInfo{FullMethod: fullMethod, IsClientStream: true, IsServerStream: true}
+	var handler grpc.StreamHandler
+	for _, desc := range hello.Greeter_ServiceDesc.Streams {
+		if desc.StreamName == "SayHelloBidirectional" {
+			handler = desc.Handler
+		}
+	}
+
+	server := websocket.Server{Handshake: wsHandshake(cors), Handler: func(conn *websocket.Conn) {
+		r := conn.Request()
+		conn.MaxPayloadBytes = maxWebSocketMessageBytes
+		ctx, cancel := context.WithCancel(metadata.NewIncomingContext(r.Context(), wsIncomingMetadata(r)))
+		defer cancel()
+		stream := &wsHelloStream{ctx: ctx, cancel: cancel, conn: conn}
+
+		slog.InfoContext(ctx, "HTTP: Opened SayHelloBidirectional WebSocket", "remote_addr", r.RemoteAddr)
+		var err error
+		if chain != nil {
+			err = chain(s, stream, info, handler)
+		} else {
+			err = handler(s, stream)
+		}
+		stream.end(err)
+		conn.Close()
+		slog.InfoContext(ctx, "HTTP: Closed SayHelloBidirectional WebSocket", "code", status.Code(err).String())
+	}}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Only HTTP/1.1 connections can be taken over for a WebSocket
+		if r.ProtoMajor != 1 {
+			writeError(w, http.StatusHTTPVersionNotSupported, codes.Unimplemented, "WebSocket upgrades need HTTP/1.1")
+			return
+		}
+		// Like the SSE endpoint, the socket outlives the REST deadlines,
+		// which would otherwise stay set on the hijacked connection
+		rc := http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+		server.ServeHTTP(hijackableWriter{w}, r)
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+)
+
+// dialHello opens /ws/hello on srv from origin, offering protocols, with
+// header set on the upgrade request.
+func dialHello(srv *httptest.Server, query, origin string, protocols []string, header http.Header) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/hello"+query, origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = protocols
+	if header != nil {
+		config.Header = header
+	}
+	return websocket.DialConfig(config)
+}
+
+// greet sends name on conn and returns the first message the server sends
+// back: a greeting, or the final message of a failed stream.
+func greet(t *testing.T, conn *websocket.Conn, name string) map[string]interface{} {
+	t.Helper()
+	if err := websocket.Message.Send(conn, name); err != nil {
+		t.Fatalf("sending %q: %v", name, err)
+	}
+	var reply map[string]interface{}
+	if err := websocket.JSON.Receive(conn, &reply); err != nil {
+		t.Fatalf("receiving reply: %v", err)
+	}
+	return reply
+}
+
+func TestWebSocketChecksOrigin(t *testing.T) {
+	srv := httptest.NewServer(testRouter{cors: CORSOptions{AllowedOrigins: []string{"https://app.example"}}}.handler())
+	defer srv.Close()
+
+	if conn, err := dialHello(srv, "", "https://evil.example", nil, nil); err == nil {
+		conn.Close()
+		t.Error("upgrade from a disallowed origin succeeded, want it refused")
+	}
+
+	conn, err := dialHello(srv, "", "https://app.example", nil, nil)
+	if err != nil {
+		t.Fatalf("upgrade from an allowed origin: %v", err)
+	}
+	defer conn.Close()
+	if got := greet(t, conn, "World")["message"]; got != "Hello World! (Message 1 received)" {
+		t.Errorf("greeting = %v", got)
+	}
+}
+
+func TestWebSocketCredentials(t *testing.T) {
+	srv := httptest.NewServer(testRouter{interceptors: authRegistry(), cors: CORSOptions{AllowedOrigins: []string{"*"}}}.handler())
+	defer srv.Close()
+	const origin = "https://app.example"
+
+	// Keys in the query string are ignored
+	conn, err := dialHello(srv, "?x-api-key=secret&authorization=Bearer+secret", origin, nil, nil)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	reply := greet(t, conn, "World")
+	conn.Close()
+	if errResp, _ := reply["error"].(map[string]interface{}); errResp["code"] != "Unauthenticated" {
+		t.Errorf("stream with the key in the query = %v, want an Unauthenticated error", reply)
+	}
+
+	conn, err = dialHello(srv, "", origin, []string{wsSubprotocol, wsBearerPrefix + "secret"}, nil)
+	if err != nil {
+		t.Fatalf("upgrade with the key as a subprotocol: %v", err)
+	}
+	if got := conn.Config().Protocol; len(got) != 1 || got[0] != wsSubprotocol {
+		t.Errorf("negotiated subprotocols = %v, want only %s", got, wsSubprotocol)
+	}
+	if reply := greet(t, conn, "World"); reply["message"] == nil {
+		t.Errorf("stream with the key as a subprotocol = %v, want a greeting", reply)
+	}
+	conn.Close()
+
+	conn, err = dialHello(srv, "", origin, nil, http.Header{"Authorization": {"Bearer secret"}})
+	if err != nil {
+		t.Fatalf("upgrade with an Authorization header: %v", err)
+	}
+	if reply := greet(t, conn, "World"); reply["message"] == nil {
+		t.Errorf("stream with an Authorization header = %v, want a greeting", reply)
+	}
+	conn.Close()
+}
+
+func TestWebSocketBridgesBidirectionalStream(t *testing.T) {
+	// An interceptor reports when the gRPC handler behind the socket returns
+	handlerDone := make(chan struct{})
+	registry := NewRegistry()
+	registry.Register(Interceptor{Name: "done", Stage: StageMetrics, Stream: func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		defer close(handlerDone)
+		return handler(srv, ss)
+	}})
+	srv := httptest.NewServer(testRouter{hello: NewHelloServer(WithStreamDelays(0, 0)), interceptors: registry, cors: CORSOptions{AllowedOrigins: []string{"*"}}}.handler())
+	defer srv.Close()
+
+	conn, err := dialHello(srv, "", "https://app.example", nil, nil)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	for i, name := range []string{"Ann", "Bob"} {
+		want := fmt.Sprintf("Hello %s! (Message %d received)", name, i+1)
+		if got := greet(t, conn, name)["message"]; got != want {
+			t.Errorf("reply to %s = %v, want %q", name, got, want)
+		}
+	}
+
+	// Closing the socket ends the gRPC stream
+	conn.Close()
+	select {
+	case <-handlerDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("gRPC stream still running 5s after the socket closed")
+	}
+}
@@ -90,6 +90,14 @@ run_test "Hello Service - Unary RPC" \
 run_test "Hello Service - Server Streaming RPC" \
     "grpcurl -plaintext -d '{\"name\":\"grpcurl\"}' $SERVER grpc.hello.Greeter/SayHelloStream"
 
+# Test Hello Service - Server Streaming RPC with custom cadence
+run_test "Hello Service - Server Streaming RPC (3 messages, 10ms apart)" \
+    "grpcurl -plaintext -H 'stream-count: 3' -H 'stream-delay-ms: 10' -d '{\"name\":\"grpcurl\"}' $SERVER grpc.hello.Greeter/SayHelloStream"
+
+# Test Hello Service - Localized Unary RPC
+run_test "Hello Service - Localized Unary RPC" \
+    "grpcurl -plaintext -d '{\"name\":\"grpcurl\",\"language\":\"fr\"}' $SERVER grpc.hello.Greeter/SayHelloInLanguage"
+
 # Test Hello Service - Client Streaming RPC
 echo -e "${BLUE}--- Hello Service - Client Streaming RPC ---${NC}"
 echo -e "${YELLOW}Command: echo with multiple names | grpcurl client streaming${NC}"
@@ -118,12 +126,34 @@ echo -e "${GREEN}✓ Test completed${NC}"
 echo ""
 sleep 1
 
+# Test Hello Service - Aggregating Bidirectional Streaming RPC
+echo -e "${BLUE}--- Hello Service - Aggregating Bidirectional Streaming RPC ---${NC}"
+echo -e "${YELLOW}Command: echo with seven names | grpcurl -H 'flush-every: 3' aggregate streaming${NC}"
+echo ""
+
+echo '{"name":"Ann"}
+{"name":"Ben"}
+{"name":"Cal"}
+{"name":"Dot"}
+{"name":"Eli"}
+{"name":"Fay"}
+{"name":"Gus"}' | grpcurl -plaintext -H 'flush-every: 3' -d @ $SERVER grpc.hello.Greeter/SayHelloAggregate
+
+echo ""
+echo -e "${GREEN}✓ Test completed${NC}"
+echo ""
+sleep 1
+
 echo -e "${BLUE}=== Goodbye Service Tests ===${NC}"
 
 # Test Goodbye Service - Unary RPC
 run_test "Goodbye Service - Unary RPC" \
     "grpcurl -plaintext -d '{\"name\":\"grpcurl\"}' $SERVER grpc.goodbye.Farewell/SayGoodbye"
 
+# Test Goodbye Service - Unary RPC with a reason
+run_test "Goodbye Service - Unary RPC with reason" \
+    "grpcurl -plaintext -d '{\"name\":\"grpcurl\",\"reason\":\"testing is done\"}' $SERVER grpc.goodbye.Farewell/SayGoodbyeWithReason"
+
 # Test Goodbye Service - Server Streaming RPC
 run_test "Goodbye Service - Server Streaming RPC" \
     "grpcurl -plaintext -d '{\"name\":\"grpcurl\"}' $SERVER grpc.goodbye.Farewell/SayGoodbyeStream"
@@ -169,6 +199,10 @@ run_test "Test with custom metadata" \
 run_test "Test with verbose output (headers and trailers)" \
     "grpcurl -plaintext -v -d '{\"name\":\"grpcurl\"}' $SERVER grpc.hello.Greeter/SayHello"
 
+# Test request ID propagation (x-request-id is echoed in headers and trailers)
+run_test "Test with request ID (echoed in headers and trailers)" \
+    "grpcurl -plaintext -v -H 'x-request-id: grpcurl-request-123' -d '{\"name\":\"grpcurl\"}' $SERVER grpc.hello.Greeter/SayHello"
+
 echo -e "${BLUE}=== File-based Input Tests ===${NC}"
 
 # Create input files for testing
@@ -220,9 +254,9 @@ echo ""
 echo -e "${GREEN}=== All Tests Completed Successfully! ===${NC}"
 echo -e "${YELLOW}Summary:${NC}"
 echo -e "  • Service Discovery: ✓"
-echo -e "  • Hello Service (4 methods): ✓"
+echo -e "  • Hello Service (6 methods): ✓"
 echo -e "  • Goodbye Service (4 methods): ✓"
 echo -e "  • Advanced Features: ✓"
 echo -e "  • File-based Input: ✓"
 echo ""
-echo -e "${BLUE}Total: 8 RPC methods tested across 2 services${NC}"
+echo -e "${BLUE}Total: 10 RPC methods tested across 2 services${NC}"
@@ -0,0 +1,91 @@
+package testutil_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"grpc-sample/config"
+	"grpc-sample/proto/hello"
+	"grpc-sample/testutil"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestStartSayHello(t *testing.T) {
+	greeter, _, cleanup := testutil.Start(t, testutil.WithProductionInterceptors(config.Default()))
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var header metadata.MD
+	reply, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatalf("SayHello: %v", err)
+	}
+	if got, want := reply.GetMessage(), "Hello World"; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+	// The request-id interceptor echoes a generated ID
+	if ids := header.Get("x-request-id"); len(ids) != 1 || ids[0] == "" {
+		t.Errorf("x-request-id header = %v, want one generated ID", ids)
+	}
+
+	// The validation interceptor rejects an empty name
+	_, err = greeter.SayHello(ctx, &hello.HelloRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SayHello with empty name: code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestStartSayHelloStream(t *testing.T) {
+	greeter, _, cleanup := testutil.Start(t, testutil.WithProductionInterceptors(config.Default()))
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "stream-count", "3", "stream-delay-ms", "0")
+
+	stream, err := greeter.SayHelloStream(ctx, &hello.HelloRequest{Name: "World"})
+	if err != nil {
+		t.Fatalf("SayHelloStream: %v", err)
+	}
+	var messages []string
+	for {
+		reply, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		messages = append(messages, reply.GetMessage())
+	}
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages %q, want 3", len(messages), messages)
+	}
+}
+
+func TestStartUsesConfiguredInterceptors(t *testing.T) {
+	cfg := config.Default()
+	cfg.APIKey = "secret"
+	greeter, _, cleanup := testutil.Start(t, testutil.WithProductionInterceptors(cfg))
+	defer cleanup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("SayHello without a key: code = %v, want Unauthenticated", status.Code(err))
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", "secret")
+	if _, err := greeter.SayHello(ctx, &hello.HelloRequest{Name: "World"}); err != nil {
+		t.Fatalf("SayHello with the key: %v", err)
+	}
+}